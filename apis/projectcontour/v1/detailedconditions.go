@@ -144,6 +144,10 @@ const (
 	// ConditionTypeCORSError describes an error condition related to CORS.
 	ConditionTypeCORSError = "CORSError"
 
+	// ConditionTypeEnvoyError describes a condition where Envoy
+	// rejected the configuration generated from an HTTPProxy resource.
+	ConditionTypeEnvoyError = "EnvoyError"
+
 	// ConditionTypeIncludeError describes an error condition with
 	// inclusion of another HTTPProxy resource.
 	ConditionTypeIncludeError = "IncludeError"
@@ -209,6 +209,118 @@ type VirtualHost struct {
 	// The policy for rate limiting on the virtual host.
 	// +optional
 	RateLimitPolicy *RateLimitPolicy `json:"rateLimitPolicy,omitempty"`
+	// EnvoyGroup is the group of Envoys that serve this virtual host.
+	// Envoys join a group with `contour bootstrap --envoy-group`. If not
+	// set, the virtual host is served by all Envoys.
+	// +optional
+	EnvoyGroup string `json:"envoyGroup,omitempty"`
+	// Listener is the name of the Envoy listener, declared in the
+	// Contour configuration file, that serves this virtual host. If not
+	// set, the virtual host is served by the default HTTP and HTTPS
+	// listeners.
+	// +optional
+	Listener string `json:"listener,omitempty"`
+	// The policy for handling HTTP requests from clients to the
+	// virtual host. It overrides the defaults in the Contour
+	// configuration file.
+	// +optional
+	HTTPPolicy *HTTPPolicy `json:"httpPolicy,omitempty"`
+	// The policy for replacing the error responses that Envoy
+	// generates for the virtual host. Its pages are matched
+	// before the pages in the Contour configuration file.
+	// +optional
+	ErrorPagePolicy *ErrorPagePolicy `json:"errorPagePolicy,omitempty"`
+}
+
+// HTTPPolicy defines how Envoy handles HTTP requests from clients
+// for a virtual host.
+//
+// The timeouts and HTTP/1 settings apply to the client connection,
+// so they are only honored on virtual hosts with TLS, which Envoy
+// serves on their own connection. Durations are expressed in the
+// Go Duration format, and "infinity" disables the timeout.
+type HTTPPolicy struct {
+	// RequestTimeout is the timeout for the entire request, from
+	// the client, to the virtual host.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	RequestTimeout string `json:"requestTimeout,omitempty"`
+	// RequestHeadersTimeout is the time Envoy waits for the client
+	// to send all the request headers.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	RequestHeadersTimeout string `json:"requestHeadersTimeout,omitempty"`
+	// StreamIdleTimeout is how long a request, or stream, may have
+	// no activity before Envoy resets it.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	StreamIdleTimeout string `json:"streamIdleTimeout,omitempty"`
+	// MaxConnectionDuration is the maximum time that a client
+	// connection may stay open.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	MaxConnectionDuration string `json:"maxConnectionDuration,omitempty"`
+	// PerRequestBufferLimitBytes is the maximum number of bytes of
+	// a request that Envoy buffers, for example for retries or
+	// mirroring. Unlike the other fields, it applies to virtual
+	// hosts with or without TLS.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PerRequestBufferLimitBytes *uint32 `json:"perRequestBufferLimitBytes,omitempty"`
+	// HTTP1 configures the HTTP/1 protocol for clients.
+	// +optional
+	HTTP1 *HTTP1Policy `json:"http1,omitempty"`
+}
+
+// HTTP1Policy defines the HTTP/1 protocol settings for clients.
+type HTTP1Policy struct {
+	// AllowAbsoluteURLs allows clients to send requests with an
+	// absolute URL, such as forward proxy requests.
+	// +optional
+	AllowAbsoluteURLs bool `json:"allowAbsoluteURLs,omitempty"`
+	// ProperCaseHeaders writes the HTTP/1 response header names in
+	// proper case, such as "Content-Type", for clients that do not
+	// handle the lower case names Envoy uses by default.
+	// +optional
+	ProperCaseHeaders bool `json:"properCaseHeaders,omitempty"`
+}
+
+// ErrorPagePolicy defines the responses Envoy sends in place of
+// the error responses it generates itself, such as when no route
+// matches, no upstream is healthy or a request is rate limited.
+// Error responses from upstream services are not replaced.
+//
+// Envoy generates the error responses on the client connection, so
+// the policy is only honored on virtual hosts with TLS, which Envoy
+// serves on their own connection.
+type ErrorPagePolicy struct {
+	// Pages are the error pages, in the order they are matched.
+	// +kubebuilder:validation:MinItems=1
+	Pages []ErrorPage `json:"pages"`
+}
+
+// ErrorPage defines the response that replaces the error responses
+// with the given status codes.
+type ErrorPage struct {
+	// StatusCodes are the status codes of the error responses to
+	// replace. Codes must be in the 400-599 range (inclusive).
+	// +kubebuilder:validation:MinItems=1
+	StatusCodes []uint32 `json:"statusCodes"`
+	// ConfigMap is the name of the ConfigMap, in the namespace of
+	// the HTTPProxy, that holds the response body.
+	ConfigMap string `json:"configMap"`
+	// Key is the key of the response body in the ConfigMap.
+	Key string `json:"key"`
+	// ContentType is the content type of the response body. If
+	// not specified, "text/plain" is used.
+	// +optional
+	ContentType string `json:"contentType,omitempty"`
+	// ResponseStatusCode replaces the status code of the response.
+	// If not specified, the status code is not changed.
+	// +optional
+	// +kubebuilder:validation:Minimum=200
+	// +kubebuilder:validation:Maximum=599
+	ResponseStatusCode uint32 `json:"responseStatusCode,omitempty"`
 }
 
 // TLS describes tls properties. The SNI names that will be matched on
@@ -243,6 +355,28 @@ type TLS struct {
 	// EnableFallbackCertificate defines if the vhost should allow a default certificate to
 	// be applied which handles all requests which don't match the SNI defined in this vhost.
 	EnableFallbackCertificate bool `json:"enableFallbackCertificate,omitempty"`
+
+	// OCSPStaplePolicy defines how Envoy uses the OCSP response stapled
+	// to the certificate of this vhost. The OCSP response is either read
+	// from the `tls.ocsp-staple` key of the TLS secret, or fetched by
+	// Contour when OCSP stapling is enabled in the Contour configuration file.
+	//
+	// `LenientStapling` (default) staples the response when one is available
+	// and valid, and otherwise serves the certificate without a staple.
+	// `StrictStapling` staples the response when one is available, but
+	// refuses to serve the certificate once a stapled response has expired.
+	// `MustStaple` refuses to serve the certificate without a valid
+	// stapled response. Until a response is available, the vhost uses
+	// `LenientStapling` and a warning is added to the HTTPProxy status.
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=LenientStapling;StrictStapling;MustStaple
+	OCSPStaplePolicy string `json:"ocspStaplePolicy,omitempty"`
+
+	// DisableHTTP3 stops this vhost from being served over HTTP/3 when
+	// HTTP/3 is enabled in the Contour configuration file.
+	// +optional
+	DisableHTTP3 bool `json:"disableHTTP3,omitempty"`
 }
 
 // CORSHeaderValue specifies the value of the string headers returned by a cross-domain request.
@@ -328,6 +462,62 @@ type Route struct {
 	// The policy for rate limiting on the route.
 	// +optional
 	RateLimitPolicy *RateLimitPolicy `json:"rateLimitPolicy,omitempty"`
+	// The policy for buffering request bodies on the route.
+	// +optional
+	BufferPolicy *BufferPolicy `json:"bufferPolicy,omitempty"`
+	// The policy for following redirect responses from the
+	// upstream service within Envoy.
+	// +optional
+	InternalRedirectPolicy *InternalRedirectPolicy `json:"internalRedirectPolicy,omitempty"`
+}
+
+// BufferPolicy defines how request bodies are buffered before
+// the request is proxied to the upstream service.
+type BufferPolicy struct {
+	// MaxRequestBytes is the maximum size of a request body that
+	// is buffered. Requests with larger bodies are rejected with
+	// a 413 (Payload Too Large) response.
+	//
+	// If authorization is enabled on the virtual host, Envoy can
+	// only send request bodies to the authorization server for the
+	// whole virtual host. So every authorized route of the virtual
+	// host, including routes without a buffer policy, sends up to
+	// the largest MaxRequestBytes of its routes of each request
+	// body to the authorization server.
+	// +required
+	// +kubebuilder:validation:Minimum=1
+	MaxRequestBytes uint32 `json:"maxRequestBytes"`
+}
+
+// InternalRedirectPolicy defines when Envoy follows a redirect
+// response from the upstream service itself, instead of passing
+// it to the client. The redirect is followed by routing a new
+// request for its location through the routes of the same
+// listener, so the client only sees the final response.
+type InternalRedirectPolicy struct {
+	// MaxInternalRedirects is the maximum number of redirects
+	// Envoy follows for a request. If not specified, one
+	// redirect is followed.
+	// +optional
+	MaxInternalRedirects uint32 `json:"maxInternalRedirects,omitempty"`
+	// RedirectResponseCodes are the status codes of the redirect
+	// responses to follow. Valid codes are 301, 302, 303, 307
+	// and 308. If not specified, only 302 responses are followed.
+	// +optional
+	RedirectResponseCodes []uint32 `json:"redirectResponseCodes,omitempty"`
+	// AllowCrossSchemeRedirect allows following redirects to a
+	// location with a different scheme than the request, such
+	// as from "https" to "http".
+	// +optional
+	AllowCrossSchemeRedirect bool `json:"allowCrossSchemeRedirect,omitempty"`
+	// AllowedHosts limits the redirects that are followed to
+	// locations on the given virtual hosts. If not specified,
+	// redirects to any virtual host served by the listener
+	// are followed. Hosts must be fully qualified domain names.
+	// Redirects on a TLS virtual host are only followed to the
+	// same virtual host.
+	// +optional
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
 }
 
 // RateLimitPolicy defines rate limiting parameters.
@@ -436,15 +626,28 @@ type Service struct {
 	// +optional
 	UpstreamValidation *UpstreamValidation `json:"validation,omitempty"`
 	// If Mirror is true the Service will receive a read only mirror of the traffic for this route.
+	// A route may have more than one mirror Service.
 	Mirror bool `json:"mirror,omitempty"`
+	// MirrorPercentage is the percentage of requests that are mirrored
+	// to this Service, with up to four decimal places. It is only valid
+	// when Mirror is true. If omitted, every request is mirrored.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(100(\.0{1,4})?|[0-9]{1,2}(\.[0-9]{1,4})?)$`
+	MirrorPercentage string `json:"mirrorPercentage,omitempty"`
 	// The policy for managing request headers during proxying.
-	// Rewriting the 'Host' header is not supported.
+	// Rewriting the 'Host' header is not supported. Mirror
+	// Services do not support header policies.
 	// +optional
 	RequestHeadersPolicy *HeadersPolicy `json:"requestHeadersPolicy,omitempty"`
 	// The policy for managing response headers during proxying.
-	// Rewriting the 'Host' header is not supported.
+	// Rewriting the 'Host' header is not supported. Mirror
+	// Services do not support header policies.
 	// +optional
 	ResponseHeadersPolicy *HeadersPolicy `json:"responseHeadersPolicy,omitempty"`
+	// The policy for managing the HTTP connections that Envoy makes
+	// to this Service.
+	// +optional
+	ConnectionPolicy *ConnectionPolicy `json:"connectionPolicy,omitempty"`
 }
 
 // HTTPHealthCheckPolicy defines health checks on the upstream service.
@@ -509,6 +712,49 @@ type TimeoutPolicy struct {
 	// +optional
 	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
 	Idle string `json:"idle,omitempty"`
+
+	// MaxStreamDuration is the maximum time that a request on this
+	// route, including its response, may last before Envoy resets it.
+	// If not specified, there is no limit.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	MaxStreamDuration string `json:"maxStreamDuration,omitempty"`
+}
+
+// ConnectionPolicy defines how Envoy manages the HTTP connections
+// it opens to an upstream service. Fields that are not set fall back
+// to the values in the Contour configuration file, and then to the
+// Envoy defaults.
+type ConnectionPolicy struct {
+	// IdleTimeout is how long an upstream connection may be idle, with no
+	// active requests, before Envoy closes it. Set this lower than the
+	// keepalive timeout of the service so that Envoy does not reuse a
+	// connection that the service is about to close.
+	// Set to "infinity" to disable the timeout.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+
+	// MaxRequestsPerConnection is the maximum number of requests that
+	// Envoy sends on a single upstream connection before closing it.
+	// If not supplied, there is no limit.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxRequestsPerConnection *uint32 `json:"maxRequestsPerConnection,omitempty"`
+
+	// MaxStreamDuration is the maximum time that a request, or stream,
+	// to the upstream service may last before Envoy resets it.
+	// Set to "infinity" to disable the limit.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	MaxStreamDuration string `json:"maxStreamDuration,omitempty"`
+
+	// PerConnectionBufferLimitBytes is the soft limit on the size of
+	// the read and write buffers of each upstream connection.
+	// If not supplied, Envoy's default of 1MiB applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PerConnectionBufferLimitBytes *uint32 `json:"perConnectionBufferLimitBytes,omitempty"`
 }
 
 // RetryOn is a string type alias with validation to ensure that the value is valid.
@@ -605,6 +851,28 @@ type HeaderHashOptions struct {
 	HeaderName string `json:"headerName,omitempty"`
 }
 
+// CookieHashOptions contains options to configure a HTTP cookie hash
+// policy, used in request attribute hash based load balancing.
+type CookieHashOptions struct {
+	// CookieName is the name of the HTTP cookie that will be used to
+	// calculate the hash key.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	CookieName string `json:"cookieName,omitempty"`
+
+	// TTL is the lifetime of the cookie that Envoy generates when a
+	// request does not carry the cookie. A TTL of "0s" generates a
+	// session cookie. If omitted, no cookie is generated, and requests
+	// without the cookie do not produce a hash.
+	// +optional
+	TTL string `json:"ttl,omitempty"`
+
+	// Path is the path attribute of the generated cookie. If omitted,
+	// the generated cookie has no path attribute.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
 // RequestHashPolicy contains configuration for an individual hash policy
 // on a request attribute.
 type RequestHashPolicy struct {
@@ -617,8 +885,20 @@ type RequestHashPolicy struct {
 	// HeaderHashOptions should be set when request header hash based load
 	// balancing is desired. It must be the only hash option field set,
 	// otherwise this request hash policy object will be ignored.
-	// +kubebuilder:validation:Required
+	// +optional
 	HeaderHashOptions *HeaderHashOptions `json:"headerHashOptions,omitempty"`
+
+	// CookieHashOptions should be set when request cookie hash based load
+	// balancing is desired. It must be the only hash option field set,
+	// otherwise this request hash policy object will be ignored.
+	// +optional
+	CookieHashOptions *CookieHashOptions `json:"cookieHashOptions,omitempty"`
+
+	// HashSourceAddress should be set to true when request source IP hash
+	// based load balancing is desired. It must be the only hash option
+	// field set, otherwise this request hash policy object will be ignored.
+	// +optional
+	HashSourceAddress bool `json:"hashSourceAddress,omitempty"`
 }
 
 // LoadBalancerPolicy defines the load balancing policy.
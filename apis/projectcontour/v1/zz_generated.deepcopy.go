@@ -67,6 +67,21 @@ func (in *AuthorizationServer) DeepCopy() *AuthorizationServer {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BufferPolicy) DeepCopyInto(out *BufferPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BufferPolicy.
+func (in *BufferPolicy) DeepCopy() *BufferPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BufferPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CORSPolicy) DeepCopyInto(out *CORSPolicy) {
 	*out = *in
@@ -122,6 +137,46 @@ func (in *CertificateDelegation) DeepCopy() *CertificateDelegation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionPolicy) DeepCopyInto(out *ConnectionPolicy) {
+	*out = *in
+	if in.MaxRequestsPerConnection != nil {
+		in, out := &in.MaxRequestsPerConnection, &out.MaxRequestsPerConnection
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.PerConnectionBufferLimitBytes != nil {
+		in, out := &in.PerConnectionBufferLimitBytes, &out.PerConnectionBufferLimitBytes
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionPolicy.
+func (in *ConnectionPolicy) DeepCopy() *ConnectionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CookieHashOptions) DeepCopyInto(out *CookieHashOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CookieHashOptions.
+func (in *CookieHashOptions) DeepCopy() *CookieHashOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(CookieHashOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DetailedCondition) DeepCopyInto(out *DetailedCondition) {
 	*out = *in
@@ -163,6 +218,48 @@ func (in *DownstreamValidation) DeepCopy() *DownstreamValidation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ErrorPage) DeepCopyInto(out *ErrorPage) {
+	*out = *in
+	if in.StatusCodes != nil {
+		in, out := &in.StatusCodes, &out.StatusCodes
+		*out = make([]uint32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ErrorPage.
+func (in *ErrorPage) DeepCopy() *ErrorPage {
+	if in == nil {
+		return nil
+	}
+	out := new(ErrorPage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ErrorPagePolicy) DeepCopyInto(out *ErrorPagePolicy) {
+	*out = *in
+	if in.Pages != nil {
+		in, out := &in.Pages, &out.Pages
+		*out = make([]ErrorPage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ErrorPagePolicy.
+func (in *ErrorPagePolicy) DeepCopy() *ErrorPagePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ErrorPagePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExtensionServiceReference) DeepCopyInto(out *ExtensionServiceReference) {
 	*out = *in
@@ -178,6 +275,21 @@ func (in *ExtensionServiceReference) DeepCopy() *ExtensionServiceReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTP1Policy) DeepCopyInto(out *HTTP1Policy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTP1Policy.
+func (in *HTTP1Policy) DeepCopy() *HTTP1Policy {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTP1Policy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HTTPHealthCheckPolicy) DeepCopyInto(out *HTTPHealthCheckPolicy) {
 	*out = *in
@@ -193,6 +305,31 @@ func (in *HTTPHealthCheckPolicy) DeepCopy() *HTTPHealthCheckPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPPolicy) DeepCopyInto(out *HTTPPolicy) {
+	*out = *in
+	if in.PerRequestBufferLimitBytes != nil {
+		in, out := &in.PerRequestBufferLimitBytes, &out.PerRequestBufferLimitBytes
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.HTTP1 != nil {
+		in, out := &in.HTTP1, &out.HTTP1
+		*out = new(HTTP1Policy)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPPolicy.
+func (in *HTTPPolicy) DeepCopy() *HTTPPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HTTPProxy) DeepCopyInto(out *HTTPProxy) {
 	*out = *in
@@ -406,6 +543,31 @@ func (in *Include) DeepCopy() *Include {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InternalRedirectPolicy) DeepCopyInto(out *InternalRedirectPolicy) {
+	*out = *in
+	if in.RedirectResponseCodes != nil {
+		in, out := &in.RedirectResponseCodes, &out.RedirectResponseCodes
+		*out = make([]uint32, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedHosts != nil {
+		in, out := &in.AllowedHosts, &out.AllowedHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InternalRedirectPolicy.
+func (in *InternalRedirectPolicy) DeepCopy() *InternalRedirectPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(InternalRedirectPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LoadBalancerPolicy) DeepCopyInto(out *LoadBalancerPolicy) {
 	*out = *in
@@ -531,6 +693,11 @@ func (in *RequestHashPolicy) DeepCopyInto(out *RequestHashPolicy) {
 		*out = new(HeaderHashOptions)
 		**out = **in
 	}
+	if in.CookieHashOptions != nil {
+		in, out := &in.CookieHashOptions, &out.CookieHashOptions
+		*out = new(CookieHashOptions)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestHashPolicy.
@@ -630,6 +797,16 @@ func (in *Route) DeepCopyInto(out *Route) {
 		*out = new(RateLimitPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.BufferPolicy != nil {
+		in, out := &in.BufferPolicy, &out.BufferPolicy
+		*out = new(BufferPolicy)
+		**out = **in
+	}
+	if in.InternalRedirectPolicy != nil {
+		in, out := &in.InternalRedirectPolicy, &out.InternalRedirectPolicy
+		*out = new(InternalRedirectPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
@@ -665,6 +842,11 @@ func (in *Service) DeepCopyInto(out *Service) {
 		*out = new(HeadersPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ConnectionPolicy != nil {
+		in, out := &in.ConnectionPolicy, &out.ConnectionPolicy
+		*out = new(ConnectionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Service.
@@ -940,6 +1122,16 @@ func (in *VirtualHost) DeepCopyInto(out *VirtualHost) {
 		*out = new(RateLimitPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HTTPPolicy != nil {
+		in, out := &in.HTTPPolicy, &out.HTTPPolicy
+		*out = new(HTTPPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ErrorPagePolicy != nil {
+		in, out := &in.ErrorPagePolicy, &out.ErrorPagePolicy
+		*out = new(ErrorPagePolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualHost.
@@ -95,6 +95,12 @@ type ExtensionServiceSpec struct {
 	// +optional
 	TimeoutPolicy *contour_api_v1.TimeoutPolicy `json:"timeoutPolicy,omitempty"`
 
+	// The policy for managing the HTTP connections that Envoy makes
+	// to the services.
+	//
+	// +optional
+	ConnectionPolicy *contour_api_v1.ConnectionPolicy `json:"connectionPolicy,omitempty"`
+
 	// This field sets the version of the GRPC protocol that Envoy uses to
 	// send requests to the extension service. Since Contour always uses the
 	// v3 Envoy API, this is currently fixed at "v3". However, other
@@ -112,6 +112,11 @@ func (in *ExtensionServiceSpec) DeepCopyInto(out *ExtensionServiceSpec) {
 		*out = new(v1.TimeoutPolicy)
 		**out = **in
 	}
+	if in.ConnectionPolicy != nil {
+		in, out := &in.ConnectionPolicy, &out.ConnectionPolicy
+		*out = new(v1.ConnectionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExtensionServiceSpec.
@@ -14,6 +14,11 @@
 package main
 
 import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
 	"github.com/projectcontour/contour/internal/envoy"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
@@ -30,10 +35,73 @@ func registerBootstrap(app *kingpin.Application) (*kingpin.CmdClause, *envoy.Boo
 	bootstrap.Flag("admin-port", "Envoy admin interface port.").IntVar(&config.AdminPort)
 	bootstrap.Flag("xds-address", "xDS gRPC API address.").StringVar(&config.XDSAddress)
 	bootstrap.Flag("xds-port", "xDS gRPC API port.").IntVar(&config.XDSGRPCPort)
+	bootstrap.Flag("xds-server", "xDS gRPC API server, as address:port or address:port/priority. May be repeated.").SetValue((*xdsServers)(&config.XDSServers))
+	bootstrap.Flag("xds-keepalive-interval", "Interval of HTTP/2 PING frames on xDS connections.").DurationVar(&config.XDSKeepaliveInterval)
+	bootstrap.Flag("xds-keepalive-timeout", "Timeout of HTTP/2 PING frames on xDS connections.").DurationVar(&config.XDSKeepaliveTimeout)
 	bootstrap.Flag("envoy-cafile", "CA Filename for Envoy secure xDS gRPC communication.").Envar("ENVOY_CAFILE").StringVar(&config.GrpcCABundle)
 	bootstrap.Flag("envoy-cert-file", "Client certificate filename for Envoy secure xDS gRPC communication.").Envar("ENVOY_CERT_FILE").StringVar(&config.GrpcClientCert)
 	bootstrap.Flag("envoy-key-file", "Client key filename for Envoy secure xDS gRPC communication.").Envar("ENVOY_KEY_FILE").StringVar(&config.GrpcClientKey)
 	bootstrap.Flag("namespace", "The namespace the Envoy container will run in.").Envar("CONTOUR_NAMESPACE").Default("projectcontour").StringVar(&config.Namespace)
+	bootstrap.Flag("ads", "Fetch xDS resources on a single aggregated discovery service (ADS) stream.").BoolVar(&config.ADS)
+	bootstrap.Flag("delta", "Fetch clusters and endpoints with the incremental (delta) xDS protocol.").BoolVar(&config.Delta)
+	bootstrap.Flag("envoy-group", "Group of Envoys this Envoy belongs to. Envoys in a group only serve the virtual hosts served by their group.").StringVar(&config.EnvoyGroup)
+	bootstrap.Flag("node-metadata", "Metadata Envoy sends to Contour, as key=value. May be repeated.").Envar("ENVOY_NODE_METADATA").StringMapVar(&config.NodeMetadata)
+	bootstrap.Flag("node-region", "Region of the Envoy locality.").Envar("ENVOY_NODE_REGION").StringVar(&config.NodeRegion)
+	bootstrap.Flag("node-zone", "Zone of the Envoy locality.").Envar("ENVOY_NODE_ZONE").StringVar(&config.NodeZone)
+	bootstrap.Flag("node-sub-zone", "Sub-zone of the Envoy locality.").Envar("ENVOY_NODE_SUB_ZONE").StringVar(&config.NodeSubZone)
+	bootstrap.Flag("overload-max-heap-size-bytes", "Heap size at which Envoy starts shedding load. Zero disables the overload manager.").Uint64Var(&config.MaxHeapSizeBytes)
+	bootstrap.Flag("max-downstream-connections", "Maximum number of downstream connections across all Envoy listeners. Zero is unlimited.").Uint64Var(&config.MaxDownstreamConnections)
+	bootstrap.Flag("runtime", "Envoy runtime value, as key=value. May be repeated.").StringMapVar(&config.RuntimeValues)
 	bootstrap.Flag("xds-resource-version", "The versions of the xDS resources to request from Contour.").Default("v3").StringVar((*string)(&config.XDSResourceVersion))
 	return bootstrap, &config
 }
+
+// xdsServers is a kingpin.Value that parses repeated --xds-server flags.
+type xdsServers []envoy.XDSServer
+
+func (x *xdsServers) Set(value string) error {
+	s, err := parseXDSServer(value)
+	if err != nil {
+		return err
+	}
+	*x = append(*x, s)
+	return nil
+}
+
+func (x *xdsServers) String() string {
+	var s []string
+	for _, server := range *x {
+		s = append(s, fmt.Sprintf("%s/%d", net.JoinHostPort(server.Address, strconv.Itoa(server.Port)), server.Priority))
+	}
+	return strings.Join(s, ",")
+}
+
+func (x *xdsServers) IsCumulative() bool { return true }
+
+// parseXDSServer parses an xDS server given as address:port, optionally
+// followed by /priority.
+func parseXDSServer(value string) (envoy.XDSServer, error) {
+	var server envoy.XDSServer
+
+	hostport := value
+	if i := strings.LastIndexByte(value, '/'); i >= 0 {
+		hostport = value[:i]
+		priority, err := strconv.ParseUint(value[i+1:], 10, 32)
+		if err != nil {
+			return server, fmt.Errorf("invalid xDS server %q: invalid priority: %v", value, err)
+		}
+		server.Priority = uint32(priority)
+	}
+
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return server, fmt.Errorf("invalid xDS server %q: %v", value, err)
+	}
+	server.Address = host
+	server.Port, err = strconv.Atoi(port)
+	if err != nil || server.Port < 1 || server.Port > 65535 {
+		return server, fmt.Errorf("invalid xDS server %q: invalid port %q", value, port)
+	}
+
+	return server, nil
+}
@@ -0,0 +1,64 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseXDSServer(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    envoy.XDSServer
+		wantErr bool
+	}{
+		"address and port": {
+			value: "contour.projectcontour:8001",
+			want:  envoy.XDSServer{Address: "contour.projectcontour", Port: 8001},
+		},
+		"priority": {
+			value: "contour.dr.example.com:8001/1",
+			want:  envoy.XDSServer{Address: "contour.dr.example.com", Port: 8001, Priority: 1},
+		},
+		"ipv6": {
+			value: "[fd00::1]:8001/2",
+			want:  envoy.XDSServer{Address: "fd00::1", Port: 8001, Priority: 2},
+		},
+		"missing port": {
+			value:   "contour.projectcontour",
+			wantErr: true,
+		},
+		"invalid port": {
+			value:   "contour.projectcontour:http",
+			wantErr: true,
+		},
+		"invalid priority": {
+			value:   "contour.projectcontour:8001/-1",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseXDSServer(tc.value)
+			assert.Equal(t, tc.wantErr, err != nil)
+			if !tc.wantErr {
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
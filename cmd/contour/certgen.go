@@ -18,6 +18,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/projectcontour/contour/internal/certgen"
 	"github.com/projectcontour/contour/internal/k8s"
@@ -44,12 +45,21 @@ func registerCertGen(app *kingpin.Application) (*kingpin.CmdClause, *certgenConf
 	certgenApp.Flag("certificate-lifetime", "Generated certificate lifetime (in days).").Default(strconv.Itoa(certs.DefaultCertificateLifetime)).UintVar(&certgenConfig.Lifetime)
 	certgenApp.Flag("overwrite", "Overwrite existing files or Secrets.").BoolVar(&certgenConfig.Overwrite)
 	certgenApp.Flag("secrets-format", "Specify how to format the generated Kubernetes Secrets.").Default("legacy").StringVar(&certgenConfig.Format)
+	certgenApp.Flag("rotate", "Renew the existing certs if they are due for renewal, keeping the CA if its private key is available (see --keep-ca-key). Implies --overwrite.").BoolVar(&certgenConfig.Rotate)
+	certgenApp.Flag("renew-before", "Renew certs that expire within this many days (with --rotate).").Default(strconv.Itoa(defaultRenewBefore)).UintVar(&certgenConfig.RenewBefore)
+	certgenApp.Flag("rotate-ca", "Generate a new CA when rotating. Previous CAs stay in the CA bundle until they expire (with --rotate).").BoolVar(&certgenConfig.RotateCA)
+	certgenApp.Flag("keep-ca-key", "Store the CA private key in the cakey Secret or cakey.pem file, so that later rotations can keep the CA (with --rotate).").BoolVar(&certgenConfig.KeepCAKey)
+	certgenApp.Flag("ca-lifetime", "Lifetime (in days) of a CA generated when rotating.").Default(strconv.Itoa(certs.DefaultCALifetime)).UintVar(&certgenConfig.CALifetime)
 
 	certgenApp.Arg("outputdir", "Directory to write output files into (default \"certs\").").Default("certs").StringVar(&certgenConfig.OutputDir)
 
 	return certgenApp, &certgenConfig
 }
 
+// defaultRenewBefore is the default number of days before expiry
+// at which certgen --rotate renews certificates.
+const defaultRenewBefore = 30
+
 // certgenConfig holds the configuration for the certificate generation process.
 type certgenConfig struct {
 
@@ -82,6 +92,22 @@ type certgenConfig struct {
 
 	// Format specifies how to format the Kubernetes Secrets (must be "legacy" or "compat").
 	Format string
+
+	// Rotate renews existing certificates rather than generating new ones.
+	Rotate bool
+
+	// RenewBefore is the number of days before expiry at which certificates are renewed.
+	RenewBefore uint
+
+	// RotateCA generates a new CA when rotating certificates.
+	RotateCA bool
+
+	// CALifetime is the number of days for which a CA generated when rotating will be valid.
+	CALifetime uint
+
+	// KeepCAKey stores the CA private key alongside the certificates
+	// when rotating, so that later rotations can keep the CA.
+	KeepCAKey bool
 }
 
 // OutputCerts outputs the certs in certs as directed by config.
@@ -127,22 +153,94 @@ func OutputCerts(config *certgenConfig, kubeclient *kubernetes.Clientset, certs
 }
 
 func doCertgen(config *certgenConfig, log logrus.FieldLogger) {
-	generatedCerts, err := certs.GenerateCerts(
-		&certs.Configuration{
-			Lifetime:  config.Lifetime,
-			Namespace: config.Namespace,
-		})
-	if err != nil {
-		log.WithError(err).Fatal("failed to generate certificates")
-	}
-
 	clients, err := k8s.NewClients(config.KubeConfig, config.InCluster)
 	if err != nil {
 		log.WithError(err).Fatalf("failed to create Kubernetes client")
 	}
 
+	var generatedCerts *certs.Certificates
+	if config.Rotate {
+		generatedCerts = rotateCerts(config, clients.ClientSet(), log)
+		if generatedCerts == nil {
+			return
+		}
+	} else {
+		generatedCerts, err = certs.GenerateCerts(
+			&certs.Configuration{
+				Lifetime:  config.Lifetime,
+				Namespace: config.Namespace,
+			})
+		if err != nil {
+			log.WithError(err).Fatal("failed to generate certificates")
+		}
+	}
+
 	if oerr := OutputCerts(config, clients.ClientSet(), generatedCerts); oerr != nil {
 		log.WithError(oerr).Fatalf("failed output certificates")
 	}
 
 }
+
+// rotateCerts renews the certs previously output as directed by
+// config. If they are not due for renewal, nil is returned.
+func rotateCerts(config *certgenConfig, kubeclient *kubernetes.Clientset, log logrus.FieldLogger) *certs.Certificates {
+	current, err := loadCerts(config, kubeclient)
+	if err != nil {
+		log.WithError(err).Fatal("failed to load current certificates")
+	}
+
+	// The CA private key is only used, and stored, when asked
+	// for, since anyone who can read it can issue certificates
+	// that Contour and Envoy trust.
+	if current != nil && !config.KeepCAKey {
+		current.CAPrivateKey = nil
+	}
+
+	renewBefore := 24 * time.Duration(config.RenewBefore) * time.Hour
+	if !config.RotateCA && !certs.NeedsRenewal(current, renewBefore, time.Now()) {
+		log.Infof("certificates do not expire within %d days, not renewing", config.RenewBefore)
+		return nil
+	}
+
+	rotated, err := certs.RotateCerts(
+		&certs.Configuration{
+			Lifetime:   config.Lifetime,
+			Namespace:  config.Namespace,
+			CALifetime: config.CALifetime,
+			RollCA:     config.RotateCA,
+		}, current)
+	if err != nil {
+		log.WithError(err).Fatal("failed to rotate certificates")
+	}
+
+	if !config.KeepCAKey {
+		rotated.CAPrivateKey = nil
+	}
+
+	// Rotated certificates always replace the current ones.
+	config.Overwrite = true
+	return rotated
+}
+
+// loadCerts loads the certs previously output as directed by config.
+// If there are no certs, nil is returned.
+func loadCerts(config *certgenConfig, kubeclient *kubernetes.Clientset) (*certs.Certificates, error) {
+	switch {
+	case config.OutputKube:
+		secrets, err := certgen.ReadSecretsKube(kubeclient, config.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		return certgen.CertificatesFromSecrets(secrets), nil
+	case config.OutputPEM:
+		return certgen.ReadCertsPEM(config.OutputDir)
+	case config.OutputYAML:
+		secrets, err := certgen.ReadSecretsYAML(config.OutputDir)
+		if err != nil {
+			return nil, err
+		}
+		return certgen.CertificatesFromSecrets(secrets), nil
+	default:
+		return nil, nil
+	}
+}
@@ -25,8 +25,10 @@ import (
 
 	"github.com/projectcontour/contour/internal/certgen"
 	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/pkg/certs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -176,3 +178,69 @@ func TestOutputFileMode(t *testing.T) {
 		})
 	}
 }
+
+func TestRotateCertsPEM(t *testing.T) {
+	outputDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(outputDir)
+
+	conf := &certgenConfig{
+		OutputPEM: true,
+		OutputDir: outputDir,
+		Lifetime:  30,
+	}
+
+	generatedCerts, err := certs.GenerateCerts(&certs.Configuration{Lifetime: conf.Lifetime})
+	require.NoError(t, err)
+	require.NoError(t, OutputCerts(conf, nil, generatedCerts))
+
+	log := fixture.NewTestLogger(t)
+
+	// The certificates are not due for renewal yet.
+	conf.Rotate = true
+	conf.RenewBefore = 7
+	assert.Nil(t, rotateCerts(conf, nil, log))
+	assert.False(t, conf.Overwrite)
+
+	conf.RenewBefore = 60
+	conf.KeepCAKey = true
+	rotated := rotateCerts(conf, nil, log)
+	require.NotNil(t, rotated)
+	require.NoError(t, OutputCerts(conf, nil, rotated))
+
+	// The CA key is kept, and the previous CA is still trusted.
+	current, err := certgen.ReadCertsPEM(outputDir)
+	require.NoError(t, err)
+	assert.Equal(t, rotated, current)
+	assert.NotEmpty(t, current.CAPrivateKey)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(current.CACertificate))
+	for _, cert := range [][]byte{generatedCerts.ContourCertificate, current.ContourCertificate} {
+		block, _ := pem.Decode(cert)
+		c, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		_, err = c.Verify(x509.VerifyOptions{DNSName: "contour", Roots: roots})
+		assert.NoError(t, err)
+	}
+
+	// Unless asked for, the CA key is neither used nor stored.
+	conf.KeepCAKey = false
+	rotated = rotateCerts(conf, nil, log)
+	require.NotNil(t, rotated)
+	assert.Empty(t, rotated.CAPrivateKey)
+	assert.NotEqual(t, current.CACertificate, rotated.CACertificate)
+}
+
+func TestCertificatesFromSecrets(t *testing.T) {
+	config := &certs.Configuration{Namespace: t.Name()}
+
+	generatedCerts, err := certs.RotateCerts(config, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, generatedCerts, certgen.CertificatesFromSecrets(certgen.AsSecrets(t.Name(), generatedCerts)))
+	assert.Equal(t, generatedCerts, certgen.CertificatesFromSecrets(certgen.AsLegacySecrets(t.Name(), generatedCerts)))
+
+	// An incomplete set of Secrets holds no certificates.
+	assert.Nil(t, certgen.CertificatesFromSecrets(certgen.AsSecrets(t.Name(), generatedCerts)[:1]))
+}
@@ -58,6 +58,10 @@ func main() {
 	rds.Arg("resources", "RDS resource filter").StringsVar(&resources)
 	sds := cli.Command("sds", "Watch secrets.")
 	sds.Arg("resources", "SDS resource filter").StringsVar(&resources)
+	nodes, nodesCtx := registerNodes(cli)
+	dump, dumpCtx := registerDump(cli)
+	diff, diffCtx := registerDiff(cli)
+	explain, explainCtx := registerExplain(cli)
 
 	serve, serveCtx := registerServe(app)
 	version := app.Command("version", "Build information for Contour.")
@@ -91,8 +95,28 @@ func main() {
 		stream := client.RouteStream()
 		watchstream(stream, resource_v3.RouteType, resources)
 	case sds.FullCommand():
-		stream := client.RouteStream()
+		stream := client.SecretStream()
 		watchstream(stream, resource_v3.SecretType, resources)
+	case nodes.FullCommand():
+		if err := doNodes(nodesCtx, os.Stdout); err != nil {
+			log.WithError(err).Fatal("failed to list Envoy nodes")
+		}
+	case dump.FullCommand():
+		if err := doDump(&client, dumpCtx, os.Stdout); err != nil {
+			log.WithError(err).Fatal("failed to dump xDS resources")
+		}
+	case diff.FullCommand():
+		changed, err := doDiff(&client, diffCtx, os.Stdout)
+		if err != nil {
+			log.WithError(err).Fatal("failed to compare xDS resources")
+		}
+		if changed {
+			os.Exit(1)
+		}
+	case explain.FullCommand():
+		if err := doExplain(explainCtx, os.Stdout); err != nil {
+			log.WithError(err).Fatal("failed to explain request")
+		}
 	case serve.FullCommand():
 		// Parse args a second time so cli flags are applied
 		// on top of any values sourced from -c's config file.
@@ -0,0 +1,392 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource_v3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/google/go-cmp/cmp"
+	"github.com/projectcontour/contour/internal/debug"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"sigs.k8s.io/yaml"
+)
+
+// dumpTypes maps the short name of each xDS type to its type URL.
+var dumpTypes = map[string]string{
+	"cds": resource_v3.ClusterType,
+	"eds": resource_v3.EndpointType,
+	"lds": resource_v3.ListenerType,
+	"rds": resource_v3.RouteType,
+	"sds": resource_v3.SecretType,
+}
+
+// snapshot is the contents of an xDS type at a point in time, as
+// written by contour cli dump.
+type snapshot struct {
+	TypeURL   string            `json:"typeURL"`
+	Version   string            `json:"version"`
+	Resources []json.RawMessage `json:"resources"`
+}
+
+type dumpContext struct {
+	// typ is the short name of the xDS type to dump.
+	typ string
+
+	// output is the output format, "yaml" or "json".
+	output string
+
+	// fqdn and cluster select the resources to dump.
+	fqdn    string
+	cluster string
+
+	// resources are the names of the resources to request.
+	resources []string
+}
+
+// registerDump registers the dump subcommand and flags
+// with the Application provided.
+func registerDump(cmd *kingpin.CmdClause) (*kingpin.CmdClause, *dumpContext) {
+	var ctx dumpContext
+
+	dump := cmd.Command("dump", "Write a snapshot of an xDS type.")
+	dump.Flag("type", "xDS type to dump.").Required().EnumVar(&ctx.typ, "cds", "eds", "lds", "rds", "sds")
+	dump.Flag("output", "Output format.").Short('o').Default("yaml").EnumVar(&ctx.output, "yaml", "json")
+	dump.Flag("fqdn", "Only dump the resources for this virtual host.").StringVar(&ctx.fqdn)
+	dump.Flag("cluster", "Only dump the resources for this cluster.").StringVar(&ctx.cluster)
+	dump.Arg("resources", "Names of the resources to request.").StringsVar(&ctx.resources)
+
+	return dump, &ctx
+}
+
+// doDump fetches a snapshot of the xDS type from Contour and writes it
+// to w.
+func doDump(client *Client, ctx *dumpContext, w io.Writer) error {
+	s, err := fetchSnapshot(client, dumpTypes[ctx.typ], ctx.resources, debug.XDSFilter{
+		FQDN:    ctx.fqdn,
+		Cluster: ctx.cluster,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeSnapshot(w, s, ctx.output)
+}
+
+type diffContext struct {
+	// old and new are the snapshot files to compare. If new is
+	// empty, old is compared with the contents of Contour.
+	old string
+	new string
+
+	// fqdn and cluster select the resources to fetch from Contour.
+	fqdn    string
+	cluster string
+}
+
+// registerDiff registers the diff subcommand and flags
+// with the Application provided.
+func registerDiff(cmd *kingpin.CmdClause) (*kingpin.CmdClause, *diffContext) {
+	var ctx diffContext
+
+	diff := cmd.Command("diff", "Compare a snapshot of an xDS type with another, or with the contents of Contour.")
+	diff.Flag("fqdn", "Only fetch the resources for this virtual host from Contour.").StringVar(&ctx.fqdn)
+	diff.Flag("cluster", "Only fetch the resources for this cluster from Contour.").StringVar(&ctx.cluster)
+	diff.Arg("old", "Snapshot file.").Required().StringVar(&ctx.old)
+	diff.Arg("new", "Snapshot file to compare with. If omitted, the contents of Contour are used.").StringVar(&ctx.new)
+
+	return diff, &ctx
+}
+
+// doDiff writes the differences between the snapshots to w, and
+// returns true if there were any.
+func doDiff(client *Client, ctx *diffContext, w io.Writer) (bool, error) {
+	from, err := readSnapshot(ctx.old)
+	if err != nil {
+		return false, err
+	}
+
+	var to *snapshot
+	if ctx.new != "" {
+		to, err = readSnapshot(ctx.new)
+	} else {
+		to, err = fetchSnapshot(client, from.TypeURL, nil, debug.XDSFilter{
+			FQDN:    ctx.fqdn,
+			Cluster: ctx.cluster,
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return diffSnapshots(w, from, to)
+}
+
+// streamFor returns a stream of the given xDS type.
+func (c *Client) streamFor(typeURL string) (stream, error) {
+	switch typeURL {
+	case resource_v3.ClusterType:
+		return c.ClusterStream(), nil
+	case resource_v3.EndpointType:
+		return c.EndpointStream(), nil
+	case resource_v3.ListenerType:
+		return c.ListenerStream(), nil
+	case resource_v3.RouteType:
+		return c.RouteStream(), nil
+	case resource_v3.SecretType:
+		return c.SecretStream(), nil
+	default:
+		return nil, fmt.Errorf("unsupported xDS type %q", typeURL)
+	}
+}
+
+// fetch requests the named resources of the given xDS type, or all of
+// them if names is empty, and returns the first response.
+func (c *Client) fetch(typeURL string, names []string) (*envoy_discovery_v3.DiscoveryResponse, error) {
+	st, err := c.streamFor(typeURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := st.Send(&envoy_discovery_v3.DiscoveryRequest{
+		TypeUrl:       typeURL,
+		ResourceNames: names,
+	}); err != nil {
+		return nil, err
+	}
+
+	return st.Recv()
+}
+
+// fetchSnapshot fetches the named resources of the given xDS type from
+// Contour and returns the ones selected by the filter.
+func fetchSnapshot(client *Client, typeURL string, names []string, filter debug.XDSFilter) (*snapshot, error) {
+	resp, err := client.fetch(typeURL, names)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := unmarshalResources(resp.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	// The filter also needs the route configurations and clusters
+	// to select clusters and endpoints.
+	var fetchErr error
+	contents := func(t string) []proto.Message {
+		if t == typeURL {
+			return resources
+		}
+
+		resp, err := client.fetch(t, nil)
+		if err != nil {
+			fetchErr = err
+			return nil
+		}
+		r, err := unmarshalResources(resp.Resources)
+		if err != nil {
+			fetchErr = err
+			return nil
+		}
+		return r
+	}
+
+	selected := debug.FilterResources(typeURL, filter, contents)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	return newSnapshot(typeURL, resp.VersionInfo, selected)
+}
+
+func unmarshalResources(anys []*any.Any) ([]proto.Message, error) {
+	resources := make([]proto.Message, 0, len(anys))
+	for _, a := range anys {
+		var r ptypes.DynamicAny
+		if err := ptypes.UnmarshalAny(a, &r); err != nil {
+			return nil, err
+		}
+		resources = append(resources, r.Message)
+	}
+	return resources, nil
+}
+
+// newSnapshot returns a snapshot of the resources. Each resource is
+// encoded with its type, so it can be decoded again.
+func newSnapshot(typeURL, version string, resources []proto.Message) (*snapshot, error) {
+	m := jsonpb.Marshaler{OrigName: true}
+
+	s := &snapshot{
+		TypeURL:   typeURL,
+		Version:   version,
+		Resources: make([]json.RawMessage, 0, len(resources)),
+	}
+
+	for _, r := range resources {
+		a, err := ptypes.MarshalAny(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := m.Marshal(&buf, a); err != nil {
+			return nil, err
+		}
+		s.Resources = append(s.Resources, buf.Bytes())
+	}
+
+	return s, nil
+}
+
+// messages returns the resources of the snapshot by name.
+func (s *snapshot) messages() (map[string]proto.Message, error) {
+	messages := map[string]proto.Message{}
+	for _, raw := range s.Resources {
+		var a any.Any
+		if err := jsonpb.Unmarshal(bytes.NewReader(raw), &a); err != nil {
+			return nil, err
+		}
+
+		var r ptypes.DynamicAny
+		if err := ptypes.UnmarshalAny(&a, &r); err != nil {
+			return nil, err
+		}
+		messages[resourceName(r.Message)] = r.Message
+	}
+	return messages, nil
+}
+
+// resourceName returns the name Envoy requests the resource by.
+func resourceName(m proto.Message) string {
+	switch r := m.(type) {
+	case *envoy_cluster_v3.Cluster:
+		return r.Name
+	case *envoy_endpoint_v3.ClusterLoadAssignment:
+		return r.ClusterName
+	case *envoy_listener_v3.Listener:
+		return r.Name
+	case *envoy_route_v3.RouteConfiguration:
+		return r.Name
+	case *envoy_tls_v3.Secret:
+		return r.Name
+	default:
+		return ""
+	}
+}
+
+// writeSnapshot writes the snapshot to w as "yaml" or "json".
+func writeSnapshot(w io.Writer, s *snapshot, output string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if output == "yaml" {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+	} else {
+		data = append(data, '\n')
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// readSnapshot reads a snapshot file in either output format.
+func readSnapshot(filename string) (*snapshot, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var s snapshot
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", filename, err)
+	}
+	return &s, nil
+}
+
+// diffSnapshots writes the resources that were removed, added or
+// changed between the snapshots to w, and returns true if there were
+// any.
+func diffSnapshots(w io.Writer, from, to *snapshot) (bool, error) {
+	if from.TypeURL != to.TypeURL {
+		return false, fmt.Errorf("cannot compare %s with %s", from.TypeURL, to.TypeURL)
+	}
+
+	oldMessages, err := from.messages()
+	if err != nil {
+		return false, err
+	}
+	newMessages, err := to.messages()
+	if err != nil {
+		return false, err
+	}
+
+	var names []string
+	for name := range oldMessages {
+		names = append(names, name)
+	}
+	for name := range newMessages {
+		if _, ok := oldMessages[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changed := false
+	for _, name := range names {
+		o, inOld := oldMessages[name]
+		n, inNew := newMessages[name]
+
+		switch {
+		case !inNew:
+			fmt.Fprintf(w, "- %s\n", name)
+		case !inOld:
+			fmt.Fprintf(w, "+ %s\n", name)
+		case !proto.Equal(o, n):
+			fmt.Fprintf(w, "~ %s\n", name)
+			for _, line := range strings.Split(strings.TrimRight(cmp.Diff(o, n, protocmp.Transform()), "\n"), "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		default:
+			continue
+		}
+		changed = true
+	}
+
+	if changed {
+		fmt.Fprintf(w, "\n%s: version %s -> %s\n", from.TypeURL, orNone(from.Version), orNone(to.Version))
+	}
+	return changed, nil
+}
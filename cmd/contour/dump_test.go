@@ -0,0 +1,107 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	resource_v3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	resources := []proto.Message{
+		&envoy_cluster_v3.Cluster{
+			Name:           "default/kuard/80/da39a3ee5e",
+			ConnectTimeout: protobuf.Duration(250 * time.Millisecond),
+		},
+	}
+
+	s, err := newSnapshot(resource_v3.ClusterType, "7", resources)
+	require.NoError(t, err)
+
+	for _, output := range []string{"yaml", "json"} {
+		t.Run(output, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeSnapshot(&buf, s, output))
+
+			filename := filepath.Join(t.TempDir(), "snapshot."+output)
+			require.NoError(t, ioutil.WriteFile(filename, buf.Bytes(), 0600))
+
+			got, err := readSnapshot(filename)
+			require.NoError(t, err)
+			assert.Equal(t, resource_v3.ClusterType, got.TypeURL)
+			assert.Equal(t, "7", got.Version)
+
+			messages, err := got.messages()
+			require.NoError(t, err)
+			require.Len(t, messages, 1)
+			protobuf.ExpectEqual(t, resources[0], messages["default/kuard/80/da39a3ee5e"])
+		})
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	cluster := func(name string, limit uint32) proto.Message {
+		return &envoy_cluster_v3.Cluster{
+			Name:                          name,
+			PerConnectionBufferLimitBytes: protobuf.UInt32(limit),
+		}
+	}
+
+	from, err := newSnapshot(resource_v3.ClusterType, "1", []proto.Message{
+		cluster("removed", 1),
+		cluster("changed", 1),
+		cluster("same", 1),
+	})
+	require.NoError(t, err)
+
+	to, err := newSnapshot(resource_v3.ClusterType, "2", []proto.Message{
+		cluster("same", 1),
+		cluster("changed", 2),
+		cluster("added", 1),
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	changed, err := diffSnapshots(&buf, from, to)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	out := buf.String()
+	assert.Contains(t, out, "+ added\n")
+	assert.Contains(t, out, "~ changed\n")
+	assert.Contains(t, out, "- removed\n")
+	assert.NotContains(t, out, "same")
+	assert.Contains(t, out, "version 1 -> 2")
+
+	buf.Reset()
+	changed, err = diffSnapshots(&buf, to, to)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, buf.String())
+
+	listeners, err := newSnapshot(resource_v3.ListenerType, "1", nil)
+	require.NoError(t, err)
+	_, err = diffSnapshots(&buf, from, listeners)
+	assert.Error(t, err)
+}
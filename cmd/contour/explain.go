@@ -0,0 +1,212 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/projectcontour/contour/internal/debug"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type explainContext struct {
+	// debugAddr is the host:port of the Contour debug service.
+	debugAddr string
+
+	// host, path and headers describe the request to explain.
+	host    string
+	path    string
+	headers []string
+
+	// secure selects the HTTPS virtual host.
+	secure bool
+
+	// output is the output format, "text" or "json".
+	output string
+}
+
+// registerExplain registers the explain subcommand and flags
+// with the Application provided.
+func registerExplain(cmd *kingpin.CmdClause) (*kingpin.CmdClause, *explainContext) {
+	var ctx explainContext
+
+	explain := cmd.Command("explain", "Explain which route serves a request.")
+	explain.Flag("debug", "Contour debug service host:port.").Default("127.0.0.1:6060").StringVar(&ctx.debugAddr)
+	explain.Flag("host", "Host of the request.").Required().StringVar(&ctx.host)
+	explain.Flag("path", "Path of the request.").Default("/").StringVar(&ctx.path)
+	explain.Flag("header", "Header of the request, as name:value. May be repeated.").Short('H').StringsVar(&ctx.headers)
+	explain.Flag("secure", "Explain an HTTPS request.").BoolVar(&ctx.secure)
+	explain.Flag("output", "Output format.").Short('o').Default("text").EnumVar(&ctx.output, "text", "json")
+
+	return explain, &ctx
+}
+
+// doExplain asks the Contour debug service how the request is routed
+// and writes the explanation to w.
+func doExplain(ctx *explainContext, w io.Writer) error {
+	query := url.Values{}
+	query.Set("host", ctx.host)
+	query.Set("path", ctx.path)
+	for _, h := range ctx.headers {
+		query.Add("header", h)
+	}
+	if ctx.secure {
+		query.Set("secure", "true")
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/explain?%s", ctx.debugAddr, query.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected response from %s: %s: %s", ctx.debugAddr, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var exp debug.Explanation
+	if err := json.NewDecoder(resp.Body).Decode(&exp); err != nil {
+		return err
+	}
+
+	if ctx.output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(exp)
+	}
+
+	return printExplanation(w, &exp)
+}
+
+// printExplanation writes the explanation to w: the virtual host, the
+// routes that were evaluated, and the matching route with the objects
+// it came from and its policies.
+func printExplanation(w io.Writer, exp *debug.Explanation) error {
+	scheme := "http"
+	if exp.Secure {
+		scheme = "https"
+	}
+	fmt.Fprintf(w, "Virtual host: %s://%s\n", scheme, exp.VirtualHost)
+	if exp.EnvoyGroup != "" {
+		fmt.Fprintf(w, "Envoy group:  %s\n", exp.EnvoyGroup)
+	}
+
+	if len(exp.TCPProxy) > 0 {
+		fmt.Fprintf(w, "TCP proxy:    %s\n", weightedClusters(exp.TCPProxy))
+		return nil
+	}
+
+	fmt.Fprintln(w, "\nRoutes evaluated:")
+	for _, c := range exp.Candidates {
+		match := strings.Join(append([]string{c.PathMatch}, c.HeaderMatches...), ", ")
+		if c.Matched {
+			fmt.Fprintf(w, "  * %s\n", match)
+		} else {
+			fmt.Fprintf(w, "    %s (%s)\n", match, c.Reason)
+		}
+	}
+
+	r := exp.Route
+	if r == nil {
+		_, err := fmt.Fprintln(w, "\nNo route matches. Envoy responds with 404.")
+		return err
+	}
+
+	fmt.Fprintln(w, "\nDefined by:")
+	for i, s := range exp.Sources {
+		line := fmt.Sprintf("  %s%s %s/%s", strings.Repeat("  ", i), s.Kind, s.Namespace, s.Name)
+		if len(s.Conditions) > 0 {
+			conditions, err := json.Marshal(s.Conditions)
+			if err != nil {
+				return err
+			}
+			line += fmt.Sprintf(" included with %s", conditions)
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintln(w, "\nPolicies:")
+	if r.HTTPSUpgrade {
+		fmt.Fprintln(w, "  Redirects to HTTPS")
+	}
+	fmt.Fprintf(w, "  Clusters:          %s\n", weightedClusters(r.Clusters))
+	if len(r.MirrorPolicies) > 0 {
+		fmt.Fprintf(w, "  Mirrors:           %s\n", weightedClusters(r.MirrorPolicies))
+	}
+	if r.PrefixRewrite != "" {
+		fmt.Fprintf(w, "  Prefix rewrite:    %s\n", r.PrefixRewrite)
+	}
+	fmt.Fprintf(w, "  Response timeout:  %s\n", orDefault(r.ResponseTimeout))
+	fmt.Fprintf(w, "  Idle timeout:      %s\n", orDefault(r.IdleTimeout))
+	if rp := r.RetryPolicy; rp != nil {
+		fmt.Fprintf(w, "  Retries:           %d on %s, per try timeout %s\n", rp.NumRetries, rp.RetryOn, orDefault(rp.PerTryTimeout))
+	}
+	if r.Websocket {
+		fmt.Fprintln(w, "  Websockets:        enabled")
+	}
+	if hp := r.RequestHeadersPolicy; hp != nil {
+		fmt.Fprintf(w, "  Request headers:   %s\n", headersPolicy(hp))
+	}
+	if hp := r.ResponseHeadersPolicy; hp != nil {
+		fmt.Fprintf(w, "  Response headers:  %s\n", headersPolicy(hp))
+	}
+
+	return nil
+}
+
+func weightedClusters(clusters []debug.WeightedCluster) string {
+	var s []string
+	for _, c := range clusters {
+		if c.Weight > 0 {
+			s = append(s, fmt.Sprintf("%s (weight %d)", c.Name, c.Weight))
+		} else {
+			s = append(s, c.Name)
+		}
+	}
+	return orNone(strings.Join(s, ", "))
+}
+
+func headersPolicy(hp *debug.HeadersPolicyDump) string {
+	var s []string
+	if hp.HostRewrite != "" {
+		s = append(s, "host rewritten to "+hp.HostRewrite)
+	}
+	var names []string
+	for name := range hp.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s = append(s, fmt.Sprintf("set %s: %s", name, hp.Set[name]))
+	}
+	for _, name := range hp.Remove {
+		s = append(s, "remove "+name)
+	}
+	return orNone(strings.Join(s, ", "))
+}
+
+func orDefault(s string) string {
+	if s == "" {
+		return "default"
+	}
+	return s
+}
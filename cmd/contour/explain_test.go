@@ -0,0 +1,89 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/debug"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintExplanation(t *testing.T) {
+	exp := &debug.Explanation{
+		VirtualHost: "example.com",
+		Secure:      true,
+		Candidates: []debug.CandidateDump{{
+			PathMatch: "prefix: /api/v2",
+			Reason:    `path does not start with "/api/v2"`,
+		}, {
+			PathMatch: "prefix: /api",
+			Matched:   true,
+		}},
+		Route: &debug.RouteDump{
+			PathMatch:       "prefix: /api",
+			Clusters:        []debug.WeightedCluster{{Name: "teams/api/80/da39a3ee5e", Weight: 90}, {Name: "teams/api-canary/80/da39a3ee5e", Weight: 10}},
+			ResponseTimeout: "10s",
+			RetryPolicy: &debug.RetryPolicyDump{
+				RetryOn:    "5xx",
+				NumRetries: 3,
+			},
+			RequestHeadersPolicy: &debug.HeadersPolicyDump{
+				Set:    map[string]string{"X-Team": "api"},
+				Remove: []string{"X-Debug"},
+			},
+		},
+		Sources: []debug.RouteSourceDump{{
+			Kind:      "HTTPProxy",
+			Namespace: "roots",
+			Name:      "example",
+		}, {
+			Kind:       "HTTPProxy",
+			Namespace:  "teams",
+			Name:       "api",
+			Conditions: []contour_api_v1.MatchCondition{{Prefix: "/api"}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, printExplanation(&buf, exp))
+
+	assert.Equal(t, `Virtual host: https://example.com
+
+Routes evaluated:
+    prefix: /api/v2 (path does not start with "/api/v2")
+  * prefix: /api
+
+Defined by:
+  HTTPProxy roots/example
+    HTTPProxy teams/api included with [{"prefix":"/api"}]
+
+Policies:
+  Clusters:          teams/api/80/da39a3ee5e (weight 90), teams/api-canary/80/da39a3ee5e (weight 10)
+  Response timeout:  10s
+  Idle timeout:      default
+  Retries:           3 on 5xx, per try timeout default
+  Request headers:   set X-Team: api, remove X-Debug
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, printExplanation(&buf, &debug.Explanation{
+		VirtualHost: "example.com",
+		Candidates:  []debug.CandidateDump{},
+	}))
+	assert.Contains(t, buf.String(), "No route matches.")
+}
@@ -0,0 +1,120 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/projectcontour/contour/internal/xds"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type nodesContext struct {
+	// debugAddr is the host:port of the Contour debug service.
+	debugAddr string
+
+	// output is the output format, "table" or "json".
+	output string
+}
+
+// registerNodes registers the nodes subcommand and flags
+// with the Application provided.
+func registerNodes(cmd *kingpin.CmdClause) (*kingpin.CmdClause, *nodesContext) {
+	var ctx nodesContext
+
+	nodes := cmd.Command("nodes", "List the connected Envoys and whether they are in sync.")
+	nodes.Flag("debug", "Contour debug service host:port.").Default("127.0.0.1:6060").StringVar(&ctx.debugAddr)
+	nodes.Flag("output", "Output format.").Short('o').Default("table").EnumVar(&ctx.output, "table", "json")
+
+	return nodes, &ctx
+}
+
+// doNodes fetches the connected Envoys from the Contour debug service
+// and writes them to w.
+func doNodes(ctx *nodesContext, w io.Writer) error {
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/xds/nodes", ctx.debugAddr))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from %s: %s", ctx.debugAddr, resp.Status)
+	}
+
+	var nodes []xds.NodeStatus
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return err
+	}
+
+	if ctx.output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(nodes)
+	}
+
+	return printNodes(w, nodes, time.Now())
+}
+
+// printNodes writes a table of the nodes to w, followed by the number
+// of nodes that are in sync.
+func printNodes(w io.Writer, nodes []xds.NodeStatus, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tCLUSTER\tVERSION\tGROUP\tCONNECTED\tIN SYNC\tOUT OF SYNC TYPES")
+
+	inSync := 0
+	for _, n := range nodes {
+		if n.InSync {
+			inSync++
+		}
+
+		var pending []string
+		for _, r := range n.Resources {
+			if !r.InSync {
+				pending = append(pending, r.TypeURL[strings.LastIndex(r.TypeURL, ".")+1:])
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\t%s\n",
+			n.ID,
+			orNone(n.Cluster),
+			orNone(n.BuildVersion),
+			orNone(n.Group),
+			now.Sub(n.Connected).Truncate(time.Second),
+			n.InSync,
+			orNone(strings.Join(pending, ",")),
+		)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d of %d Envoys in sync\n", inSync, len(nodes))
+	return err
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,56 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintNodes(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	nodes := []xds.NodeStatus{{
+		ID:           "envoy-1",
+		Cluster:      "contour",
+		BuildVersion: "v1.17.1",
+		Connected:    now.Add(-90 * time.Second),
+		InSync:       true,
+	}, {
+		ID:        "envoy-2",
+		Group:     "internal",
+		Connected: now.Add(-time.Hour),
+		Resources: []xds.ResourceStatus{{
+			TypeURL: "type.googleapis.com/envoy.config.cluster.v3.Cluster",
+		}, {
+			TypeURL: "type.googleapis.com/envoy.config.listener.v3.Listener",
+			InSync:  true,
+		}},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, printNodes(&buf, nodes, now))
+
+	assert.Equal(t, `ID       CLUSTER  VERSION  GROUP     CONNECTED  IN SYNC  OUT OF SYNC TYPES
+envoy-1  contour  v1.17.1  -         1m30s      true     -
+envoy-2  -        -        internal  1h0m0s     false    Cluster
+
+1 of 2 Envoys in sync
+`, buf.String())
+}
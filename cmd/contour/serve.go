@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -35,6 +36,7 @@ import (
 	"github.com/projectcontour/contour/internal/httpsvc"
 	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/projectcontour/contour/internal/ocsp"
 	"github.com/projectcontour/contour/internal/timeout"
 	"github.com/projectcontour/contour/internal/workgroup"
 	"github.com/projectcontour/contour/internal/xds"
@@ -226,6 +228,16 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 				Infof("client certificate namespace %q not defined in 'root-namespaces', adding namespace to watch",
 					ctx.Config.TLS.ClientCertificate.Namespace)
 		}
+
+		// Add the error page ConfigMap namespaces to informerNamespaces if they aren't present.
+		for _, page := range ctx.Config.ErrorPagePolicy.Pages {
+			if !contains(informerNamespaces, page.ConfigMap.Namespace) {
+				informerNamespaces = append(informerNamespaces, page.ConfigMap.Namespace)
+				log.WithField("context", "error-page-policy").
+					Infof("error page ConfigMap namespace %q not defined in 'root-namespaces', adding namespace to watch",
+						page.ConfigMap.Namespace)
+			}
+		}
 	}
 
 	var configuredSecretRefs []*types.NamespacedName
@@ -236,6 +248,11 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		configuredSecretRefs = append(configuredSecretRefs, clientCert)
 	}
 
+	var configuredConfigMapRefs []*types.NamespacedName
+	for _, page := range ctx.Config.ErrorPagePolicy.Pages {
+		configuredConfigMapRefs = append(configuredConfigMapRefs, namespacedNameOf(page.ConfigMap))
+	}
+
 	// Set up Prometheus registry and register base metrics.
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
@@ -273,6 +290,25 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	if err != nil {
 		return fmt.Errorf("error parsing request timeout: %w", err)
 	}
+	requestHeadersTimeout, err := timeout.Parse(ctx.Config.Timeouts.RequestHeadersTimeout)
+	if err != nil {
+		return fmt.Errorf("error parsing request headers timeout: %w", err)
+	}
+	clusterIdleTimeout, err := timeout.Parse(ctx.Config.Cluster.IdleTimeout)
+	if err != nil {
+		return fmt.Errorf("error parsing cluster idle timeout: %w", err)
+	}
+	clusterMaxStreamDuration, err := timeout.Parse(ctx.Config.Cluster.MaxStreamDuration)
+	if err != nil {
+		return fmt.Errorf("error parsing cluster max stream duration: %w", err)
+	}
+
+	connectionPolicy := dag.ConnectionPolicy{
+		IdleTimeout:                   clusterIdleTimeout,
+		MaxRequestsPerConnection:      ctx.Config.Cluster.MaxRequestsPerConnection,
+		MaxStreamDuration:             clusterMaxStreamDuration,
+		PerConnectionBufferLimitBytes: ctx.Config.Cluster.PerConnectionBufferLimitBytes,
+	}
 
 	listenerConfig := xdscache_v3.ListenerConfig{
 		UseProxyProto:                 ctx.useProxyProto,
@@ -286,12 +322,14 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		AccessLogFields:               ctx.Config.AccessLogFields,
 		MinimumTLSVersion:             annotation.MinTLSVersion(ctx.Config.TLS.MinimumProtocolVersion, "1.2"),
 		RequestTimeout:                requestTimeout,
+		RequestHeadersTimeout:         requestHeadersTimeout,
 		ConnectionIdleTimeout:         connectionIdleTimeout,
 		StreamIdleTimeout:             streamIdleTimeout,
 		MaxConnectionDuration:         maxConnectionDuration,
 		ConnectionShutdownGracePeriod: connectionShutdownGracePeriod,
 		DefaultHTTPVersions:           parseDefaultHTTPVersions(ctx.Config.DefaultHTTPVersions),
 		AllowChunkedLength:            !ctx.Config.DisableAllowChunkedLength,
+		Listeners:                     extraListeners(ctx.Config.Listeners),
 	}
 
 	contourMetrics := metrics.NewMetrics(registry)
@@ -300,9 +338,11 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	// due to their high update rate and their orthogonal nature.
 	endpointHandler := xdscache_v3.NewEndpointsTranslator(log.WithField("context", "endpointstranslator"))
 
+	secretCache := &xdscache_v3.SecretCache{}
+
 	resources := []xdscache.ResourceCache{
 		xdscache_v3.NewListenerCache(listenerConfig, ctx.statsAddr, ctx.statsPort),
-		&xdscache_v3.SecretCache{},
+		secretCache,
 		&xdscache_v3.RouteCache{},
 		&xdscache_v3.ClusterCache{},
 		endpointHandler,
@@ -314,40 +354,116 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	// register observer for endpoints updates.
 	endpointHandler.Observer = contour.ComposeObservers(snapshotHandler)
 
+	// Fetch OCSP responses for TLS certificates if configured.
+	var stapler *ocsp.Stapler
+	if ctx.Config.TLS.OCSP.FetchStaples {
+		stapler = &ocsp.Stapler{
+			Responder: &ocsp.HTTPResponder{
+				URL:    ctx.Config.TLS.OCSP.ResponderURL,
+				Client: &http.Client{Timeout: 10 * time.Second},
+			},
+			RefreshInterval: ctx.Config.TLS.OCSP.RefreshInterval,
+			Observer:        contour.ComposeObservers(secretCache, contour.ObserverFunc(snapshotHandler.RefreshSecrets)),
+			FieldLogger:     log.WithField("context", "ocsp"),
+		}
+		secretCache.OCSPStapler = stapler
+	}
+
+	var ocspStaples dag.OCSPStaples
+	if stapler != nil {
+		ocspStaples = stapler
+	}
+
+	// Track the expiry of the certificates referenced by virtual hosts.
+	certExpiry := &contour.CertificateExpiryObserver{
+		Metrics:     contourMetrics,
+		FieldLogger: log.WithField("context", "certificate-expiry"),
+	}
+
+	// Track the xDS responses that Envoy rejects.
+	nackTracker := &contour.NackTracker{
+		Metrics:     contourMetrics,
+		FieldLogger: log.WithField("context", "nacks"),
+	}
+
+	// Track the connected Envoys and whether they are in sync.
+	nodeTracker := &contour.NodeTracker{
+		Metrics: contourMetrics,
+	}
+
+	// Measure how long changes take to reach Envoy.
+	propagationTracker := &contour.PropagationTracker{
+		Metrics: contourMetrics,
+	}
+	snapshotHandler.Propagation = propagationTracker
+	endpointHandler.Propagation = propagationTracker
+	nodeRecorder := xds.NodeRecorders{nodeTracker, propagationTracker}
+
+	certExpiryWarning := ctx.Config.TLS.CertificateExpiryWarning
+	if certExpiryWarning == 0 {
+		certExpiryWarning = 30 * 24 * time.Hour
+	}
+
+	// Keep the latest DAG for the debug service.
+	dagCache := &debug.DAGCache{}
+
 	// Build the core Kubernetes event handler.
 	eventHandler := &contour.EventHandler{
 		HoldoffDelay:    100 * time.Millisecond,
 		HoldoffMaxDelay: 500 * time.Millisecond,
-		Observer:        dag.ComposeObservers(append(xdscache.ObserversOf(resources), snapshotHandler)...),
+		Propagation:     propagationTracker,
+		Observer:        dag.ComposeObservers(append(xdscache.ObserversOf(resources), snapshotHandler, certExpiry, nackTracker, dagCache)...),
 		Builder: dag.Builder{
 			Source: dag.KubernetesCache{
-				RootNamespaces:       ctx.proxyRootNamespaces(),
-				IngressClass:         ctx.ingressClass,
-				ConfiguredSecretRefs: configuredSecretRefs,
-				FieldLogger:          log.WithField("context", "KubernetesCache"),
+				RootNamespaces:          ctx.proxyRootNamespaces(),
+				IngressClass:            ctx.ingressClass,
+				ConfiguredSecretRefs:    configuredSecretRefs,
+				ConfiguredConfigMapRefs: configuredConfigMapRefs,
+				FieldLogger:             log.WithField("context", "KubernetesCache"),
 			},
 			Processors: []dag.Processor{
 				&dag.IngressProcessor{
 					FieldLogger:       log.WithField("context", "IngressProcessor"),
 					ClientCertificate: clientCert,
+					ConnectionPolicy:  connectionPolicy,
 				},
 				&dag.ExtensionServiceProcessor{
 					FieldLogger:       log.WithField("context", "ExtensionServiceProcessor"),
 					ClientCertificate: clientCert,
+					ConnectionPolicy:  connectionPolicy,
 				},
 				&dag.HTTPProxyProcessor{
-					DisablePermitInsecure: ctx.Config.DisablePermitInsecure,
-					FallbackCertificate:   fallbackCert,
-					DNSLookupFamily:       ctx.Config.Cluster.DNSLookupFamily,
-					ClientCertificate:     clientCert,
+					DisablePermitInsecure:    ctx.Config.DisablePermitInsecure,
+					FallbackCertificate:      fallbackCert,
+					DNSLookupFamily:          ctx.Config.Cluster.DNSLookupFamily,
+					ClientCertificate:        clientCert,
+					CertificateExpiryWarning: certExpiryWarning,
+					ConfigRejections:         nackTracker,
+					OCSPStaples:              ocspStaples,
+					Listeners:                listenerProtocols(ctx.Config.Listeners),
+					HTTP3Port:                http3Port(ctx.Config),
+					ConnectionPolicy:         connectionPolicy,
 				},
 				&dag.ServiceAPIsProcessor{},
-				&dag.ListenerProcessor{},
+				&dag.ListenerProcessor{
+					FieldLogger: log.WithField("context", "ListenerProcessor"),
+					ErrorPages:  ctx.Config.ErrorPagePolicy.Pages,
+				},
 			},
 		},
 		FieldLogger: log.WithField("context", "contourEventHandler"),
 	}
 
+	// Update the status of rejected configuration as soon as the
+	// rejections change.
+	nackTracker.Rebuild = eventHandler.Rebuild
+
+	// Switch MustStaple virtual hosts from LenientStapling once
+	// their OCSP responses have been fetched.
+	if stapler != nil {
+		stapler.Observer = contour.ComposeObservers(stapler.Observer, contour.ObserverFunc(eventHandler.Rebuild))
+	}
+
 	// Log that we're using the fallback certificate if configured.
 	if fallbackCert != nil {
 		log.WithField("context", "fallback-certificate").Infof("enabled fallback certificate with secret: %q", fallbackCert)
@@ -416,6 +532,20 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		log.WithField("hack_zone", "clients").Info("TEMP fall back to clients for secrets")
 	}
 
+	// Inform on ConfigMaps, filtering by root namespaces.
+	for _, r := range k8s.ConfigMapsResources() {
+		var handler cache.ResourceEventHandler = &dynamicHandler
+
+		// If root namespaces are defined, filter for ConfigMaps in only those namespaces.
+		if len(informerNamespaces) > 0 {
+			handler = k8s.NewNamespaceFilter(informerNamespaces, &dynamicHandler)
+		}
+
+		if err := informOnResource(clients, r, handler); err != nil {
+			log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+		}
+	}
+
 	// Inform on endpoints.
 	for _, r := range k8s.EndpointsResources() {
 		if err := informOnResource(externalClients, r, &k8s.DynamicClientHandler{
@@ -465,6 +595,11 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 
 	// Register our event handler with the workgroup.
 	g.Add(eventHandler.Start())
+	g.Add(certExpiry.Start)
+
+	if stapler != nil {
+		g.Add(stapler.Start)
+	}
 
 	// Create metrics service and register with workgroup.
 	metricsvc := httpsvc.Service{
@@ -476,10 +611,27 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 
 	metricsvc.ServeMux.Handle("/metrics", metrics.Handler(registry))
 
+	// xdsListening is set while the xDS gRPC server is listening.
+	var xdsListening int32
+
+	livez := health.CheckHandler(health.PingCheck())
+	readyz := health.CheckHandler(
+		health.InformerCheck("informers", clients),
+		health.InformerCheck("external-informers", externalClients),
+		health.FuncCheck("dag", "the DAG has not been built", func() bool {
+			return dagCache.Latest() != nil
+		}),
+		health.FuncCheck("xds", "the xDS server is not listening", func() bool {
+			return atomic.LoadInt32(&xdsListening) == 1
+		}),
+	)
+
 	if ctx.healthAddr == ctx.metricsAddr && ctx.healthPort == ctx.metricsPort {
 		h := health.Handler(clients.ClientSet())
 		metricsvc.ServeMux.Handle("/health", h)
 		metricsvc.ServeMux.Handle("/healthz", h)
+		metricsvc.ServeMux.Handle("/livez", livez)
+		metricsvc.ServeMux.Handle("/readyz", readyz)
 	}
 
 	g.Add(metricsvc.Start)
@@ -495,6 +647,8 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		h := health.Handler(clients.ClientSet())
 		healthsvc.ServeMux.Handle("/health", h)
 		healthsvc.ServeMux.Handle("/healthz", h)
+		healthsvc.ServeMux.Handle("/livez", livez)
+		healthsvc.ServeMux.Handle("/readyz", readyz)
 
 		g.Add(healthsvc.Start)
 	}
@@ -506,7 +660,11 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			Port:        ctx.debugPort,
 			FieldLogger: log.WithField("context", "debugsvc"),
 		},
-		Builder: &eventHandler.Builder,
+		Builder:   &eventHandler.Builder,
+		DAG:       dagCache,
+		Resources: xdscache.ResourcesOf(resources),
+		Nacks:     nackTracker,
+		Nodes:     nodeTracker,
 	}
 	g.Add(debugsvc.Start)
 
@@ -601,9 +759,9 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		case config.EnvoyServerType:
 			v3cache := contour_xds_v3.NewSnapshotCache(false, log)
 			snapshotHandler.AddSnapshotter(v3cache)
-			contour_xds_v3.RegisterServer(envoy_server_v3.NewServer(context.Background(), v3cache, contour_xds_v3.NewRequestLoggingCallbacks(log)), grpcServer)
+			contour_xds_v3.RegisterServer(envoy_server_v3.NewServer(context.Background(), v3cache, contour_xds_v3.NewRequestLoggingCallbacks(log, nackTracker, nodeRecorder)), grpcServer)
 		case config.ContourServerType:
-			contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, xdscache.ResourcesOf(resources)...), grpcServer)
+			contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, nackTracker, nodeRecorder, xdscache.ResourcesOf(resources)...), grpcServer)
 		default:
 			// This can't happen due to config validation.
 			log.Fatalf("invalid xDS server type %q", ctx.Config.Server.XDSServerType)
@@ -620,6 +778,9 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			log = log.WithField("insecure", true)
 		}
 
+		atomic.StoreInt32(&xdsListening, 1)
+		defer atomic.StoreInt32(&xdsListening, 0)
+
 		log.Infof("started xDS server type: %q", ctx.Config.Server.XDSServerType)
 		defer log.Info("stopped xDS server")
 
@@ -14,6 +14,7 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
@@ -21,6 +22,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"time"
 
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
@@ -166,44 +168,127 @@ func (ctx *serveContext) tlsconfig(log logrus.FieldLogger) *tls.Config {
 		log.WithError(err).Fatal("failed to verify TLS flags")
 	}
 
-	// Define a closure that lazily loads certificates and key at TLS handshake
-	// to ensure that latest certificates are used in case they have been rotated.
-	loadConfig := func() (*tls.Config, error) {
-		cert, err := tls.LoadX509KeyPair(ctx.contourCert, ctx.contourKey)
-		if err != nil {
-			return nil, err
-		}
+	// Certificates and key are loaded lazily at TLS handshake, and
+	// reloaded whenever the files change, so that rotated
+	// certificates are used without restarting Contour.
+	reloader := &tlsReloader{
+		certFile:    ctx.contourCert,
+		keyFile:     ctx.contourKey,
+		caFile:      ctx.caFile,
+		FieldLogger: log,
+	}
+
+	// Attempt to load certificates and key to catch configuration errors early.
+	if _, lerr := reloader.load(); lerr != nil {
+		log.WithError(lerr).Fatal("failed to load certificate and key")
+	}
+
+	return &tls.Config{
+		ClientAuth:         tls.RequireAndVerifyClientCert,
+		Rand:               rand.Reader,
+		GetConfigForClient: reloader.GetConfigForClient,
+	}
+}
+
+// tlsReloader holds the gRPC server TLS configuration loaded from
+// the certificate, key and CA bundle files, and reloads it when the
+// contents of the files change. If the rotated files cannot be
+// loaded, for example because they are only partially written, the
+// last good configuration continues to be used.
+type tlsReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	logrus.FieldLogger
+
+	mu     sync.Mutex
+	config *tls.Config
+	loaded [][]byte
+
+	// failure is the last reload error that was logged, so that
+	// it is logged once rather than on every TLS handshake.
+	failure string
+}
 
-		ca, err := ioutil.ReadFile(ctx.caFile)
+// load reads the certificate, key and CA bundle files and returns
+// the TLS configuration for their current contents.
+func (r *tlsReloader) load() (*tls.Config, error) {
+	var contents [][]byte
+	for _, filename := range []string{r.certFile, r.keyFile, r.caFile} {
+		data, err := ioutil.ReadFile(filename)
 		if err != nil {
 			return nil, err
 		}
+		contents = append(contents, data)
+	}
 
-		certPool := x509.NewCertPool()
-		if ok := certPool.AppendCertsFromPEM(ca); !ok {
-			return nil, fmt.Errorf("unable to append certificate in %s to CA pool", ctx.caFile)
-		}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-		return &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			ClientAuth:   tls.RequireAndVerifyClientCert,
-			ClientCAs:    certPool,
-			MinVersion:   tls.VersionTLS12,
-		}, nil
+	if r.config != nil && equalContents(r.loaded, contents) {
+		return r.config, nil
 	}
 
-	// Attempt to load certificates and key to catch configuration errors early.
-	if _, lerr := loadConfig(); lerr != nil {
-		log.WithError(lerr).Fatal("failed to load certificate and key")
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return nil, err
 	}
 
-	return &tls.Config{
-		ClientAuth: tls.RequireAndVerifyClientCert,
-		Rand:       rand.Reader,
-		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
-			return loadConfig()
-		},
+	certPool := x509.NewCertPool()
+	if ok := certPool.AppendCertsFromPEM(contents[2]); !ok {
+		return nil, fmt.Errorf("unable to append certificate in %s to CA pool", r.caFile)
+	}
+
+	if r.config != nil {
+		r.Info("reloaded gRPC TLS certificates")
 	}
+
+	r.loaded = contents
+	r.config = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certPool,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	return r.config, nil
+}
+
+// GetConfigForClient returns the current TLS configuration,
+// falling back to the last good one if it cannot be reloaded.
+func (r *tlsReloader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	config, err := r.load()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		r.failure = ""
+		return config, nil
+	}
+
+	if r.config == nil {
+		return nil, err
+	}
+
+	if err.Error() != r.failure {
+		r.failure = err.Error()
+		r.WithError(err).Error("failed to reload gRPC TLS certificates, using previously loaded certificates")
+	}
+	return r.config, nil
+}
+
+func equalContents(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 // verifyTLSFlags indicates if the TLS flags are set up correctly.
@@ -243,6 +328,8 @@ func parseDefaultHTTPVersions(versions []config.HTTPVersionType) []envoy_v3.HTTP
 			wanted[envoy_v3.HTTPVersion1] = struct{}{}
 		case config.HTTPVersion2:
 			wanted[envoy_v3.HTTPVersion2] = struct{}{}
+		case config.HTTPVersion3:
+			wanted[envoy_v3.HTTPVersion3] = struct{}{}
 		}
 	}
 
@@ -255,6 +342,49 @@ func parseDefaultHTTPVersions(versions []config.HTTPVersionType) []envoy_v3.HTTP
 	return parsed
 }
 
+// http3Port returns the UDP port that clients reach the HTTP/3
+// listener on, or 0 if HTTP/3 is not enabled.
+func http3Port(params config.Parameters) int {
+	for _, v := range params.DefaultHTTPVersions {
+		if v != config.HTTPVersion3 {
+			continue
+		}
+		if params.HTTP3.AdvertisedPort != 0 {
+			return params.HTTP3.AdvertisedPort
+		}
+		return 443
+	}
+	return 0
+}
+
+// extraListeners converts the extra listeners of the configuration
+// file into their xDS cache configuration.
+func extraListeners(listeners []config.ListenerParameters) []xdscache_v3.ExtraListener {
+	var extra []xdscache_v3.ExtraListener
+	for _, l := range listeners {
+		extra = append(extra, xdscache_v3.ExtraListener{
+			Name:          l.Name,
+			Address:       l.Address,
+			Port:          l.Port,
+			Protocol:      l.Protocol,
+			UseProxyProto: l.UseProxyProtocol,
+			AccessLog:     l.AccessLog,
+			HTTPVersions:  parseDefaultHTTPVersions(l.HTTPVersions),
+		})
+	}
+	return extra
+}
+
+// listenerProtocols returns the protocols of the extra listeners of
+// the configuration file, by listener name.
+func listenerProtocols(listeners []config.ListenerParameters) map[string]config.ListenerProtocol {
+	protocols := map[string]config.ListenerProtocol{}
+	for _, l := range listeners {
+		protocols[l.Name] = l.Protocol
+	}
+	return protocols
+}
+
 func namespacedNameOf(n config.NamespacedName) *types.NamespacedName {
 	if len(strings.TrimSpace(n.Name)) == 0 && len(strings.TrimSpace(n.Namespace)) == 0 {
 		return nil
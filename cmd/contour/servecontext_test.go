@@ -29,6 +29,7 @@ import (
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/pkg/config"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
 )
@@ -240,6 +241,55 @@ func TestTlsVersionDeprecation(t *testing.T) {
 	assert.Equal(t, tlsConfig.MinVersion, uint16(tls.VersionTLS12))
 }
 
+func TestTLSReloaderKeepsLastGoodConfig(t *testing.T) {
+	log, hook := test.NewNullLogger()
+
+	configDir, err := ioutil.TempDir("", "contour-testdata-")
+	checkFatalErr(t, err)
+	defer os.RemoveAll(configDir)
+
+	err = linkFiles("testdata/1", configDir)
+	checkFatalErr(t, err)
+
+	reloader := &tlsReloader{
+		certFile:    filepath.Join(configDir, "contourcert.pem"),
+		keyFile:     filepath.Join(configDir, "contourkey.pem"),
+		caFile:      filepath.Join(configDir, "CAcert.pem"),
+		FieldLogger: log,
+	}
+
+	first, err := reloader.GetConfigForClient(nil)
+	checkFatalErr(t, err)
+
+	// Unchanged files are not parsed again.
+	again, err := reloader.GetConfigForClient(nil)
+	checkFatalErr(t, err)
+	assert.Same(t, first, again)
+
+	// A partially written certificate does not replace the loaded one.
+	checkFatalErr(t, os.Remove(reloader.certFile))
+	checkFatalErr(t, ioutil.WriteFile(reloader.certFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0600))
+	got, err := reloader.GetConfigForClient(nil)
+	checkFatalErr(t, err)
+	assert.Same(t, first, got)
+
+	// The failure is logged once, not on every handshake.
+	_, err = reloader.GetConfigForClient(nil)
+	checkFatalErr(t, err)
+	assert.Len(t, hook.AllEntries(), 1)
+
+	// Rotated files are picked up.
+	err = linkFiles("testdata/2", configDir)
+	checkFatalErr(t, err)
+	got, err = reloader.GetConfigForClient(nil)
+	checkFatalErr(t, err)
+	assert.NotSame(t, first, got)
+
+	want, err := tls.LoadX509KeyPair("testdata/2/contourcert.pem", "testdata/2/contourkey.pem")
+	checkFatalErr(t, err)
+	assert.Equal(t, want.Certificate, got.Certificates[0].Certificate)
+}
+
 func checkFatalErr(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
@@ -347,6 +397,10 @@ func TestParseHTTPVersions(t *testing.T) {
 				config.HTTPVersion1, config.HTTPVersion2},
 			parseVersions: []envoy_v3.HTTPVersionType{envoy_v3.HTTPVersion1, envoy_v3.HTTPVersion2},
 		},
+		"http/1.1+http/2+http/3": {
+			versions:      []config.HTTPVersionType{config.HTTPVersion1, config.HTTPVersion2, config.HTTPVersion3},
+			parseVersions: []envoy_v3.HTTPVersionType{envoy_v3.HTTPVersion1, envoy_v3.HTTPVersion2, envoy_v3.HTTPVersion3},
+		},
 	}
 
 	for name, testcase := range cases {
@@ -364,3 +418,14 @@ func TestParseHTTPVersions(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTP3Port(t *testing.T) {
+	params := config.Defaults()
+	assert.Equal(t, 0, http3Port(params))
+
+	params.DefaultHTTPVersions = []config.HTTPVersionType{config.HTTPVersion2, config.HTTPVersion3}
+	assert.Equal(t, 443, http3Port(params))
+
+	params.HTTP3.AdvertisedPort = 8443
+	assert.Equal(t, 8443, http3Port(params))
+}
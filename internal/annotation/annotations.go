@@ -54,6 +54,7 @@ var annotationsByKind = map[string]map[string]struct{}{
 		"ingress.kubernetes.io/force-ssl-redirect":       {},
 		"kubernetes.io/ingress.allow-http":               {},
 		"kubernetes.io/ingress.class":                    {},
+		"projectcontour.io/envoy-group":                  {},
 		"projectcontour.io/ingress.class":                {},
 		"projectcontour.io/num-retries":                  {},
 		"projectcontour.io/response-timeout":             {},
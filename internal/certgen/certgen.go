@@ -18,6 +18,8 @@ package certgen
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path"
 
 	"github.com/projectcontour/contour/internal/dag"
@@ -39,8 +41,13 @@ const (
 	EnvoyCertificateKey = "envoycert.pem"
 	// EnvoyPrivateKeyKey is the dictionary key for the Envoy private key.
 	EnvoyPrivateKeyKey = "envoykey.pem"
+	// CAPrivateKeyKey is the dictionary key for the CA private key.
+	CAPrivateKeyKey = "cakey.pem"
 )
 
+// secretNames holds the names of all the Secrets that certgen writes.
+var secretNames = []string{"contourcert", "envoycert", "cacert", "cakey"}
+
 // OverwritePolicy specifies whether an output should be overwritten.
 type OverwritePolicy int
 
@@ -103,7 +110,48 @@ func WriteCertsPEM(outputDir string, certdata *certs.Certificates, force Overwri
 		return err
 	}
 
-	return writePEM(outputDir, "envoykey.pem", certdata.EnvoyPrivateKey, force)
+	err = writePEM(outputDir, "envoykey.pem", certdata.EnvoyPrivateKey, force)
+	if err != nil {
+		return err
+	}
+
+	// The CA key is only kept when certificates are rotated.
+	if len(certdata.CAPrivateKey) > 0 {
+		return writePEM(outputDir, CAPrivateKeyKey, certdata.CAPrivateKey, force)
+	}
+
+	return nil
+}
+
+// ReadCertsPEM reads the certs previously written by WriteCertsPEM
+// from outputDir. If there are no certs in outputDir, nil is returned.
+func ReadCertsPEM(outputDir string) (*certs.Certificates, error) {
+	files := map[string]*[]byte{}
+	certdata := &certs.Certificates{}
+
+	files[CACertificateKey] = &certdata.CACertificate
+	files[CAPrivateKeyKey] = &certdata.CAPrivateKey
+	files[ContourCertificateKey] = &certdata.ContourCertificate
+	files[ContourPrivateKeyKey] = &certdata.ContourPrivateKey
+	files[EnvoyCertificateKey] = &certdata.EnvoyCertificate
+	files[EnvoyPrivateKeyKey] = &certdata.EnvoyPrivateKey
+
+	for filename, data := range files {
+		buf, err := ioutil.ReadFile(path.Join(outputDir, filename))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		*data = buf
+	}
+
+	if !complete(certdata) {
+		return nil, nil
+	}
+
+	return certdata, nil
 }
 
 // WriteSecretsYAML writes all the keypairs out to Kubernetes Secrets in YAML form
@@ -123,6 +171,51 @@ func WriteSecretsYAML(outputDir string, secrets []*corev1.Secret, force Overwrit
 	return nil
 }
 
+// ReadSecretsYAML reads the Secrets previously written by
+// WriteSecretsYAML from outputDir. Secrets that have not been
+// written are skipped.
+func ReadSecretsYAML(outputDir string) ([]*corev1.Secret, error) {
+	var secrets []*corev1.Secret
+
+	for _, name := range secretNames {
+		buf, err := ioutil.ReadFile(path.Join(outputDir, name+".yaml"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		s, err := readSecret(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Secret %q: %w", name, err)
+		}
+		secrets = append(secrets, s)
+	}
+
+	return secrets, nil
+}
+
+// ReadSecretsKube reads the Secrets previously written by
+// WriteSecretsKube from namespace. Secrets that do not exist
+// are skipped.
+func ReadSecretsKube(client *kubernetes.Clientset, namespace string) ([]*corev1.Secret, error) {
+	var secrets []*corev1.Secret
+
+	for _, name := range secretNames {
+		s, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		secrets = append(secrets, s)
+	}
+
+	return secrets, nil
+}
+
 // WriteSecretsKube writes all the keypairs out to Kubernetes Secrets in the
 // compact format which is compatible with Secrets generated by cert-manager.
 func WriteSecretsKube(client *kubernetes.Clientset, secrets []*corev1.Secret, force OverwritePolicy) error {
@@ -148,7 +241,7 @@ func WriteSecretsKube(client *kubernetes.Clientset, secrets []*corev1.Secret, fo
 // Secrets in in compact Secret format, which is compatible with
 // both cert-manager and Contour.
 func AsSecrets(namespace string, certdata *certs.Certificates) []*corev1.Secret {
-	return []*corev1.Secret{
+	secrets := []*corev1.Secret{
 		newSecret(corev1.SecretTypeTLS,
 			"contourcert", namespace,
 			map[string][]byte{
@@ -164,6 +257,8 @@ func AsSecrets(namespace string, certdata *certs.Certificates) []*corev1.Secret
 				corev1.TLSPrivateKeyKey: certdata.EnvoyPrivateKey,
 			}),
 	}
+
+	return appendCAKeySecret(secrets, namespace, certdata)
 }
 
 // AsLegacySecrets transforms the given Certificates struct into a slice of
@@ -171,7 +266,7 @@ func AsSecrets(namespace string, certdata *certs.Certificates) []*corev1.Secret
 // The difference is that the CA cert is in a separate secret, rather
 // than duplicated inline in each TLS secrets.
 func AsLegacySecrets(namespace string, certdata *certs.Certificates) []*corev1.Secret {
-	return []*corev1.Secret{
+	secrets := []*corev1.Secret{
 		newSecret(corev1.SecretTypeTLS,
 			"contourcert", namespace,
 			map[string][]byte{
@@ -190,4 +285,62 @@ func AsLegacySecrets(namespace string, certdata *certs.Certificates) []*corev1.S
 				"cacert.pem": certdata.CACertificate,
 			}),
 	}
+
+	return appendCAKeySecret(secrets, namespace, certdata)
+}
+
+// appendCAKeySecret appends a Secret holding the CA private key to
+// secrets if certdata has one. The CA key is only kept when
+// certificates are rotated.
+func appendCAKeySecret(secrets []*corev1.Secret, namespace string, certdata *certs.Certificates) []*corev1.Secret {
+	if len(certdata.CAPrivateKey) == 0 {
+		return secrets
+	}
+
+	return append(secrets, newSecret(corev1.SecretTypeOpaque,
+		"cakey", namespace,
+		map[string][]byte{
+			CAPrivateKeyKey: certdata.CAPrivateKey,
+		}))
+}
+
+// CertificatesFromSecrets transforms Secrets in either the compact
+// or the legacy format back into a Certificates struct. If the
+// Secrets do not hold a complete set of certificates, nil is returned.
+func CertificatesFromSecrets(secrets []*corev1.Secret) *certs.Certificates {
+	certdata := &certs.Certificates{}
+
+	for _, s := range secrets {
+		switch s.Name {
+		case "contourcert":
+			certdata.ContourCertificate = s.Data[corev1.TLSCertKey]
+			certdata.ContourPrivateKey = s.Data[corev1.TLSPrivateKeyKey]
+			if ca := s.Data[dag.CACertificateKey]; len(ca) > 0 {
+				certdata.CACertificate = ca
+			}
+		case "envoycert":
+			certdata.EnvoyCertificate = s.Data[corev1.TLSCertKey]
+			certdata.EnvoyPrivateKey = s.Data[corev1.TLSPrivateKeyKey]
+		case "cacert":
+			certdata.CACertificate = s.Data[CACertificateKey]
+		case "cakey":
+			certdata.CAPrivateKey = s.Data[CAPrivateKeyKey]
+		}
+	}
+
+	if !complete(certdata) {
+		return nil
+	}
+
+	return certdata
+}
+
+// complete returns true if certdata holds the CA certificate and
+// the Contour and Envoy keypairs.
+func complete(certdata *certs.Certificates) bool {
+	return len(certdata.CACertificate) > 0 &&
+		len(certdata.ContourCertificate) > 0 &&
+		len(certdata.ContourPrivateKey) > 0 &&
+		len(certdata.EnvoyCertificate) > 0 &&
+		len(certdata.EnvoyPrivateKey) > 0
 }
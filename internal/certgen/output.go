@@ -29,6 +29,17 @@ func writeSecret(f *os.File, secret *corev1.Secret) error {
 	return s.Encode(secret, f)
 }
 
+// readSecret reads a Secret written by writeSecret.
+func readSecret(data []byte) (*corev1.Secret, error) {
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	secret := &corev1.Secret{}
+	if _, _, err := s.Decode(data, nil, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
 func createFile(filepath string, force bool) (*os.File, error) {
 
 	err := os.MkdirAll(path.Dir(filepath), 0755)
@@ -0,0 +1,174 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"sync"
+	"time"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// certificateExpiryInterval is how often CertificateExpiryObserver
+// re-evaluates the certificates of the last DAG it observed.
+const certificateExpiryInterval = time.Hour
+
+// CertificateExpiryObserver is a dag.Observer that tracks the expiry
+// of the TLS certificates referenced by secure virtual hosts. It
+// records the days remaining for each certificate in Metrics, and
+// logs an error when a certificate that is still being served by
+// Envoy expires.
+type CertificateExpiryObserver struct {
+	// Metrics to emit. If nil, no metrics are emitted.
+	Metrics *metrics.Metrics
+
+	logrus.FieldLogger
+
+	mu    sync.Mutex
+	certs []referencedCertificate
+
+	// expired holds the served certificates that were expired at
+	// the last update, so that each is only logged once.
+	expired map[referencedCertificate]bool
+
+	// now returns the current time. If nil, time.Now is used.
+	now func() time.Time
+}
+
+// referencedCertificate is a certificate referenced by a virtual host.
+type referencedCertificate struct {
+	meta     metrics.CertificateMeta
+	notAfter time.Time
+
+	// served is true if Envoy presents the certificate to
+	// clients, rather than using it to validate peers.
+	served bool
+}
+
+// OnChange implements dag.Observer.
+func (c *CertificateExpiryObserver) OnChange(d *dag.DAG) {
+	var certs []referencedCertificate
+
+	add := func(fqdn string, secret *dag.Secret, notAfter time.Time, served bool) {
+		if secret == nil || notAfter.IsZero() {
+			return
+		}
+
+		certs = append(certs, referencedCertificate{
+			meta: metrics.CertificateMeta{
+				Namespace: secret.Namespace(),
+				Name:      secret.Name(),
+				FQDN:      fqdn,
+			},
+			notAfter: notAfter,
+			served:   served,
+		})
+	}
+
+	d.Visit(func(v dag.Vertex) {
+		listener, ok := v.(*dag.Listener)
+		if !ok {
+			return
+		}
+
+		listener.Visit(func(v dag.Vertex) {
+			svh, ok := v.(*dag.SecureVirtualHost)
+			if !ok {
+				return
+			}
+
+			add(svh.Name, svh.Secret, certNotAfter(svh.Secret), true)
+			add(svh.Name, svh.FallbackCertificate, certNotAfter(svh.FallbackCertificate), true)
+			if dv := svh.DownstreamValidation; dv != nil && dv.CACertificate != nil {
+				add(svh.Name, dv.CACertificate, dv.CACertificate.CANotAfter(), false)
+			}
+		})
+	})
+
+	c.mu.Lock()
+	c.certs = certs
+	c.mu.Unlock()
+
+	c.update()
+}
+
+// Start fulfills the g.Start contract. It periodically re-evaluates
+// the certificates of the last observed DAG until stop is closed, so
+// that the metrics stay current between DAG rebuilds.
+func (c *CertificateExpiryObserver) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(certificateExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			c.update()
+		}
+	}
+}
+
+// update records the days remaining for each observed certificate,
+// and logs the served certificates that expired since the last
+// update.
+func (c *CertificateExpiryObserver) update() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+
+	expiry := map[metrics.CertificateMeta]float64{}
+	expired := map[referencedCertificate]bool{}
+	for _, cert := range c.certs {
+		remaining := cert.notAfter.Sub(now)
+
+		days := remaining.Hours() / 24
+		if d, ok := expiry[cert.meta]; !ok || days < d {
+			expiry[cert.meta] = days
+		}
+
+		if !cert.served || remaining > 0 {
+			continue
+		}
+
+		expired[cert] = true
+		if !c.expired[cert] && c.FieldLogger != nil {
+			c.WithField("namespace", cert.meta.Namespace).
+				WithField("name", cert.meta.Name).
+				WithField("fqdn", cert.meta.FQDN).
+				WithField("expired", cert.notAfter.UTC().Format(time.RFC3339)).
+				Error("serving expired TLS certificate")
+		}
+	}
+	c.expired = expired
+
+	if c.Metrics != nil {
+		c.Metrics.SetCertificateExpiryMetric(expiry)
+	}
+}
+
+// certNotAfter returns the expiry of the secret's certificate chain,
+// or the zero time if secret is nil.
+func certNotAfter(secret *dag.Secret) time.Time {
+	if secret == nil {
+		return time.Time{}
+	}
+	return secret.NotAfter()
+}
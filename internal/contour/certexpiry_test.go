@@ -0,0 +1,125 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+	"time"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCertificateExpiryObserver(t *testing.T) {
+	proxy := fixture.NewProxy("roots/example").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{
+			Fqdn: "example.com",
+			TLS: &contour_api_v1.TLS{
+				SecretName:                fixture.SecretRootsCert.Name,
+				EnableFallbackCertificate: true,
+			},
+		},
+		Routes: []contour_api_v1.Route{{
+			Services: []contour_api_v1.Service{{
+				Name:      fixture.ServiceRootsKuard.Name,
+				Namespace: fixture.ServiceRootsKuard.Namespace,
+				Port:      8080,
+			}},
+		}},
+	})
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			RootNamespaces: []string{"roots"},
+			FieldLogger:    fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{
+				FallbackCertificate: &types.NamespacedName{
+					Namespace: fixture.SecretRootsFallback.Namespace,
+					Name:      fixture.SecretRootsFallback.Name,
+				},
+			},
+			&dag.ListenerProcessor{},
+		},
+	}
+	for _, o := range []interface{}{proxy, fixture.ServiceRootsKuard, fixture.SecretRootsCert, fixture.SecretRootsFallback} {
+		builder.Source.Insert(o)
+	}
+
+	// The fixture certificate expires at this time.
+	notAfter := time.Date(2029, 12, 2, 1, 34, 33, 0, time.UTC)
+	now := notAfter.Add(-10 * 24 * time.Hour)
+
+	log, hook := test.NewNullLogger()
+	r := prometheus.NewRegistry()
+	observer := &CertificateExpiryObserver{
+		Metrics:     metrics.NewMetrics(r),
+		FieldLogger: log,
+		now:         func() time.Time { return now },
+	}
+
+	observer.OnChange(builder.Build())
+
+	gauge := func(name string) float64 {
+		gathering, err := r.Gather()
+		require.NoError(t, err)
+
+		for _, mf := range gathering {
+			if mf.GetName() != metrics.CertificateExpiryGauge {
+				continue
+			}
+			for _, m := range mf.Metric {
+				for _, l := range m.Label {
+					if l.GetName() == "name" && l.GetValue() == name {
+						return m.GetGauge().GetValue()
+					}
+				}
+			}
+		}
+
+		t.Fatalf("no %s metric for %q", metrics.CertificateExpiryGauge, name)
+		return 0
+	}
+
+	assert.Equal(t, float64(10), gauge(fixture.SecretRootsCert.Name))
+	assert.Equal(t, float64(10), gauge(fixture.SecretRootsFallback.Name))
+	assert.Empty(t, hook.AllEntries())
+
+	// Once expired, the certificates that are still served are logged.
+	now = notAfter.Add(12 * time.Hour)
+	observer.update()
+
+	assert.Equal(t, -0.5, gauge(fixture.SecretRootsCert.Name))
+	require.Len(t, hook.AllEntries(), 2)
+	for _, e := range hook.AllEntries() {
+		assert.Equal(t, logrus.ErrorLevel, e.Level)
+		assert.Equal(t, "example.com", e.Data["fqdn"])
+	}
+
+	// Certificates are only logged when they expire, not on
+	// every update or rebuild.
+	hook.Reset()
+	observer.update()
+	observer.OnChange(builder.Build())
+	assert.Empty(t, hook.AllEntries())
+}
@@ -26,6 +26,8 @@ import (
 	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
 )
 
 // EventHandler implements cache.ResourceEventHandler, filters k8s events towards
@@ -39,6 +41,10 @@ type EventHandler struct {
 
 	StatusUpdater k8s.StatusUpdater
 
+	// Propagation, if not nil, is told which changes are included
+	// in each DAG rebuild.
+	Propagation *PropagationTracker
+
 	logrus.FieldLogger
 
 	// IsLeader will become ready to read when this EventHandler becomes
@@ -48,6 +54,9 @@ type EventHandler struct {
 
 	update chan interface{}
 
+	// rebuild holds a pending request from Rebuild.
+	rebuild chan struct{}
+
 	// Sequence is a channel that receives a incrementing sequence number
 	// for each update processed. The updates may be processed immediately, or
 	// delayed by a holdoff timer. In each case a non blocking send to Sequence
@@ -61,27 +70,30 @@ type EventHandler struct {
 }
 
 type opAdd struct {
-	obj interface{}
+	obj      interface{}
+	received time.Time
 }
 
 type opUpdate struct {
 	oldObj, newObj interface{}
+	received       time.Time
 }
 
 type opDelete struct {
-	obj interface{}
+	obj      interface{}
+	received time.Time
 }
 
 func (e *EventHandler) OnAdd(obj interface{}) {
-	e.update <- opAdd{obj: obj}
+	e.update <- opAdd{obj: obj, received: time.Now()}
 }
 
 func (e *EventHandler) OnUpdate(oldObj, newObj interface{}) {
-	e.update <- opUpdate{oldObj: oldObj, newObj: newObj}
+	e.update <- opUpdate{oldObj: oldObj, newObj: newObj, received: time.Now()}
 }
 
 func (e *EventHandler) OnDelete(obj interface{}) {
-	e.update <- opDelete{obj: obj}
+	e.update <- opDelete{obj: obj, received: time.Now()}
 }
 
 // UpdateNow enqueues a DAG update subject to the holdoff timer.
@@ -89,10 +101,22 @@ func (e *EventHandler) UpdateNow() {
 	e.update <- true
 }
 
+// Rebuild requests a DAG update subject to the holdoff timer. Unlike
+// UpdateNow, Rebuild never blocks: a request made while another is
+// pending is coalesced with it, and requests made while the
+// EventHandler is not running are dropped.
+func (e *EventHandler) Rebuild() {
+	select {
+	case e.rebuild <- struct{}{}:
+	default:
+	}
+}
+
 // Start initializes the EventHandler and returns a function suitable
 // for registration with a workgroup.Group.
 func (e *EventHandler) Start() func(<-chan struct{}) error {
 	e.update = make(chan interface{})
+	e.rebuild = make(chan struct{}, 1)
 	return e.run
 }
 
@@ -112,6 +136,10 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 		// pending is a reference to the current timer's channel.
 		pending <-chan time.Time
 
+		// events holds the changes received but not yet included
+		// in a DAG rebuild.
+		events []PropagationEvent
+
 		// lastDAGRebuild holds the last time rebuildDAG was called.
 		// lastDAGRebuild is seeded to the current time on entry to
 		// run to allow the holdoff timer to batch the updates from
@@ -124,6 +152,34 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 		return
 	}
 
+	// enqueue processes an update and, if it changed the cache,
+	// schedules a DAG rebuild.
+	enqueue := func(op interface{}) {
+		if e.onUpdate(op) {
+			outstanding++
+			if ev, ok := propagationEventOf(op); ok {
+				events = append(events, ev)
+			}
+			// If there is already a timer running, stop it.
+			if timer != nil {
+				timer.Stop()
+			}
+
+			delay := e.HoldoffDelay
+			if time.Since(lastDAGRebuild) > e.HoldoffMaxDelay {
+				// the maximum holdoff delay has been exceeded so schedule the update
+				// immediately by delaying for 0ns.
+				delay = 0
+			}
+			timer = time.NewTimer(delay)
+			pending = timer.C
+		} else {
+			// notify any watchers that we received the event but chose
+			// not to process it.
+			e.incSequence()
+		}
+	}
+
 	for {
 		// In the main loop one of four things can happen.
 		// 1. We're waiting for an event on op, stop, or pending, noting that
@@ -136,29 +192,13 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 		// Only one of these things can happen at a time.
 		select {
 		case op := <-e.update:
-			if e.onUpdate(op) {
-				outstanding++
-				// If there is already a timer running, stop it.
-				if timer != nil {
-					timer.Stop()
-				}
-
-				delay := e.HoldoffDelay
-				if time.Since(lastDAGRebuild) > e.HoldoffMaxDelay {
-					// the maximum holdoff delay has been exceeded so schedule the update
-					// immediately by delaying for 0ns.
-					delay = 0
-				}
-				timer = time.NewTimer(delay)
-				pending = timer.C
-			} else {
-				// notify any watchers that we received the event but chose
-				// not to process it.
-				e.incSequence()
-			}
+			enqueue(op)
+		case <-e.rebuild:
+			enqueue(true)
 		case <-pending:
 			e.WithField("last_update", time.Since(lastDAGRebuild)).WithField("outstanding", reset()).Info("performing delayed update")
-			e.rebuildDAG()
+			e.rebuildDAG(events)
+			events = nil
 			e.incSequence()
 			lastDAGRebuild = time.Now()
 		case <-stop:
@@ -207,10 +247,46 @@ func (e *EventHandler) incSequence() {
 	}
 }
 
-// rebuildDAG builds a new DAG and sends it to the Observer,
-// the updates the status on objects, and updates the metrics.
-func (e *EventHandler) rebuildDAG() {
+// propagationEventOf returns the kind and receive time of a change
+// to a Kubernetes object.
+func propagationEventOf(op interface{}) (PropagationEvent, bool) {
+	var (
+		obj      interface{}
+		received time.Time
+	)
+
+	switch op := op.(type) {
+	case opAdd:
+		obj, received = op.obj, op.received
+	case opUpdate:
+		obj, received = op.newObj, op.received
+	case opDelete:
+		obj, received = op.obj, op.received
+		if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = d.Obj
+		}
+	default:
+		return PropagationEvent{}, false
+	}
+
+	kind := "unknown"
+	if _, ok := obj.(runtime.Object); ok {
+		if k := k8s.KindOf(obj); k != "" {
+			kind = k
+		}
+	}
+
+	return PropagationEvent{Kind: kind, Received: received}, true
+}
+
+// rebuildDAG builds a new DAG that includes the given changes and
+// sends it to the Observer, the updates the status on objects, and
+// updates the metrics.
+func (e *EventHandler) rebuildDAG(events []PropagationEvent) {
 	latestDAG := e.Builder.Build()
+	if e.Propagation != nil {
+		e.Propagation.Rebuilt(events)
+	}
 	e.Observer.OnChange(latestDAG)
 
 	for _, upd := range latestDAG.StatusCache.GetStatusUpdates() {
@@ -0,0 +1,337 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/sirupsen/logrus"
+)
+
+// NackTracker tracks the xDS responses that Envoy nodes rejected.
+//
+// NackTracker is an xds.NackRecorder that receives the ACKs and
+// NACKs of each node, a dag.Observer that keeps the last DAG so
+// rejections can be traced back to the virtual hosts that produced
+// them, and a dag.ConfigRejections that reports those virtual hosts
+// on the HTTPProxy status.
+type NackTracker struct {
+	// Metrics to emit. If nil, no metrics are emitted.
+	Metrics *metrics.Metrics
+
+	logrus.FieldLogger
+
+	// Rebuild is called when the set of rejected virtual hosts
+	// changes, so that their status can be updated. If nil, the
+	// status is updated by the next DAG rebuild. Rebuild is called
+	// with the tracker's lock held, so it must not block or call
+	// back into the tracker.
+	Rebuild func()
+
+	mu  sync.Mutex
+	dag *dag.DAG
+
+	// nodes holds the rejection of the last response sent to each
+	// connected node, by type URL. A nil entry means that the last
+	// response was accepted.
+	nodes map[metrics.XDSMeta]*rejection
+
+	// rejected holds the reason for each rejected virtual host.
+	rejected map[string]string
+}
+
+// rejection is a NACK and the virtual hosts it was traced to.
+type rejection struct {
+	nack  xds.Nack
+	fqdns []string
+}
+
+// OnChange implements dag.Observer.
+func (t *NackTracker) OnChange(d *dag.DAG) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.dag = d
+}
+
+// Ack implements xds.NackRecorder.
+func (t *NackTracker) Ack(nodeID, typeURL string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.init()
+	t.nodes[metrics.XDSMeta{NodeID: nodeID, TypeURL: typeURL}] = nil
+	t.update()
+}
+
+// Nack implements xds.NackRecorder.
+func (t *NackTracker) Nack(n xds.Nack) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	meta := metrics.XDSMeta{NodeID: n.NodeID, TypeURL: n.TypeURL}
+	r := &rejection{
+		nack:  n,
+		fqdns: traceNack(t.dag, n.Message),
+	}
+
+	if t.FieldLogger != nil {
+		t.WithField("node_id", n.NodeID).
+			WithField("type_url", n.TypeURL).
+			WithField("response_nonce", n.ResponseNonce).
+			WithField("version_info", n.VersionInfo).
+			WithField("fqdns", r.fqdns).
+			WithField("code", n.Code).
+			Error("Envoy rejected xDS response: ", n.Message)
+	}
+
+	if t.Metrics != nil {
+		t.Metrics.IncXDSNack(meta)
+	}
+
+	t.init()
+	t.nodes[meta] = r
+	t.update()
+}
+
+// Disconnect implements xds.NackRecorder.
+func (t *NackTracker) Disconnect(nodeID, typeURL string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.init()
+	delete(t.nodes, metrics.XDSMeta{NodeID: nodeID, TypeURL: typeURL})
+	t.update()
+}
+
+// RejectedVirtualHost implements dag.ConfigRejections.
+func (t *NackTracker) RejectedVirtualHost(fqdn string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reason, ok := t.rejected[fqdn]
+	return reason, ok
+}
+
+// Nacks returns the current rejection of each node and type URL,
+// ordered by node ID and type URL.
+func (t *NackTracker) Nacks() []xds.Nack {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	nacks := []xds.Nack{}
+	for _, r := range t.nodes {
+		if r != nil {
+			nacks = append(nacks, r.nack)
+		}
+	}
+
+	sort.Slice(nacks, func(i, j int) bool {
+		if nacks[i].NodeID != nacks[j].NodeID {
+			return nacks[i].NodeID < nacks[j].NodeID
+		}
+		return nacks[i].TypeURL < nacks[j].TypeURL
+	})
+
+	return nacks
+}
+
+func (t *NackTracker) init() {
+	if t.nodes == nil {
+		t.nodes = map[metrics.XDSMeta]*rejection{}
+	}
+}
+
+// update recalculates the rejected virtual hosts and the metrics
+// from the current state of the nodes. It must be called with t.mu
+// held.
+func (t *NackTracker) update() {
+	rejected := map[string]string{}
+	state := map[metrics.XDSMeta]bool{}
+
+	// Visit the nodes in order so that the reason recorded for a
+	// virtual host rejected by several nodes is stable.
+	metas := make([]metrics.XDSMeta, 0, len(t.nodes))
+	for meta := range t.nodes {
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].NodeID != metas[j].NodeID {
+			return metas[i].NodeID < metas[j].NodeID
+		}
+		return metas[i].TypeURL < metas[j].TypeURL
+	})
+
+	for _, meta := range metas {
+		r := t.nodes[meta]
+		state[meta] = r != nil
+		if r == nil {
+			continue
+		}
+
+		for _, fqdn := range r.fqdns {
+			if _, ok := rejected[fqdn]; !ok {
+				rejected[fqdn] = fmt.Sprintf("node %q rejected %s configuration: %s",
+					meta.NodeID, path.Base(meta.TypeURL), r.nack.Message)
+			}
+		}
+	}
+
+	if t.Metrics != nil {
+		t.Metrics.SetXDSRejectedMetric(state)
+	}
+
+	if sameRejections(t.rejected, rejected) {
+		return
+	}
+	t.rejected = rejected
+
+	if t.Rebuild != nil {
+		t.Rebuild()
+	}
+}
+
+func sameRejections(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+// traceNack returns the names of the virtual hosts in d whose
+// configuration is referenced by the Envoy rejection message. A
+// virtual host is referenced if the message contains its name, or
+// the name of a cluster, secret or path regex that it uses.
+func traceNack(d *dag.DAG, message string) []string {
+	if d == nil || message == "" {
+		return nil
+	}
+
+	found := map[string]bool{}
+
+	d.Visit(func(v dag.Vertex) {
+		listener, ok := v.(*dag.Listener)
+		if !ok {
+			return
+		}
+
+		listener.Visit(func(v dag.Vertex) {
+			var name string
+			switch vh := v.(type) {
+			case *dag.VirtualHost:
+				name = vh.Name
+			case *dag.SecureVirtualHost:
+				name = vh.Name
+			default:
+				return
+			}
+
+			if found[name] {
+				return
+			}
+
+			if containsHostname(message, name) || referencesVertex(v, message) {
+				found[name] = true
+			}
+		})
+	})
+
+	fqdns := make([]string, 0, len(found))
+	for fqdn := range found {
+		fqdns = append(fqdns, fqdn)
+	}
+	sort.Strings(fqdns)
+	return fqdns
+}
+
+// referencesVertex returns true if the message contains the name of
+// a cluster, secret or path regex beneath v.
+func referencesVertex(v dag.Vertex, message string) bool {
+	referenced := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if referenced {
+			return
+		}
+
+		switch v := v.(type) {
+		case *dag.Route:
+			if re, ok := v.PathMatchCondition.(*dag.RegexMatchCondition); ok && strings.Contains(message, re.Regex) {
+				referenced = true
+				return
+			}
+		case *dag.Cluster:
+			if strings.Contains(message, envoy.Clustername(v)) {
+				referenced = true
+				return
+			}
+		case *dag.Secret:
+			if strings.Contains(message, envoy.Secretname(v)) {
+				referenced = true
+				return
+			}
+		}
+
+		v.Visit(visit)
+	}
+
+	v.Visit(visit)
+	return referenced
+}
+
+// containsHostname returns true if message contains hostname, and
+// the match is not part of a longer hostname.
+func containsHostname(message, hostname string) bool {
+	isHostnameChar := func(c byte) bool {
+		return c == '.' || c == '-' || c == '_' ||
+			('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+	}
+
+	for offset := 0; ; {
+		i := strings.Index(message[offset:], hostname)
+		if i < 0 {
+			return false
+		}
+
+		start := offset + i
+		end := start + len(hostname)
+
+		// Allow the hostname to end a sentence.
+		after := end
+		if after < len(message) && message[after] == '.' {
+			after++
+		}
+
+		if (start == 0 || !isHostnameChar(message[start-1])) &&
+			(after == len(message) || !isHostnameChar(message[after])) {
+			return true
+		}
+
+		offset = start + 1
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNackTracker(t *testing.T) {
+	const listenerType = "type.googleapis.com/envoy.config.listener.v3.Listener"
+
+	proxy := func(name, fqdn, prefix string) *contour_api_v1.HTTPProxy {
+		return fixture.NewProxy(name).WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: fqdn},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: prefix}},
+				Services: []contour_api_v1.Service{{
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+				}},
+			}},
+		})
+	}
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			RootNamespaces: []string{"roots"},
+			FieldLogger:    fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{},
+		},
+	}
+	for _, o := range []interface{}{
+		proxy("roots/example", "example.com", "/"),
+		proxy("roots/www", "www.example.com", "/"),
+		fixture.ServiceRootsKuard,
+	} {
+		builder.Source.Insert(o)
+	}
+
+	log, hook := test.NewNullLogger()
+	r := prometheus.NewRegistry()
+	rebuilds := make(chan struct{}, 10)
+	tracker := &NackTracker{
+		Metrics:     metrics.NewMetrics(r),
+		FieldLogger: log,
+		Rebuild:     func() { rebuilds <- struct{}{} },
+	}
+	tracker.OnChange(builder.Build())
+
+	gauge := func(nodeID string) float64 {
+		gathering, err := r.Gather()
+		require.NoError(t, err)
+
+		for _, mf := range gathering {
+			if mf.GetName() != metrics.XDSRejectedGauge {
+				continue
+			}
+			for _, m := range mf.Metric {
+				for _, l := range m.Label {
+					if l.GetName() == "node_id" && l.GetValue() == nodeID {
+						return m.GetGauge().GetValue()
+					}
+				}
+			}
+		}
+		return -1
+	}
+
+	tracker.Ack("envoy-1", listenerType)
+	assert.Equal(t, float64(0), gauge("envoy-1"))
+	assert.Empty(t, tracker.Nacks())
+
+	// The rejection is traced to example.com, but not www.example.com.
+	nack := xds.Nack{
+		NodeID:  "envoy-1",
+		TypeURL: listenerType,
+		Message: "duplicate domain example.com.",
+	}
+	tracker.Nack(nack)
+	<-rebuilds
+
+	assert.Equal(t, float64(1), gauge("envoy-1"))
+	assert.Equal(t, []xds.Nack{nack}, tracker.Nacks())
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, []string{"example.com"}, hook.LastEntry().Data["fqdns"])
+
+	reason, ok := tracker.RejectedVirtualHost("example.com")
+	assert.True(t, ok)
+	assert.Equal(t, `node "envoy-1" rejected envoy.config.listener.v3.Listener configuration: duplicate domain example.com.`, reason)
+	_, ok = tracker.RejectedVirtualHost("www.example.com")
+	assert.False(t, ok)
+
+	// Accepting a later response clears the rejection.
+	tracker.Ack("envoy-1", listenerType)
+	<-rebuilds
+
+	assert.Equal(t, float64(0), gauge("envoy-1"))
+	assert.Empty(t, tracker.Nacks())
+	_, ok = tracker.RejectedVirtualHost("example.com")
+	assert.False(t, ok)
+
+	// Disconnecting a node removes its rejections and metrics.
+	tracker.Nack(nack)
+	<-rebuilds
+	tracker.Disconnect("envoy-1", listenerType)
+	<-rebuilds
+
+	assert.Equal(t, float64(-1), gauge("envoy-1"))
+	_, ok = tracker.RejectedVirtualHost("example.com")
+	assert.False(t, ok)
+	assert.Empty(t, rebuilds)
+}
+
+func TestContainsHostname(t *testing.T) {
+	tests := map[string]struct {
+		message string
+		want    bool
+	}{
+		"exact":          {message: "example.com", want: true},
+		"in sentence":    {message: "domain example.com is invalid", want: true},
+		"end sentence":   {message: "invalid domain example.com.", want: true},
+		"quoted":         {message: `invalid domain "example.com"`, want: true},
+		"subdomain":      {message: "invalid domain www.example.com", want: false},
+		"longer":         {message: "invalid domain example.com.au", want: false},
+		"later instance": {message: "www.example.com and example.com", want: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, containsHostname(tc.message, "example.com"))
+		})
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	envoy_config_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/projectcontour/contour/internal/xds"
+)
+
+// NodeTracker is an xds.NodeRecorder that keeps an inventory of the
+// connected Envoy nodes, and whether each node accepted the last
+// response it was sent for each resource type.
+type NodeTracker struct {
+	// Metrics to emit. If nil, no metrics are emitted.
+	Metrics *metrics.Metrics
+
+	// now returns the current time. If nil, time.Now is used.
+	now func() time.Time
+
+	mu      sync.Mutex
+	streams map[uint64]*nodeStream
+
+	// connected counts the open streams of each node ID, and
+	// pending counts the resource types of each node ID whose last
+	// response has not been accepted.
+	connected map[string]int
+	pending   map[string]int
+}
+
+// nodeStream is an xDS stream of an Envoy node.
+type nodeStream struct {
+	node      *envoy_config_v3.Node
+	start     time.Time
+	resources map[string]*xds.ResourceStatus
+}
+
+// Connect implements xds.NodeRecorder.
+func (t *NodeTracker) Connect(stream uint64, node *envoy_config_v3.Node) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.init()
+
+	if _, ok := t.streams[stream]; ok {
+		return
+	}
+
+	t.streams[stream] = &nodeStream{
+		node:      node,
+		start:     t.time(),
+		resources: map[string]*xds.ResourceStatus{},
+	}
+	t.connected[node.GetId()]++
+	t.update()
+}
+
+// Sent implements xds.NodeRecorder.
+func (t *NodeTracker) Sent(stream uint64, typeURL, version, nonce string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.streams[stream]
+	if !ok {
+		return
+	}
+
+	r, ok := s.resources[typeURL]
+	if !ok {
+		r = &xds.ResourceStatus{
+			TypeURL:     typeURL,
+			StreamStart: s.start,
+			InSync:      true,
+		}
+		s.resources[typeURL] = r
+	}
+
+	r.SentVersion = version
+	r.SentNonce = nonce
+	t.setInSync(s, r, r.AckedNonce == nonce)
+}
+
+// Acked implements xds.NodeRecorder.
+func (t *NodeTracker) Acked(stream uint64, typeURL, version, nonce string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.streams[stream]
+	if !ok {
+		return
+	}
+
+	r, ok := s.resources[typeURL]
+	if !ok {
+		return
+	}
+
+	r.AckedVersion = version
+	r.AckedNonce = nonce
+	t.setInSync(s, r, r.SentNonce == nonce)
+}
+
+// Disconnect implements xds.NodeRecorder.
+func (t *NodeTracker) Disconnect(stream uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.streams[stream]
+	if !ok {
+		return
+	}
+
+	for _, r := range s.resources {
+		t.setInSync(s, r, true)
+	}
+
+	id := s.node.GetId()
+	if t.connected[id]--; t.connected[id] <= 0 {
+		delete(t.connected, id)
+	}
+	delete(t.streams, stream)
+	t.update()
+}
+
+// Nodes returns the status of each connected Envoy node, ordered by
+// node ID. The status of a resource type sent on several streams of
+// a node is the status of the newest stream.
+func (t *NodeTracker) Nodes() []xds.NodeStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Visit the streams from oldest to newest, so that the newest
+	// stream of a resource type wins.
+	streams := make([]*nodeStream, 0, len(t.streams))
+	for _, s := range t.streams {
+		streams = append(streams, s)
+	}
+	sort.SliceStable(streams, func(i, j int) bool {
+		return streams[i].start.Before(streams[j].start)
+	})
+
+	nodes := map[string]*xds.NodeStatus{}
+	resources := map[string]map[string]xds.ResourceStatus{}
+
+	for _, s := range streams {
+		id := s.node.GetId()
+
+		n, ok := nodes[id]
+		if !ok {
+			n = nodeStatus(s.node)
+			n.Connected = s.start
+			nodes[id] = n
+			resources[id] = map[string]xds.ResourceStatus{}
+		}
+
+		// The details of the newest stream are the most current.
+		n.Cluster = s.node.GetCluster()
+		n.BuildVersion = buildVersion(s.node)
+		n.Group = xds.GroupOf(s.node)
+
+		for typeURL, r := range s.resources {
+			resources[id][typeURL] = *r
+		}
+	}
+
+	status := []xds.NodeStatus{}
+	for id, n := range nodes {
+		n.InSync = true
+		n.Resources = []xds.ResourceStatus{}
+		for _, r := range resources[id] {
+			n.Resources = append(n.Resources, r)
+			n.InSync = n.InSync && r.InSync
+		}
+		sort.Slice(n.Resources, func(i, j int) bool {
+			return n.Resources[i].TypeURL < n.Resources[j].TypeURL
+		})
+
+		status = append(status, *n)
+	}
+
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].ID < status[j].ID
+	})
+
+	return status
+}
+
+func (t *NodeTracker) init() {
+	if t.streams == nil {
+		t.streams = map[uint64]*nodeStream{}
+		t.connected = map[string]int{}
+		t.pending = map[string]int{}
+	}
+}
+
+func (t *NodeTracker) time() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// setInSync records whether the node on s accepted the last response
+// of r, and updates the metrics if that changed. It must be called
+// with t.mu held.
+func (t *NodeTracker) setInSync(s *nodeStream, r *xds.ResourceStatus, inSync bool) {
+	if r.InSync == inSync {
+		return
+	}
+	r.InSync = inSync
+
+	id := s.node.GetId()
+	switch inSync {
+	case true:
+		if t.pending[id]--; t.pending[id] <= 0 {
+			delete(t.pending, id)
+		}
+	default:
+		t.pending[id]++
+	}
+
+	t.update()
+}
+
+// update sets the node metrics. It must be called with t.mu held.
+func (t *NodeTracker) update() {
+	if t.Metrics != nil {
+		t.Metrics.SetXDSNodeMetric(len(t.connected), len(t.pending))
+	}
+}
+
+// nodeStatus returns the details of an Envoy node.
+func nodeStatus(node *envoy_config_v3.Node) *xds.NodeStatus {
+	n := &xds.NodeStatus{
+		ID: node.GetId(),
+	}
+
+	if l := node.GetLocality(); l != nil {
+		n.Locality = &xds.Locality{
+			Region:  l.GetRegion(),
+			Zone:    l.GetZone(),
+			SubZone: l.GetSubZone(),
+		}
+	}
+
+	return n
+}
+
+// buildVersion returns the Envoy version of the node.
+func buildVersion(node *envoy_config_v3.Node) string {
+	if bv := node.GetUserAgentBuildVersion(); bv != nil && bv.Version != nil {
+		return fmt.Sprintf("v%d.%d.%d", bv.Version.MajorNumber, bv.Version.MinorNumber, bv.Version.Patch)
+	}
+	return node.GetUserAgentVersion()
+}
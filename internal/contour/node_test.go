@@ -0,0 +1,134 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+	"time"
+
+	envoy_config_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeTracker(t *testing.T) {
+	const (
+		clusterType  = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+		listenerType = "type.googleapis.com/envoy.config.listener.v3.Listener"
+	)
+
+	start := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	r := prometheus.NewRegistry()
+	tracker := &NodeTracker{
+		Metrics: metrics.NewMetrics(r),
+		now:     func() time.Time { return now },
+	}
+
+	gauge := func(name string) float64 {
+		gathering, err := r.Gather()
+		require.NoError(t, err)
+
+		for _, mf := range gathering {
+			if mf.GetName() == name {
+				return mf.Metric[0].GetGauge().GetValue()
+			}
+		}
+		return -1
+	}
+
+	node := &envoy_config_v3.Node{
+		Id:      "envoy-1",
+		Cluster: "contour",
+		UserAgentVersionType: &envoy_config_v3.Node_UserAgentBuildVersion{
+			UserAgentBuildVersion: &envoy_config_v3.BuildVersion{
+				Version: &envoy_type_v3.SemanticVersion{MajorNumber: 1, MinorNumber: 17, Patch: 1},
+			},
+		},
+		Locality: &envoy_config_v3.Locality{Region: "us-east-1", Zone: "us-east-1a"},
+	}
+
+	assert.Empty(t, tracker.Nodes())
+
+	tracker.Connect(1, node)
+	tracker.Sent(1, listenerType, "1", "1")
+	tracker.Sent(1, clusterType, "1", "2")
+
+	assert.Equal(t, float64(1), gauge(metrics.XDSConnectedGauge))
+	assert.Equal(t, float64(1), gauge(metrics.XDSOutOfSyncGauge))
+
+	tracker.Acked(1, listenerType, "1", "1")
+	assert.Equal(t, []xds.NodeStatus{{
+		ID:           "envoy-1",
+		Cluster:      "contour",
+		BuildVersion: "v1.17.1",
+		Locality:     &xds.Locality{Region: "us-east-1", Zone: "us-east-1a"},
+		Connected:    start,
+		InSync:       false,
+		Resources: []xds.ResourceStatus{{
+			TypeURL:     clusterType,
+			StreamStart: start,
+			SentVersion: "1",
+			SentNonce:   "2",
+		}, {
+			TypeURL:      listenerType,
+			StreamStart:  start,
+			SentVersion:  "1",
+			SentNonce:    "1",
+			AckedVersion: "1",
+			AckedNonce:   "1",
+			InSync:       true,
+		}},
+	}}, tracker.Nodes())
+
+	// Accepting the last cluster response brings the node in sync.
+	tracker.Acked(1, clusterType, "1", "2")
+	assert.Equal(t, float64(0), gauge(metrics.XDSOutOfSyncGauge))
+	assert.True(t, tracker.Nodes()[0].InSync)
+
+	// A second stream of the same node counts once, and the newest
+	// stream of a resource type is reported.
+	now = start.Add(time.Minute)
+	tracker.Connect(2, node)
+	tracker.Sent(2, clusterType, "2", "1")
+	assert.Equal(t, float64(1), gauge(metrics.XDSConnectedGauge))
+	assert.Equal(t, float64(1), gauge(metrics.XDSOutOfSyncGauge))
+
+	nodes := tracker.Nodes()
+	require.Len(t, nodes, 1)
+	assert.Equal(t, start, nodes[0].Connected)
+	assert.False(t, nodes[0].InSync)
+	assert.Equal(t, "2", nodes[0].Resources[0].SentVersion)
+	assert.Equal(t, start.Add(time.Minute), nodes[0].Resources[0].StreamStart)
+
+	// Closing the out of sync stream leaves the node in sync.
+	tracker.Disconnect(2)
+	assert.Equal(t, float64(1), gauge(metrics.XDSConnectedGauge))
+	assert.Equal(t, float64(0), gauge(metrics.XDSOutOfSyncGauge))
+
+	tracker.Disconnect(1)
+	assert.Equal(t, float64(0), gauge(metrics.XDSConnectedGauge))
+	assert.Empty(t, tracker.Nodes())
+
+	// Unknown streams are ignored.
+	tracker.Sent(3, clusterType, "1", "1")
+	tracker.Acked(3, clusterType, "1", "1")
+	tracker.Disconnect(3)
+	assert.Empty(t, tracker.Nodes())
+}
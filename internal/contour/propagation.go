@@ -0,0 +1,270 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	envoy_config_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/projectcontour/contour/internal/metrics"
+)
+
+// PropagationEvent is a change to a Kubernetes object that was
+// received by the EventHandler.
+type PropagationEvent struct {
+	// Kind is the kind of the object that changed.
+	Kind string
+
+	// Received is when the change was received.
+	Received time.Time
+}
+
+// PropagationTracker measures how long a change to a Kubernetes
+// object takes to reach Envoy. It records the time from receiving
+// the change to rebuilding the DAG, from rebuilding the DAG to sending
+// the resulting xDS responses, and from sending each response to the
+// Envoy node accepting it.
+//
+// A response is only attributed to a change if the change altered
+// the contents of the response's resource type. Changes to Endpoints
+// are translated without a DAG rebuild, so for them the rebuild is
+// the translation of the Endpoints into cluster load assignments.
+type PropagationTracker struct {
+	// Metrics to emit. If nil, no metrics are emitted.
+	Metrics *metrics.Metrics
+
+	// now returns the current time. If nil, time.Now is used.
+	now func() time.Time
+
+	mu sync.Mutex
+
+	// rebuilt and kinds are the time and changed object kinds of
+	// the latest DAG rebuild.
+	rebuilt time.Time
+	kinds   []string
+
+	// generation counts the changes to resource types, and types
+	// holds the latest change to each resource type.
+	generation uint64
+	types      map[string]*propagationChange
+
+	// streams holds the state of each resource type of each
+	// connected xDS stream.
+	streams map[uint64]map[string]*propagationState
+}
+
+// propagationChange is a change to the contents of a resource type.
+type propagationChange struct {
+	generation uint64
+
+	// kinds are the changed object kinds, and built is when the
+	// change was built.
+	kinds []string
+	built time.Time
+}
+
+// propagationState is the propagation state of a resource type on
+// an xDS stream.
+type propagationState struct {
+	// generation is the change the last response was attributed to.
+	generation uint64
+
+	// kinds are the changed object kinds that were sent but not yet
+	// accepted, sent is when the first of them was sent, and nonce
+	// and last are the nonce and send time of the last response.
+	kinds map[string]bool
+	sent  time.Time
+	nonce string
+	last  time.Time
+}
+
+// Rebuilt records that the DAG was rebuilt to include the given
+// changes. The resource types whose contents changed in the rebuild
+// are recorded with Changed.
+func (p *PropagationTracker) Rebuilt(events []PropagationEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rebuilt = p.time()
+	p.kinds = p.observeRebuild(events)
+}
+
+// Changed records that the contents of the given resource types
+// changed in the latest DAG rebuild.
+func (p *PropagationTracker) Changed(typeURLs ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, typeURL := range typeURLs {
+		p.change(typeURL, p.kinds, p.rebuilt)
+	}
+}
+
+// Updated records that the contents of the given resource type
+// changed to include the given changes without a DAG rebuild.
+func (p *PropagationTracker) Updated(typeURL string, events []PropagationEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.time()
+	p.change(typeURL, p.observeRebuild(events), now)
+}
+
+// observeRebuild records the rebuild latency of the given changes
+// and returns their kinds.
+func (p *PropagationTracker) observeRebuild(events []PropagationEvent) []string {
+	now := p.time()
+
+	kinds := map[string]bool{}
+	for _, e := range events {
+		kinds[e.Kind] = true
+		if p.Metrics != nil {
+			p.Metrics.ObserveRebuildLatency(e.Kind, now.Sub(e.Received))
+		}
+	}
+
+	names := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// change records a change to the contents of a resource type. Streams
+// may already have sent the changed contents while the change was
+// being recorded, so those responses are attributed to it too.
+func (p *PropagationTracker) change(typeURL string, kinds []string, built time.Time) {
+	if len(kinds) == 0 {
+		return
+	}
+
+	p.generation++
+	c := &propagationChange{
+		generation: p.generation,
+		kinds:      kinds,
+		built:      built,
+	}
+
+	if p.types == nil {
+		p.types = map[string]*propagationChange{}
+	}
+	p.types[typeURL] = c
+
+	for _, types := range p.streams {
+		if s, ok := types[typeURL]; ok && !s.last.Before(built) {
+			p.attribute(s, c, s.last)
+		}
+	}
+}
+
+// attribute attributes a response sent at the given time to a change,
+// unless the change was already attributed to an earlier response.
+func (p *PropagationTracker) attribute(s *propagationState, c *propagationChange, sent time.Time) {
+	if s.generation >= c.generation {
+		return
+	}
+	s.generation = c.generation
+
+	for _, kind := range c.kinds {
+		if len(s.kinds) == 0 {
+			s.sent = sent
+		}
+		s.kinds[kind] = true
+
+		if p.Metrics != nil {
+			p.Metrics.ObservePushLatency(kind, sent.Sub(c.built))
+		}
+	}
+}
+
+// Connect implements xds.NodeRecorder.
+func (p *PropagationTracker) Connect(stream uint64, node *envoy_config_v3.Node) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.streams == nil {
+		p.streams = map[uint64]map[string]*propagationState{}
+	}
+	if _, ok := p.streams[stream]; !ok {
+		p.streams[stream] = map[string]*propagationState{}
+	}
+}
+
+// Sent implements xds.NodeRecorder.
+func (p *PropagationTracker) Sent(stream uint64, typeURL, version, nonce string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	types, ok := p.streams[stream]
+	if !ok {
+		return
+	}
+
+	now := p.time()
+
+	s, ok := types[typeURL]
+	if !ok {
+		// The first response of a stream carries the whole
+		// configuration, not a change.
+		s = &propagationState{
+			generation: p.generation,
+			kinds:      map[string]bool{},
+		}
+		types[typeURL] = s
+	} else if c, ok := p.types[typeURL]; ok {
+		p.attribute(s, c, now)
+	}
+
+	// Accepting a later response also accepts the changes
+	// sent before it.
+	s.nonce = nonce
+	s.last = now
+}
+
+// Acked implements xds.NodeRecorder.
+func (p *PropagationTracker) Acked(stream uint64, typeURL, version, nonce string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.streams[stream][typeURL]
+	if !ok || len(s.kinds) == 0 || s.nonce != nonce {
+		return
+	}
+
+	now := p.time()
+	for kind := range s.kinds {
+		if p.Metrics != nil {
+			p.Metrics.ObserveAckLatency(kind, now.Sub(s.sent))
+		}
+		delete(s.kinds, kind)
+	}
+}
+
+// Disconnect implements xds.NodeRecorder.
+func (p *PropagationTracker) Disconnect(stream uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.streams, stream)
+}
+
+func (p *PropagationTracker) time() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,212 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+	"time"
+
+	envoy_config_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPropagationTracker(t *testing.T) {
+	const (
+		clusterType  = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+		endpointType = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
+		listenerType = "type.googleapis.com/envoy.config.listener.v3.Listener"
+	)
+
+	start := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	r := prometheus.NewRegistry()
+	tracker := &PropagationTracker{
+		Metrics: metrics.NewMetrics(r),
+		now:     func() time.Time { return now },
+	}
+
+	// histogram returns the sample count and sum of the named
+	// histogram for the given kind.
+	histogram := func(name, kind string) (uint64, float64) {
+		gathering, err := r.Gather()
+		require.NoError(t, err)
+
+		for _, mf := range gathering {
+			if mf.GetName() != name {
+				continue
+			}
+			for _, m := range mf.Metric {
+				for _, l := range m.Label {
+					if l.GetName() == "kind" && l.GetValue() == kind {
+						return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+					}
+				}
+			}
+		}
+		return 0, 0
+	}
+
+	tracker.Connect(1, &envoy_config_v3.Node{Id: "envoy-1"})
+
+	// The initial configuration is not a change.
+	tracker.Sent(1, listenerType, "1", "1")
+	tracker.Sent(1, clusterType, "1", "2")
+	tracker.Sent(1, endpointType, "1", "3")
+	tracker.Acked(1, listenerType, "1", "1")
+	tracker.Acked(1, clusterType, "1", "2")
+	tracker.Acked(1, endpointType, "1", "3")
+	count, _ := histogram(metrics.PropagationPushHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(0), count)
+
+	// An HTTPProxy and a Service change are batched into one rebuild.
+	now = start.Add(time.Second)
+	tracker.Rebuilt([]PropagationEvent{
+		{Kind: "HTTPProxy", Received: start},
+		{Kind: "HTTPProxy", Received: start.Add(500 * time.Millisecond)},
+		{Kind: "Service", Received: start.Add(500 * time.Millisecond)},
+	})
+	count, sum := histogram(metrics.PropagationRebuildHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(2), count)
+	assert.Equal(t, 1.5, sum)
+
+	// Only the listeners changed in the rebuild.
+	tracker.Changed(listenerType)
+
+	// The listeners are sent, but the clusters did not change.
+	now = start.Add(1200 * time.Millisecond)
+	tracker.Sent(1, listenerType, "2", "4")
+	count, sum = histogram(metrics.PropagationPushHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 0.2, sum, 1e-9)
+
+	// An endpoints push that follows the rebuild is caused by a
+	// change to Endpoints, so it is not attributed to the rebuild.
+	tracker.Updated(endpointType, []PropagationEvent{{Kind: "Endpoints", Received: start.Add(1100 * time.Millisecond)}})
+	now = start.Add(1300 * time.Millisecond)
+	tracker.Sent(1, endpointType, "2", "5")
+	count, _ = histogram(metrics.PropagationPushHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(1), count)
+	count, _ = histogram(metrics.PropagationPushHistogram, "Service")
+	assert.Equal(t, uint64(1), count)
+	count, sum = histogram(metrics.PropagationRebuildHistogram, "Endpoints")
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 0.1, sum, 1e-9)
+	count, sum = histogram(metrics.PropagationPushHistogram, "Endpoints")
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 0.1, sum, 1e-9)
+
+	// A push that is not caused by any change is not attributed.
+	tracker.Sent(1, clusterType, "2", "6")
+	count, _ = histogram(metrics.PropagationPushHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(1), count)
+	count, _ = histogram(metrics.PropagationPushHistogram, "Endpoints")
+	assert.Equal(t, uint64(1), count)
+
+	// Sending the listeners again is not another push of the
+	// same rebuild, but the later response is the one to accept.
+	now = start.Add(1500 * time.Millisecond)
+	tracker.Sent(1, listenerType, "2", "7")
+	tracker.Acked(1, listenerType, "2", "4")
+	count, _ = histogram(metrics.PropagationPushHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(1), count)
+	count, _ = histogram(metrics.PropagationAckHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(0), count)
+
+	now = start.Add(2 * time.Second)
+	tracker.Acked(1, listenerType, "2", "7")
+	count, sum = histogram(metrics.PropagationAckHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 0.8, sum, 1e-9)
+	count, _ = histogram(metrics.PropagationAckHistogram, "Service")
+	assert.Equal(t, uint64(1), count)
+
+	// Accepting the same response again is not counted twice.
+	tracker.Acked(1, listenerType, "2", "7")
+	count, _ = histogram(metrics.PropagationAckHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(1), count)
+
+	// A response sent before the rebuild records its changed
+	// types is attributed to the rebuild when they are recorded.
+	now = start.Add(3 * time.Second)
+	tracker.Rebuilt([]PropagationEvent{{Kind: "Secret", Received: now}})
+	now = start.Add(3100 * time.Millisecond)
+	tracker.Sent(1, clusterType, "3", "8")
+	count, _ = histogram(metrics.PropagationPushHistogram, "Secret")
+	assert.Equal(t, uint64(0), count)
+	now = start.Add(3200 * time.Millisecond)
+	tracker.Changed(clusterType)
+	count, sum = histogram(metrics.PropagationPushHistogram, "Secret")
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 0.1, sum, 1e-9)
+
+	// Responses on unknown streams are ignored.
+	tracker.Disconnect(1)
+	tracker.Sent(1, listenerType, "3", "9")
+	tracker.Acked(1, listenerType, "3", "9")
+	count, _ = histogram(metrics.PropagationAckHistogram, "HTTPProxy")
+	assert.Equal(t, uint64(1), count)
+}
+
+func TestPropagationEventOf(t *testing.T) {
+	received := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		op   interface{}
+		want PropagationEvent
+		ok   bool
+	}{
+		"add": {
+			op:   opAdd{obj: (*v1.Service)(fixture.NewService("default/kuard")), received: received},
+			want: PropagationEvent{Kind: "Service", Received: received},
+			ok:   true,
+		},
+		"update": {
+			op:   opUpdate{newObj: (*contour_api_v1.HTTPProxy)(fixture.NewProxy("default/kuard")), received: received},
+			want: PropagationEvent{Kind: "HTTPProxy", Received: received},
+			ok:   true,
+		},
+		"delete tombstone": {
+			op: opDelete{
+				obj:      cache.DeletedFinalStateUnknown{Key: "default/kuard", Obj: (*v1.Service)(fixture.NewService("default/kuard"))},
+				received: received,
+			},
+			want: PropagationEvent{Kind: "Service", Received: received},
+			ok:   true,
+		},
+		"unknown object": {
+			op:   opAdd{obj: "kuard", received: received},
+			want: PropagationEvent{Kind: "unknown", Received: received},
+			ok:   true,
+		},
+		"rebuild request": {
+			op: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := propagationEventOf(tc.op)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -15,6 +15,7 @@ package dag
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/projectcontour/contour/internal/annotation"
@@ -194,6 +195,69 @@ func (v vhostGetter) visit(vertex Vertex) {
 	}
 }
 
+// GetListenerProxies returns all listener proxies in the DAG,
+// by listener name.
+func (dag *DAG) GetListenerProxies() map[string]*ListenerProxy {
+	getter := listenerProxyGetter(map[string]*ListenerProxy{})
+	dag.Visit(getter.visit)
+	return getter
+}
+
+// EnsureListenerProxy adds a listener proxy for the provided
+// listener to the DAG if it does not already exist, and returns it.
+func (dag *DAG) EnsureListenerProxy(listener string) *ListenerProxy {
+	if lp := dag.GetListenerProxies()[listener]; lp != nil {
+		return lp
+	}
+
+	lp := &ListenerProxy{
+		Listener: listener,
+	}
+	dag.AddRoot(lp)
+	return lp
+}
+
+// listenerProxyGetter is a visitor that gets all listener proxies
+// in the DAG.
+type listenerProxyGetter map[string]*ListenerProxy
+
+func (l listenerProxyGetter) visit(vertex Vertex) {
+	switch obj := vertex.(type) {
+	case *ListenerProxy:
+		l[obj.Listener] = obj
+	default:
+		vertex.Visit(l.visit)
+	}
+}
+
+// GetEnvoyGroups returns the sorted names of the Envoy groups that
+// serve virtual hosts in the DAG.
+func (dag *DAG) GetEnvoyGroups() []string {
+	groups := map[string]bool{}
+	for _, vhost := range dag.GetVirtualHosts() {
+		if vhost.EnvoyGroup != "" {
+			groups[vhost.EnvoyGroup] = true
+		}
+	}
+	for _, svhost := range dag.GetSecureVirtualHosts() {
+		if svhost.EnvoyGroup != "" {
+			groups[svhost.EnvoyGroup] = true
+		}
+	}
+	for _, lp := range dag.GetListenerProxies() {
+		if lp.EnvoyGroup != "" {
+			groups[lp.EnvoyGroup] = true
+		}
+	}
+
+	var names []string
+	for group := range groups {
+		names = append(names, group)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetExtensionClusters returns all extension clusters in the DAG.
 func (dag *DAG) GetExtensionClusters() map[string]*ExtensionCluster {
 	getter := extensionClusterGetter(map[string]*ExtensionCluster{})
@@ -2926,6 +2926,74 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	cookieTTL := time.Hour
+	proxyLoadBalancerHashPolicyCookieAndSourceAddress := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name:      "nginx",
+					Namespace: "default",
+					Port:      80,
+				}},
+				LoadBalancerPolicy: &contour_api_v1.LoadBalancerPolicy{
+					Strategy: "RequestHash",
+					RequestHashPolicies: []contour_api_v1.RequestHashPolicy{
+						{
+							Terminal: true,
+							CookieHashOptions: &contour_api_v1.CookieHashOptions{
+								CookieName: "JSESSIONID",
+							},
+						},
+						{
+							CookieHashOptions: &contour_api_v1.CookieHashOptions{
+								CookieName: "affinity",
+								TTL:        "1h",
+								Path:       "/app",
+							},
+						},
+						{
+							// Duplicated cookie name, should be ignored.
+							CookieHashOptions: &contour_api_v1.CookieHashOptions{
+								CookieName: "affinity",
+							},
+						},
+						{
+							// Invalid TTL, should be ignored.
+							CookieHashOptions: &contour_api_v1.CookieHashOptions{
+								CookieName: "other",
+								TTL:        "forever",
+							},
+						},
+						{
+							// More than one hash option, should be ignored.
+							HashSourceAddress: true,
+							HeaderHashOptions: &contour_api_v1.HeaderHashOptions{
+								HeaderName: "X-Some-Header",
+							},
+						},
+						{
+							HashSourceAddress: true,
+						},
+						{
+							// Duplicated source address, should be ignored.
+							HashSourceAddress: true,
+						},
+					},
+				},
+			}},
+		},
+	}
+
 	// proxy109 has a route that rewrites headers.
 	proxy109 := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -5537,7 +5605,7 @@ func TestDAGInsert(t *testing.T) {
 								{
 									CookieHashOptions: &CookieHashOptions{
 										CookieName: "X-Contour-Session-Affinity",
-										TTL:        time.Duration(0),
+										TTL:        new(time.Duration),
 										Path:       "/",
 									},
 								},
@@ -5579,6 +5647,43 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
+		"insert proxy with load balancer request cookie and source address hash policies": {
+			objs: []interface{}{
+				proxyLoadBalancerHashPolicyCookieAndSourceAddress,
+				s9,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", &Route{
+							PathMatchCondition: prefix("/"),
+							Clusters: []*Cluster{
+								{Upstream: service(s9), LoadBalancerPolicy: "RequestHash"},
+							},
+							RequestHashPolicies: []RequestHashPolicy{
+								{
+									Terminal: true,
+									CookieHashOptions: &CookieHashOptions{
+										CookieName: "JSESSIONID",
+									},
+								},
+								{
+									CookieHashOptions: &CookieHashOptions{
+										CookieName: "affinity",
+										TTL:        &cookieTTL,
+										Path:       "/app",
+									},
+								},
+								{
+									HashSourceAddress: true,
+								},
+							},
+						}),
+					),
+				},
+			),
+		},
 		"insert proxy with all invalid request header hash policies": {
 			objs: []interface{}{
 				proxyLoadBalancerHashPolicyHeaderAllInvalid,
@@ -6754,11 +6859,12 @@ func prefix(prefix string) MatchCondition { return &PrefixMatchCondition{Prefix:
 func regex(regex string) MatchCondition   { return &RegexMatchCondition{Regex: regex} }
 
 func withMirror(r *Route, mirror *Service) *Route {
-	r.MirrorPolicy = &MirrorPolicy{
+	r.MirrorPolicies = append(r.MirrorPolicies, &MirrorPolicy{
 		Cluster: &Cluster{
 			Upstream: mirror,
 		},
-	}
+		PartsPerMillion: MirrorAll,
+	})
 	return r
 
 }
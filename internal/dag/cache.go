@@ -46,9 +46,13 @@ type KubernetesCache struct {
 	// Secrets that are referred from the configuration file.
 	ConfiguredSecretRefs []*types.NamespacedName
 
+	// ConfigMaps that are referred from the configuration file.
+	ConfiguredConfigMapRefs []*types.NamespacedName
+
 	ingresses            map[types.NamespacedName]*v1beta1.Ingress
 	httpproxies          map[types.NamespacedName]*contour_api_v1.HTTPProxy
 	secrets              map[types.NamespacedName]*v1.Secret
+	configmaps           map[types.NamespacedName]*v1.ConfigMap
 	httpproxydelegations map[types.NamespacedName]*contour_api_v1.TLSCertificateDelegation
 	services             map[types.NamespacedName]*v1.Service
 	gateways             map[types.NamespacedName]*serviceapis.Gateway
@@ -67,6 +71,7 @@ func (kc *KubernetesCache) init() {
 	kc.ingresses = make(map[types.NamespacedName]*v1beta1.Ingress)
 	kc.httpproxies = make(map[types.NamespacedName]*contour_api_v1.HTTPProxy)
 	kc.secrets = make(map[types.NamespacedName]*v1.Secret)
+	kc.configmaps = make(map[types.NamespacedName]*v1.ConfigMap)
 	kc.httpproxydelegations = make(map[types.NamespacedName]*contour_api_v1.TLSCertificateDelegation)
 	kc.services = make(map[types.NamespacedName]*v1.Service)
 	kc.gateways = make(map[types.NamespacedName]*serviceapis.Gateway)
@@ -143,6 +148,9 @@ func (kc *KubernetesCache) Insert(obj interface{}) bool {
 
 		kc.secrets[k8s.NamespacedNameOf(obj)] = obj
 		return kc.secretTriggersRebuild(obj)
+	case *v1.ConfigMap:
+		kc.configmaps[k8s.NamespacedNameOf(obj)] = obj
+		return kc.configMapTriggersRebuild(obj)
 	case *v1.Service:
 		kc.services[k8s.NamespacedNameOf(obj)] = obj
 		return kc.serviceTriggersRebuild(obj)
@@ -220,6 +228,11 @@ func (kc *KubernetesCache) remove(obj interface{}) bool {
 		_, ok := kc.secrets[m]
 		delete(kc.secrets, m)
 		return ok
+	case *v1.ConfigMap:
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.configmaps[m]
+		delete(kc.configmaps, m)
+		return ok
 	case *v1.Service:
 		m := k8s.NamespacedNameOf(obj)
 		_, ok := kc.services[m]
@@ -420,6 +433,48 @@ func (kc *KubernetesCache) secretTriggersRebuild(secret *v1.Secret) bool {
 	return false
 }
 
+// configMapTriggersRebuild returns true if this ConfigMap is referenced
+// by the error pages of an HTTPProxy in the same namespace, or by
+// the configuration file. Other ConfigMaps, such as the one used for
+// leader election, change often and do not affect the DAG.
+func (kc *KubernetesCache) configMapTriggersRebuild(configMap *v1.ConfigMap) bool {
+	for _, proxy := range kc.httpproxies {
+		if proxy.Namespace != configMap.Namespace {
+			continue
+		}
+
+		vh := proxy.Spec.VirtualHost
+		if vh == nil || vh.ErrorPagePolicy == nil {
+			continue
+		}
+
+		for _, page := range vh.ErrorPagePolicy.Pages {
+			if page.ConfigMap == configMap.Name {
+				return true
+			}
+		}
+	}
+
+	for _, c := range kc.ConfiguredConfigMapRefs {
+		if c.Namespace == configMap.Namespace && c.Name == configMap.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LookupConfigMap returns a ConfigMap if present or an error if the
+// underlying kubernetes ConfigMap is missing.
+func (kc *KubernetesCache) LookupConfigMap(name types.NamespacedName) (*v1.ConfigMap, error) {
+	cm, ok := kc.configmaps[name]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap not found")
+	}
+
+	return cm, nil
+}
+
 // LookupSecret returns a Secret if present or nil if the underlying kubernetes
 // secret fails validation or is missing.
 func (kc *KubernetesCache) LookupSecret(name types.NamespacedName, validate func(*v1.Secret) error) (*Secret, error) {
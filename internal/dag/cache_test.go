@@ -699,6 +699,64 @@ func TestKubernetesCacheInsert(t *testing.T) {
 			},
 			want: true,
 		},
+		"insert unreferenced configmap": {
+			obj: &v1.ConfigMap{
+				ObjectMeta: fixture.ObjectMeta("default/leader-elect"),
+			},
+			want: false,
+		},
+		"insert configmap that is referred by configuration file": {
+			obj: &v1.ConfigMap{
+				ObjectMeta: fixture.ObjectMeta("default/configMapReferredByConfigFile"),
+			},
+			want: true,
+		},
+		"insert configmap referenced by httpproxy error page": {
+			pre: []interface{}{
+				&contour_api_v1.HTTPProxy{
+					ObjectMeta: fixture.ObjectMeta("default/simple"),
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "example.com",
+							ErrorPagePolicy: &contour_api_v1.ErrorPagePolicy{
+								Pages: []contour_api_v1.ErrorPage{{
+									StatusCodes: []uint32{503},
+									ConfigMap:   "error-pages",
+									Key:         "unavailable.html",
+								}},
+							},
+						},
+					},
+				},
+			},
+			obj: &v1.ConfigMap{
+				ObjectMeta: fixture.ObjectMeta("default/error-pages"),
+			},
+			want: true,
+		},
+		"insert configmap referenced by httpproxy error page in other namespace": {
+			pre: []interface{}{
+				&contour_api_v1.HTTPProxy{
+					ObjectMeta: fixture.ObjectMeta("default/simple"),
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "example.com",
+							ErrorPagePolicy: &contour_api_v1.ErrorPagePolicy{
+								Pages: []contour_api_v1.ErrorPage{{
+									StatusCodes: []uint32{503},
+									ConfigMap:   "error-pages",
+									Key:         "unavailable.html",
+								}},
+							},
+						},
+					},
+				},
+			},
+			obj: &v1.ConfigMap{
+				ObjectMeta: fixture.ObjectMeta("other/error-pages"),
+			},
+			want: false,
+		},
 	}
 
 	for name, tc := range tests {
@@ -706,6 +764,8 @@ func TestKubernetesCacheInsert(t *testing.T) {
 			cache := KubernetesCache{
 				ConfiguredSecretRefs: []*types.NamespacedName{
 					{Name: "secretReferredByConfigFile", Namespace: "default"}},
+				ConfiguredConfigMapRefs: []*types.NamespacedName{
+					{Name: "configMapReferredByConfigFile", Namespace: "default"}},
 				FieldLogger: fixture.NewTestLogger(t),
 			}
 			for _, p := range tc.pre {
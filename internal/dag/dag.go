@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/status"
 	"github.com/projectcontour/contour/internal/timeout"
 	"github.com/projectcontour/contour/internal/xds"
@@ -69,6 +70,34 @@ type DAG struct {
 
 	// roots are the root vertices of this DAG.
 	roots []Vertex
+
+	// routeSources holds the objects each route was computed from.
+	routeSources map[*Route][]RouteSource
+}
+
+// RouteSource is a Kubernetes object that contributed to a Route.
+type RouteSource struct {
+	Kind      string
+	Namespace string
+	Name      string
+
+	// Conditions are the conditions the previous source included
+	// this object with.
+	Conditions []contour_api_v1.MatchCondition
+}
+
+// RouteSources returns the objects the route was computed from. For
+// an HTTPProxy route, they are the chain of included HTTPProxies from
+// the root HTTPProxy to the one that defines the route.
+func (d *DAG) RouteSources(r *Route) []RouteSource {
+	return d.routeSources[r]
+}
+
+func (d *DAG) setRouteSources(r *Route, sources []RouteSource) {
+	if d.routeSources == nil {
+		d.routeSources = map[*Route][]RouteSource{}
+	}
+	d.routeSources[r] = sources
 }
 
 // Visit calls fn on each root of this DAG.
@@ -177,8 +206,8 @@ type Route struct {
 	// Indicates that during forwarding, the matched prefix (or path) should be swapped with this value
 	PrefixRewrite string
 
-	// Mirror Policy defines the mirroring policy for this Route.
-	MirrorPolicy *MirrorPolicy
+	// MirrorPolicies define the mirroring policies for this Route.
+	MirrorPolicies []*MirrorPolicy
 
 	// RequestHeadersPolicy defines how headers are managed during forwarding
 	RequestHeadersPolicy *HeadersPolicy
@@ -192,6 +221,13 @@ type Route struct {
 	// RequestHashPolicies is a list of policies for configuring hashes on
 	// request attributes.
 	RequestHashPolicies []RequestHashPolicy
+
+	// BufferPolicy defines if/how request bodies for the route are buffered.
+	BufferPolicy *BufferPolicy
+
+	// InternalRedirectPolicy defines if/how Envoy follows redirect
+	// responses from the upstream service.
+	InternalRedirectPolicy *InternalRedirectPolicy
 }
 
 // HasPathPrefix returns whether this route has a PrefixPathCondition.
@@ -214,6 +250,32 @@ type TimeoutPolicy struct {
 
 	// IdleTimeout is the timeout applied to idle connections.
 	IdleTimeout timeout.Setting
+
+	// MaxStreamDuration is the maximum duration of a
+	// request on the route.
+	MaxStreamDuration timeout.Setting
+}
+
+// ConnectionPolicy defines how Envoy manages the HTTP
+// connections it opens to an upstream cluster.
+type ConnectionPolicy struct {
+	// IdleTimeout is how long an upstream connection may
+	// be idle before it is closed.
+	IdleTimeout timeout.Setting
+
+	// MaxRequestsPerConnection is the maximum number of
+	// requests sent on an upstream connection. Zero means
+	// no limit.
+	MaxRequestsPerConnection uint32
+
+	// MaxStreamDuration is the maximum duration of a request
+	// to the upstream cluster.
+	MaxStreamDuration timeout.Setting
+
+	// PerConnectionBufferLimitBytes is the soft limit on the
+	// size of each upstream connection's buffers. Zero means
+	// the Envoy default.
+	PerConnectionBufferLimitBytes uint32
 }
 
 // RetryPolicy defines the retry / number / timeout options
@@ -237,8 +299,15 @@ type RetryPolicy struct {
 // MirrorPolicy defines the mirroring policy for a route.
 type MirrorPolicy struct {
 	Cluster *Cluster
+
+	// PartsPerMillion is the fraction of requests that are
+	// mirrored to Cluster, in millionths.
+	PartsPerMillion uint32
 }
 
+// MirrorAll is the MirrorPolicy fraction that mirrors every request.
+const MirrorAll = 1000000
+
 // HeadersPolicy defines how headers are managed during forwarding
 type HeadersPolicy struct {
 	// HostRewrite defines if a host should be rewritten on upstream requests
@@ -248,6 +317,34 @@ type HeadersPolicy struct {
 	Remove []string
 }
 
+// BufferPolicy holds request body buffering parameters.
+type BufferPolicy struct {
+	// MaxRequestBytes is the maximum size of a buffered
+	// request body. Larger requests are rejected.
+	MaxRequestBytes uint32
+}
+
+// InternalRedirectPolicy holds the parameters for following
+// upstream redirects within Envoy.
+type InternalRedirectPolicy struct {
+	// MaxInternalRedirects is the maximum number of redirects
+	// followed for a request. Zero means the Envoy default.
+	MaxInternalRedirects uint32
+
+	// RedirectResponseCodes are the status codes of the
+	// redirects to follow. If empty, only 302 is followed.
+	RedirectResponseCodes []uint32
+
+	// AllowCrossSchemeRedirect allows redirects that change
+	// the scheme of the request.
+	AllowCrossSchemeRedirect bool
+
+	// AllowedHosts are the names of the virtual hosts that
+	// redirects may target. If empty, any virtual host may be
+	// targeted.
+	AllowedHosts []string
+}
+
 // RateLimitPolicy holds rate limiting parameters.
 type RateLimitPolicy struct {
 	Local *LocalRateLimitPolicy
@@ -273,8 +370,9 @@ type CookieHashOptions struct {
 	// CookieName is the name of the header to hash.
 	CookieName string
 
-	// TTL is how long the cookie should be valid for.
-	TTL time.Duration
+	// TTL is how long a generated cookie should be valid for.
+	// If nil, no cookie is generated.
+	TTL *time.Duration
 
 	// Path is the request path the cookie is valid for.
 	Path string
@@ -292,6 +390,9 @@ type RequestHashPolicy struct {
 
 	// CookieHashOptions is set when a cookie hash is desired.
 	CookieHashOptions *CookieHashOptions
+
+	// HashSourceAddress is set when a source IP hash is desired.
+	HashSourceAddress bool
 }
 
 // CORSPolicy allows setting the CORS policy
@@ -351,8 +452,10 @@ func (r *Route) Visit(f func(Vertex)) {
 	}
 	// Allow any mirror clusters to also be visited so that
 	// they are also added to CDS.
-	if r.MirrorPolicy != nil && r.MirrorPolicy.Cluster != nil {
-		f(r.MirrorPolicy.Cluster)
+	for _, mp := range r.MirrorPolicies {
+		if mp.Cluster != nil {
+			f(mp.Cluster)
+		}
 	}
 }
 
@@ -369,9 +472,30 @@ type VirtualHost struct {
 	// are rate limited.
 	RateLimitPolicy *RateLimitPolicy
 
+	// EnvoyGroup is the group of Envoys that serve the virtual host.
+	// If empty, the virtual host is served by all Envoys.
+	EnvoyGroup string
+
+	// Listener is the name of the extra listener that serves the
+	// virtual host. If empty, the virtual host is served by the
+	// default HTTP or HTTPS listener.
+	Listener string
+
+	// PerRequestBufferLimitBytes is the maximum number of bytes
+	// of a request that is buffered. If zero, the listener's
+	// limit applies.
+	PerRequestBufferLimitBytes uint32
+
 	routes map[string]*Route
 }
 
+// ServedBy returns true if the virtual host is served by Envoys in
+// the given group. Envoys that are not in a group have the empty
+// group, and only serve virtual hosts that are served by all Envoys.
+func (v *VirtualHost) ServedBy(group string) bool {
+	return v.EnvoyGroup == "" || v.EnvoyGroup == group
+}
+
 func (v *VirtualHost) addRoute(route *Route) {
 	if v.routes == nil {
 		v.routes = make(map[string]*Route)
@@ -393,6 +517,19 @@ func (v *VirtualHost) Visit(f func(Vertex)) {
 	}
 }
 
+// MaxRequestBytes returns the largest buffer policy request
+// body size of the routes of the virtual host, or zero if no
+// route has a buffer policy.
+func (v *VirtualHost) MaxRequestBytes() uint32 {
+	var max uint32
+	for _, r := range v.routes {
+		if r.BufferPolicy != nil && r.BufferPolicy.MaxRequestBytes > max {
+			max = r.BufferPolicy.MaxRequestBytes
+		}
+	}
+	return max
+}
+
 func (v *VirtualHost) Valid() bool {
 	// A VirtualHost is valid if it has at least one route.
 	return len(v.routes) > 0
@@ -411,6 +548,11 @@ type SecureVirtualHost struct {
 	// FallbackCertificate
 	FallbackCertificate *Secret
 
+	// OCSPStaplePolicy defines how Envoy handles the OCSP
+	// response stapled to this host's certificate. One of
+	// "", "LenientStapling", "StrictStapling" or "MustStaple".
+	OCSPStaplePolicy string
+
 	// Service to TCP proxy all incoming connections.
 	*TCPProxy
 
@@ -431,6 +573,67 @@ type SecureVirtualHost struct {
 	// only reason to set this to `true` is when you are migrating
 	// from internal to external authorization.
 	AuthorizationFailOpen bool
+
+	// HTTP3Port is the UDP port that clients reach the HTTP/3
+	// listener serving this host on, or 0 if the host is not
+	// served over HTTP/3.
+	HTTP3Port int
+
+	// HTTPPolicy overrides the listener's HTTP connection
+	// manager settings for this host. If nil, the listener's
+	// settings apply.
+	HTTPPolicy *HTTPPolicy
+
+	// ErrorPages are the error pages of this host, which are
+	// matched before the error pages of the listener.
+	ErrorPages []*ErrorPage
+}
+
+// ErrorPage is a response that replaces the error responses
+// Envoy generates with one of the given status codes.
+type ErrorPage struct {
+	// StatusCodes are the status codes of the error
+	// responses to replace.
+	StatusCodes []uint32
+
+	// Body is the body of the response.
+	Body string
+
+	// ContentType is the content type of the body. If
+	// empty, Envoy uses "text/plain".
+	ContentType string
+
+	// ResponseStatusCode replaces the status code of the
+	// response if it is not zero.
+	ResponseStatusCode uint32
+}
+
+// HTTPPolicy defines the HTTP connection manager settings
+// of a secure virtual host. Timeouts that use the default
+// leave the listener's setting in place.
+type HTTPPolicy struct {
+	// RequestTimeout is the timeout for the entire request.
+	RequestTimeout timeout.Setting
+
+	// RequestHeadersTimeout is the timeout for receiving
+	// the request headers.
+	RequestHeadersTimeout timeout.Setting
+
+	// StreamIdleTimeout is the timeout for a stream with
+	// no activity.
+	StreamIdleTimeout timeout.Setting
+
+	// MaxConnectionDuration is the maximum lifetime of a
+	// client connection.
+	MaxConnectionDuration timeout.Setting
+
+	// AllowAbsoluteURLs allows HTTP/1 requests with an
+	// absolute URL.
+	AllowAbsoluteURLs bool
+
+	// ProperCaseHeaders writes HTTP/1 header names in
+	// proper case.
+	ProperCaseHeaders bool
 }
 
 func (s *SecureVirtualHost) Visit(f func(Vertex)) {
@@ -462,6 +665,10 @@ type Listener struct {
 	Port int
 
 	VirtualHosts []Vertex
+
+	// ErrorPages are the error pages of the configuration
+	// file, which apply to all the virtual hosts.
+	ErrorPages []*ErrorPage
 }
 
 func (l *Listener) Visit(f func(Vertex)) {
@@ -470,6 +677,37 @@ func (l *Listener) Visit(f func(Vertex)) {
 	}
 }
 
+// A ListenerProxy forwards every connection, or datagram, received
+// by an extra TCP or UDP listener to a TCPProxy. Unlike a
+// SecureVirtualHost it is not selected by SNI, so it needs no TLS
+// and takes the whole listener.
+type ListenerProxy struct {
+	// Listener is the name of the extra listener.
+	Listener string
+
+	// VirtualHost is the fqdn of the root HTTPProxy that
+	// declares the proxy.
+	VirtualHost string
+
+	// EnvoyGroup is the group of Envoys that serve the proxy.
+	// If empty, the proxy is served by all Envoys.
+	EnvoyGroup string
+
+	TCPProxy *TCPProxy
+}
+
+// ServedBy returns true if the proxy is served by Envoys in the
+// given group.
+func (l *ListenerProxy) ServedBy(group string) bool {
+	return l.EnvoyGroup == "" || l.EnvoyGroup == group
+}
+
+func (l *ListenerProxy) Visit(f func(Vertex)) {
+	if l.TCPProxy != nil {
+		f(l.TCPProxy)
+	}
+}
+
 // TCPProxy represents a cluster of TCP endpoints.
 type TCPProxy struct {
 
@@ -586,6 +824,10 @@ type Cluster struct {
 	// ClientCertificate is the optional identifier of the TLS secret containing client certificate and
 	// private key to be used when establishing TLS connection to upstream cluster.
 	ClientCertificate *Secret
+
+	// ConnectionPolicy defines how Envoy manages the HTTP
+	// connections to this cluster.
+	ConnectionPolicy ConnectionPolicy
 }
 
 func (c Cluster) Visit(f func(Vertex)) {
@@ -720,6 +962,24 @@ func (s *Secret) PrivateKey() []byte {
 	return s.Object.Data[v1.TLSPrivateKeyKey]
 }
 
+// OCSPStaple returns the secret's DER encoded OCSP response, if any.
+func (s *Secret) OCSPStaple() []byte {
+	return s.Object.Data[OCSPStapleKey]
+}
+
+// NotAfter returns the earliest expiry time of the certificates in
+// the secret's tls certificate chain, or the zero time if the chain
+// cannot be parsed.
+func (s *Secret) NotAfter() time.Time {
+	return certificateNotAfter(s.Cert())
+}
+
+// CANotAfter returns the earliest expiry time of the certificates
+// in the secret's CA bundle, or the zero time if there is no bundle.
+func (s *Secret) CANotAfter() time.Time {
+	return certificateNotAfter(s.Object.Data[CACertificateKey])
+}
+
 // HTTPHealthCheckPolicy http health check policy
 type HTTPHealthCheckPolicy struct {
 	Path               string
@@ -766,6 +1026,10 @@ type ExtensionCluster struct {
 	// ClientCertificate is the optional identifier of the TLS secret containing client certificate and
 	// private key to be used when establishing TLS connection to upstream cluster.
 	ClientCertificate *Secret
+
+	// ConnectionPolicy defines how Envoy manages the HTTP
+	// connections to this extension.
+	ConnectionPolicy ConnectionPolicy
 }
 
 // Visit processes extension clusters.
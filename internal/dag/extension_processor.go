@@ -35,6 +35,10 @@ type ExtensionServiceProcessor struct {
 	// secret containing client certificate and private key to be
 	// used when establishing TLS connection to upstream cluster.
 	ClientCertificate *types.NamespacedName
+
+	// ConnectionPolicy is the default policy for the HTTP
+	// connections to extension services.
+	ConnectionPolicy ConnectionPolicy
 }
 
 var _ Processor = &ExtensionServiceProcessor{}
@@ -85,6 +89,12 @@ func (p *ExtensionServiceProcessor) buildExtensionService(
 			"spec.timeoutPolicy failed to parse: %s", err)
 	}
 
+	cp, err := connectionPolicy(p.ConnectionPolicy, ext.Spec.ConnectionPolicy)
+	if err != nil {
+		validCondition.AddErrorf(contour_api_v1.ConditionTypeSpecError, "ConnectionPolicyNotValid",
+			"spec.connectionPolicy failed to parse: %s", err)
+	}
+
 	var clientCertSecret *Secret
 	if p.ClientCertificate != nil {
 		clientCertSecret, err = cache.LookupSecret(*p.ClientCertificate, validSecret)
@@ -107,6 +117,7 @@ func (p *ExtensionServiceProcessor) buildExtensionService(
 		TimeoutPolicy:      tp,
 		SNI:                "",
 		ClientCertificate:  clientCertSecret,
+		ConnectionPolicy:   cp,
 	}
 
 	lbPolicy := loadBalancerPolicy(ext.Spec.LoadBalancerPolicy)
@@ -130,6 +141,12 @@ func (p *ExtensionServiceProcessor) buildExtensionService(
 			".Spec.TimeoutPolicy.Idle")
 	}
 
+	if timeouts := ext.Spec.TimeoutPolicy; timeouts != nil && timeouts.MaxStreamDuration != "" {
+		validCondition.AddWarningf("SpecError", "IgnoredField",
+			"ignoring field %q; max stream durations are not supported for ExtensionClusters",
+			".Spec.TimeoutPolicy.MaxStreamDuration")
+	}
+
 	// API server validation ensures that the protocol is "h2" or "h2c".
 	if ext.Spec.Protocol != nil {
 		extension.Protocol = stringOrDefault(*ext.Spec.Protocol, extension.Protocol)
@@ -18,6 +18,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	contour_api_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
@@ -71,6 +72,51 @@ type HTTPProxyProcessor struct {
 	// ClientCertificate is the optional identifier of the TLS secret containing client certificate and
 	// private key to be used when establishing TLS connection to upstream cluster.
 	ClientCertificate *types.NamespacedName
+
+	// CertificateExpiryWarning is the window before expiry in
+	// which referenced TLS certificates cause a warning to be
+	// added to the HTTPProxy status. If zero, no warnings are added.
+	CertificateExpiryWarning time.Duration
+
+	// ConfigRejections is the optional source of the virtual hosts
+	// whose configuration was rejected by Envoy. Root HTTPProxies
+	// for those virtual hosts have a warning added to their status.
+	ConfigRejections ConfigRejections
+
+	// OCSPStaples is the optional source of the OCSP responses
+	// fetched for TLS certificates. A MustStaple virtual host whose
+	// certificate has no response falls back to LenientStapling.
+	OCSPStaples OCSPStaples
+
+	// Listeners are the protocols of the extra listeners that
+	// virtual hosts may choose, by listener name.
+	Listeners map[string]config.ListenerProtocol
+
+	// HTTP3Port is the UDP port that clients reach the HTTP/3
+	// listener on. If 0, HTTP/3 is disabled.
+	HTTP3Port int
+
+	// ConnectionPolicy is the default policy for the HTTP
+	// connections to upstream services. Services may
+	// override each of its fields.
+	ConnectionPolicy ConnectionPolicy
+}
+
+// ConfigRejections reports the virtual hosts whose generated
+// configuration was rejected by Envoy.
+type ConfigRejections interface {
+	// RejectedVirtualHost returns a description of why Envoy
+	// rejected the configuration of the named virtual host, and
+	// whether it was rejected.
+	RejectedVirtualHost(fqdn string) (string, bool)
+}
+
+// OCSPStaples reports the TLS certificates that have an OCSP
+// response to staple.
+type OCSPStaples interface {
+	// Stapled returns true if an OCSP response is available
+	// for the certificate of the secret.
+	Stapled(secret *Secret) bool
 }
 
 // Run translates HTTPProxies into DAG objects and
@@ -137,12 +183,49 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 		return
 	}
 
+	if p.ConfigRejections != nil {
+		if reason, ok := p.ConfigRejections.RejectedVirtualHost(host); ok {
+			validCond.AddWarningf(contour_api_v1.ConditionTypeEnvoyError, "ConfigRejected",
+				"Envoy rejected the configuration for %q: %s", host, reason)
+		}
+	}
+
 	if len(proxy.Spec.Routes) == 0 && len(proxy.Spec.Includes) == 0 && proxy.Spec.TCPProxy == nil {
 		validCond.AddError(contour_api_v1.ConditionTypeSpecError, "NothingDefined",
 			"HTTPProxy.Spec must have at least one Route, Include, or a TCPProxy")
 		return
 	}
 
+	listener := proxy.Spec.VirtualHost.Listener
+	if listener != "" {
+		protocol, ok := p.Listeners[listener]
+		if !ok {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "ListenerNotFound",
+				"Spec.VirtualHost.Listener %q is not configured", listener)
+			return
+		}
+
+		tls := proxy.Spec.VirtualHost.TLS
+		switch {
+		case (protocol == config.HTTPListenerProtocol || protocol == config.UDPListenerProtocol) && tls != nil:
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				"Spec.VirtualHost.Listener %q serves %s and cannot be used with Spec.VirtualHost.TLS", listener, protocol)
+			return
+		case protocol == config.HTTPSListenerProtocol && tls == nil:
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				"Spec.VirtualHost.Listener %q serves %s and requires Spec.VirtualHost.TLS", listener, protocol)
+			return
+		case (protocol == config.TCPListenerProtocol || protocol == config.UDPListenerProtocol) && proxy.Spec.TCPProxy == nil:
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				"Spec.VirtualHost.Listener %q serves %s and requires Spec.TCPProxy", listener, protocol)
+			return
+		case protocol != config.HTTPListenerProtocol && tls != nil && tls.EnableFallbackCertificate:
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				"Spec.VirtualHost.Listener %q cannot be used with Spec.VirtualHost.TLS fallback certificates", listener)
+			return
+		}
+	}
+
 	var tlsEnabled bool
 	if tls := proxy.Spec.VirtualHost.TLS; tls != nil {
 		if !isBlank(tls.SecretName) && tls.Passthrough {
@@ -183,9 +266,29 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 
 			svhost := p.dag.EnsureSecureVirtualHost(host)
 			svhost.Secret = sec
+			p.checkCertificateExpiry(validCond, "Spec.VirtualHost.TLS Secret", tls.SecretName, sec.NotAfter())
 			// default to a minimum TLS version of 1.2 if it's not specified
 			svhost.MinTLSVersion = annotation.MinTLSVersion(tls.MinimumProtocolVersion, "1.2")
 
+			switch tls.OCSPStaplePolicy {
+			case "", "LenientStapling", "StrictStapling":
+				svhost.OCSPStaplePolicy = tls.OCSPStaplePolicy
+			case "MustStaple":
+				// Envoy rejects a MUST_STAPLE certificate that has
+				// no OCSP response, so staple leniently until one
+				// has been fetched.
+				svhost.OCSPStaplePolicy = tls.OCSPStaplePolicy
+				if len(sec.OCSPStaple()) == 0 && (p.OCSPStaples == nil || !p.OCSPStaples.Stapled(sec)) {
+					svhost.OCSPStaplePolicy = "LenientStapling"
+					validCond.AddWarningf(contour_api_v1.ConditionTypeTLSError, "OCSPStapleMissing",
+						"Spec.VirtualHost.TLS Secret %q has no OCSP response, falling back to LenientStapling", tls.SecretName)
+				}
+			default:
+				validCond.AddErrorf(contour_api_v1.ConditionTypeTLSError, "OCSPStaplePolicyNotValid",
+					"Spec.VirtualHost.TLS.OCSPStaplePolicy %q is invalid", tls.OCSPStaplePolicy)
+				return
+			}
+
 			// Check if FallbackCertificate && ClientValidation are both enabled in the same vhost
 			if tls.EnableFallbackCertificate && tls.ClientValidation != nil {
 				validCond.AddError(contour_api_v1.ConditionTypeTLSError, "TLSIncompatibleFeatures",
@@ -225,6 +328,7 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 					return
 				}
 
+				p.checkCertificateExpiry(validCond, "Spec.VirtualHost.TLS fallback Secret", p.FallbackCertificate.String(), sec.NotAfter())
 				svhost.FallbackCertificate = sec
 			}
 
@@ -236,6 +340,7 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 						"Spec.VirtualHost.TLS client validation is invalid: %s", err)
 					return
 				}
+				p.checkCertificateExpiry(validCond, "Spec.VirtualHost.TLS client validation CA Secret", tls.ClientValidation.CACertificate, dv.CACertificate.CANotAfter())
 				svhost.DownstreamValidation = dv
 			}
 
@@ -282,17 +387,33 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 	}
 
 	if proxy.Spec.TCPProxy != nil {
-		if !tlsEnabled {
+		// Without TLS there is no SNI to select the virtual host,
+		// so the TCPProxy needs a listener of its own.
+		plain := !tlsEnabled && p.tcpProxyListener(listener)
+		if !tlsEnabled && !plain {
 			validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "TLSMustBeConfigured",
 				"Spec.TCPProxy requires that either Spec.TLS.Passthrough or Spec.TLS.SecretName be set")
 			return
 		}
-		if !p.processHTTPProxyTCPProxy(validCond, proxy, nil, host) {
+		tcpproxy, ok := p.processHTTPProxyTCPProxy(validCond, proxy, nil)
+		if !ok {
 			return
 		}
+
+		if !plain {
+			p.dag.EnsureSecureVirtualHost(host).TCPProxy = tcpproxy
+		} else {
+			if p.Listeners[listener] == config.UDPListenerProtocol && !validUDPProxy(validCond, tcpproxy) {
+				return
+			}
+			lp := p.dag.EnsureListenerProxy(listener)
+			lp.VirtualHost = host
+			lp.EnvoyGroup = proxy.Spec.VirtualHost.EnvoyGroup
+			lp.TCPProxy = tcpproxy
+		}
 	}
 
-	routes := p.computeRoutes(validCond, proxy, proxy, nil, nil, tlsEnabled)
+	routes := p.computeRoutes(validCond, proxy, proxy, nil, nil, []RouteSource{routeSource(proxy, nil)}, tlsEnabled)
 	insecure := p.dag.EnsureVirtualHost(host)
 	cp, err := toCORSPolicy(proxy.Spec.VirtualHost.CORSPolicy)
 	if err != nil {
@@ -309,14 +430,77 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 		return
 	}
 	insecure.RateLimitPolicy = rlp
+	insecure.EnvoyGroup = proxy.Spec.VirtualHost.EnvoyGroup
 
-	addRoutes(insecure, routes)
+	hp, bufferLimit, err := httpPolicy(proxy.Spec.VirtualHost.HTTPPolicy)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "HTTPPolicyNotValid",
+			"Spec.VirtualHost.HTTPPolicy is invalid: %s", err)
+		return
+	}
+	insecure.PerRequestBufferLimitBytes = bufferLimit
+
+	// Only a secure virtual host has a connection manager of its
+	// own, so the connection settings can't apply to anything else.
+	if hp != nil && *hp != (HTTPPolicy{}) && (!tlsEnabled || proxy.Spec.TCPProxy != nil) {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeVirtualHostError, "IgnoredField",
+			"ignoring field %q; timeouts and HTTP/1 settings only apply to virtual hosts that terminate TLS",
+			"Spec.VirtualHost.HTTPPolicy")
+	}
+
+	// Error pages replace the replies of the connection manager,
+	// so they too only apply to a secure virtual host.
+	var ep []*ErrorPage
+	if proxy.Spec.VirtualHost.ErrorPagePolicy != nil {
+		if !tlsEnabled || proxy.Spec.TCPProxy != nil {
+			validCond.AddWarningf(contour_api_v1.ConditionTypeVirtualHostError, "IgnoredField",
+				"ignoring field %q; error pages only apply to virtual hosts that terminate TLS",
+				"Spec.VirtualHost.ErrorPagePolicy")
+		} else {
+			ep, err = errorPages(p.source, proxy.Namespace, proxy.Spec.VirtualHost.ErrorPagePolicy)
+			if err != nil {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "ErrorPagePolicyNotValid",
+					"Spec.VirtualHost.ErrorPagePolicy is invalid: %s", err)
+				return
+			}
+		}
+	}
+
+	// The ext_authz filter can only be sent request bodies for the
+	// whole virtual host, so a buffer policy on one route makes the
+	// other authorized routes send their request bodies too.
+	if tlsEnabled && proxy.Spec.TCPProxy == nil && proxy.Spec.VirtualHost.AuthorizationConfigured() {
+		if max, unbuffered := authorizationRequestBodyBytes(routes); max > 0 && unbuffered {
+			validCond.AddWarningf(contour_api_v1.ConditionTypeVirtualHostError, "AuthorizationRequestBody",
+				"routes with a buffer policy make authorized routes without one also send up to %d bytes of their request body to the authorization server",
+				max)
+		}
+	}
+
+	// The secure virtual host may also have been created for TLS
+	// passthrough or TCP proxying, without any routes.
+	if secure := p.dag.GetSecureVirtualHost(host); secure != nil {
+		secure.EnvoyGroup = proxy.Spec.VirtualHost.EnvoyGroup
+		secure.Listener = listener
+	}
+
+	// An extra HTTPS, TCP or UDP listener has no plain HTTP counterpart,
+	// so the insecure virtual host is only served on the default
+	// HTTP listener or on an extra HTTP listener.
+	switch {
+	case listener == "":
+		addRoutes(insecure, routes)
+	case p.Listeners[listener] == config.HTTPListenerProtocol:
+		insecure.Listener = listener
+		addRoutes(insecure, routes)
+	}
 
 	// if TLS is enabled for this virtual host and there is no tcp proxy defined,
 	// then add routes to the secure virtualhost definition.
 	if tlsEnabled && proxy.Spec.TCPProxy == nil {
 		secure := p.dag.EnsureSecureVirtualHost(host)
 		secure.CORSPolicy = cp
+		secure.Listener = listener
 
 		rlp, err := rateLimitPolicy(proxy.Spec.VirtualHost.RateLimitPolicy)
 		if err != nil {
@@ -325,6 +509,16 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 			return
 		}
 		secure.RateLimitPolicy = rlp
+		secure.HTTPPolicy = hp
+		secure.ErrorPages = ep
+		secure.PerRequestBufferLimitBytes = bufferLimit
+
+		// HTTP/3 is only served alongside the default HTTPS
+		// listener, and Envoy does not validate client certificates
+		// over QUIC.
+		if tls := proxy.Spec.VirtualHost.TLS; listener == "" && !tls.DisableHTTP3 && tls.ClientValidation == nil {
+			secure.HTTP3Port = p.HTTP3Port
+		}
 
 		addRoutes(secure, routes)
 	}
@@ -347,6 +541,7 @@ func (p *HTTPProxyProcessor) computeRoutes(
 	proxy *contour_api_v1.HTTPProxy,
 	conditions []contour_api_v1.MatchCondition,
 	visited []*contour_api_v1.HTTPProxy,
+	sources []RouteSource,
 	enforceTLS bool,
 ) []*Route {
 	for _, v := range visited {
@@ -400,7 +595,8 @@ func (p *HTTPProxyProcessor) computeRoutes(
 
 		inc, incCommit := p.dag.StatusCache.ProxyAccessor(includedProxy)
 		incValidCond := inc.ConditionFor(status.ValidCondition)
-		routes = append(routes, p.computeRoutes(incValidCond, rootProxy, includedProxy, append(conditions, include.Conditions...), visited, enforceTLS)...)
+		incSources := append(sources[:len(sources):len(sources)], routeSource(includedProxy, include.Conditions))
+		routes = append(routes, p.computeRoutes(incValidCond, rootProxy, includedProxy, append(conditions, include.Conditions...), visited, incSources, enforceTLS)...)
 		incCommit()
 
 		// dest is not an orphaned httpproxy, as there is an httpproxy that points to it
@@ -461,19 +657,39 @@ func (p *HTTPProxyProcessor) computeRoutes(
 			return nil
 		}
 
+		irp, err := internalRedirectPolicy(route.InternalRedirectPolicy)
+		if err != nil {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "InternalRedirectPolicyNotValid",
+				"route.internalRedirectPolicy is invalid: %s", err)
+			return nil
+		}
+
+		// Each TLS virtual host has a route configuration of its
+		// own, so redirects can't be followed to other hosts.
+		if enforceTLS && irp != nil {
+			for _, host := range irp.AllowedHosts {
+				if host != rootProxy.Spec.VirtualHost.Fqdn {
+					validCond.AddWarningf(contour_api_v1.ConditionTypeRouteError, "InternalRedirectHostUnreachable",
+						"route.internalRedirectPolicy.allowedHosts %q is not reachable from TLS virtual host %q", host, rootProxy.Spec.VirtualHost.Fqdn)
+				}
+			}
+		}
+
 		requestHashPolicies, lbPolicy := loadBalancerRequestHashPolicies(route.LoadBalancerPolicy, validCond)
 
 		r := &Route{
-			PathMatchCondition:    mergePathMatchConditions(conds),
-			HeaderMatchConditions: mergeHeaderMatchConditions(conds),
-			Websocket:             route.EnableWebsockets,
-			HTTPSUpgrade:          routeEnforceTLS(enforceTLS, route.PermitInsecure && !p.DisablePermitInsecure),
-			TimeoutPolicy:         tp,
-			RetryPolicy:           retryPolicy(route.RetryPolicy),
-			RequestHeadersPolicy:  reqHP,
-			ResponseHeadersPolicy: respHP,
-			RateLimitPolicy:       rlp,
-			RequestHashPolicies:   requestHashPolicies,
+			PathMatchCondition:     mergePathMatchConditions(conds),
+			HeaderMatchConditions:  mergeHeaderMatchConditions(conds),
+			Websocket:              route.EnableWebsockets,
+			HTTPSUpgrade:           routeEnforceTLS(enforceTLS, route.PermitInsecure && !p.DisablePermitInsecure),
+			TimeoutPolicy:          tp,
+			RetryPolicy:            retryPolicy(route.RetryPolicy),
+			RequestHeadersPolicy:   reqHP,
+			ResponseHeadersPolicy:  respHP,
+			RateLimitPolicy:        rlp,
+			RequestHashPolicies:    requestHashPolicies,
+			BufferPolicy:           bufferPolicy(route.BufferPolicy),
+			InternalRedirectPolicy: irp,
 		}
 
 		// If the enclosing root proxy enabled authorization,
@@ -543,6 +759,12 @@ func (p *HTTPProxyProcessor) computeRoutes(
 				return nil
 			}
 
+			if service.MirrorPercentage != "" && !service.Mirror {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "MirrorPercentageInvalid",
+					"service %q: mirrorPercentage may only be set on a mirror service", service.Name)
+				return nil
+			}
+
 			m := types.NamespacedName{Name: service.Name, Namespace: service.Namespace}
 			s, err := p.dag.EnsureService(m, intstr.FromInt(service.Port), p.source)
 			if err != nil {
@@ -569,6 +791,10 @@ func (p *HTTPProxyProcessor) computeRoutes(
 						"Service [%s:%d] TLS upstream validation policy error: %s", service.Name, service.Port, err)
 					return nil
 				}
+				if uv != nil {
+					p.checkCertificateExpiry(validCond, fmt.Sprintf("Service [%s:%d] upstream validation CA Secret", service.Name, service.Port),
+						service.UpstreamValidation.CACertificate, uv.CACertificate.CANotAfter())
+				}
 			}
 
 			dynamicHeaders["CONTOUR_SERVICE_NAME"] = service.Name
@@ -598,6 +824,13 @@ func (p *HTTPProxyProcessor) computeRoutes(
 				}
 			}
 
+			cp, err := connectionPolicy(p.ConnectionPolicy, service.ConnectionPolicy)
+			if err != nil {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ConnectionPolicyNotValid",
+					"service %q: connectionPolicy failed to parse: %s", service.Name, err)
+				return nil
+			}
+
 			c := &Cluster{
 				Upstream:              s,
 				LoadBalancerPolicy:    lbPolicy,
@@ -610,37 +843,74 @@ func (p *HTTPProxyProcessor) computeRoutes(
 				SNI:                   determineSNI(r.RequestHeadersPolicy, reqHP, s),
 				DNSLookupFamily:       string(p.DNSLookupFamily),
 				ClientCertificate:     clientCertSecret,
-			}
-			if service.Mirror && r.MirrorPolicy != nil {
-				validCond.AddError(contour_api_v1.ConditionTypeServiceError, "OnlyOneMirror",
-					"only one service per route may be nominated as mirror")
-				return nil
+				ConnectionPolicy:      cp,
 			}
 			if service.Mirror {
-				r.MirrorPolicy = &MirrorPolicy{
-					Cluster: c,
+				// Envoy sends mirrored requests with the headers of
+				// the route, and has no per-mirror header policies.
+				if service.RequestHeadersPolicy != nil {
+					validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "IgnoredField",
+						"service %q: ignoring field %q; header policies are not supported on mirror services", service.Name, "requestHeadersPolicy")
+				}
+				if service.ResponseHeadersPolicy != nil {
+					validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "IgnoredField",
+						"service %q: ignoring field %q; header policies are not supported on mirror services", service.Name, "responseHeadersPolicy")
+				}
+
+				fraction, err := mirrorPartsPerMillion(service.MirrorPercentage)
+				if err != nil {
+					validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "MirrorPercentageInvalid",
+						"service %q: %s", service.Name, err)
+					return nil
 				}
+				r.MirrorPolicies = append(r.MirrorPolicies, &MirrorPolicy{
+					Cluster:         c,
+					PartsPerMillion: fraction,
+				})
 			} else {
 				r.Clusters = append(r.Clusters, c)
 			}
 		}
+		p.dag.setRouteSources(r, sources)
 		routes = append(routes, r)
 	}
 
-	routes = expandPrefixMatches(routes)
+	routes = expandPrefixMatches(p.dag, routes)
 
 	return routes
 }
 
+// authorizationRequestBodyBytes returns the largest buffer policy
+// limit of the routes, which the authorization server is sent up to
+// of each request body, and whether any route that is authorized has
+// no buffer policy of its own.
+func authorizationRequestBodyBytes(routes []*Route) (uint32, bool) {
+	var max uint32
+	var unbuffered bool
+
+	for _, r := range routes {
+		switch {
+		case r.BufferPolicy != nil:
+			if r.BufferPolicy.MaxRequestBytes > max {
+				max = r.BufferPolicy.MaxRequestBytes
+			}
+		case !r.AuthDisabled:
+			unbuffered = true
+		}
+	}
+
+	return max, unbuffered
+}
+
 // processHTTPProxyTCPProxy processes the spec.tcpproxy stanza in a HTTPProxy document
-// following the chain of spec.tcpproxy.include references. It returns true if processing
-// was successful, otherwise false if an error was encountered. The details of the error
-// will be recorded on the status of the relevant HTTPProxy object,
-func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.DetailedCondition, httpproxy *contour_api_v1.HTTPProxy, visited []*contour_api_v1.HTTPProxy, host string) bool {
+// following the chain of spec.tcpproxy.include references. It returns the TCPProxy and true
+// if processing was successful, otherwise false if an error was encountered. The details of
+// the error will be recorded on the status of the relevant HTTPProxy object,
+func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.DetailedCondition, httpproxy *contour_api_v1.HTTPProxy, visited []*contour_api_v1.HTTPProxy) (*TCPProxy, bool) {
 	tcpproxy := httpproxy.Spec.TCPProxy
 	if tcpproxy == nil {
 		// nothing to do
-		return true
+		return nil, true
 	}
 
 	visited = append(visited, httpproxy)
@@ -655,7 +925,7 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 	if len(tcpproxy.Services) > 0 && tcpProxyInclude != nil {
 		validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "NoServicesAndInclude",
 			"cannot specify services and include in the same httpproxy")
-		return false
+		return nil, false
 	}
 
 	lbPolicy := loadBalancerPolicy(tcpproxy.LoadBalancerPolicy)
@@ -676,7 +946,7 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 			if err != nil {
 				validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyError, "UnresolvedServiceRef",
 					"Spec.TCPProxy unresolved service reference: %s", err)
-				return false
+				return nil, false
 			}
 			proxy.Clusters = append(proxy.Clusters, &Cluster{
 				Upstream:             s,
@@ -685,17 +955,14 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 				TCPHealthCheckPolicy: tcpHealthCheckPolicy(tcpproxy.HealthCheckPolicy),
 			})
 		}
-		secure := p.dag.EnsureSecureVirtualHost(host)
-		secure.TCPProxy = &proxy
-
-		return true
+		return &proxy, true
 	}
 
 	if tcpProxyInclude == nil {
 		// We don't allow an empty TCPProxy object.
 		validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "NothingDefined",
 			"either services or inclusion must be specified")
-		return false
+		return nil, false
 	}
 
 	namespace := tcpProxyInclude.Namespace
@@ -709,14 +976,14 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 	if !ok {
 		validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyIncludeError, "IncludeNotFound",
 			"include %s/%s not found", m.Namespace, m.Name)
-		return false
+		return nil, false
 	}
 
 	if dest.Spec.VirtualHost != nil {
 
 		validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyIncludeError, "RootIncludesRoot",
 			"root httpproxy cannot include another root httpproxy")
-		return false
+		return nil, false
 	}
 
 	// dest is no longer an orphan
@@ -732,7 +999,7 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 			path = append(path, fmt.Sprintf("%s/%s", dest.Namespace, dest.Name))
 			validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyIncludeError, "IncludeCreatesCycle",
 				"include creates a cycle: %s", strings.Join(path, " -> "))
-			return false
+			return nil, false
 		}
 	}
 
@@ -740,8 +1007,37 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 	inc, commit := p.dag.StatusCache.ProxyAccessor(dest)
 	incValidCond := inc.ConditionFor(status.ValidCondition)
 	defer commit()
-	ok = p.processHTTPProxyTCPProxy(incValidCond, dest, visited, host)
-	return ok
+	return p.processHTTPProxyTCPProxy(incValidCond, dest, visited)
+}
+
+// tcpProxyListener returns true if the extra listener only serves
+// TCPProxies, over TCP or UDP.
+func (p *HTTPProxyProcessor) tcpProxyListener(listener string) bool {
+	switch p.Listeners[listener] {
+	case config.TCPListenerProtocol, config.UDPListenerProtocol:
+		return true
+	default:
+		return false
+	}
+}
+
+// validUDPProxy returns true if the TCPProxy can proxy UDP. Envoy's
+// UDP proxy forwards datagrams to a single cluster and does not
+// health check it.
+func validUDPProxy(validCond *contour_api_v1.DetailedCondition, proxy *TCPProxy) bool {
+	if len(proxy.Clusters) != 1 {
+		validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "UDPProxyNotValid",
+			"Spec.TCPProxy on a UDP listener must have exactly one service")
+		return false
+	}
+
+	if proxy.Clusters[0].TCPHealthCheckPolicy != nil {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeTCPProxyError, "IgnoredField",
+			"ignoring field %q; health checks are not supported on UDP listeners", "Spec.TCPProxy.HealthCheckPolicy")
+		proxy.Clusters[0].TCPHealthCheckPolicy = nil
+	}
+
+	return true
 }
 
 // validHTTPProxies returns a slice of *contour_api_v1.HTTPProxy objects.
@@ -782,9 +1078,71 @@ func (p *HTTPProxyProcessor) validHTTPProxies() []*contour_api_v1.HTTPProxy {
 			}
 		}
 	}
+	return p.exclusiveListeners(valid)
+}
+
+// exclusiveListeners returns the proxies, excluding those that share
+// an extra TCP or UDP listener with a proxy that takes the whole
+// listener. Excluded HTTPProxy objects have their status updated
+// accordingly.
+func (p *HTTPProxyProcessor) exclusiveListeners(proxies []*contour_api_v1.HTTPProxy) []*contour_api_v1.HTTPProxy {
+	byListener := map[string][]*contour_api_v1.HTTPProxy{}
+	exclusive := map[string]bool{}
+	for _, proxy := range proxies {
+		vhost := proxy.Spec.VirtualHost
+		if vhost == nil || !p.tcpProxyListener(vhost.Listener) {
+			continue
+		}
+		byListener[vhost.Listener] = append(byListener[vhost.Listener], proxy)
+		if vhost.TLS == nil || p.Listeners[vhost.Listener] == config.UDPListenerProtocol {
+			exclusive[vhost.Listener] = true
+		}
+	}
+
+	var valid []*contour_api_v1.HTTPProxy
+	for _, proxy := range proxies {
+		vhost := proxy.Spec.VirtualHost
+		if vhost == nil || !exclusive[vhost.Listener] || len(byListener[vhost.Listener]) == 1 {
+			valid = append(valid, proxy)
+			continue
+		}
+
+		var conflicting []string
+		for _, proxy := range byListener[vhost.Listener] {
+			conflicting = append(conflicting, proxy.Namespace+"/"+proxy.Name)
+		}
+		sort.Strings(conflicting) // sort for test stability
+
+		pa, commit := p.dag.StatusCache.ProxyAccessor(proxy)
+		pa.Vhost = vhost.Fqdn
+		pa.ConditionFor(status.ValidCondition).AddErrorf(contour_api_v1.ConditionTypeVirtualHostError,
+			"ListenerConflict",
+			"Spec.VirtualHost.Listener %q is used by multiple HTTPProxies and one of them takes the whole listener: %s",
+			vhost.Listener, strings.Join(conflicting, ", "))
+		commit()
+	}
 	return valid
 }
 
+// checkCertificateExpiry adds a warning to validCond if notAfter
+// falls within the configured certificate expiry warning window.
+// The field and name identify the referenced Secret in the message.
+func (p *HTTPProxyProcessor) checkCertificateExpiry(validCond *contour_api_v1.DetailedCondition, field string, name string, notAfter time.Time) {
+	if p.CertificateExpiryWarning <= 0 || notAfter.IsZero() {
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case !now.Before(notAfter):
+		validCond.AddWarningf(contour_api_v1.ConditionTypeTLSError, "CertificateExpired",
+			"%s %q certificate expired at %s", field, name, notAfter.UTC().Format(time.RFC3339))
+	case notAfter.Sub(now) < p.CertificateExpiryWarning:
+		validCond.AddWarningf(contour_api_v1.ConditionTypeTLSError, "CertificateExpiring",
+			"%s %q certificate expires at %s", field, name, notAfter.UTC().Format(time.RFC3339))
+	}
+}
+
 // rootAllowed returns true if the HTTPProxy lives in a permitted root namespace.
 func (p *HTTPProxyProcessor) rootAllowed(namespace string) bool {
 	if len(p.source.RootNamespaces) == 0 {
@@ -822,7 +1180,7 @@ func (p *HTTPProxyProcessor) setOrphaned(obj k8s.Object) {
 // | `/foo/`         | `/bar`      | `/foo/type` | X `/bartype`   |
 // | `/foo`          | `/bar/`     | `/foosball` | X `/bar/sball` |
 // | `/foo/`         | `/bar/`     | `/foo/type` |   `/bar/type`  |
-func expandPrefixMatches(routes []*Route) []*Route {
+func expandPrefixMatches(d *DAG, routes []*Route) []*Route {
 	prefixedRoutes := map[string][]*Route{}
 
 	expandedRoutes := []*Route{}
@@ -865,6 +1223,7 @@ func expandPrefixMatches(routes []*Route) []*Route {
 
 			// Shallow copy the Route. TODO(jpeach) deep copying would be more robust.
 			newRoute := *routes[0]
+			d.setRouteSources(&newRoute, d.RouteSources(routes[0]))
 
 			// Now, make the original route handle '/foo' and the new route handle '/foo'.
 			routes[0].PrefixRewrite = strings.TrimRight(routes[0].PrefixRewrite, "/")
@@ -898,6 +1257,17 @@ func expandPrefixMatches(routes []*Route) []*Route {
 	return expandedRoutes
 }
 
+// routeSource returns the RouteSource for an HTTPProxy included with
+// the given conditions.
+func routeSource(proxy *contour_api_v1.HTTPProxy, conditions []contour_api_v1.MatchCondition) RouteSource {
+	return RouteSource{
+		Kind:       "HTTPProxy",
+		Namespace:  proxy.Namespace,
+		Name:       proxy.Name,
+		Conditions: conditions,
+	}
+}
+
 func getProtocol(service contour_api_v1.Service, s *Service) (string, error) {
 	// Determine the protocol to use to speak to this Cluster.
 	var protocol string
@@ -34,6 +34,10 @@ type IngressProcessor struct {
 	// ClientCertificate is the optional identifier of the TLS secret containing client certificate and
 	// private key to be used when establishing TLS connection to upstream cluster.
 	ClientCertificate *types.NamespacedName
+
+	// ConnectionPolicy is the policy for the HTTP
+	// connections to upstream services.
+	ConnectionPolicy ConnectionPolicy
 }
 
 // Run translates Ingresses into DAG objects and
@@ -88,6 +92,9 @@ func (p *IngressProcessor) computeSecureVirtualhosts() {
 				svhost.Secret = sec
 				// default to a minimum TLS version of 1.2 if it's not specified
 				svhost.MinTLSVersion = annotation.MinTLSVersion(annotation.ContourAnnotation(ing, "tls-minimum-protocol-version"), "1.2")
+				if group := annotation.ContourAnnotation(ing, "envoy-group"); group != "" {
+					svhost.EnvoyGroup = group
+				}
 			}
 		}
 	}
@@ -139,7 +146,7 @@ func (p *IngressProcessor) computeIngressRule(ing *v1beta1.Ingress, rule v1beta1
 			continue
 		}
 
-		r, err := route(ing, path, s, clientCertSecret, p.FieldLogger)
+		r, err := route(ing, path, s, clientCertSecret, p.ConnectionPolicy, p.FieldLogger)
 		if err != nil {
 			p.WithError(err).
 				WithField("name", ing.GetName()).
@@ -148,10 +155,18 @@ func (p *IngressProcessor) computeIngressRule(ing *v1beta1.Ingress, rule v1beta1
 				Errorf("path regex is not valid")
 			return
 		}
+		p.dag.setRouteSources(r, []RouteSource{{
+			Kind:      "Ingress",
+			Namespace: ing.Namespace,
+			Name:      ing.Name,
+		}})
 
 		// should we create port 80 routes for this ingress
 		if annotation.TLSRequired(ing) || annotation.HTTPAllowed(ing) {
 			vhost := p.dag.EnsureVirtualHost(host)
+			if group := annotation.ContourAnnotation(ing, "envoy-group"); group != "" {
+				vhost.EnvoyGroup = group
+			}
 			vhost.addRoute(r)
 		}
 
@@ -165,7 +180,7 @@ func (p *IngressProcessor) computeIngressRule(ing *v1beta1.Ingress, rule v1beta1
 }
 
 // route builds a dag.Route for the supplied Ingress.
-func route(ingress *v1beta1.Ingress, path string, service *Service, clientCertSecret *Secret, log logrus.FieldLogger) (*Route, error) {
+func route(ingress *v1beta1.Ingress, path string, service *Service, clientCertSecret *Secret, cp ConnectionPolicy, log logrus.FieldLogger) (*Route, error) {
 	log = log.WithFields(logrus.Fields{
 		"name":      ingress.Name,
 		"namespace": ingress.Namespace,
@@ -180,6 +195,7 @@ func route(ingress *v1beta1.Ingress, path string, service *Service, clientCertSe
 			Upstream:          service,
 			Protocol:          service.Protocol,
 			ClientCertificate: clientCertSecret,
+			ConnectionPolicy:  cp,
 		}},
 	}
 
@@ -13,25 +13,76 @@
 
 package dag
 
-import "sort"
+import (
+	"sort"
+
+	"github.com/projectcontour/contour/pkg/config"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+)
 
 // ListenerProcessor adds an HTTP and an HTTPS listener to
 // the DAG if there are virtual hosts and secure virtual
 // hosts already defined as roots in the DAG.
-type ListenerProcessor struct{}
+type ListenerProcessor struct {
+	logrus.FieldLogger
+
+	// ErrorPages are the error pages of the configuration
+	// file, which are added to both listeners.
+	ErrorPages []config.ErrorPageParameters
+
+	// pageErrors holds the error of each configured error page
+	// at the last run, by index, so that errors are only logged
+	// when they change rather than on every rebuild.
+	pageErrors map[int]string
+}
 
 // Run adds HTTP and HTTPS listeners to the DAG if there are
 // virtual hosts and secure virtual hosts already defined as
 // roots in the DAG.
-func (p *ListenerProcessor) Run(dag *DAG, _ *KubernetesCache) {
-	p.buildHTTPListener(dag)
-	p.buildHTTPSListener(dag)
+func (p *ListenerProcessor) Run(dag *DAG, source *KubernetesCache) {
+	pages := p.errorPages(source)
+
+	p.buildHTTPListener(dag, pages)
+	p.buildHTTPSListener(dag, pages)
+}
+
+// errorPages returns the error pages of the configuration file.
+// Pages whose body can't be found are skipped, and logged once
+// until their error changes.
+func (p *ListenerProcessor) errorPages(source *KubernetesCache) []*ErrorPage {
+	var pages []*ErrorPage
+
+	pageErrors := map[int]string{}
+	for i, page := range p.ErrorPages {
+		name := types.NamespacedName{Namespace: page.ConfigMap.Namespace, Name: page.ConfigMap.Name}
+
+		body, err := errorPageBody(source, name, page.Key)
+		if err != nil {
+			pageErrors[i] = err.Error()
+			if p.pageErrors[i] != err.Error() {
+				p.WithError(err).Error("ignoring error page")
+			}
+			continue
+		}
+
+		pages = append(pages, &ErrorPage{
+			StatusCodes:        page.StatusCodes,
+			Body:               body,
+			ContentType:        page.ContentType,
+			ResponseStatusCode: page.ResponseStatusCode,
+		})
+	}
+
+	p.pageErrors = pageErrors
+
+	return pages
 }
 
 // buildHTTPListener builds a *dag.Listener for the vhosts bound to port 80.
 // The list of virtual hosts will attached to the listener will be sorted
 // by hostname.
-func (p *ListenerProcessor) buildHTTPListener(dag *DAG) {
+func (p *ListenerProcessor) buildHTTPListener(dag *DAG, pages []*ErrorPage) {
 	var virtualhosts []Vertex
 	var remove []Vertex
 
@@ -62,6 +113,7 @@ func (p *ListenerProcessor) buildHTTPListener(dag *DAG) {
 	http := &Listener{
 		Port:         80,
 		VirtualHosts: virtualhosts,
+		ErrorPages:   pages,
 	}
 
 	dag.AddRoot(http)
@@ -70,7 +122,7 @@ func (p *ListenerProcessor) buildHTTPListener(dag *DAG) {
 // buildHTTPSListener builds a *dag.Listener for the vhosts bound to port 443.
 // The list of virtual hosts will attached to the listener will be sorted
 // by hostname.
-func (p *ListenerProcessor) buildHTTPSListener(dag *DAG) {
+func (p *ListenerProcessor) buildHTTPSListener(dag *DAG, pages []*ErrorPage) {
 	var virtualhosts []Vertex
 	var remove []Vertex
 
@@ -101,6 +153,7 @@ func (p *ListenerProcessor) buildHTTPSListener(dag *DAG) {
 	https := &Listener{
 		Port:         443,
 		VirtualHosts: virtualhosts,
+		ErrorPages:   pages,
 	}
 
 	dag.AddRoot(https)
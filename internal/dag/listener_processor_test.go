@@ -0,0 +1,76 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"testing"
+
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/pkg/config"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestListenerProcessorErrorPages(t *testing.T) {
+	log, hook := test.NewNullLogger()
+
+	p := &ListenerProcessor{
+		FieldLogger: log,
+		ErrorPages: []config.ErrorPageParameters{{
+			StatusCodes: []uint32{503},
+			ConfigMap:   config.NamespacedName{Name: "error-pages", Namespace: "projectcontour"},
+			Key:         "unavailable.html",
+		}},
+	}
+
+	source := &KubernetesCache{
+		FieldLogger: fixture.NewTestLogger(t),
+	}
+
+	// A missing page is only logged once, not on every rebuild.
+	assert.Empty(t, p.errorPages(source))
+	assert.Empty(t, p.errorPages(source))
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "ignoring error page", hook.LastEntry().Message)
+
+	// A different error is logged again.
+	hook.Reset()
+	source.Insert(&v1.ConfigMap{
+		ObjectMeta: fixture.ObjectMeta("projectcontour/error-pages"),
+		Data:       map[string]string{"other.html": "other"},
+	})
+	assert.Empty(t, p.errorPages(source))
+	assert.Empty(t, p.errorPages(source))
+	require.Len(t, hook.AllEntries(), 1)
+
+	hook.Reset()
+	source.Insert(&v1.ConfigMap{
+		ObjectMeta: fixture.ObjectMeta("projectcontour/error-pages"),
+		Data:       map[string]string{"unavailable.html": "unavailable"},
+	})
+	assert.Equal(t, []*ErrorPage{{
+		StatusCodes: []uint32{503},
+		Body:        "unavailable",
+	}}, p.errorPages(source))
+	assert.Empty(t, hook.AllEntries())
+
+	// Once fixed, a page that goes missing again is logged again.
+	source.Remove(&v1.ConfigMap{
+		ObjectMeta: fixture.ObjectMeta("projectcontour/error-pages"),
+	})
+	assert.Empty(t, p.errorPages(source))
+	assert.Len(t, hook.AllEntries(), 1)
+}
@@ -14,9 +14,12 @@
 package dag
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +28,7 @@ import (
 	"github.com/projectcontour/contour/internal/timeout"
 	"github.com/sirupsen/logrus"
 	"k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation"
 )
@@ -65,6 +69,46 @@ func retryOn(ro []contour_api_v1.RetryOn) string {
 	return strings.Join(ss, ",")
 }
 
+func bufferPolicy(bp *contour_api_v1.BufferPolicy) *BufferPolicy {
+	if bp == nil || bp.MaxRequestBytes == 0 {
+		return nil
+	}
+
+	return &BufferPolicy{
+		MaxRequestBytes: bp.MaxRequestBytes,
+	}
+}
+
+func internalRedirectPolicy(irp *contour_api_v1.InternalRedirectPolicy) (*InternalRedirectPolicy, error) {
+	if irp == nil {
+		return nil, nil
+	}
+
+	for _, code := range irp.RedirectResponseCodes {
+		switch code {
+		case 301, 302, 303, 307, 308:
+		default:
+			return nil, fmt.Errorf("invalid redirect response code %d", code)
+		}
+	}
+
+	for _, host := range irp.AllowedHosts {
+		if host == "" {
+			return nil, errors.New("allowed hosts must not be empty")
+		}
+		if msgs := validation.IsDNS1123Subdomain(host); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid allowed host %q: %v", host, msgs)
+		}
+	}
+
+	return &InternalRedirectPolicy{
+		MaxInternalRedirects:     irp.MaxInternalRedirects,
+		RedirectResponseCodes:    irp.RedirectResponseCodes,
+		AllowCrossSchemeRedirect: irp.AllowCrossSchemeRedirect,
+		AllowedHosts:             irp.AllowedHosts,
+	}, nil
+}
+
 func retryPolicy(rp *contour_api_v1.RetryPolicy) *RetryPolicy {
 	if rp == nil {
 		return nil
@@ -260,12 +304,144 @@ func timeoutPolicy(tp *contour_api_v1.TimeoutPolicy) (TimeoutPolicy, error) {
 		return TimeoutPolicy{}, fmt.Errorf("error parsing idle timeout: %w", err)
 	}
 
+	maxStreamDuration, err := timeout.Parse(tp.MaxStreamDuration)
+	if err != nil {
+		return TimeoutPolicy{}, fmt.Errorf("error parsing max stream duration: %w", err)
+	}
+
 	return TimeoutPolicy{
-		ResponseTimeout: responseTimeout,
-		IdleTimeout:     idleTimeout,
+		ResponseTimeout:   responseTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxStreamDuration: maxStreamDuration,
 	}, nil
 }
 
+// errorPages returns the error pages of the policy, whose bodies
+// are held by ConfigMaps in the given namespace.
+func errorPages(source *KubernetesCache, namespace string, ep *contour_api_v1.ErrorPagePolicy) ([]*ErrorPage, error) {
+	if ep == nil {
+		return nil, nil
+	}
+
+	var pages []*ErrorPage
+	for _, page := range ep.Pages {
+		if len(page.StatusCodes) == 0 {
+			return nil, fmt.Errorf("error page for ConfigMap %q has no status codes", page.ConfigMap)
+		}
+		for _, code := range page.StatusCodes {
+			if code < 400 || code > 599 {
+				return nil, fmt.Errorf("invalid error page status code %d", code)
+			}
+		}
+
+		body, err := errorPageBody(source, types.NamespacedName{Namespace: namespace, Name: page.ConfigMap}, page.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		pages = append(pages, &ErrorPage{
+			StatusCodes:        page.StatusCodes,
+			Body:               body,
+			ContentType:        page.ContentType,
+			ResponseStatusCode: page.ResponseStatusCode,
+		})
+	}
+
+	return pages, nil
+}
+
+// errorPageBody returns the value of the key in the ConfigMap.
+func errorPageBody(source *KubernetesCache, name types.NamespacedName, key string) (string, error) {
+	cm, err := source.LookupConfigMap(name)
+	if err != nil {
+		return "", fmt.Errorf("error page ConfigMap %q: %w", name, err)
+	}
+
+	body, ok := cm.Data[key]
+	if !ok {
+		return "", fmt.Errorf("error page ConfigMap %q has no key %q", name, key)
+	}
+
+	return body, nil
+}
+
+// httpPolicy returns the HTTPPolicy of a secure virtual host
+// and its request buffer limit, which applies to all virtual
+// hosts. If hp is nil, the returned HTTPPolicy is nil.
+func httpPolicy(hp *contour_api_v1.HTTPPolicy) (*HTTPPolicy, uint32, error) {
+	if hp == nil {
+		return nil, 0, nil
+	}
+
+	var policy HTTPPolicy
+
+	for _, t := range []struct {
+		name    string
+		value   string
+		setting *timeout.Setting
+	}{
+		{"request timeout", hp.RequestTimeout, &policy.RequestTimeout},
+		{"request headers timeout", hp.RequestHeadersTimeout, &policy.RequestHeadersTimeout},
+		{"stream idle timeout", hp.StreamIdleTimeout, &policy.StreamIdleTimeout},
+		{"max connection duration", hp.MaxConnectionDuration, &policy.MaxConnectionDuration},
+	} {
+		setting, err := timeout.Parse(t.value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error parsing %s: %w", t.name, err)
+		}
+		*t.setting = setting
+	}
+
+	if hp.HTTP1 != nil {
+		policy.AllowAbsoluteURLs = hp.HTTP1.AllowAbsoluteURLs
+		policy.ProperCaseHeaders = hp.HTTP1.ProperCaseHeaders
+	}
+
+	var bufferLimit uint32
+	if hp.PerRequestBufferLimitBytes != nil {
+		bufferLimit = *hp.PerRequestBufferLimitBytes
+	}
+
+	return &policy, bufferLimit, nil
+}
+
+// connectionPolicy returns the ConnectionPolicy for an upstream
+// service, taking each field that cp sets and falling back to
+// defaults for the rest.
+func connectionPolicy(defaults ConnectionPolicy, cp *contour_api_v1.ConnectionPolicy) (ConnectionPolicy, error) {
+	if cp == nil {
+		return defaults, nil
+	}
+
+	policy := defaults
+
+	if cp.IdleTimeout != "" {
+		idleTimeout, err := timeout.Parse(cp.IdleTimeout)
+		if err != nil {
+			return ConnectionPolicy{}, fmt.Errorf("error parsing idle timeout: %w", err)
+		}
+		policy.IdleTimeout = idleTimeout
+	}
+
+	if cp.MaxStreamDuration != "" {
+		maxStreamDuration, err := timeout.Parse(cp.MaxStreamDuration)
+		if err != nil {
+			return ConnectionPolicy{}, fmt.Errorf("error parsing max stream duration: %w", err)
+		}
+		policy.MaxStreamDuration = maxStreamDuration
+	}
+
+	if cp.MaxRequestsPerConnection != nil {
+		policy.MaxRequestsPerConnection = *cp.MaxRequestsPerConnection
+	}
+
+	if cp.PerConnectionBufferLimitBytes != nil {
+		policy.PerConnectionBufferLimitBytes = *cp.PerConnectionBufferLimitBytes
+	}
+
+	return policy, nil
+}
+
 func httpHealthCheckPolicy(hc *contour_api_v1.HTTPHealthCheckPolicy) *HTTPHealthCheckPolicy {
 	if hc == nil {
 		return nil
@@ -332,6 +508,80 @@ func prefixReplacementsAreValid(replacements []contour_api_v1.ReplacePrefix) (st
 	return "", nil
 }
 
+// hashOptionsCount returns the number of hash options set on the
+// request hash policy.
+func hashOptionsCount(hp contour_api_v1.RequestHashPolicy) int {
+	n := 0
+	if hp.HeaderHashOptions != nil {
+		n++
+	}
+	if hp.CookieHashOptions != nil {
+		n++
+	}
+	if hp.HashSourceAddress {
+		n++
+	}
+	return n
+}
+
+// cookieNameRegex matches the token characters that are valid
+// in a HTTP cookie name.
+var cookieNameRegex = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// cookieHashOptions validates and converts the cookie hash options
+// of a request hash policy.
+func cookieHashOptions(in *contour_api_v1.CookieHashOptions) (*CookieHashOptions, error) {
+	if !cookieNameRegex.MatchString(in.CookieName) {
+		return nil, fmt.Errorf("invalid cookie name %q", in.CookieName)
+	}
+
+	if in.Path != "" && !strings.HasPrefix(in.Path, "/") {
+		return nil, fmt.Errorf("cookie path %q must start with \"/\"", in.Path)
+	}
+
+	cookie := &CookieHashOptions{
+		CookieName: in.CookieName,
+		Path:       in.Path,
+	}
+
+	if in.TTL != "" {
+		ttl, err := time.ParseDuration(in.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookie TTL %q: %w", in.TTL, err)
+		}
+		if ttl < 0 {
+			return nil, fmt.Errorf("cookie TTL %q must not be negative", in.TTL)
+		}
+		cookie.TTL = &ttl
+	}
+
+	return cookie, nil
+}
+
+// mirrorPercentageRegex matches a percentage between 0 and 100
+// with up to four decimal places.
+var mirrorPercentageRegex = regexp.MustCompile(`^(100(\.0{1,4})?|[0-9]{1,2}(\.[0-9]{1,4})?)$`)
+
+// mirrorPartsPerMillion converts a mirror percentage to the fraction
+// of requests to mirror, in millionths. An empty percentage mirrors
+// every request.
+func mirrorPartsPerMillion(percentage string) (uint32, error) {
+	if percentage == "" {
+		return MirrorAll, nil
+	}
+
+	if !mirrorPercentageRegex.MatchString(percentage) {
+		return 0, fmt.Errorf("mirrorPercentage %q must be a number between 0 and 100 with up to four decimal places", percentage)
+	}
+
+	f, err := strconv.ParseFloat(percentage, 64)
+	if err != nil {
+		return 0, fmt.Errorf("mirrorPercentage %q is invalid: %w", percentage, err)
+	}
+
+	return uint32(math.Round(f * MirrorAll / 100)), nil
+}
+
 func rateLimitPolicy(in *contour_api_v1.RateLimitPolicy) (*RateLimitPolicy, error) {
 	if in == nil || in.Local == nil {
 		return nil, nil
@@ -394,7 +644,7 @@ func loadBalancerRequestHashPolicies(lbp *contour_api_v1.LoadBalancerPolicy, val
 		return []RequestHashPolicy{
 			{CookieHashOptions: &CookieHashOptions{
 				CookieName: "X-Contour-Session-Affinity",
-				TTL:        time.Duration(0),
+				TTL:        new(time.Duration),
 				Path:       "/",
 			}},
 		}, LoadBalancerPolicyCookie
@@ -403,12 +653,56 @@ func loadBalancerRequestHashPolicies(lbp *contour_api_v1.LoadBalancerPolicy, val
 		actualStrategy := strategy
 		// Map of unique header names.
 		headerHashPolicies := map[string]bool{}
+		// Map of unique cookie names.
+		cookieHashPolicies := map[string]bool{}
+		hashSourceAddress := false
 		for _, hashPolicy := range lbp.RequestHashPolicies {
-			if hashPolicy.HeaderHashOptions == nil {
+			if n := hashOptionsCount(hashPolicy); n == 0 {
 				validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
 					"ignoring invalid nil hash policy options")
 				continue
+			} else if n > 1 {
+				validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
+					"ignoring hash policy with more than one hash option set")
+				continue
 			}
+
+			if hashPolicy.HashSourceAddress {
+				if hashSourceAddress {
+					validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
+						"ignoring duplicated source address hash policy")
+					continue
+				}
+				hashSourceAddress = true
+
+				rhp = append(rhp, RequestHashPolicy{
+					Terminal:          hashPolicy.Terminal,
+					HashSourceAddress: true,
+				})
+				continue
+			}
+
+			if hashPolicy.CookieHashOptions != nil {
+				cookie, err := cookieHashOptions(hashPolicy.CookieHashOptions)
+				if err != nil {
+					validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
+						"ignoring invalid cookie hash policy options: %s", err)
+					continue
+				}
+				if _, ok := cookieHashPolicies[cookie.CookieName]; ok {
+					validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
+						"ignoring invalid cookie hash policy options with duplicated cookie name %s", cookie.CookieName)
+					continue
+				}
+				cookieHashPolicies[cookie.CookieName] = true
+
+				rhp = append(rhp, RequestHashPolicy{
+					Terminal:          hashPolicy.Terminal,
+					CookieHashOptions: cookie,
+				})
+				continue
+			}
+
 			headerName := http.CanonicalHeaderKey(hashPolicy.HeaderHashOptions.HeaderName)
 			if msgs := validation.IsHTTPHeaderName(headerName); len(msgs) != 0 {
 				validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
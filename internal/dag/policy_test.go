@@ -189,6 +189,104 @@ func TestRetryPolicy(t *testing.T) {
 	}
 }
 
+func TestBufferPolicy(t *testing.T) {
+	tests := map[string]struct {
+		bp   *contour_api_v1.BufferPolicy
+		want *BufferPolicy
+	}{
+		"nil buffer policy": {
+			bp:   nil,
+			want: nil,
+		},
+		"zero max request bytes": {
+			bp:   &contour_api_v1.BufferPolicy{},
+			want: nil,
+		},
+		"max request bytes": {
+			bp: &contour_api_v1.BufferPolicy{
+				MaxRequestBytes: 8192,
+			},
+			want: &BufferPolicy{
+				MaxRequestBytes: 8192,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := bufferPolicy(tc.bp)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestInternalRedirectPolicy(t *testing.T) {
+	tests := map[string]struct {
+		irp     *contour_api_v1.InternalRedirectPolicy
+		want    *InternalRedirectPolicy
+		wantErr bool
+	}{
+		"nil internal redirect policy": {
+			irp:  nil,
+			want: nil,
+		},
+		"empty internal redirect policy": {
+			irp:  &contour_api_v1.InternalRedirectPolicy{},
+			want: &InternalRedirectPolicy{},
+		},
+		"full internal redirect policy": {
+			irp: &contour_api_v1.InternalRedirectPolicy{
+				MaxInternalRedirects:     3,
+				RedirectResponseCodes:    []uint32{301, 302, 303, 307, 308},
+				AllowCrossSchemeRedirect: true,
+				AllowedHosts:             []string{"storage.example.com"},
+			},
+			want: &InternalRedirectPolicy{
+				MaxInternalRedirects:     3,
+				RedirectResponseCodes:    []uint32{301, 302, 303, 307, 308},
+				AllowCrossSchemeRedirect: true,
+				AllowedHosts:             []string{"storage.example.com"},
+			},
+		},
+		"invalid redirect response code": {
+			irp: &contour_api_v1.InternalRedirectPolicy{
+				RedirectResponseCodes: []uint32{304},
+			},
+			wantErr: true,
+		},
+		"empty allowed host": {
+			irp: &contour_api_v1.InternalRedirectPolicy{
+				AllowedHosts: []string{""},
+			},
+			wantErr: true,
+		},
+		"allowed host with a path": {
+			irp: &contour_api_v1.InternalRedirectPolicy{
+				AllowedHosts: []string{"storage.example.com/assets"},
+			},
+			wantErr: true,
+		},
+		"allowed host with a port": {
+			irp: &contour_api_v1.InternalRedirectPolicy{
+				AllowedHosts: []string{"storage.example.com:8080"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := internalRedirectPolicy(tc.irp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestTimeoutPolicy(t *testing.T) {
 	tests := map[string]struct {
 		tp      *contour_api_v1.TimeoutPolicy
@@ -233,6 +331,20 @@ func TestTimeoutPolicy(t *testing.T) {
 				IdleTimeout: timeout.DurationSetting(900 * time.Second),
 			},
 		},
+		"max stream duration": {
+			tp: &contour_api_v1.TimeoutPolicy{
+				MaxStreamDuration: "10m",
+			},
+			want: TimeoutPolicy{
+				MaxStreamDuration: timeout.DurationSetting(10 * time.Minute),
+			},
+		},
+		"invalid max stream duration": {
+			tp: &contour_api_v1.TimeoutPolicy{
+				MaxStreamDuration: "10",
+			},
+			wantErr: true,
+		},
 	}
 
 	for name, tc := range tests {
@@ -249,6 +361,148 @@ func TestTimeoutPolicy(t *testing.T) {
 	}
 }
 
+func TestHTTPPolicy(t *testing.T) {
+	u32 := func(val uint32) *uint32 { return &val }
+
+	tests := map[string]struct {
+		hp        *contour_api_v1.HTTPPolicy
+		want      *HTTPPolicy
+		wantLimit uint32
+		wantErr   bool
+	}{
+		"nil http policy": {
+			hp:   nil,
+			want: nil,
+		},
+		"buffer limit only": {
+			hp: &contour_api_v1.HTTPPolicy{
+				PerRequestBufferLimitBytes: u32(8192),
+			},
+			want:      &HTTPPolicy{},
+			wantLimit: 8192,
+		},
+		"timeouts and http/1 settings": {
+			hp: &contour_api_v1.HTTPPolicy{
+				RequestTimeout:        "infinity",
+				RequestHeadersTimeout: "5s",
+				StreamIdleTimeout:     "10m",
+				MaxConnectionDuration: "1h",
+				HTTP1: &contour_api_v1.HTTP1Policy{
+					AllowAbsoluteURLs: true,
+					ProperCaseHeaders: true,
+				},
+			},
+			want: &HTTPPolicy{
+				RequestTimeout:        timeout.DisabledSetting(),
+				RequestHeadersTimeout: timeout.DurationSetting(5 * time.Second),
+				StreamIdleTimeout:     timeout.DurationSetting(10 * time.Minute),
+				MaxConnectionDuration: timeout.DurationSetting(time.Hour),
+				AllowAbsoluteURLs:     true,
+				ProperCaseHeaders:     true,
+			},
+		},
+		"invalid request headers timeout": {
+			hp: &contour_api_v1.HTTPPolicy{
+				RequestHeadersTimeout: "5",
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotLimit, gotErr := httpPolicy(tc.hp)
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.NoError(t, gotErr)
+				assert.Equal(t, tc.want, got)
+				assert.Equal(t, tc.wantLimit, gotLimit)
+			}
+		})
+	}
+}
+
+func TestConnectionPolicy(t *testing.T) {
+	u32 := func(val uint32) *uint32 { return &val }
+
+	defaults := ConnectionPolicy{
+		IdleTimeout:              timeout.DurationSetting(60 * time.Second),
+		MaxRequestsPerConnection: 1000,
+	}
+
+	tests := map[string]struct {
+		cp      *contour_api_v1.ConnectionPolicy
+		want    ConnectionPolicy
+		wantErr bool
+	}{
+		"nil connection policy": {
+			cp:   nil,
+			want: defaults,
+		},
+		"empty connection policy": {
+			cp:   &contour_api_v1.ConnectionPolicy{},
+			want: defaults,
+		},
+		"override idle timeout": {
+			cp: &contour_api_v1.ConnectionPolicy{
+				IdleTimeout: "4s",
+			},
+			want: ConnectionPolicy{
+				IdleTimeout:              timeout.DurationSetting(4 * time.Second),
+				MaxRequestsPerConnection: 1000,
+			},
+		},
+		"disable idle timeout": {
+			cp: &contour_api_v1.ConnectionPolicy{
+				IdleTimeout: "infinity",
+			},
+			want: ConnectionPolicy{
+				IdleTimeout:              timeout.DisabledSetting(),
+				MaxRequestsPerConnection: 1000,
+			},
+		},
+		"all fields": {
+			cp: &contour_api_v1.ConnectionPolicy{
+				IdleTimeout:                   "4s",
+				MaxRequestsPerConnection:      u32(10),
+				MaxStreamDuration:             "5m",
+				PerConnectionBufferLimitBytes: u32(32768),
+			},
+			want: ConnectionPolicy{
+				IdleTimeout:                   timeout.DurationSetting(4 * time.Second),
+				MaxRequestsPerConnection:      10,
+				MaxStreamDuration:             timeout.DurationSetting(5 * time.Minute),
+				PerConnectionBufferLimitBytes: 32768,
+			},
+		},
+		"invalid idle timeout": {
+			cp: &contour_api_v1.ConnectionPolicy{
+				IdleTimeout: "4",
+			},
+			wantErr: true,
+		},
+		"invalid max stream duration": {
+			cp: &contour_api_v1.ConnectionPolicy{
+				MaxStreamDuration: "forever",
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := connectionPolicy(defaults, tc.cp)
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.Equal(t, tc.want, got)
+				assert.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
 func TestLoadBalancerPolicy(t *testing.T) {
 	tests := map[string]struct {
 		lbp  *contour_api_v1.LoadBalancerPolicy
@@ -302,6 +556,63 @@ func TestLoadBalancerPolicy(t *testing.T) {
 	}
 }
 
+func TestMirrorPartsPerMillion(t *testing.T) {
+	tests := map[string]struct {
+		percentage string
+		want       uint32
+		wantErr    bool
+	}{
+		"empty": {
+			percentage: "",
+			want:       MirrorAll,
+		},
+		"hundred": {
+			percentage: "100.0",
+			want:       MirrorAll,
+		},
+		"zero": {
+			percentage: "0",
+			want:       0,
+		},
+		"whole percentage": {
+			percentage: "25",
+			want:       250000,
+		},
+		"fractional percentage": {
+			percentage: "0.0001",
+			want:       1,
+		},
+		"more than hundred": {
+			percentage: "100.5",
+			wantErr:    true,
+		},
+		"too many decimal places": {
+			percentage: "1.00001",
+			wantErr:    true,
+		},
+		"negative": {
+			percentage: "-1",
+			wantErr:    true,
+		},
+		"not a number": {
+			percentage: "ten",
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := mirrorPartsPerMillion(tc.percentage)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestHeadersPolicy(t *testing.T) {
 	tests := map[string]struct {
 		hp      *contour_api_v1.HeadersPolicy
@@ -20,13 +20,19 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"golang.org/x/crypto/ocsp"
 	v1 "k8s.io/api/core/v1"
 )
 
 // CACertificateKey is the key name for accessing TLS CA certificate bundles in Kubernetes Secrets.
 const CACertificateKey = "ca.crt"
 
+// OCSPStapleKey is the key name for accessing a DER encoded OCSP response
+// to staple to the TLS certificate in Kubernetes Secrets.
+const OCSPStapleKey = "tls.ocsp-staple"
+
 // isValidSecret returns true if the secret is interesting and well
 // formed. TLS certificate/key pairs must be secrets of type
 // "kubernetes.io/tls". Certificate bundles may be "kubernetes.io/tls"
@@ -53,6 +59,14 @@ func isValidSecret(secret *v1.Secret) (bool, error) {
 			return false, fmt.Errorf("invalid TLS private key: %v", err)
 		}
 
+		// The OCSP staple is optional, but if it is present it
+		// must at least be a well formed OCSP response.
+		if data := secret.Data[OCSPStapleKey]; len(data) > 0 {
+			if _, err := ocsp.ParseResponse(data, nil); err != nil {
+				return false, fmt.Errorf("invalid OCSP staple: %v", err)
+			}
+		}
+
 	// Generic secrets may have a 'ca.crt' only.
 	case v1.SecretTypeOpaque, "":
 		if _, ok := secret.Data[v1.TLSCertKey]; ok {
@@ -145,6 +159,34 @@ func validateCertificate(data []byte) error {
 	return nil
 }
 
+// certificateNotAfter returns the earliest NotAfter time of the PEM
+// encoded certificates in data. The zero time is returned if data
+// holds no parseable certificates.
+func certificateNotAfter(data []byte) time.Time {
+	var notAfter time.Time
+
+	for containsPEMHeader(data) {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		if notAfter.IsZero() || cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+
+	return notAfter
+}
+
 func hasCommonName(c *x509.Certificate) bool {
 	return strings.TrimSpace(c.Subject.CommonName) != ""
 }
@@ -14,12 +14,21 @@
 package dag
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -70,6 +79,77 @@ func TestIsValidSecret(t *testing.T) {
 	}
 }
 
+func TestIsValidSecretOCSPStaple(t *testing.T) {
+	data := secretdata(fixture.CERTIFICATE, fixture.RSA_PRIVATE_KEY)
+	data[OCSPStapleKey] = []byte("not an OCSP response")
+
+	valid, err := isValidSecret(&v1.Secret{
+		Type: v1.SecretTypeTLS,
+		Data: data,
+	})
+	assert.False(t, valid)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid OCSP staple")
+
+	// An empty staple is ignored.
+	data[OCSPStapleKey] = []byte{}
+	valid, err = isValidSecret(&v1.Secret{
+		Type: v1.SecretTypeTLS,
+		Data: data,
+	})
+	assert.True(t, valid)
+	assert.NoError(t, err)
+}
+
+func TestCertificateNotAfter(t *testing.T) {
+	assert.True(t, certificateNotAfter(nil).IsZero())
+	assert.True(t, certificateNotAfter([]byte("not a certificate")).IsZero())
+
+	soon := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	later := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	cert1, _ := selfSignedCertificate(t, "www.example.com", later)
+	cert2, _ := selfSignedCertificate(t, "www.example.com", soon)
+
+	// The earliest expiry in the bundle wins.
+	assert.Equal(t, soon, certificateNotAfter([]byte(cert1+cert2)))
+	assert.Equal(t, later, (&Secret{Object: &v1.Secret{
+		Data: secretdata(cert1, ""),
+	}}).NotAfter())
+	assert.Equal(t, soon, (&Secret{Object: &v1.Secret{
+		Data: caBundleData(cert1, cert2),
+	}}).CANotAfter())
+}
+
+// selfSignedCertificate returns a PEM encoded self-signed certificate
+// and private key for name that expires at notAfter.
+func selfSignedCertificate(t *testing.T, name string, notAfter time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
 func secretdata(cert, key string) map[string][]byte {
 	return map[string][]byte{
 		v1.TLSCertKey:       []byte(cert),
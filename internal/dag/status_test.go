@@ -15,10 +15,13 @@ package dag
 
 import (
 	"testing"
+	"time"
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	contour_api_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/internal/status"
+	"github.com/projectcontour/contour/pkg/config"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
@@ -30,9 +33,13 @@ import (
 func TestDAGStatus(t *testing.T) {
 
 	type testcase struct {
-		objs                []interface{}
-		fallbackCertificate *types.NamespacedName
-		want                map[types.NamespacedName]contour_api_v1.DetailedCondition
+		objs                     []interface{}
+		fallbackCertificate      *types.NamespacedName
+		ocspStaples              OCSPStaples
+		certificateExpiryWarning time.Duration
+		configRejections         ConfigRejections
+		listeners                map[string]config.ListenerProtocol
+		want                     map[types.NamespacedName]contour_api_v1.DetailedCondition
 	}
 
 	run := func(t *testing.T, desc string, tc testcase) {
@@ -48,8 +55,15 @@ func TestDAGStatus(t *testing.T) {
 					&IngressProcessor{
 						FieldLogger: fixture.NewTestLogger(t),
 					},
+					&ExtensionServiceProcessor{
+						FieldLogger: fixture.NewTestLogger(t),
+					},
 					&HTTPProxyProcessor{
-						FallbackCertificate: tc.fallbackCertificate,
+						FallbackCertificate:      tc.fallbackCertificate,
+						OCSPStaples:              tc.ocspStaples,
+						Listeners:                tc.listeners,
+						ConfigRejections:         tc.configRejections,
+						CertificateExpiryWarning: tc.certificateExpiryWarning,
 					},
 					&ListenerProcessor{},
 				},
@@ -1036,7 +1050,7 @@ func TestDAGStatus(t *testing.T) {
 		},
 	})
 
-	proxyInvalidTwoMirrors := &contour_api_v1.HTTPProxy{
+	proxyValidTwoMirrors := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "www",
 			Namespace: fixture.ServiceRootsKuard.Namespace,
@@ -1047,27 +1061,136 @@ func TestDAGStatus(t *testing.T) {
 			},
 			Routes: []contour_api_v1.Route{{
 				Services: []contour_api_v1.Service{{
-					Name: fixture.ServiceRootsKuard.Name,
-					Port: 8080,
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
 				}, {
-					Name:   fixture.ServiceRootsKuard.Name,
-					Port:   8080,
-					Mirror: true,
+					Name:             fixture.ServiceRootsKuard.Name,
+					Namespace:        fixture.ServiceRootsKuard.Namespace,
+					Port:             8080,
+					Mirror:           true,
+					MirrorPercentage: "12.5",
 				}, {
-					Name:   fixture.ServiceRootsKuard.Name,
-					Port:   8080,
-					Mirror: true,
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+					Mirror:    true,
 				}},
 			}},
 		},
 	}
 
 	run(t, "proxy with two mirrors", testcase{
-		objs: []interface{}{proxyInvalidTwoMirrors, fixture.ServiceRootsKuard},
+		objs: []interface{}{proxyValidTwoMirrors, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyValidTwoMirrors.Name, Namespace: proxyValidTwoMirrors.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyValidTwoMirrors.Generation).
+				Valid(),
+		},
+	})
+
+	proxyInvalidMirrorPercentage := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "www",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+				}, {
+					Name:             fixture.ServiceRootsKuard.Name,
+					Namespace:        fixture.ServiceRootsKuard.Namespace,
+					Port:             8080,
+					Mirror:           true,
+					MirrorPercentage: "101",
+				}},
+			}},
+		},
+	}
+
+	run(t, "proxy with invalid mirror percentage", testcase{
+		objs: []interface{}{proxyInvalidMirrorPercentage, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyInvalidMirrorPercentage.Name, Namespace: proxyInvalidMirrorPercentage.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyInvalidMirrorPercentage.Generation).
+				WithError(contour_api_v1.ConditionTypeServiceError, "MirrorPercentageInvalid",
+					`service "kuard": mirrorPercentage "101" must be a number between 0 and 100 with up to four decimal places`),
+		},
+	})
+
+	proxyMirrorPercentageWithoutMirror := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "www",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name:             fixture.ServiceRootsKuard.Name,
+					Namespace:        fixture.ServiceRootsKuard.Namespace,
+					Port:             8080,
+					MirrorPercentage: "50",
+				}},
+			}},
+		},
+	}
+
+	run(t, "proxy with mirror percentage on a non-mirror service", testcase{
+		objs: []interface{}{proxyMirrorPercentageWithoutMirror, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyMirrorPercentageWithoutMirror.Name, Namespace: proxyMirrorPercentageWithoutMirror.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyMirrorPercentageWithoutMirror.Generation).
+				WithError(contour_api_v1.ConditionTypeServiceError, "MirrorPercentageInvalid",
+					`service "kuard": mirrorPercentage may only be set on a mirror service`),
+		},
+	})
+
+	proxyMirrorWithHeadersPolicy := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "www",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+				}, {
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+					Mirror:    true,
+					RequestHeadersPolicy: &contour_api_v1.HeadersPolicy{
+						Set: []contour_api_v1.HeaderValue{{Name: "x-mirror", Value: "true"}},
+					},
+				}},
+			}},
+		},
+	}
+
+	mirrorHeadersPolicyCondition := fixture.NewValidCondition().
+		WithGeneration(proxyMirrorWithHeadersPolicy.Generation).
+		Valid()
+	mirrorHeadersPolicyCondition.AddWarning(contour_api_v1.ConditionTypeServiceError, "IgnoredField",
+		`service "kuard": ignoring field "requestHeadersPolicy"; header policies are not supported on mirror services`)
+
+	run(t, "proxy with header policy on a mirror service", testcase{
+		objs: []interface{}{proxyMirrorWithHeadersPolicy, fixture.ServiceRootsKuard},
 		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
-			{Name: proxyInvalidTwoMirrors.Name, Namespace: proxyInvalidTwoMirrors.Namespace}: fixture.NewValidCondition().
-				WithGeneration(proxyInvalidTwoMirrors.Generation).
-				WithError(contour_api_v1.ConditionTypeServiceError, "OnlyOneMirror", "only one service per route may be nominated as mirror"),
+			{Name: proxyMirrorWithHeadersPolicy.Name, Namespace: proxyMirrorWithHeadersPolicy.Namespace}: mirrorHeadersPolicyCondition,
 		},
 	})
 
@@ -2369,4 +2492,840 @@ func TestDAGStatus(t *testing.T) {
 		},
 	})
 
+	proxyMustStaple := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "must-staple",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName:       fixture.SecretRootsCert.Name,
+					OCSPStaplePolicy: "MustStaple",
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+				}},
+			}},
+		},
+	}
+
+	run(t, "MustStaple without an OCSP response falls back to LenientStapling", testcase{
+		objs: []interface{}{proxyMustStaple, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyMustStaple.Name, Namespace: proxyMustStaple.Namespace}: fixture.NewValidCondition().
+				AddWarning(contour_api_v1.ConditionTypeTLSError, "OCSPStapleMissing",
+					`Spec.VirtualHost.TLS Secret "ssl-cert" has no OCSP response, falling back to LenientStapling`).
+				Valid(),
+		},
+	})
+
+	run(t, "MustStaple with a fetched OCSP response", testcase{
+		objs:        []interface{}{proxyMustStaple, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		ocspStaples: stapledSecrets{fixture.SecretRootsCert.Name: true},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyMustStaple.Name, Namespace: proxyMustStaple.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	certExpiresAt := time.Now().Add(7 * 24 * time.Hour)
+	certExpiredAt := time.Now().Add(-time.Hour)
+	expiringCert, expiringKey := selfSignedCertificate(t, "example.com", certExpiresAt)
+	expiredCert, expiredKey := selfSignedCertificate(t, "example.com", certExpiredAt)
+
+	secretExpiring := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "expiring",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Type: v1.SecretTypeTLS,
+		Data: secretdata(expiringCert, expiringKey),
+	}
+
+	secretExpired := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "expired",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Type: v1.SecretTypeTLS,
+		Data: secretdata(expiredCert, expiredKey),
+	}
+
+	proxyCertificateExpiring := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "certificate-expiring",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: secretExpiring.Name,
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+				}},
+			}},
+		},
+	}
+
+	proxyCertificateExpired := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "certificate-expired",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: secretExpired.Name,
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+				}},
+			}},
+		},
+	}
+
+	run(t, "certificate expiring within the warning window", testcase{
+		objs:                     []interface{}{proxyCertificateExpiring, secretExpiring, fixture.ServiceRootsKuard},
+		certificateExpiryWarning: 30 * 24 * time.Hour,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyCertificateExpiring.Name, Namespace: proxyCertificateExpiring.Namespace}: fixture.NewValidCondition().
+				AddWarning(contour_api_v1.ConditionTypeTLSError, "CertificateExpiring",
+					`Spec.VirtualHost.TLS Secret "expiring" certificate expires at `+certExpiresAt.UTC().Format(time.RFC3339)).
+				Valid(),
+		},
+	})
+
+	run(t, "certificate expiring outside the warning window", testcase{
+		objs:                     []interface{}{proxyCertificateExpiring, secretExpiring, fixture.ServiceRootsKuard},
+		certificateExpiryWarning: 24 * time.Hour,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyCertificateExpiring.Name, Namespace: proxyCertificateExpiring.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	run(t, "certificate expiry warnings are disabled by default", testcase{
+		objs: []interface{}{proxyCertificateExpired, secretExpired, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyCertificateExpired.Name, Namespace: proxyCertificateExpired.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	run(t, "expired certificate is served with a warning", testcase{
+		objs:                     []interface{}{proxyCertificateExpired, secretExpired, fixture.ServiceRootsKuard},
+		certificateExpiryWarning: 24 * time.Hour,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyCertificateExpired.Name, Namespace: proxyCertificateExpired.Namespace}: fixture.NewValidCondition().
+				AddWarning(contour_api_v1.ConditionTypeTLSError, "CertificateExpired",
+					`Spec.VirtualHost.TLS Secret "expired" certificate expired at `+certExpiredAt.UTC().Format(time.RFC3339)).
+				Valid(),
+		},
+	})
+
+	proxyConfigRejected := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "config-rejected",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name:      fixture.ServiceRootsKuard.Name,
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Port:      8080,
+				}},
+			}},
+		},
+	}
+
+	run(t, "configuration rejected for another virtual host", testcase{
+		objs:             []interface{}{proxyConfigRejected, fixture.ServiceRootsKuard},
+		configRejections: rejectedVirtualHosts{"other.com": "bad config"},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyConfigRejected.Name, Namespace: proxyConfigRejected.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	run(t, "configuration rejected by Envoy is valid with a warning", testcase{
+		objs:             []interface{}{proxyConfigRejected, fixture.ServiceRootsKuard},
+		configRejections: rejectedVirtualHosts{"example.com": "bad config"},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyConfigRejected.Name, Namespace: proxyConfigRejected.Namespace}: fixture.NewValidCondition().
+				AddWarning(contour_api_v1.ConditionTypeEnvoyError, "ConfigRejected",
+					`Envoy rejected the configuration for "example.com": bad config`).
+				Valid(),
+		},
+	})
+
+	listeners := map[string]config.ListenerProtocol{
+		"internal": config.HTTPListenerProtocol,
+		"proxied":  config.HTTPSListenerProtocol,
+		"tcp":      config.TCPListenerProtocol,
+		"udp":      config.UDPListenerProtocol,
+	}
+
+	listenerTLS := &contour_api_v1.TLS{SecretName: "ssl-cert"}
+	kuardService := contour_api_v1.Service{
+		Name:      fixture.ServiceRootsKuard.Name,
+		Namespace: fixture.ServiceRootsKuard.Namespace,
+		Port:      8080,
+	}
+
+	proxyListenerHTTP := fixture.NewProxy("roots/listener-http").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "internal"},
+		Routes:      []contour_api_v1.Route{{Services: []contour_api_v1.Service{kuardService}}},
+	})
+
+	run(t, "http listener", testcase{
+		objs:      []interface{}{proxyListenerHTTP, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerHTTP.Name, Namespace: proxyListenerHTTP.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyListenerMissing := fixture.NewProxy("roots/listener-missing").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "missing"},
+		Routes:      []contour_api_v1.Route{{Services: []contour_api_v1.Service{kuardService}}},
+	})
+
+	run(t, "listener not found", testcase{
+		objs:      []interface{}{proxyListenerMissing, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerMissing.Name, Namespace: proxyListenerMissing.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerNotFound",
+				`Spec.VirtualHost.Listener "missing" is not configured`),
+		},
+	})
+
+	proxyListenerHTTPTLS := fixture.NewProxy("roots/listener-http-tls").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "internal", TLS: listenerTLS},
+		Routes:      []contour_api_v1.Route{{Services: []contour_api_v1.Service{kuardService}}},
+	})
+
+	run(t, "http listener with tls", testcase{
+		objs:      []interface{}{proxyListenerHTTPTLS, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerHTTPTLS.Name, Namespace: proxyListenerHTTPTLS.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				`Spec.VirtualHost.Listener "internal" serves http and cannot be used with Spec.VirtualHost.TLS`),
+		},
+	})
+
+	proxyListenerHTTPSNoTLS := fixture.NewProxy("roots/listener-https-no-tls").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "proxied"},
+		Routes:      []contour_api_v1.Route{{Services: []contour_api_v1.Service{kuardService}}},
+	})
+
+	run(t, "https listener without tls", testcase{
+		objs:      []interface{}{proxyListenerHTTPSNoTLS, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerHTTPSNoTLS.Name, Namespace: proxyListenerHTTPSNoTLS.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				`Spec.VirtualHost.Listener "proxied" serves https and requires Spec.VirtualHost.TLS`),
+		},
+	})
+
+	proxyListenerHTTPSFallback := fixture.NewProxy("roots/listener-https-fallback").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "proxied", TLS: &contour_api_v1.TLS{
+			SecretName:                "ssl-cert",
+			EnableFallbackCertificate: true,
+		}},
+		Routes: []contour_api_v1.Route{{Services: []contour_api_v1.Service{kuardService}}},
+	})
+
+	run(t, "https listener with fallback certificate", testcase{
+		objs:      []interface{}{proxyListenerHTTPSFallback, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerHTTPSFallback.Name, Namespace: proxyListenerHTTPSFallback.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				`Spec.VirtualHost.Listener "proxied" cannot be used with Spec.VirtualHost.TLS fallback certificates`),
+		},
+	})
+
+	proxyListenerTCPNoTCPProxy := fixture.NewProxy("roots/listener-tcp-no-tcpproxy").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "tcp", TLS: listenerTLS},
+		Routes:      []contour_api_v1.Route{{Services: []contour_api_v1.Service{kuardService}}},
+	})
+
+	run(t, "tcp listener without tcpproxy", testcase{
+		objs:      []interface{}{proxyListenerTCPNoTCPProxy, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerTCPNoTCPProxy.Name, Namespace: proxyListenerTCPNoTCPProxy.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				`Spec.VirtualHost.Listener "tcp" serves tcp and requires Spec.TCPProxy`),
+		},
+	})
+
+	proxyListenerTCP := fixture.NewProxy("roots/listener-tcp").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "tcp"},
+		TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuardService}},
+	})
+
+	run(t, "tcp listener without tls", testcase{
+		objs:      []interface{}{proxyListenerTCP, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerTCP.Name, Namespace: proxyListenerTCP.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyListenerHTTPTCPProxy := fixture.NewProxy("roots/listener-http-tcpproxy").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "internal"},
+		TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuardService}},
+	})
+
+	run(t, "tcpproxy without tls on an http listener", testcase{
+		objs:      []interface{}{proxyListenerHTTPTCPProxy, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerHTTPTCPProxy.Name, Namespace: proxyListenerHTTPTCPProxy.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeTCPProxyError, "TLSMustBeConfigured",
+				"Spec.TCPProxy requires that either Spec.TLS.Passthrough or Spec.TLS.SecretName be set"),
+		},
+	})
+
+	proxyListenerUDP := fixture.NewProxy("roots/listener-udp").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "udp"},
+		TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuardService}},
+	})
+
+	run(t, "udp listener", testcase{
+		objs:      []interface{}{proxyListenerUDP, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerUDP.Name, Namespace: proxyListenerUDP.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyListenerUDPTLS := fixture.NewProxy("roots/listener-udp-tls").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "udp", TLS: listenerTLS},
+		TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuardService}},
+	})
+
+	run(t, "udp listener with tls", testcase{
+		objs:      []interface{}{proxyListenerUDPTLS, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerUDPTLS.Name, Namespace: proxyListenerUDPTLS.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerProtocolMismatch",
+				`Spec.VirtualHost.Listener "udp" serves udp and cannot be used with Spec.VirtualHost.TLS`),
+		},
+	})
+
+	proxyListenerUDPWeighted := fixture.NewProxy("roots/listener-udp-weighted").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com", Listener: "udp"},
+		TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuardService, kuardService}},
+	})
+
+	run(t, "udp listener with weighted services", testcase{
+		objs:      []interface{}{proxyListenerUDPWeighted, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerUDPWeighted.Name, Namespace: proxyListenerUDPWeighted.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeTCPProxyError, "UDPProxyNotValid",
+				"Spec.TCPProxy on a UDP listener must have exactly one service"),
+		},
+	})
+
+	proxyListenerSharedA := fixture.NewProxy("roots/a").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "a.example.com", Listener: "tcp", TLS: &contour_api_v1.TLS{Passthrough: true}},
+		TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuardService}},
+	})
+
+	proxyListenerSharedB := fixture.NewProxy("roots/b").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "b.example.com", Listener: "tcp", TLS: &contour_api_v1.TLS{Passthrough: true}},
+		TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuardService}},
+	})
+
+	proxyListenerWholeB := fixture.NewProxy("roots/b").WithSpec(contour_api_v1.HTTPProxySpec{
+		VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "b.example.com", Listener: "tcp"},
+		TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuardService}},
+	})
+
+	run(t, "virtual hosts selected by SNI can share a listener", testcase{
+		objs:      []interface{}{proxyListenerSharedA, proxyListenerSharedB, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerSharedA.Name, Namespace: proxyListenerSharedA.Namespace}: fixture.NewValidCondition().Valid(),
+			{Name: proxyListenerSharedB.Name, Namespace: proxyListenerSharedB.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	run(t, "virtual host without tls conflicts with others on its listener", testcase{
+		objs:      []interface{}{proxyListenerSharedA, proxyListenerWholeB, fixture.ServiceRootsKuard},
+		listeners: listeners,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyListenerSharedA.Name, Namespace: proxyListenerSharedA.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerConflict",
+				`Spec.VirtualHost.Listener "tcp" is used by multiple HTTPProxies and one of them takes the whole listener: roots/a, roots/b`),
+			{Name: proxyListenerWholeB.Name, Namespace: proxyListenerWholeB.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerConflict",
+				`Spec.VirtualHost.Listener "tcp" is used by multiple HTTPProxies and one of them takes the whole listener: roots/a, roots/b`),
+		},
+	})
+
+	requestBufferLimit := uint32(8192)
+
+	proxyHTTPPolicyBufferLimit := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "http-policy-buffer-limit",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				HTTPPolicy: &contour_api_v1.HTTPPolicy{
+					PerRequestBufferLimitBytes: &requestBufferLimit,
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "http policy request buffer limit applies without tls", testcase{
+		objs: []interface{}{proxyHTTPPolicyBufferLimit, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyHTTPPolicyBufferLimit.Name, Namespace: proxyHTTPPolicyBufferLimit.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyHTTPPolicyNoTLS := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "http-policy-no-tls",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				HTTPPolicy: &contour_api_v1.HTTPPolicy{
+					RequestTimeout: "1m",
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "http policy connection settings are ignored without tls", testcase{
+		objs: []interface{}{proxyHTTPPolicyNoTLS, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyHTTPPolicyNoTLS.Name, Namespace: proxyHTTPPolicyNoTLS.Namespace}: fixture.NewValidCondition().
+				AddWarning(contour_api_v1.ConditionTypeVirtualHostError, "IgnoredField",
+					`ignoring field "Spec.VirtualHost.HTTPPolicy"; timeouts and HTTP/1 settings only apply to virtual hosts that terminate TLS`).
+				Valid(),
+		},
+	})
+
+	proxyHTTPPolicyInvalid := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "http-policy-invalid",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				HTTPPolicy: &contour_api_v1.HTTPPolicy{
+					StreamIdleTimeout: "1",
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "http policy with invalid stream idle timeout is invalid", testcase{
+		objs: []interface{}{proxyHTTPPolicyInvalid, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyHTTPPolicyInvalid.Name, Namespace: proxyHTTPPolicyInvalid.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "HTTPPolicyNotValid",
+				`Spec.VirtualHost.HTTPPolicy is invalid: error parsing stream idle timeout: unable to parse timeout string "1": time: missing unit in duration "1"`),
+		},
+	})
+
+	configMapErrorPages := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "error-pages",
+		},
+		Data: map[string]string{
+			"unavailable.html": "<h1>unavailable</h1>",
+		},
+	}
+
+	proxyErrorPages := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "error-pages",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+				ErrorPagePolicy: &contour_api_v1.ErrorPagePolicy{
+					Pages: []contour_api_v1.ErrorPage{{
+						StatusCodes: []uint32{502, 503},
+						ConfigMap:   configMapErrorPages.Name,
+						Key:         "unavailable.html",
+					}},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "error pages with tls", testcase{
+		objs: []interface{}{proxyErrorPages, configMapErrorPages, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyErrorPages.Name, Namespace: proxyErrorPages.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyErrorPagesNoTLS := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "error-pages-no-tls",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				ErrorPagePolicy: &contour_api_v1.ErrorPagePolicy{
+					Pages: []contour_api_v1.ErrorPage{{
+						StatusCodes: []uint32{503},
+						ConfigMap:   configMapErrorPages.Name,
+						Key:         "unavailable.html",
+					}},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "error pages are ignored without tls", testcase{
+		objs: []interface{}{proxyErrorPagesNoTLS, configMapErrorPages, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyErrorPagesNoTLS.Name, Namespace: proxyErrorPagesNoTLS.Namespace}: fixture.NewValidCondition().
+				AddWarning(contour_api_v1.ConditionTypeVirtualHostError, "IgnoredField",
+					`ignoring field "Spec.VirtualHost.ErrorPagePolicy"; error pages only apply to virtual hosts that terminate TLS`).
+				Valid(),
+		},
+	})
+
+	proxyErrorPagesMissingKey := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "error-pages-missing-key",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+				ErrorPagePolicy: &contour_api_v1.ErrorPagePolicy{
+					Pages: []contour_api_v1.ErrorPage{{
+						StatusCodes: []uint32{503},
+						ConfigMap:   configMapErrorPages.Name,
+						Key:         "missing.html",
+					}},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "error page with missing configmap key is invalid", testcase{
+		objs: []interface{}{proxyErrorPagesMissingKey, configMapErrorPages, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyErrorPagesMissingKey.Name, Namespace: proxyErrorPagesMissingKey.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ErrorPagePolicyNotValid",
+				`Spec.VirtualHost.ErrorPagePolicy is invalid: error page ConfigMap "roots/error-pages" has no key "missing.html"`),
+		},
+	})
+
+	proxyErrorPagesRedirect := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "error-pages-redirect",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+				ErrorPagePolicy: &contour_api_v1.ErrorPagePolicy{
+					Pages: []contour_api_v1.ErrorPage{{
+						StatusCodes: []uint32{302},
+						ConfigMap:   configMapErrorPages.Name,
+						Key:         "unavailable.html",
+					}},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "error page with redirect status code is invalid", testcase{
+		objs: []interface{}{proxyErrorPagesRedirect, configMapErrorPages, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyErrorPagesRedirect.Name, Namespace: proxyErrorPagesRedirect.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeVirtualHostError, "ErrorPagePolicyNotValid",
+				`Spec.VirtualHost.ErrorPagePolicy is invalid: invalid error page status code 302`),
+		},
+	})
+
+	extensionServiceAuth := &contour_api_v1alpha1.ExtensionService{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "auth",
+		},
+		Spec: contour_api_v1alpha1.ExtensionServiceSpec{
+			Services: []contour_api_v1alpha1.ExtensionServiceTarget{{
+				Name: fixture.ServiceRootsKuard.Name,
+				Port: 8080,
+			}},
+		},
+	}
+
+	proxyBufferPolicy := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "buffer-policy",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+				Authorization: &contour_api_v1.AuthorizationServer{
+					ExtensionServiceRef: contour_api_v1.ExtensionServiceReference{
+						Name: extensionServiceAuth.Name,
+					},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions:   []contour_api_v1.MatchCondition{{Prefix: "/upload"}},
+				BufferPolicy: &contour_api_v1.BufferPolicy{MaxRequestBytes: 8192},
+				Services:     []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "buffer policy on every authorized route", testcase{
+		objs: []interface{}{proxyBufferPolicy, extensionServiceAuth, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyBufferPolicy.Name, Namespace: proxyBufferPolicy.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyBufferPolicyOtherRoutes := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "buffer-policy-other-routes",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+				Authorization: &contour_api_v1.AuthorizationServer{
+					ExtensionServiceRef: contour_api_v1.ExtensionServiceReference{
+						Name: extensionServiceAuth.Name,
+					},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions:   []contour_api_v1.MatchCondition{{Prefix: "/upload"}},
+				BufferPolicy: &contour_api_v1.BufferPolicy{MaxRequestBytes: 8192},
+				Services:     []contour_api_v1.Service{kuardService},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+				Services:   []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "buffer policy sends request bodies of other authorized routes", testcase{
+		objs: []interface{}{proxyBufferPolicyOtherRoutes, extensionServiceAuth, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyBufferPolicyOtherRoutes.Name, Namespace: proxyBufferPolicyOtherRoutes.Namespace}: fixture.NewValidCondition().
+				AddWarning(contour_api_v1.ConditionTypeVirtualHostError, "AuthorizationRequestBody",
+					"routes with a buffer policy make authorized routes without one also send up to 8192 bytes of their request body to the authorization server").
+				Valid(),
+		},
+	})
+
+	proxyBufferPolicyAuthDisabled := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "buffer-policy-auth-disabled",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+				Authorization: &contour_api_v1.AuthorizationServer{
+					ExtensionServiceRef: contour_api_v1.ExtensionServiceReference{
+						Name: extensionServiceAuth.Name,
+					},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions:   []contour_api_v1.MatchCondition{{Prefix: "/upload"}},
+				BufferPolicy: &contour_api_v1.BufferPolicy{MaxRequestBytes: 8192},
+				Services:     []contour_api_v1.Service{kuardService},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+				AuthPolicy: &contour_api_v1.AuthorizationPolicy{Disabled: true},
+				Services:   []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "buffer policy with authorization disabled on other routes", testcase{
+		objs: []interface{}{proxyBufferPolicyAuthDisabled, extensionServiceAuth, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyBufferPolicyAuthDisabled.Name, Namespace: proxyBufferPolicyAuthDisabled.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyInternalRedirect := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "internal-redirect",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				InternalRedirectPolicy: &contour_api_v1.InternalRedirectPolicy{
+					AllowedHosts: []string{"storage.example.com"},
+				},
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "internal redirect to another host without tls", testcase{
+		objs: []interface{}{proxyInternalRedirect, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyInternalRedirect.Name, Namespace: proxyInternalRedirect.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyInternalRedirectTLS := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "internal-redirect-tls",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+			},
+			Routes: []contour_api_v1.Route{{
+				InternalRedirectPolicy: &contour_api_v1.InternalRedirectPolicy{
+					AllowedHosts: []string{"example.com"},
+				},
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "internal redirect to the same host with tls", testcase{
+		objs: []interface{}{proxyInternalRedirectTLS, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyInternalRedirectTLS.Name, Namespace: proxyInternalRedirectTLS.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyInternalRedirectTLSOtherHost := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "internal-redirect-tls-other-host",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+			},
+			Routes: []contour_api_v1.Route{{
+				InternalRedirectPolicy: &contour_api_v1.InternalRedirectPolicy{
+					AllowedHosts: []string{"storage.example.com"},
+				},
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "internal redirect to another host with tls is unreachable", testcase{
+		objs: []interface{}{proxyInternalRedirectTLSOtherHost, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyInternalRedirectTLSOtherHost.Name, Namespace: proxyInternalRedirectTLSOtherHost.Namespace}: fixture.NewValidCondition().
+				AddWarning(contour_api_v1.ConditionTypeRouteError, "InternalRedirectHostUnreachable",
+					`route.internalRedirectPolicy.allowedHosts "storage.example.com" is not reachable from TLS virtual host "example.com"`).
+				Valid(),
+		},
+	})
+
+	proxyInternalRedirectInvalidHost := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+			Name:      "internal-redirect-invalid-host",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				InternalRedirectPolicy: &contour_api_v1.InternalRedirectPolicy{
+					AllowedHosts: []string{"Storage_Example"},
+				},
+				Services: []contour_api_v1.Service{kuardService},
+			}},
+		},
+	}
+
+	run(t, "internal redirect with invalid allowed host is invalid", testcase{
+		objs: []interface{}{proxyInternalRedirectInvalidHost, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyInternalRedirectInvalidHost.Name, Namespace: proxyInternalRedirectInvalidHost.Namespace}: fixture.NewValidCondition().WithError(contour_api_v1.ConditionTypeRouteError, "InternalRedirectPolicyNotValid",
+				`route.internalRedirectPolicy is invalid: invalid allowed host "Storage_Example": [a DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')]`),
+		},
+	})
+}
+
+// rejectedVirtualHosts is a ConfigRejections that rejects a fixed
+// set of virtual hosts.
+type rejectedVirtualHosts map[string]string
+
+func (r rejectedVirtualHosts) RejectedVirtualHost(fqdn string) (string, bool) {
+	reason, ok := r[fqdn]
+	return reason, ok
+}
+
+// stapledSecrets is an OCSPStaples that has OCSP responses for
+// a fixed set of secrets, by name.
+type stapledSecrets map[string]bool
+
+func (s stapledSecrets) Stapled(secret *Secret) bool {
+	return s[secret.Object.Name]
 }
@@ -0,0 +1,796 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/timeout"
+)
+
+// DAGCache is a dag.Observer that keeps the latest DAG.
+type DAGCache struct {
+	mu     sync.Mutex
+	latest *dag.DAG
+}
+
+// OnChange implements dag.Observer.
+func (c *DAGCache) OnChange(d *dag.DAG) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latest = d
+}
+
+// Latest returns the latest DAG, or nil if no DAG has been built.
+func (c *DAGCache) Latest() *dag.DAG {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.latest
+}
+
+// DAGDump is the JSON representation of a DAG. Secrets are listed
+// by name, without their contents.
+type DAGDump struct {
+	Listeners       []ListenerDump      `json:"listeners"`
+	ListenerProxies []ListenerProxyDump `json:"listenerProxies,omitempty"`
+	VirtualHosts    []VirtualHostDump   `json:"virtualHosts"`
+	Clusters        []ClusterDump       `json:"clusters"`
+	Services        []ServiceDump       `json:"services"`
+	Secrets         []SecretDump        `json:"secrets"`
+}
+
+// ListenerDump is the JSON representation of a dag.Listener.
+type ListenerDump struct {
+	Address      string          `json:"address"`
+	Port         int             `json:"port"`
+	VirtualHosts []string        `json:"virtualHosts"`
+	ErrorPages   []ErrorPageDump `json:"errorPages,omitempty"`
+}
+
+// ListenerProxyDump is the JSON representation of a
+// dag.ListenerProxy.
+type ListenerProxyDump struct {
+	Listener    string            `json:"listener"`
+	VirtualHost string            `json:"virtualHost"`
+	EnvoyGroup  string            `json:"envoyGroup,omitempty"`
+	TCPProxy    []WeightedCluster `json:"tcpProxy"`
+}
+
+// VirtualHostDump is the JSON representation of a dag.VirtualHost
+// or dag.SecureVirtualHost.
+type VirtualHostDump struct {
+	Name                       string               `json:"name"`
+	Secure                     bool                 `json:"secure"`
+	EnvoyGroup                 string               `json:"envoyGroup,omitempty"`
+	Listener                   string               `json:"listener,omitempty"`
+	TLS                        *TLSDump             `json:"tls,omitempty"`
+	HTTP3Port                  int                  `json:"http3Port,omitempty"`
+	HTTPPolicy                 *HTTPPolicyDump      `json:"httpPolicy,omitempty"`
+	PerRequestBufferLimitBytes uint32               `json:"perRequestBufferLimitBytes,omitempty"`
+	ErrorPages                 []ErrorPageDump      `json:"errorPages,omitempty"`
+	CORSPolicy                 *CORSPolicyDump      `json:"corsPolicy,omitempty"`
+	RateLimitPolicy            *RateLimitPolicyDump `json:"rateLimitPolicy,omitempty"`
+	Routes                     []RouteDump          `json:"routes,omitempty"`
+	TCPProxy                   []WeightedCluster    `json:"tcpProxy,omitempty"`
+}
+
+// HTTPPolicyDump is the JSON representation of a dag.HTTPPolicy.
+type HTTPPolicyDump struct {
+	RequestTimeout        string `json:"requestTimeout,omitempty"`
+	RequestHeadersTimeout string `json:"requestHeadersTimeout,omitempty"`
+	StreamIdleTimeout     string `json:"streamIdleTimeout,omitempty"`
+	MaxConnectionDuration string `json:"maxConnectionDuration,omitempty"`
+	AllowAbsoluteURLs     bool   `json:"allowAbsoluteURLs,omitempty"`
+	ProperCaseHeaders     bool   `json:"properCaseHeaders,omitempty"`
+}
+
+// ErrorPageDump is the JSON representation of a dag.ErrorPage.
+type ErrorPageDump struct {
+	StatusCodes        []uint32 `json:"statusCodes"`
+	Body               string   `json:"body"`
+	ContentType        string   `json:"contentType,omitempty"`
+	ResponseStatusCode uint32   `json:"responseStatusCode,omitempty"`
+}
+
+// TLSDump is the TLS configuration of a secure virtual host.
+type TLSDump struct {
+	Secret               string             `json:"secret,omitempty"`
+	FallbackCertificate  string             `json:"fallbackCertificate,omitempty"`
+	MinTLSVersion        string             `json:"minTLSVersion,omitempty"`
+	OCSPStaplePolicy     string             `json:"ocspStaplePolicy,omitempty"`
+	ClientValidation     *ValidationDump    `json:"clientValidation,omitempty"`
+	AuthorizationService *AuthorizationDump `json:"authorizationService,omitempty"`
+}
+
+// ValidationDump is the JSON representation of a
+// dag.PeerValidationContext.
+type ValidationDump struct {
+	CACertificate string `json:"caCertificate,omitempty"`
+	SubjectName   string `json:"subjectName,omitempty"`
+}
+
+// AuthorizationDump is the external authorization configuration of
+// a secure virtual host.
+type AuthorizationDump struct {
+	Cluster         string `json:"cluster"`
+	ResponseTimeout string `json:"responseTimeout,omitempty"`
+	FailOpen        bool   `json:"failOpen,omitempty"`
+}
+
+// CORSPolicyDump is the JSON representation of a dag.CORSPolicy.
+type CORSPolicyDump struct {
+	AllowCredentials bool     `json:"allowCredentials,omitempty"`
+	AllowOrigin      []string `json:"allowOrigin,omitempty"`
+	AllowMethods     []string `json:"allowMethods,omitempty"`
+	AllowHeaders     []string `json:"allowHeaders,omitempty"`
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"`
+	MaxAge           string   `json:"maxAge,omitempty"`
+}
+
+// RouteDump is the JSON representation of a dag.Route.
+type RouteDump struct {
+	PathMatch              string                      `json:"pathMatch"`
+	HeaderMatches          []string                    `json:"headerMatches,omitempty"`
+	Clusters               []WeightedCluster           `json:"clusters"`
+	HTTPSUpgrade           bool                        `json:"httpsUpgrade,omitempty"`
+	Websocket              bool                        `json:"websocket,omitempty"`
+	AuthDisabled           bool                        `json:"authDisabled,omitempty"`
+	AuthContext            map[string]string           `json:"authContext,omitempty"`
+	PrefixRewrite          string                      `json:"prefixRewrite,omitempty"`
+	ResponseTimeout        string                      `json:"responseTimeout,omitempty"`
+	IdleTimeout            string                      `json:"idleTimeout,omitempty"`
+	MaxStreamDuration      string                      `json:"maxStreamDuration,omitempty"`
+	RetryPolicy            *RetryPolicyDump            `json:"retryPolicy,omitempty"`
+	MirrorPolicies         []WeightedCluster           `json:"mirrorPolicies,omitempty"`
+	RequestHeadersPolicy   *HeadersPolicyDump          `json:"requestHeadersPolicy,omitempty"`
+	ResponseHeadersPolicy  *HeadersPolicyDump          `json:"responseHeadersPolicy,omitempty"`
+	RateLimitPolicy        *RateLimitPolicyDump        `json:"rateLimitPolicy,omitempty"`
+	RequestHashPolicies    []RequestHashPolicyDump     `json:"requestHashPolicies,omitempty"`
+	BufferPolicy           *BufferPolicyDump           `json:"bufferPolicy,omitempty"`
+	InternalRedirectPolicy *InternalRedirectPolicyDump `json:"internalRedirectPolicy,omitempty"`
+}
+
+// BufferPolicyDump is the JSON representation of a dag.BufferPolicy.
+type BufferPolicyDump struct {
+	MaxRequestBytes uint32 `json:"maxRequestBytes"`
+}
+
+// InternalRedirectPolicyDump is the JSON representation of a
+// dag.InternalRedirectPolicy.
+type InternalRedirectPolicyDump struct {
+	MaxInternalRedirects     uint32   `json:"maxInternalRedirects,omitempty"`
+	RedirectResponseCodes    []uint32 `json:"redirectResponseCodes,omitempty"`
+	AllowCrossSchemeRedirect bool     `json:"allowCrossSchemeRedirect,omitempty"`
+	AllowedHosts             []string `json:"allowedHosts,omitempty"`
+}
+
+// HeadersPolicyDump is the JSON representation of a dag.HeadersPolicy.
+type HeadersPolicyDump struct {
+	HostRewrite string            `json:"hostRewrite,omitempty"`
+	Set         map[string]string `json:"set,omitempty"`
+	Remove      []string          `json:"remove,omitempty"`
+}
+
+// RateLimitPolicyDump is the JSON representation of a
+// dag.RateLimitPolicy.
+type RateLimitPolicyDump struct {
+	Local *LocalRateLimitPolicyDump `json:"local,omitempty"`
+}
+
+// LocalRateLimitPolicyDump is the JSON representation of a
+// dag.LocalRateLimitPolicy.
+type LocalRateLimitPolicyDump struct {
+	MaxTokens            uint32            `json:"maxTokens"`
+	TokensPerFill        uint32            `json:"tokensPerFill"`
+	FillInterval         string            `json:"fillInterval"`
+	ResponseStatusCode   uint32            `json:"responseStatusCode,omitempty"`
+	ResponseHeadersToAdd map[string]string `json:"responseHeadersToAdd,omitempty"`
+}
+
+// RequestHashPolicyDump is the JSON representation of a
+// dag.RequestHashPolicy.
+type RequestHashPolicyDump struct {
+	Terminal      bool   `json:"terminal,omitempty"`
+	HeaderName    string `json:"headerName,omitempty"`
+	CookieName    string `json:"cookieName,omitempty"`
+	CookieTTL     string `json:"cookieTTL,omitempty"`
+	CookiePath    string `json:"cookiePath,omitempty"`
+	SourceAddress bool   `json:"sourceAddress,omitempty"`
+}
+
+// RetryPolicyDump is the JSON representation of a dag.RetryPolicy.
+type RetryPolicyDump struct {
+	RetryOn              string   `json:"retryOn,omitempty"`
+	RetriableStatusCodes []uint32 `json:"retriableStatusCodes,omitempty"`
+	NumRetries           uint32   `json:"numRetries,omitempty"`
+	PerTryTimeout        string   `json:"perTryTimeout,omitempty"`
+}
+
+// WeightedCluster is a reference to a cluster. For mirror policies,
+// the weight is in parts per million.
+type WeightedCluster struct {
+	Name   string `json:"name"`
+	Weight uint32 `json:"weight,omitempty"`
+}
+
+// ClusterDump is the JSON representation of a dag.Cluster or
+// dag.ExtensionCluster.
+type ClusterDump struct {
+	Name                  string                `json:"name"`
+	Service               string                `json:"service"`
+	Protocol              string                `json:"protocol,omitempty"`
+	LoadBalancerPolicy    string                `json:"loadBalancerPolicy,omitempty"`
+	SNI                   string                `json:"sni,omitempty"`
+	DNSLookupFamily       string                `json:"dnsLookupFamily,omitempty"`
+	HealthCheck           string                `json:"healthCheck,omitempty"`
+	UpstreamValidation    *ValidationDump       `json:"upstreamValidation,omitempty"`
+	ClientCertificate     string                `json:"clientCertificate,omitempty"`
+	ConnectionPolicy      *ConnectionPolicyDump `json:"connectionPolicy,omitempty"`
+	RequestHeadersPolicy  *HeadersPolicyDump    `json:"requestHeadersPolicy,omitempty"`
+	ResponseHeadersPolicy *HeadersPolicyDump    `json:"responseHeadersPolicy,omitempty"`
+	ResponseTimeout       string                `json:"responseTimeout,omitempty"`
+	IdleTimeout           string                `json:"idleTimeout,omitempty"`
+}
+
+// ConnectionPolicyDump is the JSON representation of a
+// dag.ConnectionPolicy.
+type ConnectionPolicyDump struct {
+	IdleTimeout                   string `json:"idleTimeout,omitempty"`
+	MaxRequestsPerConnection      uint32 `json:"maxRequestsPerConnection,omitempty"`
+	MaxStreamDuration             string `json:"maxStreamDuration,omitempty"`
+	PerConnectionBufferLimitBytes uint32 `json:"perConnectionBufferLimitBytes,omitempty"`
+}
+
+// ServiceDump is the JSON representation of a dag.Service.
+type ServiceDump struct {
+	Name               string `json:"name"`
+	Port               int32  `json:"port"`
+	PortName           string `json:"portName,omitempty"`
+	Protocol           string `json:"protocol,omitempty"`
+	ExternalName       string `json:"externalName,omitempty"`
+	MaxConnections     uint32 `json:"maxConnections,omitempty"`
+	MaxPendingRequests uint32 `json:"maxPendingRequests,omitempty"`
+	MaxRequests        uint32 `json:"maxRequests,omitempty"`
+	MaxRetries         uint32 `json:"maxRetries,omitempty"`
+}
+
+// SecretDump names a secret used by the DAG.
+type SecretDump struct {
+	Name     string     `json:"name"`
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+}
+
+// DAGFilter selects the parts of the DAG to dump. Empty fields
+// select everything.
+type DAGFilter struct {
+	// FQDN selects the virtual hosts with this name, and the
+	// clusters, services and secrets they use.
+	FQDN string
+
+	// Cluster selects the cluster with this name, the routes that
+	// use it, and the services and secrets it uses.
+	Cluster string
+}
+
+// DumpDAG returns the JSON representation of the parts of the DAG
+// selected by the filter.
+func DumpDAG(d *dag.DAG, filter DAGFilter) *DAGDump {
+	dw := dagDumper{
+		filter:       filter,
+		dump:         &DAGDump{},
+		seen:         map[dag.Vertex]bool{},
+		virtualHosts: map[string]*VirtualHostDump{},
+	}
+	d.Visit(dw.visit)
+	dw.sort()
+	return dw.dump
+}
+
+type dagDumper struct {
+	filter       DAGFilter
+	dump         *DAGDump
+	seen         map[dag.Vertex]bool
+	virtualHosts map[string]*VirtualHostDump
+}
+
+func (dw *dagDumper) visit(v dag.Vertex) {
+	switch v := v.(type) {
+	case *dag.Listener:
+		l := ListenerDump{Address: v.Address, Port: v.Port, VirtualHosts: []string{}}
+		for _, vh := range v.VirtualHosts {
+			switch vh := vh.(type) {
+			case *dag.VirtualHost:
+				if dw.selectsVirtualHost(vh.Name) {
+					l.VirtualHosts = append(l.VirtualHosts, "http://"+vh.Name)
+					dw.visitVirtualHost(vh, nil)
+				}
+			case *dag.SecureVirtualHost:
+				if dw.selectsVirtualHost(vh.Name) {
+					l.VirtualHosts = append(l.VirtualHosts, "https://"+vh.Name)
+					dw.visitVirtualHost(&vh.VirtualHost, vh)
+				}
+			}
+		}
+		l.ErrorPages = errorPagesDump(v.ErrorPages)
+		dw.dump.Listeners = append(dw.dump.Listeners, l)
+	case *dag.ListenerProxy:
+		if dw.selectsVirtualHost(v.VirtualHost) {
+			dw.visitListenerProxy(v)
+		}
+	case *dag.ExtensionCluster:
+		// Extension clusters are only dumped when they are used by
+		// a selected virtual host.
+		if dw.filter.FQDN == "" {
+			dw.visitExtensionCluster(v)
+		}
+	}
+}
+
+// visitListenerProxy adds lp to the dump if it uses a selected
+// cluster.
+func (dw *dagDumper) visitListenerProxy(lp *dag.ListenerProxy) {
+	dump := ListenerProxyDump{
+		Listener:    lp.Listener,
+		VirtualHost: lp.VirtualHost,
+		EnvoyGroup:  lp.EnvoyGroup,
+		TCPProxy:    []WeightedCluster{},
+	}
+
+	if lp.TCPProxy != nil {
+		for _, c := range lp.TCPProxy.Clusters {
+			if dw.selectsCluster(c) {
+				dump.TCPProxy = append(dump.TCPProxy, WeightedCluster{Name: envoy.Clustername(c), Weight: c.Weight})
+				dw.visitCluster(c)
+			}
+		}
+	}
+
+	if dw.filter.Cluster != "" && len(dump.TCPProxy) == 0 {
+		return
+	}
+
+	dw.dump.ListenerProxies = append(dw.dump.ListenerProxies, dump)
+}
+
+func (dw *dagDumper) selectsVirtualHost(name string) bool {
+	return dw.filter.FQDN == "" || dw.filter.FQDN == name
+}
+
+// visitVirtualHost adds the routes of vh to the dump. If svh is not
+// nil, vh is the virtual host of svh.
+func (dw *dagDumper) visitVirtualHost(vh *dag.VirtualHost, svh *dag.SecureVirtualHost) {
+	key := "http://" + vh.Name
+	if svh != nil {
+		key = "https://" + vh.Name
+	}
+
+	dump := &VirtualHostDump{
+		Name:                       vh.Name,
+		Secure:                     svh != nil,
+		EnvoyGroup:                 vh.EnvoyGroup,
+		Listener:                   vh.Listener,
+		PerRequestBufferLimitBytes: vh.PerRequestBufferLimitBytes,
+		RateLimitPolicy:            rateLimitPolicyDump(vh.RateLimitPolicy),
+		CORSPolicy:                 corsPolicyDump(vh.CORSPolicy),
+	}
+
+	vh.Visit(func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok {
+			if route, ok := dw.routeDump(r); ok {
+				dump.Routes = append(dump.Routes, route)
+			}
+		}
+	})
+	sort.Slice(dump.Routes, func(i, j int) bool {
+		return routeKey(dump.Routes[i]) < routeKey(dump.Routes[j])
+	})
+
+	if svh != nil && svh.TCPProxy != nil {
+		for _, c := range svh.TCPProxy.Clusters {
+			if dw.selectsCluster(c) {
+				dump.TCPProxy = append(dump.TCPProxy, WeightedCluster{Name: envoy.Clustername(c), Weight: c.Weight})
+				dw.visitCluster(c)
+			}
+		}
+	}
+
+	if dw.filter.Cluster != "" && len(dump.Routes) == 0 && len(dump.TCPProxy) == 0 {
+		return
+	}
+
+	if svh != nil {
+		dump.TLS = &TLSDump{
+			Secret:              dw.secret(svh.Secret),
+			FallbackCertificate: dw.secret(svh.FallbackCertificate),
+			MinTLSVersion:       svh.MinTLSVersion,
+			OCSPStaplePolicy:    svh.OCSPStaplePolicy,
+			ClientValidation:    dw.validation(svh.DownstreamValidation),
+		}
+		dump.HTTP3Port = svh.HTTP3Port
+		dump.HTTPPolicy = httpPolicyDump(svh.HTTPPolicy)
+		dump.ErrorPages = errorPagesDump(svh.ErrorPages)
+		if svh.AuthorizationService != nil {
+			dump.TLS.AuthorizationService = &AuthorizationDump{
+				Cluster:         svh.AuthorizationService.Name,
+				ResponseTimeout: timeoutString(svh.AuthorizationResponseTimeout),
+				FailOpen:        svh.AuthorizationFailOpen,
+			}
+			dw.visitExtensionCluster(svh.AuthorizationService)
+		}
+	}
+
+	dw.virtualHosts[key] = dump
+}
+
+func (dw *dagDumper) selectsCluster(c *dag.Cluster) bool {
+	return dw.filter.Cluster == "" || dw.filter.Cluster == envoy.Clustername(c)
+}
+
+// routeDump returns the JSON representation of r, and whether r is
+// selected by the filter.
+func (dw *dagDumper) routeDump(r *dag.Route) (RouteDump, bool) {
+	route := newRouteDump(r)
+
+	selected := dw.filter.Cluster == ""
+	for _, c := range r.Clusters {
+		selected = selected || dw.selectsCluster(c)
+	}
+	for _, m := range r.MirrorPolicies {
+		selected = selected || dw.selectsCluster(m.Cluster)
+	}
+
+	if !selected {
+		return route, false
+	}
+
+	for _, c := range r.Clusters {
+		if dw.selectsCluster(c) {
+			dw.visitCluster(c)
+		}
+	}
+	for _, m := range r.MirrorPolicies {
+		if dw.selectsCluster(m.Cluster) {
+			dw.visitCluster(m.Cluster)
+		}
+	}
+
+	return route, true
+}
+
+// newRouteDump returns the JSON representation of r.
+func newRouteDump(r *dag.Route) RouteDump {
+	route := RouteDump{
+		PathMatch:             r.PathMatchCondition.String(),
+		Clusters:              []WeightedCluster{},
+		HTTPSUpgrade:          r.HTTPSUpgrade,
+		Websocket:             r.Websocket,
+		AuthDisabled:          r.AuthDisabled,
+		AuthContext:           r.AuthContext,
+		PrefixRewrite:         r.PrefixRewrite,
+		ResponseTimeout:       timeoutString(r.TimeoutPolicy.ResponseTimeout),
+		IdleTimeout:           timeoutString(r.TimeoutPolicy.IdleTimeout),
+		MaxStreamDuration:     timeoutString(r.TimeoutPolicy.MaxStreamDuration),
+		RequestHeadersPolicy:  headersPolicyDump(r.RequestHeadersPolicy),
+		ResponseHeadersPolicy: headersPolicyDump(r.ResponseHeadersPolicy),
+		RateLimitPolicy:       rateLimitPolicyDump(r.RateLimitPolicy),
+	}
+
+	if bp := r.BufferPolicy; bp != nil {
+		route.BufferPolicy = &BufferPolicyDump{MaxRequestBytes: bp.MaxRequestBytes}
+	}
+
+	if irp := r.InternalRedirectPolicy; irp != nil {
+		route.InternalRedirectPolicy = &InternalRedirectPolicyDump{
+			MaxInternalRedirects:     irp.MaxInternalRedirects,
+			RedirectResponseCodes:    irp.RedirectResponseCodes,
+			AllowCrossSchemeRedirect: irp.AllowCrossSchemeRedirect,
+			AllowedHosts:             irp.AllowedHosts,
+		}
+	}
+
+	for _, hp := range r.RequestHashPolicies {
+		policy := RequestHashPolicyDump{
+			Terminal:      hp.Terminal,
+			SourceAddress: hp.HashSourceAddress,
+		}
+		if hp.HeaderHashOptions != nil {
+			policy.HeaderName = hp.HeaderHashOptions.HeaderName
+		}
+		if c := hp.CookieHashOptions; c != nil {
+			policy.CookieName = c.CookieName
+			policy.CookiePath = c.Path
+			if c.TTL != nil {
+				policy.CookieTTL = c.TTL.String()
+			}
+		}
+		route.RequestHashPolicies = append(route.RequestHashPolicies, policy)
+	}
+
+	for _, h := range r.HeaderMatchConditions {
+		route.HeaderMatches = append(route.HeaderMatches, h.String())
+	}
+
+	if rp := r.RetryPolicy; rp != nil {
+		route.RetryPolicy = &RetryPolicyDump{
+			RetryOn:              rp.RetryOn,
+			RetriableStatusCodes: rp.RetriableStatusCodes,
+			NumRetries:           rp.NumRetries,
+			PerTryTimeout:        timeoutString(rp.PerTryTimeout),
+		}
+	}
+
+	for _, c := range r.Clusters {
+		route.Clusters = append(route.Clusters, WeightedCluster{Name: envoy.Clustername(c), Weight: c.Weight})
+	}
+	for _, m := range r.MirrorPolicies {
+		route.MirrorPolicies = append(route.MirrorPolicies, WeightedCluster{Name: envoy.Clustername(m.Cluster), Weight: m.PartsPerMillion})
+	}
+
+	return route
+}
+
+func (dw *dagDumper) visitCluster(c *dag.Cluster) {
+	if dw.seen[c] {
+		return
+	}
+	dw.seen[c] = true
+
+	cluster := ClusterDump{
+		Name:                  envoy.Clustername(c),
+		Protocol:              c.Protocol,
+		LoadBalancerPolicy:    c.LoadBalancerPolicy,
+		SNI:                   c.SNI,
+		DNSLookupFamily:       c.DNSLookupFamily,
+		UpstreamValidation:    dw.validation(c.UpstreamValidation),
+		ClientCertificate:     dw.secret(c.ClientCertificate),
+		ConnectionPolicy:      connectionPolicyDump(c.ConnectionPolicy),
+		RequestHeadersPolicy:  headersPolicyDump(c.RequestHeadersPolicy),
+		ResponseHeadersPolicy: headersPolicyDump(c.ResponseHeadersPolicy),
+	}
+
+	if hc := c.HTTPHealthCheckPolicy; hc != nil {
+		cluster.HealthCheck = fmt.Sprintf("http %s", hc.Path)
+	}
+	if c.TCPHealthCheckPolicy != nil {
+		cluster.HealthCheck = "tcp"
+	}
+
+	if s := c.Upstream; s != nil {
+		cluster.Service = serviceName(s.Weighted)
+		dw.visitService(s)
+	}
+
+	dw.dump.Clusters = append(dw.dump.Clusters, cluster)
+}
+
+func (dw *dagDumper) visitExtensionCluster(e *dag.ExtensionCluster) {
+	if dw.seen[e] || (dw.filter.Cluster != "" && dw.filter.Cluster != e.Name) {
+		return
+	}
+	dw.seen[e] = true
+
+	dw.dump.Clusters = append(dw.dump.Clusters, ClusterDump{
+		Name:               e.Name,
+		Service:            e.Upstream.ClusterName,
+		Protocol:           e.Protocol,
+		LoadBalancerPolicy: e.LoadBalancerPolicy,
+		SNI:                e.SNI,
+		UpstreamValidation: dw.validation(e.UpstreamValidation),
+		ClientCertificate:  dw.secret(e.ClientCertificate),
+		ConnectionPolicy:   connectionPolicyDump(e.ConnectionPolicy),
+		ResponseTimeout:    timeoutString(e.TimeoutPolicy.ResponseTimeout),
+		IdleTimeout:        timeoutString(e.TimeoutPolicy.IdleTimeout),
+	})
+}
+
+func (dw *dagDumper) visitService(s *dag.Service) {
+	if dw.seen[s] {
+		return
+	}
+	dw.seen[s] = true
+
+	dw.dump.Services = append(dw.dump.Services, ServiceDump{
+		Name:               s.Weighted.ServiceNamespace + "/" + s.Weighted.ServiceName,
+		Port:               s.Weighted.ServicePort.Port,
+		PortName:           s.Weighted.ServicePort.Name,
+		Protocol:           s.Protocol,
+		ExternalName:       s.ExternalName,
+		MaxConnections:     s.MaxConnections,
+		MaxPendingRequests: s.MaxPendingRequests,
+		MaxRequests:        s.MaxRequests,
+		MaxRetries:         s.MaxRetries,
+	})
+}
+
+// secret adds s to the dump, and returns its name. If s is nil,
+// secret returns the empty string.
+func (dw *dagDumper) secret(s *dag.Secret) string {
+	if s == nil || s.Object == nil {
+		return ""
+	}
+
+	name := s.Namespace() + "/" + s.Name()
+	if !dw.seen[s] {
+		dw.seen[s] = true
+
+		dump := SecretDump{Name: name}
+		if t := s.NotAfter(); !t.IsZero() {
+			dump.NotAfter = &t
+		}
+		dw.dump.Secrets = append(dw.dump.Secrets, dump)
+	}
+
+	return name
+}
+
+func (dw *dagDumper) validation(pvc *dag.PeerValidationContext) *ValidationDump {
+	if pvc == nil {
+		return nil
+	}
+	return &ValidationDump{
+		CACertificate: dw.secret(pvc.CACertificate),
+		SubjectName:   pvc.SubjectName,
+	}
+}
+
+// sort orders the dump, so that dumps of the same DAG are equal.
+func (dw *dagDumper) sort() {
+	for _, vh := range dw.virtualHosts {
+		dw.dump.VirtualHosts = append(dw.dump.VirtualHosts, *vh)
+	}
+
+	sort.Slice(dw.dump.Listeners, func(i, j int) bool {
+		return dw.dump.Listeners[i].Port < dw.dump.Listeners[j].Port
+	})
+	for _, l := range dw.dump.Listeners {
+		sort.Strings(l.VirtualHosts)
+	}
+	sort.Slice(dw.dump.ListenerProxies, func(i, j int) bool {
+		return dw.dump.ListenerProxies[i].Listener < dw.dump.ListenerProxies[j].Listener
+	})
+	sort.Slice(dw.dump.VirtualHosts, func(i, j int) bool {
+		a, b := dw.dump.VirtualHosts[i], dw.dump.VirtualHosts[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return !a.Secure && b.Secure
+	})
+	sort.Slice(dw.dump.Clusters, func(i, j int) bool {
+		return dw.dump.Clusters[i].Name < dw.dump.Clusters[j].Name
+	})
+	sort.Slice(dw.dump.Services, func(i, j int) bool {
+		a, b := dw.dump.Services[i], dw.dump.Services[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Port < b.Port
+	})
+	sort.Slice(dw.dump.Secrets, func(i, j int) bool {
+		return dw.dump.Secrets[i].Name < dw.dump.Secrets[j].Name
+	})
+}
+
+func corsPolicyDump(cp *dag.CORSPolicy) *CORSPolicyDump {
+	if cp == nil {
+		return nil
+	}
+	return &CORSPolicyDump{
+		AllowCredentials: cp.AllowCredentials,
+		AllowOrigin:      cp.AllowOrigin,
+		AllowMethods:     cp.AllowMethods,
+		AllowHeaders:     cp.AllowHeaders,
+		ExposeHeaders:    cp.ExposeHeaders,
+		MaxAge:           timeoutString(cp.MaxAge),
+	}
+}
+
+// connectionPolicyDump returns nil for a connection policy that
+// leaves every setting at its default.
+func connectionPolicyDump(cp dag.ConnectionPolicy) *ConnectionPolicyDump {
+	if cp == (dag.ConnectionPolicy{}) {
+		return nil
+	}
+	return &ConnectionPolicyDump{
+		IdleTimeout:                   timeoutString(cp.IdleTimeout),
+		MaxRequestsPerConnection:      cp.MaxRequestsPerConnection,
+		MaxStreamDuration:             timeoutString(cp.MaxStreamDuration),
+		PerConnectionBufferLimitBytes: cp.PerConnectionBufferLimitBytes,
+	}
+}
+
+func errorPagesDump(pages []*dag.ErrorPage) []ErrorPageDump {
+	var dump []ErrorPageDump
+	for _, p := range pages {
+		dump = append(dump, ErrorPageDump{
+			StatusCodes:        p.StatusCodes,
+			Body:               p.Body,
+			ContentType:        p.ContentType,
+			ResponseStatusCode: p.ResponseStatusCode,
+		})
+	}
+	return dump
+}
+
+func headersPolicyDump(hp *dag.HeadersPolicy) *HeadersPolicyDump {
+	if hp == nil {
+		return nil
+	}
+	return &HeadersPolicyDump{
+		HostRewrite: hp.HostRewrite,
+		Set:         hp.Set,
+		Remove:      hp.Remove,
+	}
+}
+
+func httpPolicyDump(hp *dag.HTTPPolicy) *HTTPPolicyDump {
+	if hp == nil {
+		return nil
+	}
+	return &HTTPPolicyDump{
+		RequestTimeout:        timeoutString(hp.RequestTimeout),
+		RequestHeadersTimeout: timeoutString(hp.RequestHeadersTimeout),
+		StreamIdleTimeout:     timeoutString(hp.StreamIdleTimeout),
+		MaxConnectionDuration: timeoutString(hp.MaxConnectionDuration),
+		AllowAbsoluteURLs:     hp.AllowAbsoluteURLs,
+		ProperCaseHeaders:     hp.ProperCaseHeaders,
+	}
+}
+
+func rateLimitPolicyDump(rp *dag.RateLimitPolicy) *RateLimitPolicyDump {
+	if rp == nil {
+		return nil
+	}
+
+	dump := &RateLimitPolicyDump{}
+	if l := rp.Local; l != nil {
+		dump.Local = &LocalRateLimitPolicyDump{
+			MaxTokens:            l.MaxTokens,
+			TokensPerFill:        l.TokensPerFill,
+			FillInterval:         l.FillInterval.String(),
+			ResponseStatusCode:   l.ResponseStatusCode,
+			ResponseHeadersToAdd: l.ResponseHeadersToAdd,
+		}
+	}
+	return dump
+}
+
+func routeKey(r RouteDump) string {
+	key := r.PathMatch
+	for _, h := range r.HeaderMatches {
+		key += "," + h
+	}
+	return key
+}
+
+func serviceName(w dag.WeightedService) string {
+	return fmt.Sprintf("%s/%s:%d", w.ServiceNamespace, w.ServiceName, w.ServicePort.Port)
+}
+
+// timeoutString returns "" for the default timeout, "infinity" for a
+// disabled timeout, and the duration otherwise.
+func timeoutString(s timeout.Setting) string {
+	switch {
+	case s.UseDefault():
+		return ""
+	case s.IsDisabled():
+		return "infinity"
+	default:
+		return s.Duration().String()
+	}
+}
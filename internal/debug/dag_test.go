@@ -0,0 +1,253 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"reflect"
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestDumpDAG(t *testing.T) {
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			RootNamespaces: []string{"roots"},
+			FieldLogger:    fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	proxy := func(name, fqdn string, service string) *contour_api_v1.HTTPProxy {
+		return fixture.NewProxy(name).WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: fqdn},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+				Services: []contour_api_v1.Service{{
+					Name:      service,
+					Namespace: "roots",
+					Port:      8080,
+				}},
+				RetryPolicy: &contour_api_v1.RetryPolicy{NumRetries: 3, PerTryTimeout: "2s"},
+			}},
+		})
+	}
+
+	for _, o := range []interface{}{
+		proxy("roots/example", "example.com", "kuard"),
+		proxy("roots/home", "home.example.com", "home"),
+		fixture.ServiceRootsKuard,
+		fixture.ServiceRootsHome,
+	} {
+		builder.Source.Insert(o)
+	}
+
+	d := builder.Build()
+
+	dump := DumpDAG(d, DAGFilter{})
+	require.Len(t, dump.Listeners, 1)
+	assert.Equal(t, []string{"http://example.com", "http://home.example.com"}, dump.Listeners[0].VirtualHosts)
+	require.Len(t, dump.VirtualHosts, 2)
+	assert.Len(t, dump.Clusters, 2)
+	assert.Len(t, dump.Services, 2)
+	assert.Empty(t, dump.Secrets)
+
+	vh := dump.VirtualHosts[0]
+	assert.Equal(t, "example.com", vh.Name)
+	assert.False(t, vh.Secure)
+	require.Len(t, vh.Routes, 1)
+	assert.Equal(t, RouteDump{
+		PathMatch: "prefix: /",
+		Clusters:  []WeightedCluster{{Name: "roots/kuard/8080/da39a3ee5e"}},
+		RetryPolicy: &RetryPolicyDump{
+			RetryOn:       "5xx",
+			NumRetries:    3,
+			PerTryTimeout: "2s",
+		},
+	}, vh.Routes[0])
+
+	// Filtering by name selects the virtual host and what it uses.
+	dump = DumpDAG(d, DAGFilter{FQDN: "home.example.com"})
+	assert.Equal(t, []string{"http://home.example.com"}, dump.Listeners[0].VirtualHosts)
+	require.Len(t, dump.VirtualHosts, 1)
+	assert.Equal(t, "home.example.com", dump.VirtualHosts[0].Name)
+	require.Len(t, dump.Clusters, 1)
+	assert.Equal(t, "roots/home/8080/da39a3ee5e", dump.Clusters[0].Name)
+	assert.Equal(t, []ServiceDump{{Name: "roots/home", Port: 8080, PortName: "http"}}, dump.Services)
+
+	// Filtering by cluster selects the virtual hosts that use it.
+	dump = DumpDAG(d, DAGFilter{Cluster: "roots/kuard/8080/da39a3ee5e"})
+	require.Len(t, dump.VirtualHosts, 1)
+	assert.Equal(t, "example.com", dump.VirtualHosts[0].Name)
+	require.Len(t, dump.Clusters, 1)
+	assert.Equal(t, "roots/kuard:8080", dump.Clusters[0].Service)
+}
+
+func TestDumpDAGPolicies(t *testing.T) {
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			RootNamespaces: []string{"roots"},
+			FieldLogger:    fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{
+				Listeners: map[string]config.ListenerProtocol{
+					"postgres": config.TCPListenerProtocol,
+				},
+			},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	kuard := contour_api_v1.Service{
+		Name:      fixture.ServiceRootsKuard.Name,
+		Namespace: fixture.ServiceRootsKuard.Namespace,
+		Port:      8080,
+	}
+
+	withConnectionPolicy := kuard
+	withConnectionPolicy.ConnectionPolicy = &contour_api_v1.ConnectionPolicy{IdleTimeout: "30s"}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("roots/secure").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn:       "secure.example.com",
+				TLS:        &contour_api_v1.TLS{SecretName: fixture.SecretRootsCert.Name},
+				HTTPPolicy: &contour_api_v1.HTTPPolicy{RequestTimeout: "1m"},
+				ErrorPagePolicy: &contour_api_v1.ErrorPagePolicy{
+					Pages: []contour_api_v1.ErrorPage{{
+						StatusCodes: []uint32{503},
+						ConfigMap:   "error-pages",
+						Key:         "unavailable.html",
+					}},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions:    []contour_api_v1.MatchCondition{{Prefix: "/"}},
+				Services:      []contour_api_v1.Service{withConnectionPolicy},
+				TimeoutPolicy: &contour_api_v1.TimeoutPolicy{MaxStreamDuration: "5m"},
+				BufferPolicy:  &contour_api_v1.BufferPolicy{MaxRequestBytes: 8192},
+				InternalRedirectPolicy: &contour_api_v1.InternalRedirectPolicy{
+					MaxInternalRedirects: 2,
+					AllowedHosts:         []string{"secure.example.com"},
+				},
+			}},
+		}),
+		fixture.NewProxy("roots/postgres").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "postgres.example.com", Listener: "postgres"},
+			TCPProxy:    &contour_api_v1.TCPProxy{Services: []contour_api_v1.Service{kuard}},
+		}),
+		&v1.ConfigMap{
+			ObjectMeta: fixture.ObjectMeta("roots/error-pages"),
+			Data:       map[string]string{"unavailable.html": "unavailable"},
+		},
+		fixture.SecretRootsCert,
+		fixture.ServiceRootsKuard,
+	} {
+		builder.Source.Insert(o)
+	}
+
+	d := builder.Build()
+
+	dump := DumpDAG(d, DAGFilter{FQDN: "secure.example.com"})
+	assert.Empty(t, dump.ListenerProxies)
+
+	var vh VirtualHostDump
+	for _, v := range dump.VirtualHosts {
+		if v.Secure {
+			vh = v
+		}
+	}
+	assert.Equal(t, &HTTPPolicyDump{RequestTimeout: "1m0s"}, vh.HTTPPolicy)
+	assert.Equal(t, []ErrorPageDump{{StatusCodes: []uint32{503}, Body: "unavailable"}}, vh.ErrorPages)
+	require.Len(t, vh.Routes, 1)
+	assert.Equal(t, "5m0s", vh.Routes[0].MaxStreamDuration)
+	assert.Equal(t, &BufferPolicyDump{MaxRequestBytes: 8192}, vh.Routes[0].BufferPolicy)
+	assert.Equal(t, &InternalRedirectPolicyDump{
+		MaxInternalRedirects: 2,
+		AllowedHosts:         []string{"secure.example.com"},
+	}, vh.Routes[0].InternalRedirectPolicy)
+	require.Len(t, dump.Clusters, 1)
+	assert.Equal(t, &ConnectionPolicyDump{IdleTimeout: "30s"}, dump.Clusters[0].ConnectionPolicy)
+
+	dump = DumpDAG(d, DAGFilter{FQDN: "postgres.example.com"})
+	assert.Equal(t, []ListenerProxyDump{{
+		Listener:    "postgres",
+		VirtualHost: "postgres.example.com",
+		TCPProxy:    []WeightedCluster{{Name: "roots/kuard/8080/da39a3ee5e"}},
+	}}, dump.ListenerProxies)
+	require.Len(t, dump.Clusters, 1)
+	assert.Nil(t, dump.Clusters[0].ConnectionPolicy)
+}
+
+// TestDumpDAGFields fails when a field is added to a DAG type, so
+// that the field is added to the dump too.
+func TestDumpDAGFields(t *testing.T) {
+	dumped := map[reflect.Type][]string{
+		reflect.TypeOf(dag.Listener{}): {
+			"Address", "Port", "VirtualHosts", "ErrorPages",
+		},
+		reflect.TypeOf(dag.ListenerProxy{}): {
+			"Listener", "VirtualHost", "EnvoyGroup", "TCPProxy",
+		},
+		reflect.TypeOf(dag.VirtualHost{}): {
+			"Name", "CORSPolicy", "RateLimitPolicy", "EnvoyGroup", "Listener", "PerRequestBufferLimitBytes",
+		},
+		reflect.TypeOf(dag.SecureVirtualHost{}): {
+			"VirtualHost", "MinTLSVersion", "Secret", "FallbackCertificate", "OCSPStaplePolicy", "TCPProxy",
+			"DownstreamValidation", "AuthorizationService", "AuthorizationResponseTimeout", "AuthorizationFailOpen",
+			"HTTP3Port", "HTTPPolicy", "ErrorPages",
+		},
+		reflect.TypeOf(dag.Route{}): {
+			"PathMatchCondition", "HeaderMatchConditions", "Clusters", "HTTPSUpgrade", "AuthDisabled", "AuthContext",
+			"Websocket", "TimeoutPolicy", "RetryPolicy", "PrefixRewrite", "MirrorPolicies", "RequestHeadersPolicy",
+			"ResponseHeadersPolicy", "RateLimitPolicy", "RequestHashPolicies", "BufferPolicy", "InternalRedirectPolicy",
+		},
+		reflect.TypeOf(dag.Cluster{}): {
+			"Upstream", "Weight", "Protocol", "UpstreamValidation", "LoadBalancerPolicy", "HTTPHealthCheckPolicy",
+			"TCPHealthCheckPolicy", "RequestHeadersPolicy", "ResponseHeadersPolicy", "SNI", "DNSLookupFamily",
+			"ClientCertificate", "ConnectionPolicy",
+		},
+		reflect.TypeOf(dag.ExtensionCluster{}): {
+			"Name", "Upstream", "Protocol", "UpstreamValidation", "LoadBalancerPolicy", "TimeoutPolicy", "SNI",
+			"ClientCertificate", "ConnectionPolicy",
+		},
+		reflect.TypeOf(dag.Service{}): {
+			"Weighted", "Protocol", "MaxConnections", "MaxPendingRequests", "MaxRequests", "MaxRetries", "ExternalName",
+		},
+		reflect.TypeOf(dag.TimeoutPolicy{}): {
+			"ResponseTimeout", "IdleTimeout", "MaxStreamDuration",
+		},
+	}
+
+	for typ, want := range dumped {
+		var got []string
+		for i := 0; i < typ.NumField(); i++ {
+			if f := typ.Field(i); f.PkgPath == "" {
+				got = append(got, f.Name)
+			}
+		}
+
+		assert.ElementsMatchf(t, want, got, "fields of dag.%s", typ.Name())
+	}
+}
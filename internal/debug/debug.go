@@ -16,11 +16,15 @@
 package debug
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"strings"
 
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/httpsvc"
+	"github.com/projectcontour/contour/internal/xds"
 )
 
 // Service serves various http endpoints including /debug/pprof.
@@ -28,6 +32,25 @@ type Service struct {
 	httpsvc.Service
 
 	Builder *dag.Builder
+
+	// DAG holds the latest DAG, which is served as JSON.
+	DAG interface {
+		Latest() *dag.DAG
+	}
+
+	// Resources are the xDS caches whose contents are served as
+	// JSON.
+	Resources []xds.Resource
+
+	// Nacks reports the xDS responses that Envoy rejected.
+	Nacks interface {
+		Nacks() []xds.Nack
+	}
+
+	// Nodes reports the connected Envoys and whether they are in sync.
+	Nodes interface {
+		Nodes() []xds.NodeStatus
+	}
 }
 
 // Start fulfills the g.Start contract.
@@ -35,6 +58,19 @@ type Service struct {
 func (svc *Service) Start(stop <-chan struct{}) error {
 	registerProfile(&svc.ServeMux)
 	registerDotWriter(&svc.ServeMux, svc.Builder)
+	if svc.DAG != nil {
+		registerDAGDump(&svc.ServeMux, svc.DAG.Latest)
+		registerExplain(&svc.ServeMux, svc.DAG.Latest)
+	}
+	if len(svc.Resources) > 0 {
+		registerXDSDump(&svc.ServeMux, svc.Resources)
+	}
+	if svc.Nacks != nil {
+		registerNacks(&svc.ServeMux, svc.Nacks.Nacks)
+	}
+	if svc.Nodes != nil {
+		registerNodes(&svc.ServeMux, svc.Nodes.Nodes)
+	}
 	return svc.Service.Start(stop)
 }
 
@@ -58,3 +94,116 @@ func registerDotWriter(mux *http.ServeMux, builder *dag.Builder) {
 		dw.writeDot(w)
 	})
 }
+
+func registerDAGDump(mux *http.ServeMux, latest func() *dag.DAG) {
+	mux.HandleFunc("/debug/dag.json", func(w http.ResponseWriter, r *http.Request) {
+		d := latest()
+		if d == nil {
+			http.Error(w, "the DAG has not been built", http.StatusServiceUnavailable)
+			return
+		}
+
+		filter := DAGFilter{
+			FQDN:    r.URL.Query().Get("fqdn"),
+			Cluster: r.URL.Query().Get("cluster"),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(DumpDAG(d, filter)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func registerExplain(mux *http.ServeMux, latest func() *dag.DAG) {
+	mux.HandleFunc("/debug/explain", func(w http.ResponseWriter, r *http.Request) {
+		d := latest()
+		if d == nil {
+			http.Error(w, "the DAG has not been built", http.StatusServiceUnavailable)
+			return
+		}
+
+		query := r.URL.Query()
+		req := ExplainRequest{
+			Host:    query.Get("host"),
+			Path:    query.Get("path"),
+			Headers: http.Header{},
+			Secure:  query.Get("secure") == "true",
+		}
+		if req.Path == "" {
+			req.Path = "/"
+		}
+		for _, h := range query["header"] {
+			kv := strings.SplitN(h, ":", 2)
+			if len(kv) != 2 {
+				http.Error(w, fmt.Sprintf("header %q is not in name:value form", h), http.StatusBadRequest)
+				return
+			}
+			req.Headers.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+
+		exp, err := Explain(d, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(exp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func registerXDSDump(mux *http.ServeMux, resources []xds.Resource) {
+	dumper := &xdsDumper{resources: map[string]xds.Resource{}}
+	for _, r := range resources {
+		dumper.resources[r.TypeURL()] = r
+	}
+
+	for path, typeURL := range xdsTypes {
+		typeURL := typeURL
+		mux.HandleFunc("/debug/xds/"+path, func(w http.ResponseWriter, r *http.Request) {
+			filter := XDSFilter{
+				FQDN:    r.URL.Query().Get("fqdn"),
+				Cluster: r.URL.Query().Get("cluster"),
+				Group:   r.URL.Query().Get("group"),
+			}
+
+			buf, err := marshalResources(dumper.dump(typeURL, filter))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(buf) // nolint:errcheck
+		})
+	}
+}
+
+func registerNacks(mux *http.ServeMux, nacks func() []xds.Nack) {
+	mux.HandleFunc("/debug/xds/nacks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(nacks()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func registerNodes(mux *http.ServeMux, nodes func() []xds.NodeStatus) {
+	mux.HandleFunc("/debug/xds/nodes", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(nodes()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -54,6 +54,8 @@ func (c *ctx) writeVertex(v dag.Vertex) {
 		fmt.Fprintf(c.w, `"%p" [shape=record, label="{http://%s}"]`+"\n", v, v.Name)
 	case *dag.SecureVirtualHost:
 		fmt.Fprintf(c.w, `"%p" [shape=record, label="{https://%s}"]`+"\n", v, v.VirtualHost.Name)
+	case *dag.ListenerProxy:
+		fmt.Fprintf(c.w, `"%p" [shape=record, label="{listener proxy|%s}"]`+"\n", v, v.Listener)
 	case *dag.Route:
 		fmt.Fprintf(c.w, `"%p" [shape=record, label="{%s}"]`+"\n", v, v.PathMatchCondition.String())
 	case *dag.TCPProxy:
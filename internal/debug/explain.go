@@ -0,0 +1,281 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/sorter"
+)
+
+// ExplainRequest is an HTTP request whose routing is explained.
+type ExplainRequest struct {
+	Host    string
+	Path    string
+	Headers http.Header
+
+	// Secure selects the HTTPS virtual host for Host instead of the
+	// HTTP one.
+	Secure bool
+}
+
+// Explanation describes how a request is routed.
+type Explanation struct {
+	VirtualHost string `json:"virtualHost"`
+	Secure      bool   `json:"secure"`
+	EnvoyGroup  string `json:"envoyGroup,omitempty"`
+
+	// Candidates are the routes of the virtual host in the order
+	// Envoy evaluates them, up to and including the matching route.
+	Candidates []CandidateDump `json:"candidates"`
+
+	// Route is the matching route, or nil if no route matches.
+	Route *RouteDump `json:"route,omitempty"`
+
+	// Sources are the objects the matching route was computed from.
+	Sources []RouteSourceDump `json:"sources,omitempty"`
+
+	// TCPProxy are the clusters of a virtual host that proxies TCP
+	// instead of routing HTTP requests.
+	TCPProxy []WeightedCluster `json:"tcpProxy,omitempty"`
+}
+
+// CandidateDump is a route evaluated for a request.
+type CandidateDump struct {
+	PathMatch     string   `json:"pathMatch"`
+	HeaderMatches []string `json:"headerMatches,omitempty"`
+	Matched       bool     `json:"matched"`
+
+	// Reason is why the route does not match.
+	Reason string `json:"reason,omitempty"`
+}
+
+// RouteSourceDump is the JSON representation of a dag.RouteSource.
+type RouteSourceDump struct {
+	Kind       string                          `json:"kind"`
+	Namespace  string                          `json:"namespace"`
+	Name       string                          `json:"name"`
+	Conditions []contour_api_v1.MatchCondition `json:"conditions,omitempty"`
+}
+
+// Explain returns how the request is routed by the DAG. Routes are
+// evaluated in the order they are sent to Envoy.
+func Explain(d *dag.DAG, req ExplainRequest) (*Explanation, error) {
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	vh, svh := findVirtualHost(d, host, req.Secure)
+	if vh == nil {
+		scheme := "http"
+		if req.Secure {
+			scheme = "https"
+		}
+		return nil, fmt.Errorf("no virtual host matches %s://%s", scheme, host)
+	}
+
+	exp := &Explanation{
+		VirtualHost: vh.Name,
+		Secure:      svh != nil,
+		EnvoyGroup:  vh.EnvoyGroup,
+		Candidates:  []CandidateDump{},
+	}
+
+	if svh != nil && svh.TCPProxy != nil {
+		for _, c := range svh.TCPProxy.Clusters {
+			exp.TCPProxy = append(exp.TCPProxy, WeightedCluster{Name: envoy.Clustername(c), Weight: c.Weight})
+		}
+		return exp, nil
+	}
+
+	path := req.Path
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	for _, r := range sortedRoutes(vh) {
+		route := newRouteDump(r)
+		reason := routeMismatch(envoy_v3.RouteMatch(r), path, req.Headers)
+
+		exp.Candidates = append(exp.Candidates, CandidateDump{
+			PathMatch:     route.PathMatch,
+			HeaderMatches: route.HeaderMatches,
+			Matched:       reason == "",
+			Reason:        reason,
+		})
+
+		if reason == "" {
+			exp.Route = &route
+			for _, s := range d.RouteSources(r) {
+				exp.Sources = append(exp.Sources, RouteSourceDump{
+					Kind:       s.Kind,
+					Namespace:  s.Namespace,
+					Name:       s.Name,
+					Conditions: s.Conditions,
+				})
+			}
+			break
+		}
+	}
+
+	return exp, nil
+}
+
+// findVirtualHost returns the virtual host Envoy selects for the
+// host. Exact names are preferred over the longest matching wildcard
+// name, which is preferred over the default virtual host. If secure,
+// the virtual host of the returned secure virtual host is returned.
+func findVirtualHost(d *dag.DAG, host string, secure bool) (*dag.VirtualHost, *dag.SecureVirtualHost) {
+	vhosts := map[string]*dag.VirtualHost{}
+	svhosts := map[string]*dag.SecureVirtualHost{}
+
+	d.Visit(func(v dag.Vertex) {
+		l, ok := v.(*dag.Listener)
+		if !ok {
+			return
+		}
+		for _, v := range l.VirtualHosts {
+			switch v := v.(type) {
+			case *dag.VirtualHost:
+				if !secure {
+					vhosts[v.Name] = v
+				}
+			case *dag.SecureVirtualHost:
+				if secure {
+					vhosts[v.Name] = &v.VirtualHost
+					svhosts[v.Name] = v
+				}
+			}
+		}
+	})
+
+	name, ok := "", false
+	if _, ok = vhosts[host]; ok {
+		name = host
+	} else {
+		for n := range vhosts {
+			if strings.HasPrefix(n, "*.") && strings.HasSuffix(host, n[1:]) && len(n) > len(name) {
+				name, ok = n, true
+			}
+		}
+	}
+	if !ok {
+		name = "*"
+	}
+
+	return vhosts[name], svhosts[name]
+}
+
+// sortedRoutes returns the routes of the virtual host in the order
+// they are sent to Envoy.
+func sortedRoutes(vh *dag.VirtualHost) []*dag.Route {
+	var routes []*dag.Route
+	vh.Visit(func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok {
+			routes = append(routes, r)
+		}
+	})
+
+	// Start from a stable order, since the virtual host visits
+	// its routes in map order.
+	sort.Slice(routes, func(i, j int) bool {
+		return routeKey(newRouteDump(routes[i])) < routeKey(newRouteDump(routes[j]))
+	})
+
+	matches := make([]*envoy_route_v3.Route, 0, len(routes))
+	byMatch := map[*envoy_route_v3.Route]*dag.Route{}
+	for _, r := range routes {
+		m := &envoy_route_v3.Route{Match: envoy_v3.RouteMatch(r)}
+		sort.Stable(sorter.For(m.Match.Headers))
+		matches = append(matches, m)
+		byMatch[m] = r
+	}
+	sort.Stable(sorter.For(matches))
+
+	sorted := make([]*dag.Route, 0, len(matches))
+	for _, m := range matches {
+		sorted = append(sorted, byMatch[m])
+	}
+	return sorted
+}
+
+// routeMismatch returns why the route match does not match the path
+// and headers, or "" if it matches.
+func routeMismatch(m *envoy_route_v3.RouteMatch, path string, headers http.Header) string {
+	switch p := m.PathSpecifier.(type) {
+	case *envoy_route_v3.RouteMatch_Prefix:
+		if !strings.HasPrefix(path, p.Prefix) {
+			return fmt.Sprintf("path does not start with %q", p.Prefix)
+		}
+	case *envoy_route_v3.RouteMatch_SafeRegex:
+		if !fullMatch(p.SafeRegex.Regex, path) {
+			return fmt.Sprintf("path does not match %q", p.SafeRegex.Regex)
+		}
+	}
+
+	for _, h := range m.Headers {
+		if !headerMatches(h, headers) {
+			return fmt.Sprintf("header %q does not match", h.Name)
+		}
+	}
+
+	return ""
+}
+
+// headerMatches returns true if the header matcher matches the
+// headers. Like Envoy, multiple values of a header are joined with
+// commas, and an inverted matcher does not match a missing header
+// unless it matches on presence.
+func headerMatches(h *envoy_route_v3.HeaderMatcher, headers http.Header) bool {
+	values := headers.Values(h.Name)
+	if len(values) == 0 {
+		_, present := h.HeaderMatchSpecifier.(*envoy_route_v3.HeaderMatcher_PresentMatch)
+		return h.InvertMatch && present
+	}
+	value := strings.Join(values, ",")
+
+	var matched bool
+	switch s := h.HeaderMatchSpecifier.(type) {
+	case *envoy_route_v3.HeaderMatcher_ExactMatch:
+		matched = value == s.ExactMatch
+	case *envoy_route_v3.HeaderMatcher_SafeRegexMatch:
+		matched = fullMatch(s.SafeRegexMatch.Regex, value)
+	case *envoy_route_v3.HeaderMatcher_PresentMatch:
+		matched = s.PresentMatch
+	case *envoy_route_v3.HeaderMatcher_PrefixMatch:
+		matched = strings.HasPrefix(value, s.PrefixMatch)
+	case *envoy_route_v3.HeaderMatcher_SuffixMatch:
+		matched = strings.HasSuffix(value, s.SuffixMatch)
+	}
+
+	return matched != h.InvertMatch
+}
+
+// fullMatch returns true if the regular expression matches all of s,
+// as Envoy's safe regex matchers do.
+func fullMatch(expr, s string) bool {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	return err == nil && re.MatchString(s)
+}
@@ -0,0 +1,186 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"net/http"
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			RootNamespaces: []string{"roots"},
+			FieldLogger:    fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	teamBlue := []contour_api_v1.MatchCondition{{
+		Prefix: "/api",
+	}, {
+		Header: &contour_api_v1.HeaderMatchCondition{Name: "x-team", Exact: "blue"},
+	}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("roots/example").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com"},
+			Includes: []contour_api_v1.Include{{
+				Name:       "api",
+				Namespace:  "teams",
+				Conditions: teamBlue,
+			}},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+				Services: []contour_api_v1.Service{{
+					Name:      "kuard",
+					Namespace: "roots",
+					Port:      8080,
+				}},
+			}},
+		}),
+		fixture.NewProxy("teams/api").WithSpec(contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/v1"}},
+				Services: []contour_api_v1.Service{{
+					Name:      "home",
+					Namespace: "roots",
+					Port:      8080,
+				}},
+				TimeoutPolicy: &contour_api_v1.TimeoutPolicy{Response: "10s"},
+			}},
+		}),
+		fixture.ServiceRootsKuard,
+		fixture.ServiceRootsHome,
+	} {
+		builder.Source.Insert(o)
+	}
+
+	d := builder.Build()
+
+	exp, err := Explain(d, ExplainRequest{
+		Host:    "example.com:8080",
+		Path:    "/api/v1/users?page=2",
+		Headers: http.Header{"X-Team": []string{"blue"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", exp.VirtualHost)
+	assert.False(t, exp.Secure)
+	assert.Equal(t, []CandidateDump{{
+		PathMatch:     "prefix: /api/v1",
+		HeaderMatches: []string{"header: name=x-team&value=blue&matchtype=&exact&invert=&false"},
+		Matched:       true,
+	}}, exp.Candidates)
+	require.NotNil(t, exp.Route)
+	assert.Equal(t, []WeightedCluster{{Name: "roots/home/8080/da39a3ee5e"}}, exp.Route.Clusters)
+	assert.Equal(t, "10s", exp.Route.ResponseTimeout)
+	assert.Equal(t, []RouteSourceDump{{
+		Kind:      "HTTPProxy",
+		Namespace: "roots",
+		Name:      "example",
+	}, {
+		Kind:       "HTTPProxy",
+		Namespace:  "teams",
+		Name:       "api",
+		Conditions: teamBlue,
+	}}, exp.Sources)
+
+	exp, err = Explain(d, ExplainRequest{
+		Host:    "example.com",
+		Path:    "/api/v1/users",
+		Headers: http.Header{"X-Team": []string{"red"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []CandidateDump{{
+		PathMatch:     "prefix: /api/v1",
+		HeaderMatches: []string{"header: name=x-team&value=blue&matchtype=&exact&invert=&false"},
+		Reason:        `header "x-team" does not match`,
+	}, {
+		PathMatch: "prefix: /",
+		Matched:   true,
+	}}, exp.Candidates)
+	assert.Equal(t, []WeightedCluster{{Name: "roots/kuard/8080/da39a3ee5e"}}, exp.Route.Clusters)
+	assert.Len(t, exp.Sources, 1)
+
+	_, err = Explain(d, ExplainRequest{Host: "example.com", Path: "/", Secure: true})
+	assert.Error(t, err)
+
+	_, err = Explain(d, ExplainRequest{Host: "example.org", Path: "/"})
+	assert.Error(t, err)
+}
+
+func TestHeaderMatches(t *testing.T) {
+	tests := map[string]struct {
+		cond    dag.HeaderMatchCondition
+		headers http.Header
+		want    bool
+	}{
+		"exact": {
+			cond:    dag.HeaderMatchCondition{Name: "x-foo", MatchType: "exact", Value: "bar"},
+			headers: http.Header{"X-Foo": []string{"bar"}},
+			want:    true,
+		},
+		"exact, multiple values": {
+			cond:    dag.HeaderMatchCondition{Name: "x-foo", MatchType: "exact", Value: "bar"},
+			headers: http.Header{"X-Foo": []string{"bar", "baz"}},
+			want:    false,
+		},
+		"contains": {
+			cond:    dag.HeaderMatchCondition{Name: "x-foo", MatchType: "contains", Value: "a.r"},
+			headers: http.Header{"X-Foo": []string{"xa.ry"}},
+			want:    true,
+		},
+		"contains is not a regex": {
+			cond:    dag.HeaderMatchCondition{Name: "x-foo", MatchType: "contains", Value: "a.r"},
+			headers: http.Header{"X-Foo": []string{"bar"}},
+			want:    false,
+		},
+		"not contains, missing header": {
+			cond:    dag.HeaderMatchCondition{Name: "x-foo", MatchType: "contains", Value: "bar", Invert: true},
+			headers: http.Header{},
+			want:    false,
+		},
+		"present": {
+			cond:    dag.HeaderMatchCondition{Name: "x-foo", MatchType: "present"},
+			headers: http.Header{"X-Foo": []string{""}},
+			want:    true,
+		},
+		"not present": {
+			cond:    dag.HeaderMatchCondition{Name: "x-foo", MatchType: "present", Invert: true},
+			headers: http.Header{},
+			want:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := envoy_v3.RouteMatch(&dag.Route{
+				PathMatchCondition:    &dag.PrefixMatchCondition{Prefix: "/"},
+				HeaderMatchConditions: []dag.HeaderMatchCondition{tc.cond},
+			})
+			require.Len(t, m.Headers, 1)
+			assert.Equal(t, tc.want, headerMatches(m.Headers[0], tc.headers))
+		})
+	}
+}
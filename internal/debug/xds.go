@@ -0,0 +1,323 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/xds"
+)
+
+// xdsTypes maps the path of each xDS cache on the debug service to
+// the type URL of its resources.
+var xdsTypes = map[string]string{
+	"listeners": resource.ListenerType,
+	"routes":    resource.RouteType,
+	"clusters":  resource.ClusterType,
+	"endpoints": resource.EndpointType,
+	"secrets":   resource.SecretType,
+}
+
+// redacted replaces the key material of secrets.
+const redacted = "[redacted]"
+
+// XDSFilter selects the xDS resources to dump. Empty fields select
+// everything.
+type XDSFilter struct {
+	// FQDN selects the route configuration virtual hosts and the
+	// listener filter chains for this name, and the clusters and
+	// endpoints their routes use.
+	FQDN string
+
+	// Cluster selects the cluster with this name, its endpoints,
+	// and the routes that use it.
+	Cluster string
+
+	// Group selects the contents for Envoys in this Envoy group.
+	Group string
+}
+
+// xdsDumper dumps the contents of the xDS caches.
+type xdsDumper struct {
+	// resources holds each xDS cache, by type URL.
+	resources map[string]xds.Resource
+}
+
+// dump returns the resources of the given type selected by the
+// filter. Secrets are returned without their key material.
+func (d *xdsDumper) dump(typeURL string, filter XDSFilter) []proto.Message {
+	if _, ok := d.resources[typeURL]; !ok {
+		return nil
+	}
+
+	return FilterResources(typeURL, filter, func(typeURL string) []proto.Message {
+		r, ok := d.resources[typeURL]
+		if !ok {
+			return nil
+		}
+		return xds.ContentsFor(r, filter.Group)
+	})
+}
+
+// FilterResources returns the resources of the given type selected by
+// the filter. contents returns the resources of a type, and is also
+// called for the route configurations and clusters that the filter
+// selects clusters and endpoints through. The filter's Group is not
+// applied, contents is expected to have done so. Secrets are returned
+// without their key material.
+func FilterResources(typeURL string, filter XDSFilter, contents func(typeURL string) []proto.Message) []proto.Message {
+	switch typeURL {
+	case resource.ListenerType:
+		return filterListeners(contents(typeURL), filter.FQDN)
+	case resource.RouteType:
+		return filterRoutes(contents(typeURL), filter.FQDN, filter.Cluster)
+	case resource.ClusterType:
+		return filterClusters(contents, filter)
+	case resource.EndpointType:
+		return filterEndpoints(contents, filter)
+	case resource.SecretType:
+		return redactSecrets(contents(typeURL))
+	default:
+		return contents(typeURL)
+	}
+}
+
+// clusterNames returns the names of the clusters selected by the
+// filter, or nil if every cluster is selected.
+func clusterNames(contents func(typeURL string) []proto.Message, filter XDSFilter) map[string]bool {
+	if filter.FQDN == "" {
+		if filter.Cluster == "" {
+			return nil
+		}
+		return map[string]bool{filter.Cluster: true}
+	}
+
+	names := map[string]bool{}
+	for _, m := range filterRoutes(contents(resource.RouteType), filter.FQDN, filter.Cluster) {
+		for _, vh := range m.(*envoy_route_v3.RouteConfiguration).VirtualHosts {
+			for _, route := range vh.Routes {
+				for _, name := range routeClusters(route) {
+					if filter.Cluster == "" || filter.Cluster == name {
+						names[name] = true
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func filterClusters(contents func(typeURL string) []proto.Message, filter XDSFilter) []proto.Message {
+	names := clusterNames(contents, filter)
+	if names == nil {
+		return contents(resource.ClusterType)
+	}
+
+	var clusters []proto.Message
+	for _, m := range contents(resource.ClusterType) {
+		if names[m.(*envoy_cluster_v3.Cluster).Name] {
+			clusters = append(clusters, m)
+		}
+	}
+	return clusters
+}
+
+func filterEndpoints(contents func(typeURL string) []proto.Message, filter XDSFilter) []proto.Message {
+	names := clusterNames(contents, filter)
+	if names == nil {
+		return contents(resource.EndpointType)
+	}
+
+	// Endpoints are named by the EDS service name of their cluster.
+	services := map[string]bool{}
+	for name := range names {
+		services[name] = true
+	}
+	for _, m := range contents(resource.ClusterType) {
+		c := m.(*envoy_cluster_v3.Cluster)
+		if names[c.Name] && c.GetEdsClusterConfig().GetServiceName() != "" {
+			services[c.GetEdsClusterConfig().GetServiceName()] = true
+		}
+	}
+
+	var endpoints []proto.Message
+	for _, m := range contents(resource.EndpointType) {
+		if services[m.(*envoy_endpoint_v3.ClusterLoadAssignment).ClusterName] {
+			endpoints = append(endpoints, m)
+		}
+	}
+	return endpoints
+}
+
+// filterListeners returns the listeners with a filter chain for the
+// given server name, with only those filter chains.
+func filterListeners(contents []proto.Message, fqdn string) []proto.Message {
+	if fqdn == "" {
+		return contents
+	}
+
+	var listeners []proto.Message
+	for _, m := range contents {
+		l := proto.Clone(m).(*envoy_listener_v3.Listener)
+
+		var chains []*envoy_listener_v3.FilterChain
+		for _, fc := range l.FilterChains {
+			for _, name := range fc.GetFilterChainMatch().GetServerNames() {
+				if name == fqdn {
+					chains = append(chains, fc)
+					break
+				}
+			}
+		}
+
+		if len(chains) > 0 {
+			l.FilterChains = chains
+			l.DefaultFilterChain = nil
+			listeners = append(listeners, l)
+		}
+	}
+	return listeners
+}
+
+// filterRoutes returns the route configurations with a virtual host
+// for the given domain, and with routes to the given cluster, with
+// only those virtual hosts and routes.
+func filterRoutes(contents []proto.Message, fqdn, cluster string) []proto.Message {
+	if fqdn == "" && cluster == "" {
+		return contents
+	}
+
+	var configs []proto.Message
+	for _, m := range contents {
+		rc := proto.Clone(m).(*envoy_route_v3.RouteConfiguration)
+
+		var vhosts []*envoy_route_v3.VirtualHost
+		for _, vh := range rc.VirtualHosts {
+			if fqdn != "" && !servesDomain(vh, fqdn) {
+				continue
+			}
+
+			if cluster != "" {
+				var routes []*envoy_route_v3.Route
+				for _, route := range vh.Routes {
+					for _, name := range routeClusters(route) {
+						if name == cluster {
+							routes = append(routes, route)
+							break
+						}
+					}
+				}
+				vh.Routes = routes
+			}
+
+			if len(vh.Routes) > 0 {
+				vhosts = append(vhosts, vh)
+			}
+		}
+
+		if len(vhosts) > 0 {
+			rc.VirtualHosts = vhosts
+			configs = append(configs, rc)
+		}
+	}
+	return configs
+}
+
+// servesDomain returns true if the virtual host matches the domain
+// on any port.
+func servesDomain(vh *envoy_route_v3.VirtualHost, fqdn string) bool {
+	for _, domain := range vh.Domains {
+		if domain == fqdn || domain == fqdn+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// routeClusters returns the names of the clusters a route forwards
+// or mirrors requests to.
+func routeClusters(route *envoy_route_v3.Route) []string {
+	action := route.GetRoute()
+	if action == nil {
+		return nil
+	}
+
+	var names []string
+	if action.GetCluster() != "" {
+		names = append(names, action.GetCluster())
+	}
+	for _, wc := range action.GetWeightedClusters().GetClusters() {
+		names = append(names, wc.Name)
+	}
+	for _, mp := range action.GetRequestMirrorPolicies() {
+		names = append(names, mp.Cluster)
+	}
+	return names
+}
+
+// redactSecrets returns copies of the secrets without their private
+// keys.
+func redactSecrets(contents []proto.Message) []proto.Message {
+	redactedSource := func() *envoy_config_v3.DataSource {
+		return &envoy_config_v3.DataSource{
+			Specifier: &envoy_config_v3.DataSource_InlineString{InlineString: redacted},
+		}
+	}
+
+	var secrets []proto.Message
+	for _, m := range contents {
+		s := proto.Clone(m).(*envoy_tls_v3.Secret)
+		if cert := s.GetTlsCertificate(); cert != nil && cert.PrivateKey != nil {
+			cert.PrivateKey = redactedSource()
+		}
+		if generic := s.GetGenericSecret(); generic != nil && generic.Secret != nil {
+			generic.Secret = redactedSource()
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets
+}
+
+// marshalResources returns the JSON encoding of the resources, as an
+// indented array.
+func marshalResources(resources []proto.Message) ([]byte, error) {
+	m := jsonpb.Marshaler{OrigName: true}
+
+	values := make([]json.RawMessage, 0, len(resources))
+	for _, r := range resources {
+		var buf bytes.Buffer
+		if err := m.Marshal(&buf, r); err != nil {
+			return nil, err
+		}
+		values = append(values, buf.Bytes())
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(values); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,156 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"strings"
+	"testing"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/dag"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticResource is an xds.Resource with fixed contents.
+type staticResource struct {
+	typeURL  string
+	contents []proto.Message
+}
+
+func (s *staticResource) Contents() []proto.Message            { return s.contents }
+func (s *staticResource) Query(names []string) []proto.Message { return nil }
+func (s *staticResource) Register(chan int, int, ...string)    {}
+func (s *staticResource) TypeURL() string                      { return s.typeURL }
+
+func TestXDSDump(t *testing.T) {
+	route := func(prefix, cluster string) *envoy_route_v3.Route {
+		return &envoy_route_v3.Route{
+			Match: &envoy_route_v3.RouteMatch{
+				PathSpecifier: &envoy_route_v3.RouteMatch_Prefix{Prefix: prefix},
+			},
+			Action: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{Cluster: cluster},
+				},
+			},
+		}
+	}
+
+	kuard := route("/", "default/kuard/80/da39a3ee5e")
+	home := route("/home", "default/home/80/da39a3ee5e")
+
+	cluster := func(name, service string) *envoy_cluster_v3.Cluster {
+		return &envoy_cluster_v3.Cluster{
+			Name: name,
+			EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+				EdsConfig:   envoy_v3.ConfigSource("contour"),
+				ServiceName: service,
+			},
+		}
+	}
+
+	dumper := &xdsDumper{resources: map[string]xds.Resource{}}
+	for _, r := range []*staticResource{{
+		typeURL: resource.RouteType,
+		contents: []proto.Message{&envoy_route_v3.RouteConfiguration{
+			Name: "ingress_http",
+			VirtualHosts: []*envoy_route_v3.VirtualHost{
+				envoy_v3.VirtualHost("example.com", kuard, home),
+				envoy_v3.VirtualHost("www.example.com", kuard),
+			},
+		}},
+	}, {
+		typeURL: resource.ClusterType,
+		contents: []proto.Message{
+			cluster("default/home/80/da39a3ee5e", "default/home/http"),
+			cluster("default/kuard/80/da39a3ee5e", "default/kuard/http"),
+		},
+	}, {
+		typeURL: resource.EndpointType,
+		contents: []proto.Message{
+			&envoy_endpoint_v3.ClusterLoadAssignment{ClusterName: "default/home/http"},
+			&envoy_endpoint_v3.ClusterLoadAssignment{ClusterName: "default/kuard/http"},
+		},
+	}, {
+		typeURL: resource.SecretType,
+		contents: []proto.Message{
+			envoy_v3.Secret(&dag.Secret{Object: fixture.SecretRootsCert}),
+		},
+	}} {
+		dumper.resources[r.typeURL] = r
+	}
+
+	names := func(messages []proto.Message) []string {
+		var names []string
+		for _, m := range messages {
+			switch m := m.(type) {
+			case *envoy_route_v3.RouteConfiguration:
+				for _, vh := range m.VirtualHosts {
+					for _, r := range vh.Routes {
+						names = append(names, vh.Domains[0]+r.Match.GetPrefix())
+					}
+				}
+			case *envoy_cluster_v3.Cluster:
+				names = append(names, m.Name)
+			case *envoy_endpoint_v3.ClusterLoadAssignment:
+				names = append(names, m.ClusterName)
+			}
+		}
+		return names
+	}
+
+	assert.Equal(t,
+		[]string{"example.com/", "example.com/home", "www.example.com/"},
+		names(dumper.dump(resource.RouteType, XDSFilter{})))
+	assert.Equal(t,
+		[]string{"www.example.com/"},
+		names(dumper.dump(resource.RouteType, XDSFilter{FQDN: "www.example.com"})))
+	assert.Equal(t,
+		[]string{"example.com/home"},
+		names(dumper.dump(resource.RouteType, XDSFilter{Cluster: "default/home/80/da39a3ee5e"})))
+
+	// The clusters and endpoints of a name are those its routes use.
+	assert.Equal(t,
+		[]string{"default/kuard/80/da39a3ee5e"},
+		names(dumper.dump(resource.ClusterType, XDSFilter{FQDN: "www.example.com"})))
+	assert.Equal(t,
+		[]string{"default/kuard/http"},
+		names(dumper.dump(resource.EndpointType, XDSFilter{FQDN: "www.example.com"})))
+	assert.Equal(t,
+		[]string{"default/home/http"},
+		names(dumper.dump(resource.EndpointType, XDSFilter{Cluster: "default/home/80/da39a3ee5e"})))
+
+	// Filtering does not modify the cache contents.
+	assert.Len(t, dumper.resources[resource.RouteType].Contents()[0].(*envoy_route_v3.RouteConfiguration).VirtualHosts, 2)
+
+	// Private keys are redacted.
+	secrets := dumper.dump(resource.SecretType, XDSFilter{})
+	require.Len(t, secrets, 1)
+	assert.Equal(t, redacted, secrets[0].(*envoy_tls_v3.Secret).GetTlsCertificate().GetPrivateKey().GetInlineString())
+	assert.NotEmpty(t, dumper.resources[resource.SecretType].Contents()[0].(*envoy_tls_v3.Secret).GetTlsCertificate().GetPrivateKey().GetInlineBytes())
+
+	buf, err := marshalResources(secrets)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf), "[\n  {\n    \"name\":"), string(buf))
+	assert.NotContains(t, string(buf), "PRIVATE KEY")
+}
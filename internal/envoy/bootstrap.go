@@ -17,6 +17,7 @@ package envoy
 
 import (
 	"os"
+	"time"
 
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
@@ -56,10 +57,64 @@ type BootstrapConfig struct {
 	// Defaults to 8001.
 	XDSGRPCPort int
 
+	// XDSServers are the gRPC xDS management servers Envoy connects to.
+	// Envoy prefers the servers with the lowest priority, and fails over
+	// to servers of the next priority when they are unhealthy. If empty,
+	// Envoy connects to XDSAddress and XDSGRPCPort.
+	XDSServers []XDSServer
+
+	// XDSKeepaliveInterval is the interval at which Envoy sends HTTP/2
+	// PING frames on its xDS connections. If zero, PING frames are not
+	// sent.
+	XDSKeepaliveInterval time.Duration
+
+	// XDSKeepaliveTimeout is how long Envoy waits for a PING response
+	// before it closes the xDS connection and reconnects, possibly to
+	// another xDS server. If zero, 20s is used.
+	XDSKeepaliveTimeout time.Duration
+
 	// XDSResourceVersion defines the XDS Server Version to use.
 	// Defaults to "v3"
 	XDSResourceVersion config.ResourceVersion
 
+	// ADS configures Envoy to fetch all its dynamic resources on a
+	// single aggregated discovery service (ADS) stream.
+	ADS bool
+
+	// Delta configures Envoy to fetch clusters and their endpoints
+	// with the incremental (delta) xDS protocol. It cannot be used
+	// with ADS.
+	Delta bool
+
+	// EnvoyGroup is the group of Envoys that Envoy belongs to. Envoys
+	// in a group are only sent the virtual hosts that are served by
+	// their group, or by all Envoys.
+	EnvoyGroup string
+
+	// NodeMetadata is added to the metadata that Envoy sends to
+	// Contour and exposes to its filters.
+	NodeMetadata map[string]string
+
+	// NodeRegion, NodeZone and NodeSubZone are the locality of
+	// Envoy, used for zone aware routing.
+	NodeRegion  string
+	NodeZone    string
+	NodeSubZone string
+
+	// MaxHeapSizeBytes is the heap size at which Envoy's overload
+	// manager starts shedding load. Envoy disables keepalive at 95%
+	// of the heap size and stops accepting requests and connections
+	// at 98%. If zero, the overload manager is not configured.
+	MaxHeapSizeBytes uint64
+
+	// MaxDownstreamConnections is the maximum number of downstream
+	// connections Envoy accepts across all its listeners. If zero,
+	// the number of connections is not limited.
+	MaxDownstreamConnections uint64
+
+	// RuntimeValues are set in Envoy's static runtime layer.
+	RuntimeValues map[string]string
+
 	// Namespace is the namespace where Contour is running
 	Namespace string
 
@@ -86,6 +141,25 @@ type BootstrapConfig struct {
 	SkipFilePathCheck bool
 }
 
+// XDSServer is a gRPC xDS management server.
+type XDSServer struct {
+	Address string
+	Port    int
+
+	// Priority is the priority of the server. Zero is the highest
+	// priority.
+	Priority uint32
+}
+
+// GetXDSServers returns the xDS servers, or a single server at
+// XDSAddress and XDSGRPCPort if none are configured.
+func (c *BootstrapConfig) GetXDSServers() []XDSServer {
+	if len(c.XDSServers) > 0 {
+		return c.XDSServers
+	}
+	return []XDSServer{{Address: c.GetXdsAddress(), Port: c.GetXdsGRPCPort()}}
+}
+
 func (c *BootstrapConfig) GetXdsAddress() string { return stringOrDefault(c.XDSAddress, "127.0.0.1") }
 func (c *BootstrapConfig) GetXdsGRPCPort() int   { return intOrDefault(c.XDSGRPCPort, 8001) }
 func (c *BootstrapConfig) GetAdminAddress() string {
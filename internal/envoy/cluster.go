@@ -46,6 +46,10 @@ func Clustername(cluster *dag.Cluster) string {
 		buf += uv.CACertificate.Object.ObjectMeta.Name
 		buf += uv.SubjectName
 	}
+	if cp := cluster.ConnectionPolicy; cp != (dag.ConnectionPolicy{}) {
+		buf += fmt.Sprintf("%v%v%d%d", cp.IdleTimeout, cp.MaxStreamDuration,
+			cp.MaxRequestsPerConnection, cp.PerConnectionBufferLimitBytes)
+	}
 
 	// This isn't a crypto hash, we just want a unique name.
 	hash := sha1.Sum([]byte(buf)) // nolint:gosec
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -28,13 +29,17 @@ import (
 	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_overload_v3 "github.com/envoyproxy/go-control-plane/envoy/config/overload/v3"
+	envoy_fixed_heap_v2alpha "github.com/envoyproxy/go-control-plane/envoy/config/resource_monitor/fixed_heap/v2alpha"
 	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	envoy_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
+	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/xds"
 )
 
 // WriteBootstrap writes bootstrap configuration to files.
@@ -67,6 +72,18 @@ type bootstrapf func(*envoy.BootstrapConfig) (string, proto.Message)
 func bootstrap(c *envoy.BootstrapConfig) ([]bootstrapf, error) {
 	var steps []bootstrapf
 
+	if c.ADS && c.Delta {
+		return nil, fmt.Errorf("%q and %q cannot be used together", "--ads", "--delta")
+	}
+
+	if len(c.XDSServers) > 0 && (c.XDSAddress != "" || c.XDSGRPCPort != 0) {
+		return nil, fmt.Errorf("%q cannot be used with %q or %q", "--xds-server", "--xds-address", "--xds-port")
+	}
+
+	if _, ok := c.RuntimeValues[globalDownstreamMaxConnections]; ok && c.MaxDownstreamConnections > 0 {
+		return nil, fmt.Errorf("%q and runtime key %q cannot be used together", "--max-downstream-connections", globalDownstreamMaxConnections)
+	}
+
 	if c.GrpcClientCert == "" && c.GrpcClientKey == "" && c.GrpcCABundle == "" {
 		steps = append(steps,
 			func(*envoy.BootstrapConfig) (string, proto.Message) {
@@ -122,6 +139,8 @@ func bootstrap(c *envoy.BootstrapConfig) ([]bootstrapf, error) {
 	// These files are JSON representation of the SDS protobuf messages that normally get sent over the xDS connection,
 	// but for xDS connection itself, bootstrapping is done by storing the SDS resources in a local filesystem.
 	// Envoy will monitor and reload the resource files and the certificate and key files referred from the SDS resources.
+	// When the certificate files share a directory, that directory is watched as well, so that files which are
+	// rotated by atomically swapping a symlink, as Kubernetes does for mounted Secrets, are also reloaded.
 	//
 	// Two files are written to ResourcesDir:
 	// - SDS resource for xDS client certificate and key for authenticating Envoy towards Contour.
@@ -149,10 +168,8 @@ func bootstrap(c *envoy.BootstrapConfig) ([]bootstrapf, error) {
 
 func bootstrapConfig(c *envoy.BootstrapConfig) *envoy_bootstrap_v3.Bootstrap {
 	return &envoy_bootstrap_v3.Bootstrap{
-		DynamicResources: &envoy_bootstrap_v3.Bootstrap_DynamicResources{
-			LdsConfig: ConfigSource("contour"),
-			CdsConfig: ConfigSource("contour"),
-		},
+		Node:             node(c),
+		DynamicResources: dynamicResources(c),
 		StaticResources: &envoy_bootstrap_v3.Bootstrap_StaticResources{
 			Clusters: []*envoy_cluster_v3.Cluster{{
 				Name:                 "contour",
@@ -162,10 +179,9 @@ func bootstrapConfig(c *envoy.BootstrapConfig) *envoy_bootstrap_v3.Bootstrap {
 				LbPolicy:             envoy_cluster_v3.Cluster_ROUND_ROBIN,
 				LoadAssignment: &envoy_endpoint_v3.ClusterLoadAssignment{
 					ClusterName: "contour",
-					Endpoints: Endpoints(
-						SocketAddress(c.GetXdsAddress(), c.GetXdsGRPCPort()),
-					),
+					Endpoints:   xdsEndpoints(c.GetXDSServers()),
 				},
+				HealthChecks: xdsHealthChecks(c.GetXDSServers()),
 				UpstreamConnectionOptions: &envoy_cluster_v3.UpstreamConnectionOptions{
 					TcpKeepalive: &envoy_core_v3.TcpKeepalive{
 						KeepaliveProbes:   protobuf.UInt32(3),
@@ -173,7 +189,7 @@ func bootstrapConfig(c *envoy.BootstrapConfig) *envoy_bootstrap_v3.Bootstrap {
 						KeepaliveInterval: protobuf.UInt32(5),
 					},
 				},
-				Http2ProtocolOptions: new(envoy_core_v3.Http2ProtocolOptions), // enables http2
+				Http2ProtocolOptions: xdsHTTP2ProtocolOptions(c), // enables http2
 				CircuitBreakers: &envoy_cluster_v3.CircuitBreakers{
 					Thresholds: []*envoy_cluster_v3.CircuitBreakers_Thresholds{{
 						Priority:           envoy_core_v3.RoutingPriority_HIGH,
@@ -207,6 +223,71 @@ func bootstrapConfig(c *envoy.BootstrapConfig) *envoy_bootstrap_v3.Bootstrap {
 			AccessLogPath: c.GetAdminAccessLogPath(),
 			Address:       SocketAddress(c.GetAdminAddress(), c.GetAdminPort()),
 		},
+		OverloadManager: overloadManager(c),
+		LayeredRuntime:  layeredRuntime(c),
+	}
+}
+
+// xdsEndpoints returns the endpoints of the xDS servers, grouped
+// by priority.
+func xdsEndpoints(servers []envoy.XDSServer) []*envoy_endpoint_v3.LocalityLbEndpoints {
+	var endpoints []*envoy_endpoint_v3.LocalityLbEndpoints
+	byPriority := map[uint32]*envoy_endpoint_v3.LocalityLbEndpoints{}
+
+	for _, s := range servers {
+		e, ok := byPriority[s.Priority]
+		if !ok {
+			e = &envoy_endpoint_v3.LocalityLbEndpoints{Priority: s.Priority}
+			byPriority[s.Priority] = e
+			endpoints = append(endpoints, e)
+		}
+		e.LbEndpoints = append(e.LbEndpoints, LBEndpoint(SocketAddress(s.Address, s.Port)))
+	}
+
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Priority < endpoints[j].Priority
+	})
+
+	return endpoints
+}
+
+// xdsHealthChecks returns gRPC health checks for the xDS servers, so
+// that Envoy fails over when a server is down. No health checks are
+// returned if there is only one server to connect to.
+func xdsHealthChecks(servers []envoy.XDSServer) []*envoy_core_v3.HealthCheck {
+	if len(servers) < 2 {
+		return nil
+	}
+
+	return []*envoy_core_v3.HealthCheck{{
+		Timeout:            protobuf.Duration(2 * time.Second),
+		Interval:           protobuf.Duration(5 * time.Second),
+		NoTrafficInterval:  protobuf.Duration(5 * time.Second),
+		UnhealthyThreshold: protobuf.UInt32(3),
+		HealthyThreshold:   protobuf.UInt32(2),
+		HealthChecker: &envoy_core_v3.HealthCheck_GrpcHealthCheck_{
+			GrpcHealthCheck: &envoy_core_v3.HealthCheck_GrpcHealthCheck{},
+		},
+	}}
+}
+
+// xdsHTTP2ProtocolOptions returns the HTTP/2 options of the xDS
+// connections, with PING based keepalive if it is configured.
+func xdsHTTP2ProtocolOptions(c *envoy.BootstrapConfig) *envoy_core_v3.Http2ProtocolOptions {
+	if c.XDSKeepaliveInterval == 0 {
+		return new(envoy_core_v3.Http2ProtocolOptions)
+	}
+
+	timeout := c.XDSKeepaliveTimeout
+	if timeout == 0 {
+		timeout = 20 * time.Second
+	}
+
+	return &envoy_core_v3.Http2ProtocolOptions{
+		ConnectionKeepalive: &envoy_core_v3.KeepaliveSettings{
+			Interval: protobuf.Duration(c.XDSKeepaliveInterval),
+			Timeout:  protobuf.Duration(timeout),
+		},
 	}
 }
 
@@ -290,6 +371,7 @@ func tlsCertificateSdsSecretConfig(c *envoy.BootstrapConfig) *envoy_service_disc
 						Filename: c.GrpcClientKey,
 					},
 				},
+				WatchedDirectory: watchedDirectory(c.GrpcClientCert, c.GrpcClientKey),
 			},
 		},
 	}
@@ -316,6 +398,7 @@ func validationContextSdsSecretConfig(c *envoy.BootstrapConfig) *envoy_service_d
 						Exact: "contour",
 					}},
 				},
+				WatchedDirectory: watchedDirectory(c.GrpcCABundle),
 			},
 		},
 	}
@@ -324,3 +407,184 @@ func validationContextSdsSecretConfig(c *envoy.BootstrapConfig) *envoy_service_d
 		Resources: []*any.Any{protobuf.MustMarshalAny(secret)},
 	}
 }
+
+// watchedDirectory returns the directory that Envoy should watch
+// to reload the given files when they are rotated. Nil is returned
+// unless all the files are in the same, explicitly named, directory.
+func watchedDirectory(filenames ...string) *envoy_core_v3.WatchedDirectory {
+	dir := path.Dir(filenames[0])
+	for _, f := range filenames[1:] {
+		if path.Dir(f) != dir {
+			return nil
+		}
+	}
+
+	if dir == "." {
+		return nil
+	}
+
+	return &envoy_core_v3.WatchedDirectory{
+		Path: dir,
+	}
+}
+
+// node returns the node details that Envoy sends to Contour, in
+// addition to the node ID and cluster from the Envoy command line.
+func node(c *envoy.BootstrapConfig) *envoy_core_v3.Node {
+	var n envoy_core_v3.Node
+
+	if len(c.NodeMetadata) > 0 || c.EnvoyGroup != "" {
+		n.Metadata = &_struct.Struct{
+			Fields: map[string]*_struct.Value{},
+		}
+		for k, v := range c.NodeMetadata {
+			n.Metadata.Fields[k] = &_struct.Value{
+				Kind: &_struct.Value_StringValue{StringValue: v},
+			}
+		}
+		if c.EnvoyGroup != "" {
+			n.Metadata.Fields[xds.EnvoyGroupMetadataKey] = &_struct.Value{
+				Kind: &_struct.Value_StringValue{StringValue: c.EnvoyGroup},
+			}
+		}
+	}
+
+	if c.NodeRegion != "" || c.NodeZone != "" || c.NodeSubZone != "" {
+		n.Locality = &envoy_core_v3.Locality{
+			Region:  c.NodeRegion,
+			Zone:    c.NodeZone,
+			SubZone: c.NodeSubZone,
+		}
+	}
+
+	if n.Metadata == nil && n.Locality == nil {
+		return nil
+	}
+
+	return &n
+}
+
+// overloadManager returns the overload manager configuration that
+// sheds load as Envoy's heap approaches c.MaxHeapSizeBytes, or nil
+// if no maximum heap size is configured.
+func overloadManager(c *envoy.BootstrapConfig) *envoy_overload_v3.OverloadManager {
+	if c.MaxHeapSizeBytes == 0 {
+		return nil
+	}
+
+	const fixedHeap = "envoy.resource_monitors.fixed_heap"
+
+	action := func(name string, threshold float64) *envoy_overload_v3.OverloadAction {
+		return &envoy_overload_v3.OverloadAction{
+			Name: name,
+			Triggers: []*envoy_overload_v3.Trigger{{
+				Name: fixedHeap,
+				TriggerOneof: &envoy_overload_v3.Trigger_Threshold{
+					Threshold: &envoy_overload_v3.ThresholdTrigger{
+						Value: threshold,
+					},
+				},
+			}},
+		}
+	}
+
+	return &envoy_overload_v3.OverloadManager{
+		RefreshInterval: protobuf.Duration(250 * time.Millisecond),
+		ResourceMonitors: []*envoy_overload_v3.ResourceMonitor{{
+			Name: fixedHeap,
+			ConfigType: &envoy_overload_v3.ResourceMonitor_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_fixed_heap_v2alpha.FixedHeapConfig{
+					MaxHeapSizeBytes: c.MaxHeapSizeBytes,
+				}),
+			},
+		}},
+		Actions: []*envoy_overload_v3.OverloadAction{
+			action("envoy.overload_actions.shrink_heap", 0.95),
+			action("envoy.overload_actions.disable_http_keepalive", 0.95),
+			action("envoy.overload_actions.stop_accepting_requests", 0.98),
+			action("envoy.overload_actions.stop_accepting_connections", 0.98),
+		},
+	}
+}
+
+// globalDownstreamMaxConnections is the runtime key that limits the
+// number of downstream connections across all listeners.
+const globalDownstreamMaxConnections = "overload.global_downstream_max_connections"
+
+// layeredRuntime returns a runtime with a static layer holding the
+// configured runtime values, followed by an admin layer so values can
+// still be changed on the admin interface. Nil is returned if there
+// are no runtime values, leaving Envoy's default runtime in place.
+func layeredRuntime(c *envoy.BootstrapConfig) *envoy_bootstrap_v3.LayeredRuntime {
+	values := map[string]string{}
+	for k, v := range c.RuntimeValues {
+		values[k] = v
+	}
+	if c.MaxDownstreamConnections > 0 {
+		values[globalDownstreamMaxConnections] = strconv.FormatUint(c.MaxDownstreamConnections, 10)
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	static := &_struct.Struct{Fields: map[string]*_struct.Value{}}
+	for k, v := range values {
+		static.Fields[k] = runtimeValue(v)
+	}
+
+	return &envoy_bootstrap_v3.LayeredRuntime{
+		Layers: []*envoy_bootstrap_v3.RuntimeLayer{{
+			Name: "static_layer",
+			LayerSpecifier: &envoy_bootstrap_v3.RuntimeLayer_StaticLayer{
+				StaticLayer: static,
+			},
+		}, {
+			Name: "admin_layer",
+			LayerSpecifier: &envoy_bootstrap_v3.RuntimeLayer_AdminLayer_{
+				AdminLayer: &envoy_bootstrap_v3.RuntimeLayer_AdminLayer{},
+			},
+		}},
+	}
+}
+
+// runtimeValue returns s as a number or boolean runtime value if it
+// is one, otherwise as a string.
+func runtimeValue(s string) *_struct.Value {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return &_struct.Value{Kind: &_struct.Value_NumberValue{NumberValue: f}}
+	}
+	if s == "true" || s == "false" {
+		return &_struct.Value{Kind: &_struct.Value_BoolValue{BoolValue: s == "true"}}
+	}
+	return &_struct.Value{Kind: &_struct.Value_StringValue{StringValue: s}}
+}
+
+// dynamicResources returns the configuration sources for listeners and
+// clusters. If ADS is enabled, they are fetched on the aggregated stream.
+func dynamicResources(c *envoy.BootstrapConfig) *envoy_bootstrap_v3.Bootstrap_DynamicResources {
+	if !c.ADS {
+		cds := ConfigSource("contour")
+		if c.Delta {
+			cds.GetApiConfigSource().ApiType = envoy_core_v3.ApiConfigSource_DELTA_GRPC
+		}
+
+		return &envoy_bootstrap_v3.Bootstrap_DynamicResources{
+			LdsConfig: ConfigSource("contour"),
+			CdsConfig: cds,
+		}
+	}
+
+	ads := &envoy_core_v3.ConfigSource{
+		ResourceApiVersion: envoy_core_v3.ApiVersion_V3,
+		ConfigSourceSpecifier: &envoy_core_v3.ConfigSource_Ads{
+			Ads: &envoy_core_v3.AggregatedConfigSource{},
+		},
+	}
+
+	return &envoy_bootstrap_v3.Bootstrap_DynamicResources{
+		AdsConfig: ConfigSource("contour").GetApiConfigSource(),
+		LdsConfig: ads,
+		CdsConfig: ads,
+	}
+}
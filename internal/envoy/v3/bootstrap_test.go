@@ -16,6 +16,7 @@ package v3
 import (
 	"path"
 	"testing"
+	"time"
 
 	envoy_bootstrap_v3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
@@ -159,6 +160,777 @@ func TestBootstrap(t *testing.T) {
   }
 }`,
 		},
+		"--ads": {
+			config: envoy.BootstrapConfig{
+				Path:      "envoy.json",
+				Namespace: "testing-ns",
+				ADS:       true,
+			},
+			wantedBootstrapConfig: `{
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "contour",
+        "alt_stat_name": "testing-ns_contour_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "contour",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {},
+        "upstream_connection_options": {
+          "tcp_keepalive": {
+            "keepalive_probes": 3,
+            "keepalive_time": 30,
+            "keepalive_interval": 5
+          }
+        }
+      },
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        }
+      }
+    ]
+  },
+  "dynamic_resources": {
+    "ads_config": {
+      "api_type": "GRPC",
+      "transport_api_version": "V3",
+      "grpc_services": [
+        {
+          "envoy_grpc": {
+            "cluster_name": "contour"
+          }
+        }
+      ]
+    },
+    "lds_config": {
+      "ads": {},
+      "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "ads": {},
+      "resource_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
+}`,
+		},
+		"--delta": {
+			config: envoy.BootstrapConfig{
+				Path:      "envoy.json",
+				Namespace: "testing-ns",
+				Delta:     true,
+			},
+			wantedBootstrapConfig: `{
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "contour",
+        "alt_stat_name": "testing-ns_contour_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "contour",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {},
+        "upstream_connection_options": {
+          "tcp_keepalive": {
+            "keepalive_probes": 3,
+            "keepalive_time": 30,
+            "keepalive_interval": 5
+          }
+        }
+      },
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        }
+      }
+    ]
+  },
+  "dynamic_resources": {
+    "lds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+		"transport_api_version": "V3",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "contour"
+            }
+          }
+        ]
+      },
+	  "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "api_config_source": {
+        "api_type": "DELTA_GRPC",
+	 	"transport_api_version": "V3",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "contour"
+            }
+          }
+        ]
+      },
+ 	  "resource_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
+}`,
+		},
+		"--envoy-group=internal": {
+			config: envoy.BootstrapConfig{
+				Path:       "envoy.json",
+				Namespace:  "testing-ns",
+				EnvoyGroup: "internal",
+			},
+			wantedBootstrapConfig: `{
+  "node": {
+    "metadata": {
+      "projectcontour.io/envoy-group": "internal"
+    }
+  },
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "contour",
+        "alt_stat_name": "testing-ns_contour_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "contour",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {},
+        "upstream_connection_options": {
+          "tcp_keepalive": {
+            "keepalive_probes": 3,
+            "keepalive_time": 30,
+            "keepalive_interval": 5
+          }
+        }
+      },
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        }
+      }
+    ]
+  },
+  "dynamic_resources": {
+    "lds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+		"transport_api_version": "V3",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "contour"
+            }
+          }
+        ]
+      },
+	  "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+	 	"transport_api_version": "V3",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "contour"
+            }
+          }
+        ]
+      },
+ 	  "resource_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
+}`,
+		},
+		"overload manager, runtime and node locality": {
+			config: envoy.BootstrapConfig{
+				Path:                     "envoy.json",
+				Namespace:                "testing-ns",
+				EnvoyGroup:               "internal",
+				NodeMetadata:             map[string]string{"team": "edge"},
+				NodeRegion:               "eu-west-1",
+				NodeZone:                 "eu-west-1a",
+				MaxHeapSizeBytes:         1073741824,
+				MaxDownstreamConnections: 50000,
+				RuntimeValues: map[string]string{
+					"re2.max_program_size.error_level":  "1000",
+					"envoy.reloadable_features.example": "false",
+				},
+			},
+			wantedBootstrapConfig: `{
+  "node": {
+    "metadata": {
+      "projectcontour.io/envoy-group": "internal",
+      "team": "edge"
+    },
+    "locality": {
+      "region": "eu-west-1",
+      "zone": "eu-west-1a"
+    }
+  },
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "contour",
+        "alt_stat_name": "testing-ns_contour_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "contour",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {},
+        "upstream_connection_options": {
+          "tcp_keepalive": {
+            "keepalive_probes": 3,
+            "keepalive_time": 30,
+            "keepalive_interval": 5
+          }
+        }
+      },
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        }
+      }
+    ]
+  },
+  "dynamic_resources": {
+    "lds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+		"transport_api_version": "V3",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "contour"
+            }
+          }
+        ]
+      },
+	  "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+	 	"transport_api_version": "V3",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "contour"
+            }
+          }
+        ]
+      },
+ 	  "resource_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
+,
+  "overload_manager": {
+    "refresh_interval": "0.250s",
+    "resource_monitors": [
+      {
+        "name": "envoy.resource_monitors.fixed_heap",
+        "typed_config": {
+          "@type": "type.googleapis.com/envoy.config.resource_monitor.fixed_heap.v2alpha.FixedHeapConfig",
+          "max_heap_size_bytes": "1073741824"
+        }
+      }
+    ],
+    "actions": [
+      {
+        "name": "envoy.overload_actions.shrink_heap",
+        "triggers": [{"name": "envoy.resource_monitors.fixed_heap", "threshold": {"value": 0.95}}]
+      },
+      {
+        "name": "envoy.overload_actions.disable_http_keepalive",
+        "triggers": [{"name": "envoy.resource_monitors.fixed_heap", "threshold": {"value": 0.95}}]
+      },
+      {
+        "name": "envoy.overload_actions.stop_accepting_requests",
+        "triggers": [{"name": "envoy.resource_monitors.fixed_heap", "threshold": {"value": 0.98}}]
+      },
+      {
+        "name": "envoy.overload_actions.stop_accepting_connections",
+        "triggers": [{"name": "envoy.resource_monitors.fixed_heap", "threshold": {"value": 0.98}}]
+      }
+    ]
+  },
+  "layered_runtime": {
+    "layers": [
+      {
+        "name": "static_layer",
+        "static_layer": {
+          "envoy.reloadable_features.example": false,
+          "overload.global_downstream_max_connections": 50000,
+          "re2.max_program_size.error_level": 1000
+        }
+      },
+      {
+        "name": "admin_layer",
+        "admin_layer": {}
+      }
+    ]
+  }
+}`,
+		},
+		"return error when --max-downstream-connections is also set as a runtime value": {
+			config: envoy.BootstrapConfig{
+				Path:                     "envoy.json",
+				Namespace:                "testing-ns",
+				MaxDownstreamConnections: 50000,
+				RuntimeValues: map[string]string{
+					"overload.global_downstream_max_connections": "100",
+				},
+			},
+			wantedError: true,
+		},
+		"--xds-server with failover": {
+			config: envoy.BootstrapConfig{
+				Path:      "envoy.json",
+				Namespace: "testing-ns",
+				XDSServers: []envoy.XDSServer{
+					{Address: "contour.dr.example.com", Port: 8001, Priority: 1},
+					{Address: "contour.projectcontour", Port: 8001},
+					{Address: "contour-standby.dr.example.com", Port: 8001, Priority: 1},
+				},
+				XDSKeepaliveInterval: 10 * time.Second,
+			},
+			wantedBootstrapConfig: `{
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "contour",
+        "alt_stat_name": "testing-ns_contour_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "contour",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "contour.projectcontour",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            },
+            {
+              "priority": 1,
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "contour.dr.example.com",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                },
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "contour-standby.dr.example.com",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "health_checks": [
+          {
+            "timeout": "2s",
+            "interval": "5s",
+            "no_traffic_interval": "5s",
+            "unhealthy_threshold": 3,
+            "healthy_threshold": 2,
+            "grpc_health_check": {}
+          }
+        ],
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {
+          "connection_keepalive": {
+            "interval": "10s",
+            "timeout": "20s"
+          }
+        },
+        "upstream_connection_options": {
+          "tcp_keepalive": {
+            "keepalive_probes": 3,
+            "keepalive_time": 30,
+            "keepalive_interval": 5
+          }
+        }
+      },
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        }
+      }
+    ]
+  },
+  "dynamic_resources": {
+    "lds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+		"transport_api_version": "V3",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "contour"
+            }
+          }
+        ]
+      },
+	  "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+	 	"transport_api_version": "V3",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "contour"
+            }
+          }
+        ]
+      },
+ 	  "resource_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
+}`,
+		},
+		"return error when using --xds-server and --xds-address": {
+			config: envoy.BootstrapConfig{
+				Path:       "envoy.json",
+				Namespace:  "testing-ns",
+				XDSAddress: "contour",
+				XDSServers: []envoy.XDSServer{{Address: "contour.projectcontour", Port: 8001}},
+			},
+			wantedError: true,
+		},
+		"return error when using --ads and --delta": {
+			config: envoy.BootstrapConfig{
+				Path:      "envoy.json",
+				Namespace: "testing-ns",
+				ADS:       true,
+				Delta:     true,
+			},
+			wantedError: true,
+		},
 		"--admin-address=8.8.8.8 --admin-port=9200": {
 			config: envoy.BootstrapConfig{
 				Path:         "envoy.json",
@@ -1079,3 +1851,61 @@ func checkErr(t *testing.T, err error) {
 		t.Fatal(err)
 	}
 }
+
+func TestSdsSecretConfigWatchedDirectory(t *testing.T) {
+	c := &envoy.BootstrapConfig{
+		GrpcCABundle:   "/certs/ca.crt",
+		GrpcClientCert: "/certs/tls.crt",
+		GrpcClientKey:  "/certs/tls.key",
+	}
+
+	want := new(envoy_service_discovery_v3.DiscoveryResponse)
+	unmarshal(t, `{
+  "resources": [
+    {
+      "@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret",
+      "name": "contour_xds_tls_certificate",
+      "tls_certificate": {
+        "certificate_chain": {
+          "filename": "/certs/tls.crt"
+        },
+        "private_key": {
+          "filename": "/certs/tls.key"
+        },
+        "watched_directory": {
+          "path": "/certs"
+        }
+      }
+    }
+  ]
+}`, want)
+	protobuf.ExpectEqual(t, want, tlsCertificateSdsSecretConfig(c))
+
+	want = new(envoy_service_discovery_v3.DiscoveryResponse)
+	unmarshal(t, `{
+  "resources": [
+    {
+      "@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret",
+      "name": "contour_xds_tls_validation_context",
+      "validation_context": {
+        "trusted_ca": {
+          "filename": "/certs/ca.crt"
+        },
+        "match_subject_alt_names": [
+          {
+            "exact": "contour"
+          }
+        ],
+        "watched_directory": {
+          "path": "/certs"
+        }
+      }
+    }
+  ]
+}`, want)
+	protobuf.ExpectEqual(t, want, validationContextSdsSecretConfig(c))
+
+	// Files in different directories cannot be watched together.
+	assert.Nil(t, watchedDirectory("/certs/tls.crt", "/keys/tls.key"))
+	assert.Nil(t, watchedDirectory("tls.crt", "tls.key"))
+}
@@ -46,6 +46,7 @@ func Cluster(c *dag.Cluster) *envoy_cluster_v3.Cluster {
 	cluster.LbPolicy = lbPolicy(c.LoadBalancerPolicy)
 	cluster.HealthChecks = edshealthcheck(c)
 	cluster.DnsLookupFamily = parseDNSLookupFamily(c.DNSLookupFamily)
+	applyConnectionPolicy(cluster, c.ConnectionPolicy)
 
 	switch len(service.ExternalName) {
 	case 0:
@@ -117,6 +118,7 @@ func ExtensionCluster(ext *dag.ExtensionCluster) *envoy_cluster_v3.Cluster {
 	cluster.AltStatName = strings.ReplaceAll(cluster.Name, "/", "_")
 
 	cluster.LbPolicy = lbPolicy(ext.LoadBalancerPolicy)
+	applyConnectionPolicy(cluster, ext.ConnectionPolicy)
 
 	// Cluster will be discovered via EDS.
 	cluster.ClusterDiscoveryType = ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS)
@@ -145,6 +147,21 @@ func ExtensionCluster(ext *dag.ExtensionCluster) *envoy_cluster_v3.Cluster {
 	return cluster
 }
 
+// applyConnectionPolicy sets the upstream HTTP connection options
+// of cluster from the supplied policy. Options that the policy
+// doesn't set are left to the Envoy defaults.
+func applyConnectionPolicy(cluster *envoy_cluster_v3.Cluster, cp dag.ConnectionPolicy) {
+	if !cp.IdleTimeout.UseDefault() || !cp.MaxStreamDuration.UseDefault() {
+		cluster.CommonHttpProtocolOptions = &envoy_core_v3.HttpProtocolOptions{
+			IdleTimeout:       envoy.Timeout(cp.IdleTimeout),
+			MaxStreamDuration: envoy.Timeout(cp.MaxStreamDuration),
+		}
+	}
+
+	cluster.MaxRequestsPerConnection = protobuf.UInt32OrNil(cp.MaxRequestsPerConnection)
+	cluster.PerConnectionBufferLimitBytes = protobuf.UInt32OrNil(cp.PerConnectionBufferLimitBytes)
+}
+
 // StaticClusterLoadAssignment creates a *envoy_endpoint_v3.ClusterLoadAssignment pointing to the external DNS address of the service
 func StaticClusterLoadAssignment(service *dag.Service) *envoy_endpoint_v3.ClusterLoadAssignment {
 	addr := SocketAddress(service.ExternalName, int(service.Weighted.ServicePort.Port))
@@ -24,6 +24,7 @@ import (
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/timeout"
 	"github.com/projectcontour/contour/internal/xds"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -488,6 +489,32 @@ func TestCluster(t *testing.T) {
 				),
 			},
 		},
+		"connection policy": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1),
+				ConnectionPolicy: dag.ConnectionPolicy{
+					IdleTimeout:                   timeout.DurationSetting(4 * time.Second),
+					MaxRequestsPerConnection:      100,
+					MaxStreamDuration:             timeout.DisabledSetting(),
+					PerConnectionBufferLimitBytes: 32768,
+				},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/32ea906879",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{
+					IdleTimeout:       protobuf.Duration(4 * time.Second),
+					MaxStreamDuration: protobuf.Duration(0),
+				},
+				MaxRequestsPerConnection:      protobuf.UInt32(100),
+				PerConnectionBufferLimitBytes: protobuf.UInt32(32768),
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -14,9 +14,11 @@
 package v3
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"sort"
 	"strings"
 	"time"
@@ -24,6 +26,7 @@ import (
 	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_filter_http_buffer_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
 	envoy_compressor_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
 	envoy_config_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
 	envoy_config_filter_http_local_ratelimit_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
@@ -31,6 +34,7 @@ import (
 	envoy_extensions_filters_http_router_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
 	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	udp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/udp/udp_proxy/v3"
 	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
@@ -145,6 +149,7 @@ type httpConnectionManagerBuilder struct {
 	metricsPrefix                 string
 	accessLoggers                 []*accesslog.AccessLog
 	requestTimeout                timeout.Setting
+	requestHeadersTimeout         timeout.Setting
 	connectionIdleTimeout         timeout.Setting
 	streamIdleTimeout             timeout.Setting
 	maxConnectionDuration         timeout.Setting
@@ -152,6 +157,9 @@ type httpConnectionManagerBuilder struct {
 	filters                       []*http.HttpFilter
 	codec                         HTTPVersionType // Note the zero value is AUTO, which is the default we want.
 	allowChunkedLength            bool
+	allowAbsoluteURLs             bool
+	properCaseHeaders             bool
+	errorPages                    []*dag.ErrorPage
 }
 
 // RouteConfigName sets the name of the RDS element that contains
@@ -189,6 +197,12 @@ func (b *httpConnectionManagerBuilder) RequestTimeout(timeout timeout.Setting) *
 	return b
 }
 
+// RequestHeadersTimeout sets the request headers timeout on the connection manager.
+func (b *httpConnectionManagerBuilder) RequestHeadersTimeout(timeout timeout.Setting) *httpConnectionManagerBuilder {
+	b.requestHeadersTimeout = timeout
+	return b
+}
+
 // ConnectionIdleTimeout sets the idle timeout on the connection manager.
 func (b *httpConnectionManagerBuilder) ConnectionIdleTimeout(timeout timeout.Setting) *httpConnectionManagerBuilder {
 	b.connectionIdleTimeout = timeout
@@ -218,6 +232,37 @@ func (b *httpConnectionManagerBuilder) AllowChunkedLength(enabled bool) *httpCon
 	return b
 }
 
+// HTTPPolicy overrides the settings of the connection manager
+// with those that the policy sets. It must be called after the
+// settings it overrides.
+func (b *httpConnectionManagerBuilder) HTTPPolicy(policy *dag.HTTPPolicy) *httpConnectionManagerBuilder {
+	if policy == nil {
+		return b
+	}
+
+	override := func(setting *timeout.Setting, value timeout.Setting) {
+		if !value.UseDefault() {
+			*setting = value
+		}
+	}
+
+	override(&b.requestTimeout, policy.RequestTimeout)
+	override(&b.requestHeadersTimeout, policy.RequestHeadersTimeout)
+	override(&b.streamIdleTimeout, policy.StreamIdleTimeout)
+	override(&b.maxConnectionDuration, policy.MaxConnectionDuration)
+
+	b.allowAbsoluteURLs = policy.AllowAbsoluteURLs
+	b.properCaseHeaders = policy.ProperCaseHeaders
+	return b
+}
+
+// ErrorPages appends pages to the error pages of the connection
+// manager. Pages are matched in the order they are added.
+func (b *httpConnectionManagerBuilder) ErrorPages(pages ...*dag.ErrorPage) *httpConnectionManagerBuilder {
+	b.errorPages = append(b.errorPages, pages...)
+	return b
+}
+
 func (b *httpConnectionManagerBuilder) DefaultFilters() *httpConnectionManagerBuilder {
 
 	// Add a default set of ordered http filters.
@@ -380,9 +425,22 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_listener_v3.Filter {
 		PreserveExternalRequestId: true,
 		MergeSlashes:              true,
 
-		RequestTimeout:    envoy.Timeout(b.requestTimeout),
-		StreamIdleTimeout: envoy.Timeout(b.streamIdleTimeout),
-		DrainTimeout:      envoy.Timeout(b.connectionShutdownGracePeriod),
+		RequestTimeout:        envoy.Timeout(b.requestTimeout),
+		RequestHeadersTimeout: envoy.Timeout(b.requestHeadersTimeout),
+		StreamIdleTimeout:     envoy.Timeout(b.streamIdleTimeout),
+		DrainTimeout:          envoy.Timeout(b.connectionShutdownGracePeriod),
+	}
+
+	if b.allowAbsoluteURLs {
+		cm.HttpProtocolOptions.AllowAbsoluteUrl = protobuf.Bool(true)
+	}
+
+	if b.properCaseHeaders {
+		cm.HttpProtocolOptions.HeaderKeyFormat = &envoy_core_v3.Http1ProtocolOptions_HeaderKeyFormat{
+			HeaderFormat: &envoy_core_v3.Http1ProtocolOptions_HeaderKeyFormat_ProperCaseWords_{
+				ProperCaseWords: &envoy_core_v3.Http1ProtocolOptions_HeaderKeyFormat_ProperCaseWords{},
+			},
+		}
 	}
 
 	// Max connection duration is infinite/disabled by default in Envoy, so if the timeout setting
@@ -397,6 +455,10 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_listener_v3.Filter {
 		cm.AccessLog = b.accessLoggers
 	}
 
+	if len(b.errorPages) > 0 {
+		cm.LocalReplyConfig = LocalReplyConfig(b.errorPages)
+	}
+
 	// If there's no explicit metrics prefix, default it to the
 	// route config name.
 	if b.metricsPrefix != "" {
@@ -413,6 +475,85 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_listener_v3.Filter {
 	}
 }
 
+// LocalReplyConfig returns a local reply config that replaces the
+// replies Envoy generates with the matching error page. Each status
+// code comparison has its own runtime key, named after the contents
+// of the page and the code, so that overriding one does not change
+// the codes matched by the others, and a key refers to the same page
+// on every virtual host.
+func LocalReplyConfig(pages []*dag.ErrorPage) *http.LocalReplyConfig {
+	statusCode := func(id string, code uint32) *accesslog.AccessLogFilter {
+		return &accesslog.AccessLogFilter{
+			FilterSpecifier: &accesslog.AccessLogFilter_StatusCodeFilter{
+				StatusCodeFilter: &accesslog.StatusCodeFilter{
+					Comparison: &accesslog.ComparisonFilter{
+						Op: accesslog.ComparisonFilter_EQ,
+						Value: &envoy_core_v3.RuntimeUInt32{
+							DefaultValue: code,
+							RuntimeKey:   fmt.Sprintf("contour.error_page.%s.%d", id, code),
+						},
+					},
+				},
+			},
+		}
+	}
+
+	var config http.LocalReplyConfig
+
+	for _, page := range pages {
+		id := errorPageID(page)
+		mapper := &http.ResponseMapper{
+			StatusCode: protobuf.UInt32OrNil(page.ResponseStatusCode),
+			Body: &envoy_core_v3.DataSource{
+				Specifier: &envoy_core_v3.DataSource_InlineString{
+					InlineString: page.Body,
+				},
+			},
+		}
+
+		// An OR filter needs at least two filters.
+		if len(page.StatusCodes) == 1 {
+			mapper.Filter = statusCode(id, page.StatusCodes[0])
+		} else {
+			var filters []*accesslog.AccessLogFilter
+			for _, code := range page.StatusCodes {
+				filters = append(filters, statusCode(id, code))
+			}
+
+			mapper.Filter = &accesslog.AccessLogFilter{
+				FilterSpecifier: &accesslog.AccessLogFilter_OrFilter{
+					OrFilter: &accesslog.OrFilter{
+						Filters: filters,
+					},
+				},
+			}
+		}
+
+		// The content type can only be set by overriding the
+		// body format, so use a format that is just the body.
+		if page.ContentType != "" {
+			mapper.BodyFormatOverride = &envoy_core_v3.SubstitutionFormatString{
+				Format: &envoy_core_v3.SubstitutionFormatString_TextFormat{
+					TextFormat: "%LOCAL_REPLY_BODY%",
+				},
+				ContentType: page.ContentType,
+			}
+		}
+
+		config.Mappers = append(config.Mappers, mapper)
+	}
+
+	return &config
+}
+
+// errorPageID returns an identifier of the error page that
+// only depends on its contents.
+func errorPageID(page *dag.ErrorPage) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v\x00%d\x00%s\x00%s", page.StatusCodes, page.ResponseStatusCode, page.ContentType, page.Body)
+	return fmt.Sprintf("%x", h.Sum(nil))[:12]
+}
+
 // HTTPConnectionManager creates a new HTTP Connection Manager filter
 // for the supplied route, access log, and client request timeout.
 func HTTPConnectionManager(routename string, accesslogger []*accesslog.AccessLog, requestTimeout time.Duration) *envoy_listener_v3.Filter {
@@ -484,6 +625,61 @@ func TCPProxy(statPrefix string, proxy *dag.TCPProxy, accesslogger []*accesslog.
 	}
 }
 
+// UDPProxyFilterName is the name of Envoy's UDP proxy listener filter.
+const UDPProxyFilterName = "envoy.filters.udp_listener.udp_proxy"
+
+// UDPProxy creates a new UDP proxy listener filter that forwards
+// datagrams to the first cluster of the TCPProxy. Envoy's UDP proxy
+// does not support weighted clusters.
+func UDPProxy(statPrefix string, proxy *dag.TCPProxy) *envoy_listener_v3.ListenerFilter {
+	return &envoy_listener_v3.ListenerFilter{
+		Name: UDPProxyFilterName,
+		ConfigType: &envoy_listener_v3.ListenerFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&udp.UdpProxyConfig{
+				StatPrefix: statPrefix,
+				RouteSpecifier: &udp.UdpProxyConfig_Cluster{
+					Cluster: envoy.Clustername(proxy.Clusters[0]),
+				},
+			}),
+		},
+	}
+}
+
+// UDPListener returns a new envoy_listener_v3.Listener that receives
+// UDP datagrams on the supplied address and port. UDP listeners have
+// no filter chains, the datagrams are handled by listener filters.
+func UDPListener(name, address string, port int, lf ...*envoy_listener_v3.ListenerFilter) *envoy_listener_v3.Listener {
+	addr := SocketAddress(address, port)
+	addr.GetSocketAddress().Protocol = envoy_core_v3.SocketAddress_UDP
+
+	return &envoy_listener_v3.Listener{
+		Name:            name,
+		Address:         addr,
+		ListenerFilters: lf,
+	}
+}
+
+// QUICListener returns a new envoy_listener_v3.Listener that serves
+// HTTP/3 over QUIC on the supplied UDP address and port.
+func QUICListener(name, address string, port int) *envoy_listener_v3.Listener {
+	addr := SocketAddress(address, port)
+	addr.GetSocketAddress().Protocol = envoy_core_v3.SocketAddress_UDP
+
+	return &envoy_listener_v3.Listener{
+		Name:    name,
+		Address: addr,
+		UdpListenerConfig: &envoy_listener_v3.UdpListenerConfig{
+			UdpListenerName: "quiche_quic_listener",
+			ConfigType: &envoy_listener_v3.UdpListenerConfig_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_listener_v3.QuicProtocolOptions{}),
+			},
+		},
+		// Each worker thread binds its own socket, so that the
+		// kernel keeps the datagrams of a connection on a thread.
+		ReusePort: true,
+	}
+}
+
 // SocketAddress creates a new TCP envoy_core_v3.Address.
 func SocketAddress(address string, port int) *envoy_core_v3.Address {
 	if address == "::" {
@@ -579,7 +775,10 @@ end
 
 // FilterExternalAuthz returns an `ext_authz` filter configured with the
 // requested parameters.
-func FilterExternalAuthz(authzClusterName string, failOpen bool, timeout timeout.Setting) *http.HttpFilter {
+//
+// If maxRequestBytes is non-zero, the check request includes the
+// request body, truncated to maxRequestBytes.
+func FilterExternalAuthz(authzClusterName string, failOpen bool, timeout timeout.Setting, maxRequestBytes uint32) *http.HttpFilter {
 	authConfig := envoy_config_filter_http_ext_authz_v3.ExtAuthz{
 		Services: &envoy_config_filter_http_ext_authz_v3.ExtAuthz_GrpcService{
 			GrpcService: &envoy_core_v3.GrpcService{
@@ -611,6 +810,17 @@ func FilterExternalAuthz(authzClusterName string, failOpen bool, timeout timeout
 		TransportApiVersion: envoy_core_v3.ApiVersion_V3,
 	}
 
+	if maxRequestBytes > 0 {
+		// Requests over the limit of a buffered route are
+		// rejected by the buffer filter before they get here,
+		// so allow partial bodies for the routes that are
+		// not buffered.
+		authConfig.WithRequestBody = &envoy_config_filter_http_ext_authz_v3.BufferSettings{
+			MaxRequestBytes:     maxRequestBytes,
+			AllowPartialMessage: true,
+		}
+	}
+
 	return &http.HttpFilter{
 		Name: "envoy.filters.http.ext_authz",
 		ConfigType: &http.HttpFilter_TypedConfig{
@@ -619,6 +829,21 @@ func FilterExternalAuthz(authzClusterName string, failOpen bool, timeout timeout
 	}
 }
 
+// FilterBuffer returns an HTTP filter that buffers request bodies.
+// The filter is expected to be disabled on each virtual host, and
+// enabled with the limit of the buffer policy on each route that
+// has one, so the limit configured here is never applied.
+func FilterBuffer() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.buffer",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_config_filter_http_buffer_v3.Buffer{
+				MaxRequestBytes: protobuf.UInt32(math.MaxUint32),
+			}),
+		},
+	}
+}
+
 // FilterChainTLS returns a TLS enabled envoy_listener_v3.FilterChain.
 func FilterChainTLS(domain string, downstream *envoy_tls_v3.DownstreamTlsContext, filters []*envoy_listener_v3.Filter) *envoy_listener_v3.FilterChain {
 	fc := &envoy_listener_v3.FilterChain{
@@ -635,6 +860,18 @@ func FilterChainTLS(domain string, downstream *envoy_tls_v3.DownstreamTlsContext
 	return fc
 }
 
+// FilterChainQUIC returns a QUIC enabled envoy_listener_v3.FilterChain
+// that matches the domain by SNI.
+func FilterChainQUIC(domain string, downstream *envoy_tls_v3.DownstreamTlsContext, filters []*envoy_listener_v3.Filter) *envoy_listener_v3.FilterChain {
+	return &envoy_listener_v3.FilterChain{
+		Filters: filters,
+		FilterChainMatch: &envoy_listener_v3.FilterChainMatch{
+			ServerNames: []string{domain},
+		},
+		TransportSocket: DownstreamQUICTransportSocket(downstream),
+	}
+}
+
 // FilterChainTLSFallback returns a TLS enabled envoy_listener_v3.FilterChain conifgured for FallbackCertificate.
 func FilterChainTLSFallback(downstream *envoy_tls_v3.DownstreamTlsContext, filters []*envoy_listener_v3.Filter) *envoy_listener_v3.FilterChain {
 	fc := &envoy_listener_v3.FilterChain{
@@ -14,6 +14,7 @@
 package v3
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -21,11 +22,13 @@ import (
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	envoy_compressor_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+	envoy_config_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
 	envoy_config_filter_http_local_ratelimit_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
 	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	envoy_tcp_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
@@ -1065,6 +1068,74 @@ func TestHTTPConnectionManager(t *testing.T) {
 	}
 }
 
+func TestHTTPConnectionManagerHTTPPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy *dag.HTTPPolicy
+		want   *http.HttpConnectionManager
+	}{
+		"nil policy keeps the listener settings": {
+			policy: nil,
+			want: &http.HttpConnectionManager{
+				HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
+					AcceptHttp_10: true,
+				},
+				CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{
+					MaxConnectionDuration: protobuf.Duration(time.Hour),
+				},
+				RequestTimeout:        protobuf.Duration(30 * time.Second),
+				RequestHeadersTimeout: protobuf.Duration(10 * time.Second),
+			},
+		},
+		"policy overrides what it sets": {
+			policy: &dag.HTTPPolicy{
+				RequestTimeout:    timeout.DisabledSetting(),
+				StreamIdleTimeout: timeout.DurationSetting(time.Minute),
+				AllowAbsoluteURLs: true,
+				ProperCaseHeaders: true,
+			},
+			want: &http.HttpConnectionManager{
+				HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
+					AcceptHttp_10:    true,
+					AllowAbsoluteUrl: protobuf.Bool(true),
+					HeaderKeyFormat: &envoy_core_v3.Http1ProtocolOptions_HeaderKeyFormat{
+						HeaderFormat: &envoy_core_v3.Http1ProtocolOptions_HeaderKeyFormat_ProperCaseWords_{
+							ProperCaseWords: &envoy_core_v3.Http1ProtocolOptions_HeaderKeyFormat_ProperCaseWords{},
+						},
+					},
+				},
+				CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{
+					MaxConnectionDuration: protobuf.Duration(time.Hour),
+				},
+				RequestTimeout:        protobuf.Duration(0),
+				RequestHeadersTimeout: protobuf.Duration(10 * time.Second),
+				StreamIdleTimeout:     protobuf.Duration(time.Minute),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			filter := HTTPConnectionManagerBuilder().
+				RouteConfigName("default/kuard").
+				RequestTimeout(timeout.DurationSetting(30 * time.Second)).
+				RequestHeadersTimeout(timeout.DurationSetting(10 * time.Second)).
+				MaxConnectionDuration(timeout.DurationSetting(time.Hour)).
+				HTTPPolicy(tc.policy).
+				DefaultFilters().
+				Get()
+
+			var got http.HttpConnectionManager
+			require.NoError(t, ptypes.UnmarshalAny(filter.GetTypedConfig(), &got))
+
+			protobuf.ExpectEqual(t, tc.want.HttpProtocolOptions, got.HttpProtocolOptions)
+			protobuf.ExpectEqual(t, tc.want.CommonHttpProtocolOptions, got.CommonHttpProtocolOptions)
+			protobuf.ExpectEqual(t, tc.want.RequestTimeout, got.RequestTimeout)
+			protobuf.ExpectEqual(t, tc.want.RequestHeadersTimeout, got.RequestHeadersTimeout)
+			protobuf.ExpectEqual(t, tc.want.StreamIdleTimeout, got.StreamIdleTimeout)
+		})
+	}
+}
+
 func TestTCPProxy(t *testing.T) {
 	const (
 		statPrefix    = "ingress_https"
@@ -1158,6 +1229,76 @@ func TestTCPProxy(t *testing.T) {
 	}
 }
 
+func TestLocalReplyConfig(t *testing.T) {
+	statusCode := func(id string, code uint32) *envoy_accesslog_v3.AccessLogFilter {
+		return &envoy_accesslog_v3.AccessLogFilter{
+			FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_StatusCodeFilter{
+				StatusCodeFilter: &envoy_accesslog_v3.StatusCodeFilter{
+					Comparison: &envoy_accesslog_v3.ComparisonFilter{
+						Op: envoy_accesslog_v3.ComparisonFilter_EQ,
+						Value: &envoy_core_v3.RuntimeUInt32{
+							DefaultValue: code,
+							RuntimeKey:   fmt.Sprintf("contour.error_page.%s.%d", id, code),
+						},
+					},
+				},
+			},
+		}
+	}
+
+	body := func(s string) *envoy_core_v3.DataSource {
+		return &envoy_core_v3.DataSource{
+			Specifier: &envoy_core_v3.DataSource_InlineString{
+				InlineString: s,
+			},
+		}
+	}
+
+	pages := []*dag.ErrorPage{{
+		StatusCodes: []uint32{404},
+		Body:        "not found",
+	}, {
+		StatusCodes:        []uint32{502, 503},
+		Body:               "<h1>unavailable</h1>",
+		ContentType:        "text/html",
+		ResponseStatusCode: 503,
+	}}
+	got := LocalReplyConfig(pages)
+
+	want := &http.LocalReplyConfig{
+		Mappers: []*http.ResponseMapper{{
+			Filter: statusCode("6f9dc3f42cfe", 404),
+			Body:   body("not found"),
+		}, {
+			Filter: &envoy_accesslog_v3.AccessLogFilter{
+				FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_OrFilter{
+					OrFilter: &envoy_accesslog_v3.OrFilter{
+						Filters: []*envoy_accesslog_v3.AccessLogFilter{
+							statusCode("b75ee378a690", 502),
+							statusCode("b75ee378a690", 503),
+						},
+					},
+				},
+			},
+			StatusCode: protobuf.UInt32(503),
+			Body:       body("<h1>unavailable</h1>"),
+			BodyFormatOverride: &envoy_core_v3.SubstitutionFormatString{
+				Format: &envoy_core_v3.SubstitutionFormatString_TextFormat{
+					TextFormat: "%LOCAL_REPLY_BODY%",
+				},
+				ContentType: "text/html",
+			},
+		}},
+	}
+
+	protobuf.ExpectEqual(t, want, got)
+	require.NoError(t, got.Validate())
+
+	// Runtime keys don't depend on the position of the page.
+	got = LocalReplyConfig(pages[1:])
+	protobuf.ExpectEqual(t, want.Mappers[1], got.Mappers[0])
+}
+
 // TestBuilderValidation tests that validation checks that
 // DefaultFilters adds the required HTTP connection manager filters.
 func TestBuilderValidation(t *testing.T) {
@@ -1190,6 +1331,19 @@ func TestBuilderValidation(t *testing.T) {
 	assert.Errorf(t, badBuilder.Validate(), "Adding a filter after the Router filter should fail")
 }
 
+func TestFilterExternalAuthzRequestBody(t *testing.T) {
+	var authz envoy_config_filter_http_ext_authz_v3.ExtAuthz
+
+	require.NoError(t, ptypes.UnmarshalAny(FilterExternalAuthz("test", false, timeout.Setting{}, 0).GetTypedConfig(), &authz))
+	assert.Nil(t, authz.WithRequestBody)
+
+	require.NoError(t, ptypes.UnmarshalAny(FilterExternalAuthz("test", false, timeout.Setting{}, 8192).GetTypedConfig(), &authz))
+	protobuf.ExpectEqual(t, &envoy_config_filter_http_ext_authz_v3.BufferSettings{
+		MaxRequestBytes:     8192,
+		AllowPartialMessage: true,
+	}, authz.WithRequestBody)
+}
+
 func TestAddFilter(t *testing.T) {
 
 	tests := map[string]struct {
@@ -1282,7 +1436,7 @@ func TestAddFilter(t *testing.T) {
 		},
 		"Add to the default filters": {
 			builder: HTTPConnectionManagerBuilder().DefaultFilters(),
-			add:     FilterExternalAuthz("test", false, timeout.Setting{}),
+			add:     FilterExternalAuthz("test", false, timeout.Setting{}, 0),
 			want: []*http.HttpFilter{
 				{
 					Name: "compressor",
@@ -1323,7 +1477,7 @@ func TestAddFilter(t *testing.T) {
 						),
 					},
 				},
-				FilterExternalAuthz("test", false, timeout.Setting{}),
+				FilterExternalAuthz("test", false, timeout.Setting{}, 0),
 				{
 					Name: "router",
 					ConfigType: &http.HttpFilter_TypedConfig{
@@ -21,8 +21,11 @@ import (
 
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_config_filter_http_buffer_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
 	envoy_config_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoy_internal_redirect_allow_listed_routes_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/internal_redirect/allow_listed_routes/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/ptypes/any"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/projectcontour/contour/internal/dag"
@@ -56,6 +59,31 @@ func RouteAuthzContext(settings map[string]string) *any.Any {
 	)
 }
 
+// RouteBufferDisabled returns a per-route config to disable buffering.
+func RouteBufferDisabled() *any.Any {
+	return protobuf.MustMarshalAny(
+		&envoy_config_filter_http_buffer_v3.BufferPerRoute{
+			Override: &envoy_config_filter_http_buffer_v3.BufferPerRoute_Disabled{
+				Disabled: true,
+			},
+		},
+	)
+}
+
+// RouteBuffer returns a per-route config to buffer request
+// bodies up to the limit of the given buffer policy.
+func RouteBuffer(bp *dag.BufferPolicy) *any.Any {
+	return protobuf.MustMarshalAny(
+		&envoy_config_filter_http_buffer_v3.BufferPerRoute{
+			Override: &envoy_config_filter_http_buffer_v3.BufferPerRoute_Buffer{
+				Buffer: &envoy_config_filter_http_buffer_v3.Buffer{
+					MaxRequestBytes: protobuf.UInt32(bp.MaxRequestBytes),
+				},
+			},
+		},
+	)
+}
+
 // RouteMatch creates a *envoy_route_v3.RouteMatch for the supplied *dag.Route.
 func RouteMatch(route *dag.Route) *envoy_route_v3.RouteMatch {
 	switch c := route.PathMatchCondition.(type) {
@@ -85,12 +113,19 @@ func RouteMatch(route *dag.Route) *envoy_route_v3.RouteMatch {
 // weighted cluster.
 func RouteRoute(r *dag.Route) *envoy_route_v3.Route_Route {
 	ra := envoy_route_v3.RouteAction{
-		RetryPolicy:           retryPolicy(r),
-		Timeout:               envoy.Timeout(r.TimeoutPolicy.ResponseTimeout),
-		IdleTimeout:           envoy.Timeout(r.TimeoutPolicy.IdleTimeout),
-		PrefixRewrite:         r.PrefixRewrite,
-		HashPolicy:            hashPolicy(r.RequestHashPolicies),
-		RequestMirrorPolicies: mirrorPolicy(r),
+		RetryPolicy:            retryPolicy(r),
+		Timeout:                envoy.Timeout(r.TimeoutPolicy.ResponseTimeout),
+		IdleTimeout:            envoy.Timeout(r.TimeoutPolicy.IdleTimeout),
+		PrefixRewrite:          r.PrefixRewrite,
+		HashPolicy:             hashPolicy(r.RequestHashPolicies),
+		RequestMirrorPolicies:  mirrorPolicy(r),
+		InternalRedirectPolicy: internalRedirectPolicy(r.InternalRedirectPolicy),
+	}
+
+	if !r.TimeoutPolicy.MaxStreamDuration.UseDefault() {
+		ra.MaxStreamDuration = &envoy_route_v3.RouteAction_MaxStreamDuration{
+			MaxStreamDuration: envoy.Timeout(r.TimeoutPolicy.MaxStreamDuration),
+		}
 	}
 
 	// Check for host header policy and set if found
@@ -142,11 +177,21 @@ func hashPolicy(requestHashPolicies []dag.RequestHashPolicy) []*envoy_route_v3.R
 			}
 		}
 		if rhp.CookieHashOptions != nil {
+			cookie := &envoy_route_v3.RouteAction_HashPolicy_Cookie{
+				Name: rhp.CookieHashOptions.CookieName,
+				Path: rhp.CookieHashOptions.Path,
+			}
+			if rhp.CookieHashOptions.TTL != nil {
+				cookie.Ttl = protobuf.Duration(*rhp.CookieHashOptions.TTL)
+			}
 			newHP.PolicySpecifier = &envoy_route_v3.RouteAction_HashPolicy_Cookie_{
-				Cookie: &envoy_route_v3.RouteAction_HashPolicy_Cookie{
-					Name: rhp.CookieHashOptions.CookieName,
-					Ttl:  protobuf.Duration(rhp.CookieHashOptions.TTL),
-					Path: rhp.CookieHashOptions.Path,
+				Cookie: cookie,
+			}
+		}
+		if rhp.HashSourceAddress {
+			newHP.PolicySpecifier = &envoy_route_v3.RouteAction_HashPolicy_ConnectionProperties_{
+				ConnectionProperties: &envoy_route_v3.RouteAction_HashPolicy_ConnectionProperties{
+					SourceIp: true,
 				},
 			}
 		}
@@ -156,13 +201,51 @@ func hashPolicy(requestHashPolicies []dag.RequestHashPolicy) []*envoy_route_v3.R
 }
 
 func mirrorPolicy(r *dag.Route) []*envoy_route_v3.RouteAction_RequestMirrorPolicy {
-	if r.MirrorPolicy == nil {
+	if len(r.MirrorPolicies) == 0 {
+		return nil
+	}
+
+	var policies []*envoy_route_v3.RouteAction_RequestMirrorPolicy
+	for _, mp := range r.MirrorPolicies {
+		policies = append(policies, &envoy_route_v3.RouteAction_RequestMirrorPolicy{
+			Cluster: envoy.Clustername(mp.Cluster),
+			RuntimeFraction: &envoy_core_v3.RuntimeFractionalPercent{
+				DefaultValue: &envoy_type_v3.FractionalPercent{
+					Numerator:   mp.PartsPerMillion,
+					Denominator: envoy_type_v3.FractionalPercent_MILLION,
+				},
+			},
+		})
+	}
+	return policies
+}
+
+// internalRedirectPolicy returns the Envoy policy for following
+// upstream redirects. Allowed hosts are matched by route name, so
+// the routes of the allowed virtual hosts must be named after them.
+func internalRedirectPolicy(irp *dag.InternalRedirectPolicy) *envoy_route_v3.InternalRedirectPolicy {
+	if irp == nil {
 		return nil
 	}
 
-	return []*envoy_route_v3.RouteAction_RequestMirrorPolicy{{
-		Cluster: envoy.Clustername(r.MirrorPolicy.Cluster),
-	}}
+	policy := &envoy_route_v3.InternalRedirectPolicy{
+		MaxInternalRedirects:     protobuf.UInt32OrNil(irp.MaxInternalRedirects),
+		RedirectResponseCodes:    irp.RedirectResponseCodes,
+		AllowCrossSchemeRedirect: irp.AllowCrossSchemeRedirect,
+	}
+
+	if len(irp.AllowedHosts) > 0 {
+		policy.Predicates = []*envoy_core_v3.TypedExtensionConfig{{
+			Name: "envoy.internal_redirect_predicates.allow_listed_routes",
+			TypedConfig: protobuf.MustMarshalAny(
+				&envoy_internal_redirect_allow_listed_routes_v3.AllowListedRoutesConfig{
+					AllowedRouteNames: irp.AllowedHosts,
+				},
+			),
+		}}
+	}
+
+	return policy
 }
 
 func retryPolicy(r *dag.Route) *envoy_route_v3.RetryPolicy {
@@ -196,6 +279,13 @@ func UpgradeHTTPS() *envoy_route_v3.Route_Redirect {
 	}
 }
 
+// AltSvcHTTP3 returns the value of the alt-svc header advertising
+// that the origin is served over HTTP/3 on the given UDP port, for
+// the next 24 hours.
+func AltSvcHTTP3(port int) string {
+	return fmt.Sprintf(`h3=":%d"; ma=86400`, port)
+}
+
 // HeaderValueList creates a list of Envoy HeaderValueOptions from the provided map.
 func HeaderValueList(hvm map[string]string, app bool) []*envoy_core_v3.HeaderValueOption {
 	var hvs []*envoy_core_v3.HeaderValueOption
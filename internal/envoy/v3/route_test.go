@@ -20,6 +20,7 @@ import (
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/fixture"
@@ -384,13 +385,31 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"max stream duration 5m": {
+			route: &dag.Route{
+				TimeoutPolicy: dag.TimeoutPolicy{
+					MaxStreamDuration: timeout.DurationSetting(5 * time.Minute),
+				},
+				Clusters: []*dag.Cluster{c1},
+			},
+			want: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					MaxStreamDuration: &envoy_route_v3.RouteAction_MaxStreamDuration{
+						MaxStreamDuration: protobuf.Duration(300 * time.Second),
+					},
+				},
+			},
+		},
 		"single service w/ a cookie hash policy (session affinity)": {
 			route: &dag.Route{
 				Clusters: []*dag.Cluster{c2},
 				RequestHashPolicies: []dag.RequestHashPolicy{
 					{CookieHashOptions: &dag.CookieHashOptions{
 						CookieName: "X-Contour-Session-Affinity",
-						TTL:        time.Duration(0),
+						TTL:        new(time.Duration),
 						Path:       "/",
 					}},
 				},
@@ -412,13 +431,48 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"single service w/ cookie and source address hash policies": {
+			route: &dag.Route{
+				Clusters: []*dag.Cluster{c2},
+				RequestHashPolicies: []dag.RequestHashPolicy{
+					{
+						Terminal: true,
+						CookieHashOptions: &dag.CookieHashOptions{
+							CookieName: "JSESSIONID",
+						},
+					},
+					{HashSourceAddress: true},
+				},
+			},
+			want: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/e4f81994fe",
+					},
+					HashPolicy: []*envoy_route_v3.RouteAction_HashPolicy{{
+						Terminal: true,
+						PolicySpecifier: &envoy_route_v3.RouteAction_HashPolicy_Cookie_{
+							Cookie: &envoy_route_v3.RouteAction_HashPolicy_Cookie{
+								Name: "JSESSIONID",
+							},
+						},
+					}, {
+						PolicySpecifier: &envoy_route_v3.RouteAction_HashPolicy_ConnectionProperties_{
+							ConnectionProperties: &envoy_route_v3.RouteAction_HashPolicy_ConnectionProperties{
+								SourceIp: true,
+							},
+						},
+					}},
+				},
+			},
+		},
 		"multiple services w/ a cookie hash policy (session affinity)": {
 			route: &dag.Route{
 				Clusters: []*dag.Cluster{c2, c2},
 				RequestHashPolicies: []dag.RequestHashPolicy{
 					{CookieHashOptions: &dag.CookieHashOptions{
 						CookieName: "X-Contour-Session-Affinity",
-						TTL:        time.Duration(0),
+						TTL:        new(time.Duration),
 						Path:       "/",
 					}},
 				},
@@ -520,7 +574,7 @@ func TestRouteRoute(t *testing.T) {
 					},
 					Weight: 90,
 				}},
-				MirrorPolicy: &dag.MirrorPolicy{
+				MirrorPolicies: []*dag.MirrorPolicy{{
 					Cluster: &dag.Cluster{
 						Upstream: &dag.Service{
 							Weighted: dag.WeightedService{
@@ -531,7 +585,8 @@ func TestRouteRoute(t *testing.T) {
 							},
 						},
 					},
-				},
+					PartsPerMillion: dag.MirrorAll,
+				}},
 			},
 			want: &envoy_route_v3.Route_Route{
 				Route: &envoy_route_v3.RouteAction{
@@ -540,6 +595,75 @@ func TestRouteRoute(t *testing.T) {
 					},
 					RequestMirrorPolicies: []*envoy_route_v3.RouteAction_RequestMirrorPolicy{{
 						Cluster: "default/kuard/8080/da39a3ee5e",
+						RuntimeFraction: &envoy_core_v3.RuntimeFractionalPercent{
+							DefaultValue: &envoy_type_v3.FractionalPercent{
+								Numerator:   1000000,
+								Denominator: envoy_type_v3.FractionalPercent_MILLION,
+							},
+						},
+					}},
+				},
+			},
+		},
+		"multiple mirrors": {
+			route: &dag.Route{
+				Clusters: []*dag.Cluster{{
+					Upstream: &dag.Service{
+						Weighted: dag.WeightedService{
+							Weight:           1,
+							ServiceName:      s1.Name,
+							ServiceNamespace: s1.Namespace,
+							ServicePort:      s1.Spec.Ports[0],
+						},
+					},
+				}},
+				MirrorPolicies: []*dag.MirrorPolicy{{
+					Cluster: &dag.Cluster{
+						Upstream: &dag.Service{
+							Weighted: dag.WeightedService{
+								Weight:           1,
+								ServiceName:      "mirror1",
+								ServiceNamespace: s1.Namespace,
+								ServicePort:      s1.Spec.Ports[0],
+							},
+						},
+					},
+					PartsPerMillion: 125000,
+				}, {
+					Cluster: &dag.Cluster{
+						Upstream: &dag.Service{
+							Weighted: dag.WeightedService{
+								Weight:           1,
+								ServiceName:      "mirror2",
+								ServiceNamespace: s1.Namespace,
+								ServicePort:      s1.Spec.Ports[0],
+							},
+						},
+					},
+					PartsPerMillion: 5,
+				}},
+			},
+			want: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					RequestMirrorPolicies: []*envoy_route_v3.RouteAction_RequestMirrorPolicy{{
+						Cluster: "default/mirror1/8080/da39a3ee5e",
+						RuntimeFraction: &envoy_core_v3.RuntimeFractionalPercent{
+							DefaultValue: &envoy_type_v3.FractionalPercent{
+								Numerator:   125000,
+								Denominator: envoy_type_v3.FractionalPercent_MILLION,
+							},
+						},
+					}, {
+						Cluster: "default/mirror2/8080/da39a3ee5e",
+						RuntimeFraction: &envoy_core_v3.RuntimeFractionalPercent{
+							DefaultValue: &envoy_type_v3.FractionalPercent{
+								Numerator:   5,
+								Denominator: envoy_type_v3.FractionalPercent_MILLION,
+							},
+						},
 					}},
 				},
 			},
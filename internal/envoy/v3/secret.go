@@ -22,7 +22,7 @@ import (
 
 // Secret creates new envoy_tls_v3.Secret from secret.
 func Secret(s *dag.Secret) *envoy_tls_v3.Secret {
-	return &envoy_tls_v3.Secret{
+	secret := &envoy_tls_v3.Secret{
 		Name: envoy.Secretname(s),
 		Type: &envoy_tls_v3.Secret_TlsCertificate{
 			TlsCertificate: &envoy_tls_v3.TlsCertificate{
@@ -39,4 +39,25 @@ func Secret(s *dag.Secret) *envoy_tls_v3.Secret {
 			},
 		},
 	}
+
+	if staple := s.OCSPStaple(); len(staple) > 0 {
+		StapleOCSPResponse(secret, staple)
+	}
+
+	return secret
+}
+
+// StapleOCSPResponse attaches the DER encoded OCSP response to the
+// TLS certificate of the given secret.
+func StapleOCSPResponse(secret *envoy_tls_v3.Secret, staple []byte) {
+	cert := secret.GetTlsCertificate()
+	if cert == nil {
+		return
+	}
+
+	cert.OcspStaple = &envoy_core_v3.DataSource{
+		Specifier: &envoy_core_v3.DataSource_InlineBytes{
+			InlineBytes: staple,
+		},
+	}
 }
@@ -27,3 +27,17 @@ func ParseTLSVersion(version string) envoy_tls_v3.TlsParameters_TlsProtocol {
 		return envoy_tls_v3.TlsParameters_TLS_AUTO
 	}
 }
+
+// ParseOCSPStaplePolicy converts an HTTPProxy OCSP staple policy name
+// into the corresponding Envoy enum. Unknown names map to the Envoy
+// default of lenient stapling.
+func ParseOCSPStaplePolicy(policy string) envoy_tls_v3.DownstreamTlsContext_OcspStaplePolicy {
+	switch policy {
+	case "StrictStapling":
+		return envoy_tls_v3.DownstreamTlsContext_STRICT_STAPLING
+	case "MustStaple":
+		return envoy_tls_v3.DownstreamTlsContext_MUST_STAPLE
+	default:
+		return envoy_tls_v3.DownstreamTlsContext_LENIENT_STAPLING
+	}
+}
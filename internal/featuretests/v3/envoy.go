@@ -28,6 +28,7 @@ import (
 	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	envoy_tcp_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
@@ -195,9 +196,19 @@ func withIdleTimeout(route *envoy_route_v3.Route_Route, timeout time.Duration) *
 }
 
 func withMirrorPolicy(route *envoy_route_v3.Route_Route, mirror string) *envoy_route_v3.Route_Route {
-	route.Route.RequestMirrorPolicies = []*envoy_route_v3.RouteAction_RequestMirrorPolicy{{
+	return withMirrorPolicyFraction(route, mirror, dag.MirrorAll)
+}
+
+func withMirrorPolicyFraction(route *envoy_route_v3.Route_Route, mirror string, partsPerMillion uint32) *envoy_route_v3.Route_Route {
+	route.Route.RequestMirrorPolicies = append(route.Route.RequestMirrorPolicies, &envoy_route_v3.RouteAction_RequestMirrorPolicy{
 		Cluster: mirror,
-	}}
+		RuntimeFraction: &envoy_core_v3.RuntimeFractionalPercent{
+			DefaultValue: &envoy_type_v3.FractionalPercent{
+				Numerator:   partsPerMillion,
+				Denominator: envoy_type_v3.FractionalPercent_MILLION,
+			},
+		},
+	})
 	return route
 }
 
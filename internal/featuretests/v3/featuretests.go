@@ -138,7 +138,7 @@ func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Cont
 	require.NoError(t, err)
 
 	srv := xds.NewServer(registry)
-	contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, xdscache.ResourcesOf(resources)...), srv)
+	contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, nil, nil, xdscache.ResourcesOf(resources)...), srv)
 
 	var g workgroup.Group
 
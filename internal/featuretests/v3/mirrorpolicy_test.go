@@ -84,3 +84,75 @@ func TestMirrorPolicy(t *testing.T) {
 		TypeUrl: clusterType,
 	})
 }
+
+func TestMirrorPolicyMultipleMirrors(t *testing.T) {
+	rh, c, done := setup(t, func(reh *contour.EventHandler) {})
+	defer done()
+
+	svc1 := fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+	svc2 := fixture.NewService("mirror").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+	svc3 := fixture.NewService("candidate").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+	rh.OnAdd(svc1)
+	rh.OnAdd(svc2)
+	rh.OnAdd(svc3)
+
+	p1 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple",
+			Namespace: svc1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com"},
+			Routes: []contour_api_v1.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/")),
+				Services: []contour_api_v1.Service{{
+					Name:      svc1.Name,
+					Namespace: svc1.Namespace,
+					Port:      8080,
+				}, {
+					Name:             svc2.Name,
+					Namespace:        svc2.Namespace,
+					Port:             8080,
+					Mirror:           true,
+					MirrorPercentage: "10",
+				}, {
+					Name:             svc3.Name,
+					Namespace:        svc3.Namespace,
+					Port:             8080,
+					Mirror:           true,
+					MirrorPercentage: "0.5",
+				}},
+			}},
+		},
+	}
+	rh.OnAdd(p1)
+
+	c.Request(routeType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		Resources: resources(t,
+			envoy_v3.RouteConfiguration("ingress_http",
+				envoy_v3.VirtualHost(p1.Spec.VirtualHost.Fqdn,
+					&envoy_route_v3.Route{
+						Match: routePrefix("/"),
+						Action: withMirrorPolicyFraction(
+							withMirrorPolicyFraction(routeCluster("default/kuard/8080/da39a3ee5e"), "default/mirror/8080/da39a3ee5e", 100000),
+							"default/candidate/8080/da39a3ee5e", 5000),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// assert that both mirror services have clusters in CDS.
+	c.Request(clusterType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		Resources: resources(t,
+			cluster("default/candidate/8080/da39a3ee5e", "default/candidate", "default_candidate_8080"),
+			cluster("default/kuard/8080/da39a3ee5e", "default/kuard", "default_kuard_8080"),
+			cluster("default/mirror/8080/da39a3ee5e", "default/mirror", "default_mirror_8080"),
+		),
+		TypeUrl: clusterType,
+	})
+}
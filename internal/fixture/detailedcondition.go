@@ -36,6 +36,13 @@ func (dcb *DetailedConditionBuilder) WithGeneration(gen int64) *DetailedConditio
 	return dcb
 }
 
+// AddWarning adds a warning to the condition, which can then
+// be completed by Valid.
+func (dcb *DetailedConditionBuilder) AddWarning(warnType, reason, message string) *DetailedConditionBuilder {
+	(*v1.DetailedCondition)(dcb).AddWarning(warnType, reason, message)
+	return dcb
+}
+
 func (dcb *DetailedConditionBuilder) Valid() v1.DetailedCondition {
 
 	dc := (*v1.DetailedCondition)(dcb)
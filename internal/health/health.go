@@ -15,9 +15,12 @@
 package health
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -35,3 +38,84 @@ func Handler(client *kubernetes.Clientset) http.Handler {
 		fmt.Fprintln(w, "OK")
 	})
 }
+
+// Check is a named check of whether part of Contour is working.
+type Check struct {
+	Name string
+
+	// Check returns an error if the check fails.
+	Check func() error
+}
+
+// CheckHandler returns a http Handler that runs the checks and reports
+// the result of each. It responds with 503 if any check fails.
+func CheckHandler(checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var out strings.Builder
+		failed := false
+		for _, c := range checks {
+			if err := c.Check(); err != nil {
+				failed = true
+				fmt.Fprintf(&out, "[-]%s failed: %v\n", c.Name, err)
+			} else {
+				fmt.Fprintf(&out, "[+]%s ok\n", c.Name)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, out.String())
+			fmt.Fprintln(w, "check failed")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, out.String())
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// PingCheck returns a Check that always passes.
+func PingCheck() Check {
+	return Check{
+		Name:  "ping",
+		Check: func() error { return nil },
+	}
+}
+
+// InformerCheck returns a Check that fails until the informer caches
+// of the clients have synced.
+func InformerCheck(name string, clients interface {
+	UnsyncedResources() []schema.GroupVersionResource
+}) Check {
+	return Check{
+		Name: name,
+		Check: func() error {
+			unsynced := clients.UnsyncedResources()
+			if len(unsynced) == 0 {
+				return nil
+			}
+
+			var names []string
+			for _, r := range unsynced {
+				names = append(names, r.GroupResource().String())
+			}
+			return fmt.Errorf("caches not synced: %s", strings.Join(names, ", "))
+		},
+	}
+}
+
+// FuncCheck returns a Check that fails with the given message while
+// ok returns false.
+func FuncCheck(name string, message string, ok func() bool) Check {
+	return Check{
+		Name: name,
+		Check: func() error {
+			if !ok() {
+				return errors.New(message)
+			}
+			return nil
+		},
+	}
+}
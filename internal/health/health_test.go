@@ -0,0 +1,68 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type unsyncedResources []schema.GroupVersionResource
+
+func (u unsyncedResources) UnsyncedResources() []schema.GroupVersionResource {
+	return u
+}
+
+func TestCheckHandler(t *testing.T) {
+	built := false
+	unsynced := unsyncedResources{
+		{Group: "projectcontour.io", Version: "v1", Resource: "httpproxies"},
+		{Version: "v1", Resource: "secrets"},
+	}
+
+	h := CheckHandler(
+		PingCheck(),
+		InformerCheck("informers", &unsynced),
+		FuncCheck("dag", "the DAG has not been built", func() bool { return built }),
+	)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec
+	}
+
+	rec := get()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, `[+]ping ok
+[-]informers failed: caches not synced: httpproxies.projectcontour.io, secrets
+[-]dag failed: the DAG has not been built
+check failed
+`, rec.Body.String())
+
+	unsynced = nil
+	built = true
+
+	rec = get()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `[+]ping ok
+[+]informers ok
+[+]dag ok
+ok
+`, rec.Body.String())
+}
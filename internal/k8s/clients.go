@@ -15,6 +15,8 @@ package k8s
 
 import (
 	"context"
+	"sort"
+	"sync"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -34,6 +36,11 @@ type Clients struct {
 	core    *kubernetes.Clientset
 	dynamic dynamic.Interface
 	cache   cache.Cache
+
+	mu sync.Mutex
+
+	// informers holds the informers created by InformerForResource.
+	informers map[schema.GroupVersionResource]Informer
 }
 
 // NewClients returns a new set of the various API clients required
@@ -92,7 +99,38 @@ func (c *Clients) InformerForResource(gvr schema.GroupVersionResource) (Informer
 		return nil, err
 	}
 
-	return c.cache.GetInformerForKind(context.Background(), gvk)
+	inf, err := c.cache.GetInformerForKind(context.Background(), gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.informers == nil {
+		c.informers = map[schema.GroupVersionResource]Informer{}
+	}
+	c.informers[gvr] = inf
+
+	return inf, nil
+}
+
+// UnsyncedResources returns the resources whose informer caches have
+// not synced yet, sorted by name.
+func (c *Clients) UnsyncedResources() []schema.GroupVersionResource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var unsynced []schema.GroupVersionResource
+	for gvr, inf := range c.informers {
+		if !inf.HasSynced() {
+			unsynced = append(unsynced, gvr)
+		}
+	}
+
+	sort.Slice(unsynced, func(i, j int) bool {
+		return unsynced[i].String() < unsynced[j].String()
+	})
+	return unsynced
 }
 
 func (c *Clients) StartInformers(stopChan <-chan struct{}) error {
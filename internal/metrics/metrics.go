@@ -37,8 +37,22 @@ type Metrics struct {
 	CacheHandlerOnUpdateSummary prometheus.Summary
 	EventHandlerOperations      *prometheus.CounterVec
 
+	certificateExpiryGauge *prometheus.GaugeVec
+
+	xdsNackCounter   *prometheus.CounterVec
+	xdsRejectedGauge *prometheus.GaugeVec
+
+	xdsConnectedGauge *prometheus.GaugeVec
+	xdsOutOfSyncGauge *prometheus.GaugeVec
+
+	propagationRebuildHistogram *prometheus.HistogramVec
+	propagationPushHistogram    *prometheus.HistogramVec
+	propagationAckHistogram     *prometheus.HistogramVec
+
 	// Keep a local cache of metrics for comparison on updates
-	proxyMetricCache *RouteMetric
+	proxyMetricCache       *RouteMetric
+	certificateMetricCache map[CertificateMeta]float64
+	xdsMetricCache         map[XDSMeta]bool
 }
 
 // RouteMetric stores various metrics for HTTPProxy objects
@@ -55,6 +69,16 @@ type Meta struct {
 	VHost, Namespace string
 }
 
+// CertificateMeta holds the secret and vhost of a certificate metric.
+type CertificateMeta struct {
+	Namespace, Name, FQDN string
+}
+
+// XDSMeta holds the Envoy node and resource type of an xDS metric.
+type XDSMeta struct {
+	NodeID, TypeURL string
+}
+
 const (
 	BuildInfoGauge = "contour_build_info"
 
@@ -67,8 +91,24 @@ const (
 	DAGRebuildGauge             = "contour_dagrebuild_timestamp"
 	cacheHandlerOnUpdateSummary = "contour_cachehandler_onupdate_duration_seconds"
 	eventHandlerOperations      = "contour_eventhandler_operation_total"
+
+	CertificateExpiryGauge = "contour_certificate_expiry_days"
+
+	XDSNackCounter   = "contour_xds_nack_total"
+	XDSRejectedGauge = "contour_xds_rejected"
+
+	XDSConnectedGauge = "contour_xds_connected_envoys"
+	XDSOutOfSyncGauge = "contour_xds_out_of_sync_envoys"
+
+	PropagationRebuildHistogram = "contour_propagation_rebuild_seconds"
+	PropagationPushHistogram    = "contour_propagation_push_seconds"
+	PropagationAckHistogram     = "contour_propagation_ack_seconds"
 )
 
+// propagationBuckets are the histogram buckets of the propagation
+// latency metrics, in seconds.
+var propagationBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
 // NewMetrics creates a new set of metrics and registers them with
 // the supplied registry.
 //
@@ -139,6 +179,65 @@ func NewMetrics(registry *prometheus.Registry) *Metrics {
 			},
 			[]string{"op", "kind"},
 		),
+		certificateExpiryGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: CertificateExpiryGauge,
+				Help: "Number of days until a TLS certificate referenced by a virtual host expires. Negative if the certificate has expired.",
+			},
+			[]string{"namespace", "name", "fqdn"},
+		),
+		xdsNackCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: XDSNackCounter,
+				Help: "Total number of xDS responses rejected by an Envoy node, by node ID and resource type URL.",
+			},
+			[]string{"node_id", "type_url"},
+		),
+		xdsRejectedGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: XDSRejectedGauge,
+				Help: "Whether an Envoy node rejected the last xDS response of a resource type it was sent. 1 if rejected, 0 if accepted.",
+			},
+			[]string{"node_id", "type_url"},
+		),
+		xdsConnectedGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: XDSConnectedGauge,
+				Help: "Number of Envoy nodes with an open xDS stream.",
+			},
+			[]string{},
+		),
+		xdsOutOfSyncGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: XDSOutOfSyncGauge,
+				Help: "Number of connected Envoy nodes that have not accepted the last xDS response of a resource type they were sent.",
+			},
+			[]string{},
+		),
+		propagationRebuildHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    PropagationRebuildHistogram,
+				Help:    "Time from Contour receiving a Kubernetes object change to rebuilding the DAG that includes it, by object kind.",
+				Buckets: propagationBuckets,
+			},
+			[]string{"kind"},
+		),
+		propagationPushHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    PropagationPushHistogram,
+				Help:    "Time from a DAG rebuild to sending the resulting xDS response to an Envoy node, by the kind of the objects that changed.",
+				Buckets: propagationBuckets,
+			},
+			[]string{"kind"},
+		),
+		propagationAckHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    PropagationAckHistogram,
+				Help:    "Time from sending an xDS response to an Envoy node to the node accepting it, by the kind of the objects that changed.",
+				Buckets: propagationBuckets,
+			},
+			[]string{"kind"},
+		),
 	}
 	m.buildInfoGauge.WithLabelValues(build.Branch, build.Sha, build.Version).Set(1)
 	m.register(registry)
@@ -157,6 +256,14 @@ func (m *Metrics) register(registry *prometheus.Registry) {
 		m.dagRebuildGauge,
 		m.CacheHandlerOnUpdateSummary,
 		m.EventHandlerOperations,
+		m.certificateExpiryGauge,
+		m.xdsNackCounter,
+		m.xdsRejectedGauge,
+		m.xdsConnectedGauge,
+		m.xdsOutOfSyncGauge,
+		m.propagationRebuildHistogram,
+		m.propagationPushHistogram,
+		m.propagationAckHistogram,
 	)
 }
 
@@ -179,6 +286,13 @@ func (m *Metrics) Zero() {
 
 	m.SetDAGLastRebuilt(time.Now())
 	m.SetHTTPProxyMetric(zeroes)
+	m.SetCertificateExpiryMetric(map[CertificateMeta]float64{{}: 0})
+	m.SetXDSRejectedMetric(map[XDSMeta]bool{{}: false})
+	m.IncXDSNack(XDSMeta{})
+	m.SetXDSNodeMetric(0, 0)
+	m.ObserveRebuildLatency("", 0)
+	m.ObservePushLatency("", 0)
+	m.ObserveAckLatency("", 0)
 
 	m.EventHandlerOperations.WithLabelValues("add", "Secret").Inc()
 
@@ -240,6 +354,75 @@ func (m *Metrics) SetHTTPProxyMetric(metrics RouteMetric) {
 	}
 }
 
+// SetCertificateExpiryMetric sets the number of days until expiry
+// for a set of certificates. Certificates that are absent from the
+// set are removed from the metric.
+func (m *Metrics) SetCertificateExpiryMetric(expiry map[CertificateMeta]float64) {
+	for meta, days := range expiry {
+		m.certificateExpiryGauge.WithLabelValues(meta.Namespace, meta.Name, meta.FQDN).Set(days)
+		delete(m.certificateMetricCache, meta)
+	}
+
+	for meta := range m.certificateMetricCache {
+		m.certificateExpiryGauge.DeleteLabelValues(meta.Namespace, meta.Name, meta.FQDN)
+	}
+
+	m.certificateMetricCache = expiry
+}
+
+// IncXDSNack counts an xDS response that was rejected by an Envoy node.
+func (m *Metrics) IncXDSNack(meta XDSMeta) {
+	m.xdsNackCounter.WithLabelValues(meta.NodeID, meta.TypeURL).Inc()
+}
+
+// SetXDSRejectedMetric records whether each connected Envoy node
+// rejected the last xDS response of each resource type. Nodes that
+// are absent from the set are removed from the xDS metrics.
+func (m *Metrics) SetXDSRejectedMetric(rejected map[XDSMeta]bool) {
+	for meta, r := range rejected {
+		value := 0.0
+		if r {
+			value = 1
+		}
+		m.xdsRejectedGauge.WithLabelValues(meta.NodeID, meta.TypeURL).Set(value)
+		delete(m.xdsMetricCache, meta)
+	}
+
+	for meta := range m.xdsMetricCache {
+		m.xdsRejectedGauge.DeleteLabelValues(meta.NodeID, meta.TypeURL)
+		m.xdsNackCounter.DeleteLabelValues(meta.NodeID, meta.TypeURL)
+	}
+
+	m.xdsMetricCache = rejected
+}
+
+// SetXDSNodeMetric records the number of connected Envoy nodes, and
+// how many of them are out of sync.
+func (m *Metrics) SetXDSNodeMetric(connected, outOfSync int) {
+	m.xdsConnectedGauge.WithLabelValues().Set(float64(connected))
+	m.xdsOutOfSyncGauge.WithLabelValues().Set(float64(outOfSync))
+}
+
+// ObserveRebuildLatency records the time from receiving a change
+// to an object of the given kind to rebuilding the DAG.
+func (m *Metrics) ObserveRebuildLatency(kind string, d time.Duration) {
+	m.propagationRebuildHistogram.WithLabelValues(kind).Observe(d.Seconds())
+}
+
+// ObservePushLatency records the time from a DAG rebuild that
+// included a change to an object of the given kind to sending the
+// resulting xDS response to an Envoy node.
+func (m *Metrics) ObservePushLatency(kind string, d time.Duration) {
+	m.propagationPushHistogram.WithLabelValues(kind).Observe(d.Seconds())
+}
+
+// ObserveAckLatency records the time from sending an xDS response
+// that included a change to an object of the given kind to the
+// Envoy node accepting it.
+func (m *Metrics) ObserveAckLatency(kind string, d time.Duration) {
+	m.propagationAckHistogram.WithLabelValues(kind).Observe(d.Seconds())
+}
+
 // Handler returns a http Handler for a metrics endpoint.
 func Handler(registry *prometheus.Registry) http.Handler {
 	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
@@ -690,3 +690,54 @@ func TestRemoveProxyMetric(t *testing.T) {
 		})
 	}
 }
+
+func TestSetCertificateExpiryMetric(t *testing.T) {
+	gather := func(t *testing.T, r *prometheus.Registry) map[CertificateMeta]float64 {
+		t.Helper()
+
+		gathering, err := r.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := map[CertificateMeta]float64{}
+		for _, mf := range gathering {
+			if mf.GetName() != CertificateExpiryGauge {
+				continue
+			}
+			for _, m := range mf.Metric {
+				var meta CertificateMeta
+				for _, l := range m.Label {
+					switch l.GetName() {
+					case "namespace":
+						meta.Namespace = l.GetValue()
+					case "name":
+						meta.Name = l.GetValue()
+					case "fqdn":
+						meta.FQDN = l.GetValue()
+					}
+				}
+				got[meta] = m.GetGauge().GetValue()
+			}
+		}
+		return got
+	}
+
+	r := prometheus.NewRegistry()
+	m := NewMetrics(r)
+
+	first := map[CertificateMeta]float64{
+		{Namespace: "default", Name: "cert", FQDN: "foo.com"}: 10,
+		{Namespace: "default", Name: "cert", FQDN: "bar.com"}: 10,
+		{Namespace: "default", Name: "old", FQDN: "baz.com"}:  -1.5,
+	}
+	m.SetCertificateExpiryMetric(first)
+	assert.Equal(t, first, gather(t, r))
+
+	// Certificates that are no longer referenced are removed.
+	second := map[CertificateMeta]float64{
+		{Namespace: "default", Name: "cert", FQDN: "foo.com"}: 9,
+	}
+	m.SetCertificateExpiryMetric(second)
+	assert.Equal(t, second, gather(t, r))
+}
@@ -0,0 +1,141 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ocsp fetches and refreshes OCSP responses so that they
+// can be stapled to the TLS certificates served by Envoy.
+package ocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// maxResponseBytes bounds the size of an OCSP response we are
+// willing to read from a responder.
+const maxResponseBytes = 1 << 20
+
+// Responder obtains a DER encoded OCSP response for a certificate.
+type Responder interface {
+	Fetch(ctx context.Context, cert *x509.Certificate, issuer *x509.Certificate) ([]byte, error)
+}
+
+// HTTPResponder fetches OCSP responses from an RFC 6960 responder
+// using HTTP POST.
+type HTTPResponder struct {
+	// URL overrides the responder named in the certificate's
+	// Authority Information Access extension.
+	URL string
+
+	// Client is the HTTP client used to contact the responder.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Fetch implements Responder.
+func (h *HTTPResponder) Fetch(ctx context.Context, cert *x509.Certificate, issuer *x509.Certificate) ([]byte, error) {
+	url := h.URL
+	if url == "" {
+		if len(cert.OCSPServer) == 0 {
+			return nil, errors.New("certificate does not name an OCSP responder")
+		}
+		url = cert.OCSPServer[0]
+	}
+
+	body, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder %q returned status %d", url, resp.StatusCode)
+	}
+
+	der, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	return der, nil
+}
+
+// LocalResponder signs OCSP responses itself using the issuer's key.
+// It stands in for a real responder in tests and local development.
+type LocalResponder struct {
+	// Issuer is the certificate of the CA that issued the
+	// certificates being checked.
+	Issuer *x509.Certificate
+
+	// Key is the private key of the issuer.
+	Key crypto.Signer
+
+	// Status is the certificate status to report. The default
+	// is ocsp.Good.
+	Status int
+
+	// Validity is how long each response is valid for. If zero,
+	// responses are valid for one hour.
+	Validity time.Duration
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Fetch implements Responder.
+func (l *LocalResponder) Fetch(_ context.Context, cert *x509.Certificate, _ *x509.Certificate) ([]byte, error) {
+	now := time.Now
+	if l.Now != nil {
+		now = l.Now
+	}
+
+	validity := l.Validity
+	if validity == 0 {
+		validity = time.Hour
+	}
+
+	thisUpdate := now().UTC().Truncate(time.Minute)
+
+	return ocsp.CreateResponse(l.Issuer, l.Issuer, ocsp.Response{
+		Status:       l.Status,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   thisUpdate.Add(validity),
+	}, l.Key)
+}
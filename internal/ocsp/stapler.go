@@ -0,0 +1,320 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocsp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ocsp"
+)
+
+// DefaultRefreshInterval is the default interval at which the
+// Stapler checks whether its OCSP responses need refreshing.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// Stapler keeps a fresh OCSP response for each TLS certificate
+// served by Envoy. Responses are fetched in the background by
+// Start, and refreshed once half of their validity period has
+// elapsed. When a response changes, the Observer is notified so
+// that the new response can be sent to Envoy.
+type Stapler struct {
+	// Responder fetches OCSP responses.
+	Responder Responder
+
+	// RefreshInterval is how often responses are checked
+	// for expiry. If zero, DefaultRefreshInterval is used.
+	RefreshInterval time.Duration
+
+	// Observer is notified when a response has been updated.
+	Observer contour.Observer
+
+	logrus.FieldLogger
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	pending chan struct{}
+
+	// now returns the current time. If nil, time.Now is used.
+	now func() time.Time
+}
+
+// entry holds the OCSP state of a single certificate.
+type entry struct {
+	cert   *x509.Certificate
+	issuer *x509.Certificate
+
+	response   []byte
+	thisUpdate time.Time
+	nextUpdate time.Time
+}
+
+// valid returns true if the entry has a response that has not expired.
+func (e *entry) valid(now time.Time) bool {
+	return len(e.response) > 0 && now.Before(e.nextUpdate)
+}
+
+// stale returns true if the entry has no response, or if half of
+// the response's validity period has elapsed.
+func (e *entry) stale(now time.Time) bool {
+	if len(e.response) == 0 {
+		return true
+	}
+
+	return !now.Before(e.thisUpdate.Add(e.nextUpdate.Sub(e.thisUpdate) / 2))
+}
+
+// Staple returns the current OCSP response for each secret that has
+// a certificate chain including its issuer. Certificates that are
+// no longer requested are forgotten and are not refreshed anymore.
+func (s *Stapler) Staple(secrets map[string]*dag.Secret) map[string][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock()
+	staples := map[string][]byte{}
+	entries := map[string]*entry{}
+	wanted := false
+
+	for name, secret := range secrets {
+		// Responses supplied in the Secret take precedence.
+		if len(secret.OCSPStaple()) > 0 {
+			continue
+		}
+
+		key := fingerprint(secret.Cert())
+		e, ok := entries[key]
+		if !ok {
+			e, ok = s.entries[key]
+		}
+		if !ok {
+			cert, issuer, err := parseChain(secret.Cert())
+			if err != nil {
+				s.log().WithError(err).WithField("secret", name).Debug("unable to staple OCSP response")
+				continue
+			}
+			e = &entry{cert: cert, issuer: issuer}
+			wanted = true
+		}
+
+		entries[key] = e
+		if e.valid(now) {
+			staples[name] = e.response
+		}
+	}
+
+	s.entries = entries
+
+	if wanted {
+		s.kick()
+	}
+
+	return staples
+}
+
+// Stapled returns true if the Stapler has a current OCSP response
+// for the secret's certificate.
+func (s *Stapler) Stapled(secret *dag.Secret) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[fingerprint(secret.Cert())]
+	return ok && e.valid(s.clock())
+}
+
+// Start fulfills the g.Start contract. It fetches OCSP responses
+// until stop is closed.
+func (s *Stapler) Start(stop <-chan struct{}) error {
+	interval := s.RefreshInterval
+	if interval == 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	s.log().Info("started OCSP stapler")
+	defer s.log().Info("stopped OCSP stapler")
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		case <-s.wakeup():
+		}
+
+		if s.refresh(ctx) && s.Observer != nil {
+			s.Observer.Refresh()
+		}
+	}
+}
+
+// refresh fetches responses for all stale entries, returning
+// true if any response was updated or has expired.
+func (s *Stapler) refresh(ctx context.Context) bool {
+	updated := false
+
+	s.mu.Lock()
+	now := s.clock()
+	var stale []*entry
+	for _, e := range s.entries {
+		if len(e.response) > 0 && !e.valid(now) {
+			// Forget expired responses so that the
+			// Observer hears about them once.
+			e.response = nil
+			updated = true
+		}
+		if e.stale(now) {
+			stale = append(stale, e)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, e := range stale {
+		der, err := s.Responder.Fetch(ctx, e.cert, e.issuer)
+		if err == nil {
+			err = s.update(e, der)
+		}
+		if err != nil {
+			s.log().WithError(err).
+				WithField("subject", e.cert.Subject.String()).
+				WithField("serial", e.cert.SerialNumber.String()).
+				Warn("failed to fetch OCSP response")
+			continue
+		}
+		updated = true
+	}
+
+	return updated
+}
+
+// update validates der as an OCSP response for the entry's
+// certificate and stores it.
+func (s *Stapler) update(e *entry, der []byte) error {
+	resp, err := ocsp.ParseResponseForCert(der, e.cert, e.issuer)
+	if err != nil {
+		return fmt.Errorf("invalid OCSP response: %w", err)
+	}
+
+	if resp.Status != ocsp.Good {
+		return fmt.Errorf("OCSP responder reported certificate status %d", resp.Status)
+	}
+
+	if resp.NextUpdate.IsZero() {
+		return errors.New("OCSP response has no next update time")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.response = der
+	e.thisUpdate = resp.ThisUpdate
+	e.nextUpdate = resp.NextUpdate
+	return nil
+}
+
+// kick schedules an immediate refresh. It must be called with s.mu held.
+func (s *Stapler) kick() {
+	if s.pending == nil {
+		s.pending = make(chan struct{}, 1)
+	}
+
+	select {
+	case s.pending <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Stapler) wakeup() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == nil {
+		s.pending = make(chan struct{}, 1)
+	}
+	return s.pending
+}
+
+func (s *Stapler) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func (s *Stapler) log() logrus.FieldLogger {
+	if s.FieldLogger == nil {
+		return logrus.StandardLogger()
+	}
+	return s.FieldLogger
+}
+
+// fingerprint returns a stable identifier for a PEM certificate chain.
+func fingerprint(chain []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(chain))
+}
+
+// parseChain returns the leaf certificate of a PEM certificate
+// chain along with the certificate that issued it.
+func parseChain(chain []byte) (*x509.Certificate, *x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+		block, chain = pem.Decode(chain)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, errors.New("no certificates found")
+	}
+
+	leaf := certs[0]
+	for _, c := range certs[1:] {
+		if leaf.CheckSignatureFrom(c) == nil {
+			return leaf, c, nil
+		}
+	}
+
+	return nil, nil, errors.New("certificate chain does not include the issuer")
+}
@@ -0,0 +1,243 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocsp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type testPKI struct {
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+	leaf  *x509.Certificate
+
+	chain []byte
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		DNSNames:     []string{"www.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(leafDER)
+	require.NoError(t, err)
+
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+	chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+
+	return &testPKI{
+		ca:    ca,
+		caKey: caKey,
+		leaf:  leaf,
+		chain: chain,
+	}
+}
+
+func (p *testPKI) secret(name string, data map[string][]byte) *dag.Secret {
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey: p.chain,
+		},
+	}
+
+	for k, v := range data {
+		s.Data[k] = v
+	}
+
+	return &dag.Secret{Object: s}
+}
+
+func TestStaplerFetchesAndRefreshes(t *testing.T) {
+	pki := newTestPKI(t)
+	now := time.Now()
+
+	stapler := &Stapler{
+		Responder: &LocalResponder{
+			Issuer:   pki.ca,
+			Key:      pki.caKey,
+			Validity: time.Hour,
+			Now:      func() time.Time { return now },
+		},
+		now: func() time.Time { return now },
+	}
+
+	secrets := map[string]*dag.Secret{
+		"default/secret/1234": pki.secret("secret", nil),
+	}
+
+	// Nothing has been fetched yet.
+	assert.Empty(t, stapler.Staple(secrets))
+	assert.False(t, stapler.Stapled(secrets["default/secret/1234"]))
+
+	// A refresh is pending for the new certificate.
+	select {
+	case <-stapler.wakeup():
+	default:
+		t.Fatal("expected a pending refresh")
+	}
+
+	assert.True(t, stapler.refresh(context.Background()))
+
+	staples := stapler.Staple(secrets)
+	require.Contains(t, staples, "default/secret/1234")
+
+	resp, err := ocsp.ParseResponseForCert(staples["default/secret/1234"], pki.leaf, pki.ca)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.Good, resp.Status)
+	assert.True(t, stapler.Stapled(secrets["default/secret/1234"]))
+
+	// The response is still fresh, so nothing is refetched.
+	assert.False(t, stapler.refresh(context.Background()))
+
+	// Once half the validity has elapsed, the response is refetched.
+	now = now.Add(31 * time.Minute)
+	assert.True(t, stapler.refresh(context.Background()))
+
+	// Expired responses are forgotten, and not stapled.
+	now = now.Add(2 * time.Hour)
+	stapler.Responder = &LocalResponder{Issuer: pki.ca, Key: pki.caKey, Status: ocsp.Revoked}
+	assert.True(t, stapler.refresh(context.Background()))
+	assert.False(t, stapler.Stapled(secrets["default/secret/1234"]))
+	assert.Empty(t, stapler.Staple(secrets))
+
+	// Once forgotten, a failed refresh changes nothing.
+	assert.False(t, stapler.refresh(context.Background()))
+}
+
+func TestStaplerSkipsSecrets(t *testing.T) {
+	pki := newTestPKI(t)
+
+	staple, err := (&LocalResponder{Issuer: pki.ca, Key: pki.caKey}).Fetch(context.Background(), pki.leaf, pki.ca)
+	require.NoError(t, err)
+
+	leafOnly := pki.secret("leaf", nil)
+	leafOnly.Object.Data[v1.TLSCertKey] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.leaf.Raw})
+
+	stapler := &Stapler{
+		Responder: &LocalResponder{Issuer: pki.ca, Key: pki.caKey},
+	}
+
+	stapler.Staple(map[string]*dag.Secret{
+		// The secret already carries a staple.
+		"default/stapled/1234": pki.secret("stapled", map[string][]byte{dag.OCSPStapleKey: staple}),
+		// The chain does not include the issuer.
+		"default/leaf/1234": leafOnly,
+	})
+
+	assert.Empty(t, stapler.entries)
+}
+
+func TestStaplerForgetsUnusedCertificates(t *testing.T) {
+	pki := newTestPKI(t)
+
+	stapler := &Stapler{
+		Responder: &LocalResponder{Issuer: pki.ca, Key: pki.caKey},
+	}
+
+	stapler.Staple(map[string]*dag.Secret{
+		"default/secret/1234": pki.secret("secret", nil),
+	})
+	assert.Len(t, stapler.entries, 1)
+
+	stapler.Staple(map[string]*dag.Secret{})
+	assert.Empty(t, stapler.entries)
+}
+
+func TestHTTPResponder(t *testing.T) {
+	pki := newTestPKI(t)
+	local := &LocalResponder{Issuer: pki.ca, Key: pki.caKey}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/ocsp-request", r.Header.Get("Content-Type"))
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+		assert.Equal(t, pki.leaf.SerialNumber, req.SerialNumber)
+
+		der, err := local.Fetch(r.Context(), pki.leaf, pki.ca)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(der)
+	}))
+	defer srv.Close()
+
+	responder := &HTTPResponder{URL: srv.URL}
+	der, err := responder.Fetch(context.Background(), pki.leaf, pki.ca)
+	require.NoError(t, err)
+
+	resp, err := ocsp.ParseResponseForCert(der, pki.leaf, pki.ca)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.Good, resp.Status)
+
+	// Without a URL override, the certificate must name a responder.
+	_, err = (&HTTPResponder{}).Fetch(context.Background(), pki.leaf, pki.ca)
+	assert.Error(t, err)
+}
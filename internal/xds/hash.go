@@ -19,6 +19,17 @@ import (
 
 const CONSTANT_HASH_VALUE = "contour"
 
+// EnvoyGroupMetadataKey is the node metadata key that holds the group
+// of an Envoy.
+const EnvoyGroupMetadataKey = "projectcontour.io/envoy-group"
+
+// GroupOf returns the group of the given Envoy node, which is set in
+// the node metadata by `contour bootstrap --envoy-group`. Envoys that
+// are not in a group return the empty string.
+func GroupOf(node *envoy_config_v3.Node) string {
+	return node.GetMetadata().GetFields()[EnvoyGroupMetadataKey].GetStringValue()
+}
+
 // ConstantHashV3 is a specialized node ID hasher used to allow
 // any instance of Envoy to connect to Contour regardless of the
 // service-node flag configured on Envoy.
@@ -31,3 +42,20 @@ func (c ConstantHashV3) ID(*envoy_config_v3.Node) string {
 func (c ConstantHashV3) String() string {
 	return CONSTANT_HASH_VALUE
 }
+
+// GroupHashV3 is a node ID hasher that hashes each instance of Envoy
+// to the snapshot of its group, regardless of the service-node flag
+// configured on Envoy.
+type GroupHashV3 struct{}
+
+func (g GroupHashV3) ID(node *envoy_config_v3.Node) string {
+	return g.Group(GroupOf(node))
+}
+
+// Group returns the node ID of the Envoys in the given group.
+func (g GroupHashV3) Group(group string) string {
+	if group == "" {
+		return CONSTANT_HASH_VALUE
+	}
+	return CONSTANT_HASH_VALUE + "/" + group
+}
@@ -0,0 +1,46 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	envoy_config_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupHashV3(t *testing.T) {
+	node := func(group string) *envoy_config_v3.Node {
+		return &envoy_config_v3.Node{
+			Id:      "envoy-1",
+			Cluster: "projectcontour",
+			Metadata: &_struct.Struct{
+				Fields: map[string]*_struct.Value{
+					EnvoyGroupMetadataKey: {
+						Kind: &_struct.Value_StringValue{StringValue: group},
+					},
+				},
+			},
+		}
+	}
+
+	var hash GroupHashV3
+
+	assert.Equal(t, "contour", hash.ID(nil))
+	assert.Equal(t, "contour", hash.ID(&envoy_config_v3.Node{Id: "envoy-1"}))
+	assert.Equal(t, "contour", hash.ID(node("")))
+	assert.Equal(t, "contour/internal", hash.ID(node("internal")))
+	assert.Equal(t, "internal", GroupOf(node("internal")))
+}
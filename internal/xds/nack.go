@@ -0,0 +1,58 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import "time"
+
+// Nack describes a DiscoveryResponse that an Envoy node rejected.
+type Nack struct {
+	// NodeID is the ID of the Envoy node that rejected the response.
+	NodeID string `json:"nodeID"`
+
+	// TypeURL is the type URL of the rejected response.
+	TypeURL string `json:"typeURL"`
+
+	// ResponseNonce is the nonce of the rejected response.
+	ResponseNonce string `json:"responseNonce"`
+
+	// VersionInfo is the version of the last response that the node
+	// accepted, which is the configuration it continues to use.
+	VersionInfo string `json:"versionInfo"`
+
+	// ResourceNames are the names of the resources in the rejected
+	// response.
+	ResourceNames []string `json:"resourceNames,omitempty"`
+
+	// Code is the gRPC status code reported by the node.
+	Code int32 `json:"code"`
+
+	// Message is the error message reported by the node.
+	Message string `json:"message"`
+
+	// Time is when the rejection was received.
+	Time time.Time `json:"time"`
+}
+
+// NackRecorder is notified whether Envoy nodes accepted (ACK) or
+// rejected (NACK) the DiscoveryResponses they were sent.
+type NackRecorder interface {
+	// Ack records that the node accepted the last response of typeURL.
+	Ack(nodeID, typeURL string)
+
+	// Nack records that a node rejected a response.
+	Nack(Nack)
+
+	// Disconnect records that the node closed its stream for typeURL.
+	Disconnect(nodeID, typeURL string)
+}
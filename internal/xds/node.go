@@ -0,0 +1,133 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"time"
+
+	envoy_config_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+)
+
+// NodeStatus describes an Envoy node that is connected to Contour,
+// and the configuration it has accepted.
+type NodeStatus struct {
+	// ID is the node ID, set with the Envoy --service-node flag.
+	ID string `json:"id"`
+
+	// Cluster is the node cluster, set with the Envoy
+	// --service-cluster flag.
+	Cluster string `json:"cluster,omitempty"`
+
+	// BuildVersion is the Envoy version of the node.
+	BuildVersion string `json:"buildVersion,omitempty"`
+
+	// Locality is where the node is running.
+	Locality *Locality `json:"locality,omitempty"`
+
+	// Group is the Envoy group of the node.
+	Group string `json:"group,omitempty"`
+
+	// Connected is when the node opened its oldest xDS stream.
+	Connected time.Time `json:"connected"`
+
+	// InSync is true if the node accepted the last response of
+	// every resource type it was sent.
+	InSync bool `json:"inSync"`
+
+	// Resources holds the status of each resource type the node
+	// was sent, ordered by type URL.
+	Resources []ResourceStatus `json:"resources"`
+}
+
+// Locality is the location of an Envoy node.
+type Locality struct {
+	Region  string `json:"region,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	SubZone string `json:"subZone,omitempty"`
+}
+
+// ResourceStatus describes the responses of a resource type that were
+// sent to an Envoy node, and the response the node last accepted.
+type ResourceStatus struct {
+	// TypeURL is the type URL of the resource type.
+	TypeURL string `json:"typeURL"`
+
+	// StreamStart is when the node opened the stream for the
+	// resource type.
+	StreamStart time.Time `json:"streamStart"`
+
+	// SentVersion and SentNonce are the version and nonce of the
+	// last response sent to the node.
+	SentVersion string `json:"sentVersion,omitempty"`
+	SentNonce   string `json:"sentNonce,omitempty"`
+
+	// AckedVersion and AckedNonce are the version and nonce of the
+	// last response the node accepted.
+	AckedVersion string `json:"ackedVersion,omitempty"`
+	AckedNonce   string `json:"ackedNonce,omitempty"`
+
+	// InSync is true if the node accepted the last response it was
+	// sent.
+	InSync bool `json:"inSync"`
+}
+
+// NodeRecorder is notified of the xDS streams that Envoy nodes open,
+// the responses sent on each stream, and the responses each node
+// accepted.
+type NodeRecorder interface {
+	// Connect records that node opened the stream.
+	Connect(stream uint64, node *envoy_config_v3.Node)
+
+	// Sent records that a response was sent on the stream.
+	Sent(stream uint64, typeURL, version, nonce string)
+
+	// Acked records that the node accepted the response sent on the
+	// stream with the given version and nonce.
+	Acked(stream uint64, typeURL, version, nonce string)
+
+	// Disconnect records that the stream was closed.
+	Disconnect(stream uint64)
+}
+
+// NodeRecorders is a NodeRecorder that notifies each of its elements
+// in turn.
+type NodeRecorders []NodeRecorder
+
+// Connect implements NodeRecorder.
+func (n NodeRecorders) Connect(stream uint64, node *envoy_config_v3.Node) {
+	for _, r := range n {
+		r.Connect(stream, node)
+	}
+}
+
+// Sent implements NodeRecorder.
+func (n NodeRecorders) Sent(stream uint64, typeURL, version, nonce string) {
+	for _, r := range n {
+		r.Sent(stream, typeURL, version, nonce)
+	}
+}
+
+// Acked implements NodeRecorder.
+func (n NodeRecorders) Acked(stream uint64, typeURL, version, nonce string) {
+	for _, r := range n {
+		r.Acked(stream, typeURL, version, nonce)
+	}
+}
+
+// Disconnect implements NodeRecorder.
+func (n NodeRecorders) Disconnect(stream uint64) {
+	for _, r := range n {
+		r.Disconnect(stream)
+	}
+}
@@ -14,9 +14,12 @@
 package xds
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"sync/atomic"
 
 	"github.com/golang/protobuf/proto"
+	protov2 "google.golang.org/protobuf/proto"
 )
 
 // Resource represents a source of proto.Messages that can be registered
@@ -35,6 +38,79 @@ type Resource interface {
 	TypeURL() string
 }
 
+// VersionedResource is a Resource that tracks the version of each of
+// its resources, so that only the resources that changed need to be
+// sent to Envoy.
+type VersionedResource interface {
+	Resource
+
+	// Versions returns the version of each resource, by name.
+	Versions() map[string]string
+}
+
+// GroupedResource is a Resource whose contents depend on the group of
+// the Envoy that requests them.
+type GroupedResource interface {
+	Resource
+
+	// Groups returns the names of the groups that have their own
+	// contents.
+	Groups() []string
+
+	// GroupContents returns the contents of this resource for
+	// Envoys in the given group.
+	GroupContents(group string) []proto.Message
+
+	// GroupQuery returns an entry for each resource name supplied,
+	// for Envoys in the given group.
+	GroupQuery(group string, names []string) []proto.Message
+}
+
+// ContentsFor returns the contents of r for Envoys in the given group.
+func ContentsFor(r Resource, group string) []proto.Message {
+	if g, ok := r.(GroupedResource); ok {
+		return g.GroupContents(group)
+	}
+	return r.Contents()
+}
+
+// QueryFor returns an entry of r for each resource name supplied, for
+// Envoys in the given group.
+func QueryFor(r Resource, group string, names []string) []proto.Message {
+	if g, ok := r.(GroupedResource); ok {
+		return g.GroupQuery(group, names)
+	}
+	return r.Query(names)
+}
+
+// VersionOf returns the version of a resource. The version is a hash
+// of the resource contents, so it is the same for equal resources,
+// even across Contour restarts.
+func VersionOf(msg proto.Message) string {
+	buf, err := protov2.MarshalOptions{Deterministic: true}.Marshal(proto.MessageV2(msg))
+	if err != nil {
+		// Resources that cannot be marshaled cannot be sent to
+		// Envoy, so their version does not matter.
+		return ""
+	}
+
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SameVersions returns true if a and b hold the same resource versions.
+func SameVersions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, v := range a {
+		if w, ok := b[name]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
 // Counter holds an atomically incrementing counter.
 type Counter uint64
 
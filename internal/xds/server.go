@@ -17,10 +17,13 @@ import (
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // NewServer If registry is non-nil gRPC server metrics will be automatically
-// configured and enabled.
+// configured and enabled. The gRPC health service is registered, so that
+// Envoy can health check its xDS servers.
 func NewServer(registry *prometheus.Registry, opts ...grpc.ServerOption) *grpc.Server {
 	var metrics *grpc_prometheus.ServerMetrics
 
@@ -36,6 +39,7 @@ func NewServer(registry *prometheus.Registry, opts ...grpc.ServerOption) *grpc.S
 	}
 
 	g := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(g, health.NewServer())
 
 	if metrics != nil {
 		metrics.InitializeMetrics(g)
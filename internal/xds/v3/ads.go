@@ -0,0 +1,485 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/sirupsen/logrus"
+)
+
+// adsOrder is the order in which responses are sent on an ADS stream.
+// Clusters and their endpoints are sent before the listeners and
+// routes that refer to them, so that Envoy never receives
+// configuration that refers to resources it does not have yet.
+var adsOrder = []string{
+	resource.ClusterType,
+	resource.EndpointType,
+	resource.ListenerType,
+	resource.RouteType,
+	resource.SecretType,
+}
+
+// adsWatch is the subscription of an ADS stream to a type URL.
+type adsWatch struct {
+	resource xds.Resource
+
+	// names are the resource names requested by Envoy. If empty,
+	// all the resources are sent.
+	names []string
+
+	// ch receives the version of the resource when it changes.
+	// At most one registration is outstanding at any time.
+	ch         chan int
+	registered bool
+
+	// version is the last version of the resource that ch received.
+	version int
+
+	// pending is true if a response may need to be sent.
+	pending bool
+
+	// force is true if a response must be sent, even if the
+	// resources have not changed since the last response.
+	force bool
+
+	// sent holds the version of each resource in the last response,
+	// or nil if no response has been sent.
+	sent map[string]string
+}
+
+// adsUpdate is a change to the resource of a watch.
+type adsUpdate struct {
+	typeURL string
+	version int
+}
+
+// adsStream is the state of an aggregated discovery service stream.
+//
+// Only one response is outstanding on the stream at any time. The
+// next response is sent once Envoy has acknowledged (or rejected) the
+// previous one, in the order given by adsOrder.
+type adsStream struct {
+	logrus.FieldLogger
+
+	st        grpcStream
+	ctx       context.Context
+	resources map[string]xds.Resource
+	state     *streamState
+	watches   map[string]*adsWatch
+	updates   chan adsUpdate
+
+	// nonce is the nonce of the last response.
+	nonce int
+
+	// inflight is the type URL of the response that Envoy has not
+	// acknowledged yet, or empty if there is no such response.
+	inflight string
+
+	// clusters are the clusters last sent to Envoy, by name.
+	clusters map[string]proto.Message
+
+	// retained is true if the last clusters sent to Envoy include
+	// clusters that have been removed, but may still be referenced
+	// by the routes that Envoy has.
+	retained bool
+
+	// awaitEndpoints is true if Envoy has accepted clusters whose
+	// endpoints it has not subscribed to yet.
+	awaitEndpoints bool
+}
+
+// StreamAggregatedResources implements the xDS aggregated discovery
+// service (ADS), which sends all the resource types on a single stream.
+func (s *contourServer) StreamAggregatedResources(srv envoy_service_discovery_v3.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	// Bump connection counter and set it as a field on the logger.
+	connection := s.connections.Next()
+	log := s.WithField("connection", connection).WithField("stream", "ads")
+
+	ctx, cancel := context.WithCancel(srv.Context())
+	defer cancel()
+
+	state := newStreamState(connection, s.nacks, s.nodes)
+	defer state.close()
+
+	a := &adsStream{
+		FieldLogger: log,
+		st:          srv,
+		ctx:         ctx,
+		resources:   s.resources,
+		state:       state,
+		watches:     map[string]*adsWatch{},
+		updates:     make(chan adsUpdate),
+	}
+
+	err := a.run()
+	if err != nil {
+		log.WithError(err).Error("stream terminated")
+	} else {
+		log.Info("stream terminated")
+	}
+
+	return err
+}
+
+// run processes requests and resource updates until the stream ends.
+func (a *adsStream) run() error {
+	reqs := make(chan *envoy_service_discovery_v3.DiscoveryRequest)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			req, err := a.st.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case reqs <- req:
+			case <-a.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		if err := a.send(); err != nil {
+			return err
+		}
+
+		select {
+		case req := <-reqs:
+			if err := a.request(req); err != nil {
+				return err
+			}
+		case u := <-a.updates:
+			w := a.watches[u.typeURL]
+			w.registered = false
+			w.version = u.version
+			w.pending = true
+		case err := <-errs:
+			return err
+		case <-a.ctx.Done():
+			return a.ctx.Err()
+		}
+	}
+}
+
+// request handles a DiscoveryRequest, which either acknowledges the
+// response in flight, or changes the subscription to its type URL.
+func (a *adsStream) request(req *envoy_service_discovery_v3.DiscoveryRequest) error {
+	logDiscoveryRequestDetails(a.FieldLogger, req)
+	a.state.request(req)
+
+	typeURL := req.GetTypeUrl()
+
+	w, ok := a.watches[typeURL]
+	if !ok {
+		r, ok := a.resources[typeURL]
+		if !ok {
+			return fmt.Errorf("no resource registered for typeURL %q", typeURL)
+		}
+
+		w = a.watch(typeURL, r)
+	}
+
+	if a.inflight == typeURL && req.GetResponseNonce() == strconv.Itoa(a.nonce) {
+		// Envoy has processed the response in flight. If it was
+		// rejected, Envoy keeps its current configuration until
+		// the resource changes again.
+		a.inflight = ""
+
+		if typeURL == resource.ClusterType && req.GetErrorDetail() == nil {
+			a.awaitEndpoints = !a.endpointsSubscribed()
+		}
+	}
+
+	if typeURL == resource.EndpointType {
+		a.awaitEndpoints = false
+	}
+
+	if req.GetResponseNonce() == "" || !sameNames(w.names, req.GetResourceNames()) {
+		// An initial request, or a change to the subscribed names,
+		// needs a response even if the resource has not changed.
+		w.names = req.GetResourceNames()
+		w.pending = w.version >= 0
+		w.force = true
+	}
+
+	a.register(w)
+	return nil
+}
+
+// watch subscribes the stream to the resource of typeURL.
+func (a *adsStream) watch(typeURL string, r xds.Resource) *adsWatch {
+	w := &adsWatch{
+		resource: r,
+		ch:       make(chan int, 1),
+		version:  -1,
+	}
+	a.watches[typeURL] = w
+
+	go func() {
+		for {
+			select {
+			case v := <-w.ch:
+				select {
+				case a.updates <- adsUpdate{typeURL: typeURL, version: v}:
+				case <-a.ctx.Done():
+					return
+				}
+			case <-a.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return w
+}
+
+// register registers the watch to be notified when its resource
+// changes beyond the last version it received.
+func (a *adsStream) register(w *adsWatch) {
+	if w.registered {
+		return
+	}
+
+	// Register the watch without resource name hints, since the
+	// subscribed names may change before the watch is notified.
+	w.registered = true
+	w.resource.Register(w.ch, w.version)
+}
+
+// send sends the next pending response, unless Envoy has not
+// acknowledged the previous response yet.
+func (a *adsStream) send() error {
+	if a.inflight != "" {
+		return nil
+	}
+
+	for _, typeURL := range adsOrder {
+		w, ok := a.watches[typeURL]
+		if !ok || !w.pending {
+			continue
+		}
+
+		switch typeURL {
+		case resource.ListenerType, resource.RouteType:
+			// The caches are not notified in dependency order, so
+			// make sure that Envoy has the current clusters and has
+			// subscribed to their endpoints before it is sent the
+			// listeners and routes that may refer to them.
+			if cw, ok := a.watches[resource.ClusterType]; ok && !a.clustersCurrent(cw) {
+				_, err := a.respond(resource.ClusterType, cw, false)
+				return err
+			}
+			if a.awaitEndpoints {
+				return nil
+			}
+		}
+
+		if sent, err := a.respond(typeURL, w, false); sent || err != nil {
+			return err
+		}
+	}
+
+	// Once the listeners and routes are up to date, the clusters
+	// that have been removed are no longer referenced and can be
+	// removed from Envoy.
+	if w, ok := a.watches[resource.ClusterType]; ok && a.retained {
+		_, err := a.respond(resource.ClusterType, w, true)
+		return err
+	}
+
+	return nil
+}
+
+// respond sends the current contents of the watch resource, unless
+// Envoy already has them. It returns true if a response was sent. If
+// prune is true, clusters that have been removed are not retained.
+func (a *adsStream) respond(typeURL string, w *adsWatch, prune bool) (bool, error) {
+	var resources []proto.Message
+	switch len(w.names) {
+	case 0:
+		resources = xds.ContentsFor(w.resource, a.state.group)
+	default:
+		resources = xds.QueryFor(w.resource, a.state.group, w.names)
+	}
+
+	switch typeURL {
+	case resource.ClusterType:
+		resources = a.retainClusters(resources, prune)
+	}
+
+	versions, versioned := sentVersions(resources)
+	if versioned && !w.force && w.sent != nil && xds.SameVersions(w.sent, versions) {
+		w.pending = false
+		a.register(w)
+		return false, nil
+	}
+
+	anys := make([]*any.Any, 0, len(resources))
+	for _, r := range resources {
+		switch typeURL {
+		case resource.ClusterType, resource.ListenerType:
+			r = adsConfigSources(r)
+		}
+
+		m, err := ptypes.MarshalAny(r)
+		if err != nil {
+			return false, err
+		}
+		anys = append(anys, m)
+	}
+
+	a.nonce++
+
+	resp := &envoy_service_discovery_v3.DiscoveryResponse{
+		VersionInfo: strconv.Itoa(w.version),
+		Resources:   anys,
+		TypeUrl:     typeURL,
+		Nonce:       strconv.Itoa(a.nonce),
+	}
+
+	if err := a.st.Send(resp); err != nil {
+		return false, err
+	}
+	a.state.response(resp)
+
+	a.inflight = typeURL
+	w.pending = false
+	w.force = false
+	w.sent = versions
+	if w.sent == nil {
+		w.sent = map[string]string{}
+	}
+	a.register(w)
+
+	return true, nil
+}
+
+// retainClusters adds the clusters that were last sent to Envoy, but
+// are no longer in resources, unless prune is true.
+func (a *adsStream) retainClusters(resources []proto.Message, prune bool) []proto.Message {
+	current := map[string]proto.Message{}
+	for _, r := range resources {
+		if c, ok := r.(*envoy_cluster_v3.Cluster); ok {
+			current[c.GetName()] = r
+		}
+	}
+
+	var removed []string
+	if !prune {
+		for name := range a.clusters {
+			if _, ok := current[name]; !ok {
+				removed = append(removed, name)
+			}
+		}
+	}
+
+	sort.Strings(removed)
+	for _, name := range removed {
+		current[name] = a.clusters[name]
+		resources = append(resources, a.clusters[name])
+	}
+
+	a.clusters = current
+	a.retained = len(removed) > 0
+	return resources
+}
+
+// clustersCurrent returns true if every cluster of the watch resource
+// has been sent to Envoy.
+func (a *adsStream) clustersCurrent(w *adsWatch) bool {
+	for _, r := range w.resource.Contents() {
+		c, ok := r.(*envoy_cluster_v3.Cluster)
+		if !ok {
+			continue
+		}
+		if sent, ok := a.clusters[c.GetName()]; !ok || !proto.Equal(sent, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// endpointsSubscribed returns true if Envoy has subscribed to the
+// endpoints of every EDS cluster that it was sent.
+func (a *adsStream) endpointsSubscribed() bool {
+	var subscribed map[string]bool
+	if w, ok := a.watches[resource.EndpointType]; ok {
+		if len(w.names) == 0 {
+			return true
+		}
+
+		subscribed = map[string]bool{}
+		for _, name := range w.names {
+			subscribed[name] = true
+		}
+	}
+
+	for _, r := range a.clusters {
+		c, ok := r.(*envoy_cluster_v3.Cluster)
+		if !ok || c.GetEdsClusterConfig() == nil {
+			continue
+		}
+
+		name := c.GetEdsClusterConfig().GetServiceName()
+		if name == "" {
+			name = c.GetName()
+		}
+		if !subscribed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// adsConfigSources returns a copy of msg in which the config sources
+// that refer to the Contour xDS cluster are replaced with ADS config
+// sources, so that Envoy fetches the endpoints, routes and secrets
+// that msg refers to on the ADS stream.
+func adsConfigSources(msg proto.Message) proto.Message {
+	return rewriteConfigSources(msg, func(cs *envoy_core_v3.ConfigSource) {
+		cs.ConfigSourceSpecifier = &envoy_core_v3.ConfigSource_Ads{
+			Ads: &envoy_core_v3.AggregatedConfigSource{},
+		}
+	})
+}
+
+// sameNames returns true if a and b contain the same names in the
+// same order.
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
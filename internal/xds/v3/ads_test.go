@@ -0,0 +1,104 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestADSConfigSources(t *testing.T) {
+	ads := &envoy_core_v3.ConfigSource{
+		ResourceApiVersion: envoy_core_v3.ApiVersion_V3,
+		ConfigSourceSpecifier: &envoy_core_v3.ConfigSource_Ads{
+			Ads: &envoy_core_v3.AggregatedConfigSource{},
+		},
+	}
+
+	cluster := &envoy_cluster_v3.Cluster{
+		Name: "default/kuard/8080/da39a3ee5e",
+		EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+			EdsConfig:   envoy_v3.ConfigSource("contour"),
+			ServiceName: "default/kuard",
+		},
+	}
+
+	got := adsConfigSources(cluster).(*envoy_cluster_v3.Cluster)
+	assert.True(t, proto.Equal(ads, got.EdsClusterConfig.EdsConfig))
+
+	// The original message is not modified.
+	assert.True(t, proto.Equal(envoy_v3.ConfigSource("contour"), cluster.EdsClusterConfig.EdsConfig))
+
+	// Config sources nested in typed configuration are also replaced.
+	listener := &envoy_listener_v3.Listener{
+		Name: "ingress_http",
+		FilterChains: envoy_v3.FilterChains(
+			envoy_v3.HTTPConnectionManager("ingress_http", nil, 0),
+		),
+	}
+
+	gotListener := adsConfigSources(listener).(*envoy_listener_v3.Listener)
+
+	var hcm http.HttpConnectionManager
+	require.NoError(t, ptypes.UnmarshalAny(gotListener.FilterChains[0].Filters[0].GetTypedConfig(), &hcm))
+	assert.True(t, proto.Equal(ads, hcm.GetRds().GetConfigSource()))
+
+	// Config sources for other clusters are not replaced.
+	other := &envoy_cluster_v3.Cluster{
+		Name: "default/kuard/8080/da39a3ee5e",
+		EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+			EdsConfig: envoy_v3.ConfigSource("other"),
+		},
+	}
+	assert.True(t, proto.Equal(other, adsConfigSources(other)))
+}
+
+func TestADSRetainClusters(t *testing.T) {
+	cluster := func(name string) proto.Message {
+		return &envoy_cluster_v3.Cluster{Name: name}
+	}
+
+	names := func(resources []proto.Message) []string {
+		var names []string
+		for _, r := range resources {
+			names = append(names, r.(*envoy_cluster_v3.Cluster).Name)
+		}
+		return names
+	}
+
+	a := &adsStream{}
+
+	got := a.retainClusters([]proto.Message{cluster("a"), cluster("b"), cluster("c")}, false)
+	assert.Equal(t, []string{"a", "b", "c"}, names(got))
+	assert.False(t, a.retained)
+
+	// Removed clusters are retained.
+	got = a.retainClusters([]proto.Message{cluster("d")}, false)
+	assert.Equal(t, []string{"d", "a", "b", "c"}, names(got))
+	assert.True(t, a.retained)
+
+	// Removed clusters are pruned.
+	got = a.retainClusters([]proto.Message{cluster("d")}, true)
+	assert.Equal(t, []string{"d"}, names(got))
+	assert.False(t, a.retained)
+}
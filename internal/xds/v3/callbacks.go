@@ -15,22 +15,56 @@ package v3
 
 import (
 	"fmt"
+	"sync"
 
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	envoy_server_v3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/projectcontour/contour/internal/xds"
 	"github.com/sirupsen/logrus"
 )
 
 // NewRequestLoggingCallbacks returns an implementation of the Envoy xDS server
 // callbacks for use when Contour is run in Envoy xDS server mode to provide
-// request detail logging. Currently only the xDS State of the World callback
-// OnStreamRequest is implemented.
-func NewRequestLoggingCallbacks(log logrus.FieldLogger) envoy_server_v3.Callbacks {
+// request detail logging. If nacks is not nil, it is notified whether Envoy
+// accepted or rejected each State of the World response. If nodes is not
+// nil, it is notified of each stream and the responses sent on it.
+func NewRequestLoggingCallbacks(log logrus.FieldLogger, nacks xds.NackRecorder, nodes xds.NodeRecorder) envoy_server_v3.Callbacks {
+	var (
+		mu      sync.Mutex
+		streams = map[int64]*streamState{}
+	)
+
+	stream := func(streamID int64) *streamState {
+		mu.Lock()
+		defer mu.Unlock()
+
+		s, ok := streams[streamID]
+		if !ok {
+			s = newStreamState(uint64(streamID), nacks, nodes)
+			streams[streamID] = s
+		}
+		return s
+	}
+
 	return &envoy_server_v3.CallbackFuncs{
 		StreamRequestFunc: func(streamID int64, req *envoy_service_discovery_v3.DiscoveryRequest) error {
 			logDiscoveryRequestDetails(log, req)
+			stream(streamID).request(req)
 			return nil
 		},
+		StreamResponseFunc: func(streamID int64, req *envoy_service_discovery_v3.DiscoveryRequest, resp *envoy_service_discovery_v3.DiscoveryResponse) {
+			stream(streamID).response(resp)
+		},
+		StreamClosedFunc: func(streamID int64) {
+			mu.Lock()
+			s, ok := streams[streamID]
+			delete(streams, streamID)
+			mu.Unlock()
+
+			if ok {
+				s.close()
+			}
+		},
 	}
 }
 
@@ -49,7 +83,6 @@ func logDiscoveryRequestDetails(l logrus.FieldLogger, req *envoy_service_discove
 
 	if status := req.ErrorDetail; status != nil {
 		// if Envoy rejected the last update log the details here.
-		// TODO(dfc) issue 1176: handle xDS ACK/NACK
 		log.WithField("code", status.Code).Error(status.Message)
 	}
 
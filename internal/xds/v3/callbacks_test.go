@@ -135,7 +135,7 @@ func TestLogDiscoveryRequestDetails(t *testing.T) {
 
 func TestOnStreamRequestCallbackLogs(t *testing.T) {
 	log, logHook := test.NewNullLogger()
-	callbacks := NewRequestLoggingCallbacks(log)
+	callbacks := NewRequestLoggingCallbacks(log, nil, nil)
 	err := callbacks.OnStreamRequest(999, &envoy_service_discovery_v3.DiscoveryRequest{
 		VersionInfo:   "req-version",
 		ResponseNonce: "resp-nonce",
@@ -0,0 +1,77 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/golang/protobuf/proto"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// rewriteConfigSources returns a copy of msg in which rewrite has been
+// applied to every config source that refers to the Contour xDS
+// cluster, including the config sources nested in typed configuration.
+//
+// The resources in the xDS caches refer to Contour with separate
+// state of the world gRPC streams. This allows a stream to tell Envoy
+// to fetch the resources they refer to with the same protocol as the
+// stream itself.
+func rewriteConfigSources(msg proto.Message, rewrite func(*envoy_core_v3.ConfigSource)) proto.Message {
+	m := protov2.Clone(proto.MessageV2(msg))
+	walkConfigSources(m.ProtoReflect(), rewrite)
+	return proto.MessageV1(m)
+}
+
+func walkConfigSources(m protoreflect.Message, rewrite func(*envoy_core_v3.ConfigSource)) {
+	switch v := m.Interface().(type) {
+	case *envoy_core_v3.ConfigSource:
+		for _, g := range v.GetApiConfigSource().GetGrpcServices() {
+			if g.GetEnvoyGrpc().GetClusterName() == "contour" {
+				rewrite(v)
+				return
+			}
+		}
+		return
+	case *anypb.Any:
+		inner, err := v.UnmarshalNew()
+		if err != nil {
+			return
+		}
+		walkConfigSources(inner.ProtoReflect(), rewrite)
+		if err := v.MarshalFrom(inner); err != nil {
+			return
+		}
+		return
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				walkConfigSources(list.Get(i).Message(), rewrite)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				walkConfigSources(v.Message(), rewrite)
+				return true
+			})
+		case fd.Message() != nil:
+			walkConfigSources(v.Message(), rewrite)
+		}
+		return true
+	})
+}
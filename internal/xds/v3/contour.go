@@ -39,11 +39,16 @@ type grpcStream interface {
 
 // NewContourServer creates an internally implemented Server that streams the
 // provided set of Resource objects. The returned Server implements the xDS
-// State of the World (SotW) variant.
-func NewContourServer(log logrus.FieldLogger, resources ...xds.Resource) Server {
+// State of the World (SotW) variant, on separate streams for each resource
+// type or on a single aggregated (ADS) stream. If nacks is not nil, it is notified
+// whether Envoy accepted or rejected each response. If nodes is not nil, it is
+// notified of each stream and the responses sent on it.
+func NewContourServer(log logrus.FieldLogger, nacks xds.NackRecorder, nodes xds.NodeRecorder, resources ...xds.Resource) Server {
 	c := contourServer{
 		FieldLogger: log,
 		resources:   map[string]xds.Resource{},
+		nacks:       nacks,
+		nodes:       nodes,
 	}
 
 	for i, r := range resources {
@@ -67,12 +72,15 @@ type contourServer struct {
 	logrus.FieldLogger
 	resources   map[string]xds.Resource
 	connections xds.Counter
+	nacks       xds.NackRecorder
+	nodes       xds.NodeRecorder
 }
 
 // stream processes a stream of DiscoveryRequests.
 func (s *contourServer) stream(st grpcStream) error {
 	// Bump connection counter and set it as a field on the logger.
-	log := s.WithField("connection", s.connections.Next())
+	connection := s.connections.Next()
+	log := s.WithField("connection", connection)
 
 	// Notify whether the stream terminated on error.
 	done := func(log logrus.FieldLogger, err error) error {
@@ -85,12 +93,19 @@ func (s *contourServer) stream(st grpcStream) error {
 		return err
 	}
 
+	state := newStreamState(connection, s.nacks, s.nodes)
+	defer state.close()
+
 	ch := make(chan int, 1)
 
 	// internally all registration values start at zero so sending
 	// a last that is less than zero will guarantee that each stream
 	// will generate a response immediately, then wait.
 	last := -1
+
+	// sent holds the version of each resource in the last response,
+	// or nil if no response has been sent.
+	var sent map[string]string
 	ctx := st.Context()
 
 	// now stick in this loop until the client disconnects.
@@ -105,6 +120,8 @@ func (s *contourServer) stream(st grpcStream) error {
 		// Note: redeclare log in this scope so the next time around the loop all is forgotten.
 		log := logDiscoveryRequestDetails(log, req)
 
+		state.request(req)
+
 		// From the request we derive the resource to stream which have
 		// been registered according to the typeURL.
 		r, ok := s.resources[req.GetTypeUrl()]
@@ -115,45 +132,60 @@ func (s *contourServer) stream(st grpcStream) error {
 		// now we wait for a notification, if this is the first request received on this
 		// connection last will be less than zero and that will trigger a response immediately.
 		r.Register(ch, last, req.ResourceNames...)
-		select {
-		case last = <-ch:
-			// boom, something in the cache has changed.
-			// TODO(dfc) the thing that has changed may not be in the scope of the filter
-			// so we're going to be sending an update that is a no-op. See #426
-
-			var resources []proto.Message
-			switch len(req.ResourceNames) {
-			case 0:
-				// no resource hints supplied, return the full
-				// contents of the resource
-				resources = r.Contents()
-			default:
-				// resource hints supplied, return exactly those
-				resources = r.Query(req.ResourceNames)
-			}
 
-			any := make([]*any.Any, 0, len(resources))
-			for _, r := range resources {
-				a, err := ptypes.MarshalAny(r)
-				if err != nil {
+		for waiting := true; waiting; {
+			select {
+			case last = <-ch:
+				// boom, something in the cache has changed.
+				var resources []proto.Message
+				switch len(req.ResourceNames) {
+				case 0:
+					// no resource hints supplied, return the full
+					// contents of the resource
+					resources = xds.ContentsFor(r, state.group)
+				default:
+					// resource hints supplied, return exactly those
+					resources = xds.QueryFor(r, state.group, req.ResourceNames)
+				}
+
+				// the thing that has changed may not be in the scope of the
+				// request, in which case Envoy already has the current version
+				// of every resource it asked for, so wait for the next change.
+				if versions, ok := sentVersions(resources); ok && sent != nil && xds.SameVersions(sent, versions) {
+					r.Register(ch, last, req.ResourceNames...)
+					continue
+				}
+
+				any := make([]*any.Any, 0, len(resources))
+				for _, r := range resources {
+					a, err := ptypes.MarshalAny(r)
+					if err != nil {
+						return done(log, err)
+					}
+					any = append(any, a)
+				}
+
+				resp := &envoy_service_discovery_v3.DiscoveryResponse{
+					VersionInfo: strconv.Itoa(last),
+					Resources:   any,
+					TypeUrl:     req.GetTypeUrl(),
+					Nonce:       strconv.Itoa(last),
+				}
+
+				if err := st.Send(resp); err != nil {
 					return done(log, err)
 				}
-				any = append(any, a)
-			}
+				state.response(resp)
 
-			resp := &envoy_service_discovery_v3.DiscoveryResponse{
-				VersionInfo: strconv.Itoa(last),
-				Resources:   any,
-				TypeUrl:     req.GetTypeUrl(),
-				Nonce:       strconv.Itoa(last),
-			}
+				sent, _ = sentVersions(resources)
+				if sent == nil {
+					sent = map[string]string{}
+				}
+				waiting = false
 
-			if err := st.Send(resp); err != nil {
-				return done(log, err)
+			case <-ctx.Done():
+				return done(log, ctx.Err())
 			}
-
-		case <-ctx.Done():
-			return done(log, ctx.Err())
 		}
 	}
 }
@@ -177,3 +209,17 @@ func (s *contourServer) StreamRoutes(srv envoy_service_route_v3.RouteDiscoverySe
 func (s *contourServer) StreamSecrets(srv envoy_service_secret_v3.SecretDiscoveryService_StreamSecretsServer) error {
 	return s.stream(srv)
 }
+
+// sentVersions returns the version of each of the resources, by name.
+// If a resource has no name, it returns false.
+func sentVersions(resources []proto.Message) (map[string]string, bool) {
+	versions := make(map[string]string, len(resources))
+	for _, r := range resources {
+		name := resourceName(r)
+		if name == "" {
+			return nil, false
+		}
+		versions[name] = xds.VersionOf(r)
+	}
+	return versions, true
+}
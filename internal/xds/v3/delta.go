@@ -0,0 +1,347 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	envoy_service_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/sirupsen/logrus"
+)
+
+type deltaGrpcStream interface {
+	Context() context.Context
+	Send(*envoy_service_discovery_v3.DeltaDiscoveryResponse) error
+	Recv() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error)
+}
+
+// deltaSubscription tracks the resources that Envoy subscribed to on a
+// delta xDS stream, and the version of each resource that Envoy has.
+type deltaSubscription struct {
+	// wildcard is true if Envoy subscribed to all the resources.
+	wildcard bool
+
+	// names are the resources that Envoy subscribed to.
+	names map[string]bool
+
+	// versions holds the version of each resource that Envoy has.
+	versions map[string]string
+}
+
+// newDeltaSubscription returns the subscription of the initial request
+// on a delta xDS stream.
+func newDeltaSubscription(req *envoy_service_discovery_v3.DeltaDiscoveryRequest) *deltaSubscription {
+	d := &deltaSubscription{
+		wildcard: len(req.GetResourceNamesSubscribe()) == 0,
+		names:    map[string]bool{},
+		versions: map[string]string{},
+	}
+
+	// When Envoy reconnects, it tells us which resources it already
+	// has, so that only the resources that changed are sent again.
+	for name, version := range req.GetInitialResourceVersions() {
+		d.versions[name] = version
+	}
+
+	return d
+}
+
+// update applies the subscription changes of req, and returns true if
+// the subscribed resources changed.
+func (d *deltaSubscription) update(req *envoy_service_discovery_v3.DeltaDiscoveryRequest) bool {
+	changed := false
+
+	for _, name := range req.GetResourceNamesSubscribe() {
+		if !d.names[name] {
+			d.names[name] = true
+			changed = true
+		}
+	}
+
+	for _, name := range req.GetResourceNamesUnsubscribe() {
+		delete(d.names, name)
+		delete(d.versions, name)
+		changed = true
+	}
+
+	return changed
+}
+
+// changes returns the subscribed resources whose version differs
+// from the version that Envoy has, and the names of the resources
+// that Envoy has, but that no longer exist.
+func (d *deltaSubscription) changes(r xds.VersionedResource) ([]proto.Message, []string) {
+	versions := r.Versions()
+
+	var query []string
+	switch d.wildcard {
+	case true:
+		for name, v := range versions {
+			if have, ok := d.versions[name]; !ok || have != v {
+				query = append(query, name)
+			}
+		}
+	default:
+		for name := range d.names {
+			// Resources that do not exist are queried anyway,
+			// since a resource may return a placeholder for them.
+			if have, ok := d.versions[name]; !ok || have != versions[name] {
+				query = append(query, name)
+			}
+		}
+	}
+
+	var changed []proto.Message
+	found := map[string]bool{}
+
+	if len(query) > 0 {
+		sort.Strings(query)
+		for _, m := range r.Query(query) {
+			name := resourceName(m)
+			found[name] = true
+
+			if have, ok := d.versions[name]; ok && have == xds.VersionOf(m) {
+				continue
+			}
+			changed = append(changed, m)
+		}
+	}
+
+	var removed []string
+	for name := range d.versions {
+		if _, ok := versions[name]; !ok && !found[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	return changed, removed
+}
+
+// DeltaClusters implements the incremental (delta) variant of CDS.
+func (s *contourServer) DeltaClusters(srv envoy_service_cluster_v3.ClusterDiscoveryService_DeltaClustersServer) error {
+	return s.delta(srv)
+}
+
+// DeltaEndpoints implements the incremental (delta) variant of EDS.
+func (s *contourServer) DeltaEndpoints(srv envoy_service_endpoint_v3.EndpointDiscoveryService_DeltaEndpointsServer) error {
+	return s.delta(srv)
+}
+
+// delta processes a stream of DeltaDiscoveryRequests. Unlike the state
+// of the world protocol, only the resources that changed since the
+// last response are sent, and resources that were removed are named.
+func (s *contourServer) delta(st deltaGrpcStream) error {
+	// Bump connection counter and set it as a field on the logger.
+	connection := s.connections.Next()
+	log := s.WithField("connection", connection).WithField("stream", "delta")
+
+	// Notify whether the stream terminated on error.
+	done := func(log logrus.FieldLogger, err error) error {
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(st.Context())
+	defer cancel()
+
+	state := newStreamState(connection, s.nacks, s.nodes)
+	defer state.close()
+
+	reqs := make(chan *envoy_service_discovery_v3.DeltaDiscoveryRequest)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			req, err := st.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		r       xds.VersionedResource
+		typeURL string
+		sub     *deltaSubscription
+
+		ch         = make(chan int, 1)
+		registered bool
+
+		// internally all registration values start at zero so
+		// registering a last that is less than zero will
+		// generate a response immediately.
+		last = -1
+
+		// pending is true if the resources may have changed
+		// since the last response.
+		pending bool
+
+		// sent is true once a response has been sent. Envoy
+		// expects a response to its initial request, even if
+		// there are no resources.
+		sent  bool
+		nonce int
+	)
+
+	for {
+		select {
+		case req := <-reqs:
+			log := logDeltaRequestDetails(log, req)
+			state.request(req)
+
+			if r == nil {
+				typeURL = req.GetTypeUrl()
+
+				res, ok := s.resources[typeURL]
+				if !ok {
+					return done(log, fmt.Errorf("no resource registered for typeURL %q", typeURL))
+				}
+				vr, ok := res.(xds.VersionedResource)
+				if !ok {
+					return done(log, fmt.Errorf("delta xDS is not supported for typeURL %q", typeURL))
+				}
+
+				r = vr
+				sub = newDeltaSubscription(req)
+			} else if req.GetTypeUrl() != typeURL {
+				return done(log, fmt.Errorf("unexpected typeURL %q on %q stream", req.GetTypeUrl(), typeURL))
+			}
+
+			if sub.update(req) {
+				pending = last >= 0
+			}
+
+		case last = <-ch:
+			// something in the cache has changed.
+			registered = false
+			pending = true
+
+		case err := <-errs:
+			return done(log, err)
+
+		case <-ctx.Done():
+			return done(log, ctx.Err())
+		}
+
+		if pending {
+			pending = false
+
+			changed, removed := sub.changes(r)
+			if len(changed) > 0 || len(removed) > 0 || !sent {
+				nonce++
+
+				resp, err := deltaResponse(typeURL, strconv.Itoa(last), strconv.Itoa(nonce), changed, removed)
+				if err != nil {
+					return done(log, err)
+				}
+
+				if err := st.Send(resp); err != nil {
+					return done(log, err)
+				}
+				state.deltaResponse(resp)
+				sent = true
+
+				for _, res := range resp.GetResources() {
+					sub.versions[res.GetName()] = res.GetVersion()
+				}
+				for _, name := range removed {
+					delete(sub.versions, name)
+				}
+			}
+		}
+
+		if !registered {
+			r.Register(ch, last)
+			registered = true
+		}
+	}
+}
+
+// deltaResponse returns a DeltaDiscoveryResponse for the changed and
+// removed resources.
+func deltaResponse(typeURL, version, nonce string, changed []proto.Message, removed []string) (*envoy_service_discovery_v3.DeltaDiscoveryResponse, error) {
+	resp := &envoy_service_discovery_v3.DeltaDiscoveryResponse{
+		SystemVersionInfo: version,
+		TypeUrl:           typeURL,
+		RemovedResources:  removed,
+		Nonce:             nonce,
+	}
+
+	for _, m := range changed {
+		res := &envoy_service_discovery_v3.Resource{
+			Name:    resourceName(m),
+			Version: xds.VersionOf(m),
+		}
+
+		// Clusters sent on a delta stream fetch their endpoints on
+		// a delta stream too.
+		if typeURL == resource.ClusterType {
+			m = rewriteConfigSources(m, func(cs *envoy_core_v3.ConfigSource) {
+				cs.GetApiConfigSource().ApiType = envoy_core_v3.ApiConfigSource_DELTA_GRPC
+			})
+		}
+
+		a, err := ptypes.MarshalAny(m)
+		if err != nil {
+			return nil, err
+		}
+		res.Resource = a
+
+		resp.Resources = append(resp.Resources, res)
+	}
+
+	return resp, nil
+}
+
+func logDeltaRequestDetails(l logrus.FieldLogger, req *envoy_service_discovery_v3.DeltaDiscoveryRequest) *logrus.Entry {
+	log := l.WithField("response_nonce", req.ResponseNonce)
+	if req.Node != nil {
+		log = log.WithField("node_id", req.Node.Id)
+	}
+
+	if status := req.ErrorDetail; status != nil {
+		// if Envoy rejected the last update log the details here.
+		log.WithField("code", status.Code).Error(status.Message)
+	}
+
+	log = log.WithField("resource_names_subscribe", req.ResourceNamesSubscribe).
+		WithField("resource_names_unsubscribe", req.ResourceNamesUnsubscribe).
+		WithField("type_url", req.GetTypeUrl())
+	log.Info("handling v3 delta xDS resource request")
+
+	return log
+}
@@ -0,0 +1,205 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// versionedResource is a fake xds.VersionedResource that returns
+// placeholder load assignments for unknown names, like the
+// EndpointsTranslator.
+type versionedResource struct {
+	mockResource
+	values map[string]*envoy_endpoint_v3.ClusterLoadAssignment
+}
+
+func (v *versionedResource) Query(names []string) []proto.Message {
+	var values []proto.Message
+	for _, n := range names {
+		value, ok := v.values[n]
+		if !ok {
+			value = &envoy_endpoint_v3.ClusterLoadAssignment{ClusterName: n}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func (v *versionedResource) Versions() map[string]string {
+	versions := map[string]string{}
+	for name, value := range v.values {
+		versions[name] = xds.VersionOf(value)
+	}
+	return versions
+}
+
+func TestDeltaSubscriptionChanges(t *testing.T) {
+	cla := func(name string, port uint32) *envoy_endpoint_v3.ClusterLoadAssignment {
+		return &envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: name,
+			Endpoints:   envoy_v3.Endpoints(envoy_v3.SocketAddress("10.0.0.1", int(port))),
+		}
+	}
+
+	names := func(resources []proto.Message) []string {
+		var names []string
+		for _, r := range resources {
+			names = append(names, resourceName(r))
+		}
+		return names
+	}
+
+	r := &versionedResource{
+		values: map[string]*envoy_endpoint_v3.ClusterLoadAssignment{
+			"default/a": cla("default/a", 80),
+			"default/b": cla("default/b", 80),
+		},
+	}
+
+	// Envoy already has the current version of default/a.
+	sub := newDeltaSubscription(&envoy_service_discovery_v3.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe: []string{"default/a", "default/b", "default/c"},
+		InitialResourceVersions: map[string]string{
+			"default/a": xds.VersionOf(r.values["default/a"]),
+		},
+	})
+	assert.True(t, sub.update(&envoy_service_discovery_v3.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe: []string{"default/a", "default/b", "default/c"},
+	}))
+	assert.False(t, sub.wildcard)
+
+	changed, removed := sub.changes(r)
+	assert.Equal(t, []string{"default/b", "default/c"}, names(changed))
+	assert.Empty(t, removed)
+
+	for _, m := range changed {
+		sub.versions[resourceName(m)] = xds.VersionOf(m)
+	}
+
+	// Nothing changed.
+	changed, removed = sub.changes(r)
+	assert.Empty(t, changed)
+	assert.Empty(t, removed)
+
+	// Only the changed resource is sent.
+	r.values["default/b"] = cla("default/b", 8080)
+	changed, removed = sub.changes(r)
+	assert.Equal(t, []string{"default/b"}, names(changed))
+	assert.Empty(t, removed)
+	sub.versions["default/b"] = xds.VersionOf(changed[0])
+
+	// Unsubscribed resources are forgotten.
+	assert.True(t, sub.update(&envoy_service_discovery_v3.DeltaDiscoveryRequest{
+		ResourceNamesUnsubscribe: []string{"default/a"},
+	}))
+	changed, removed = sub.changes(r)
+	assert.Empty(t, changed)
+	assert.Empty(t, removed)
+	assert.NotContains(t, sub.versions, "default/a")
+}
+
+func TestDeltaSubscriptionWildcard(t *testing.T) {
+	cluster := func(name string) *envoy_cluster_v3.Cluster {
+		return &envoy_cluster_v3.Cluster{Name: name}
+	}
+
+	values := map[string]proto.Message{
+		"a": cluster("a"),
+		"b": cluster("b"),
+	}
+
+	r := &clusterResource{values: values}
+
+	sub := newDeltaSubscription(&envoy_service_discovery_v3.DeltaDiscoveryRequest{})
+	assert.True(t, sub.wildcard)
+
+	changed, removed := sub.changes(r)
+	assert.Len(t, changed, 2)
+	assert.Empty(t, removed)
+	for _, m := range changed {
+		sub.versions[resourceName(m)] = xds.VersionOf(m)
+	}
+
+	// Removed clusters are named.
+	delete(values, "a")
+	changed, removed = sub.changes(r)
+	assert.Empty(t, changed)
+	assert.Equal(t, []string{"a"}, removed)
+}
+
+// clusterResource is a fake xds.VersionedResource that omits unknown
+// names, like the ClusterCache.
+type clusterResource struct {
+	mockResource
+	values map[string]proto.Message
+}
+
+func (c *clusterResource) Query(names []string) []proto.Message {
+	var values []proto.Message
+	for _, n := range names {
+		if v, ok := c.values[n]; ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (c *clusterResource) Versions() map[string]string {
+	versions := map[string]string{}
+	for name, value := range c.values {
+		versions[name] = xds.VersionOf(value)
+	}
+	return versions
+}
+
+func TestDeltaResponse(t *testing.T) {
+	cluster := &envoy_cluster_v3.Cluster{
+		Name: "default/kuard/8080/da39a3ee5e",
+		EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+			EdsConfig:   envoy_v3.ConfigSource("contour"),
+			ServiceName: "default/kuard",
+		},
+	}
+
+	resp, err := deltaResponse(resource.ClusterType, "7", "1", []proto.Message{cluster}, []string{"default/old/80/da39a3ee5e"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "7", resp.SystemVersionInfo)
+	assert.Equal(t, "1", resp.Nonce)
+	assert.Equal(t, []string{"default/old/80/da39a3ee5e"}, resp.RemovedResources)
+	require.Len(t, resp.Resources, 1)
+	assert.Equal(t, cluster.Name, resp.Resources[0].Name)
+
+	// The version is the version of the cached cluster, so that it
+	// matches the version the cache reports.
+	assert.Equal(t, xds.VersionOf(cluster), resp.Resources[0].Version)
+
+	// The endpoints of the cluster are fetched with delta xDS.
+	var got envoy_cluster_v3.Cluster
+	require.NoError(t, ptypes.UnmarshalAny(resp.Resources[0].Resource, &got))
+	assert.Equal(t, envoy_core_v3.ApiConfigSource_DELTA_GRPC, got.EdsClusterConfig.EdsConfig.GetApiConfigSource().ApiType)
+}
@@ -0,0 +1,223 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/projectcontour/contour/internal/xds"
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// streamState tracks the responses sent on an xDS stream so that
+// the ACK or NACK of each response can be reported to a NackRecorder,
+// and the stream and its responses to a NodeRecorder.
+type streamState struct {
+	nacks xds.NackRecorder
+	nodes xds.NodeRecorder
+
+	// id identifies the stream to the NodeRecorder.
+	id uint64
+
+	// connected is true once the stream has been reported to the
+	// NodeRecorder.
+	connected bool
+
+	// nodeID is the ID of the Envoy node on the stream. Envoy may
+	// only send its node details on the first request of a stream.
+	nodeID string
+
+	// group is the Envoy group of the node on the stream.
+	group string
+
+	// sent holds the last response sent for each type URL.
+	sent map[string]sentResponse
+}
+
+// sentResponse is a DiscoveryResponse sent on a stream.
+type sentResponse struct {
+	version string
+	nonce   string
+
+	// resources holds the resources of a state of the world
+	// response. They are only decoded for their names if the
+	// response is rejected.
+	resources []*any.Any
+
+	// resourceNames holds the names of the resources of a
+	// delta response.
+	resourceNames []string
+}
+
+// names returns the names of the resources in the response.
+func (r sentResponse) names() []string {
+	if r.resources != nil {
+		return resourceNames(r.resources)
+	}
+	return r.resourceNames
+}
+
+func newStreamState(id uint64, nacks xds.NackRecorder, nodes xds.NodeRecorder) *streamState {
+	return &streamState{
+		id:    id,
+		nacks: nacks,
+		nodes: nodes,
+		sent:  map[string]sentResponse{},
+	}
+}
+
+// discoveryRequest is a state of the world or delta DiscoveryRequest.
+type discoveryRequest interface {
+	GetNode() *envoy_config_core_v3.Node
+	GetTypeUrl() string
+	GetResponseNonce() string
+	GetErrorDetail() *status.Status
+}
+
+// request reports whether req accepted or rejected the last
+// response sent on the stream for its type URL.
+func (s *streamState) request(req discoveryRequest) {
+	if id := req.GetNode().GetId(); id != "" {
+		s.nodeID = id
+	}
+	if node := req.GetNode(); node != nil {
+		s.group = xds.GroupOf(node)
+
+		if s.nodes != nil && !s.connected {
+			s.nodes.Connect(s.id, node)
+			s.connected = true
+		}
+	}
+
+	// Initial requests have no nonce, and requests with an older
+	// nonce refer to a response that has already been superseded.
+	last, ok := s.sent[req.GetTypeUrl()]
+	if !ok || req.GetResponseNonce() == "" || req.GetResponseNonce() != last.nonce {
+		return
+	}
+
+	detail := req.GetErrorDetail()
+	if detail == nil {
+		if s.nodes != nil && s.connected {
+			s.nodes.Acked(s.id, req.GetTypeUrl(), last.version, last.nonce)
+		}
+		if s.nacks != nil {
+			s.nacks.Ack(s.nodeID, req.GetTypeUrl())
+		}
+		return
+	}
+
+	if s.nacks == nil {
+		return
+	}
+
+	// Delta requests have no version, since each resource is
+	// versioned separately.
+	var version string
+	if req, ok := req.(interface{ GetVersionInfo() string }); ok {
+		version = req.GetVersionInfo()
+	}
+
+	s.nacks.Nack(xds.Nack{
+		NodeID:        s.nodeID,
+		TypeURL:       req.GetTypeUrl(),
+		ResponseNonce: req.GetResponseNonce(),
+		VersionInfo:   version,
+		ResourceNames: last.names(),
+		Code:          detail.GetCode(),
+		Message:       detail.GetMessage(),
+		Time:          time.Now(),
+	})
+}
+
+// response records a response sent on the stream.
+func (s *streamState) response(resp *envoy_service_discovery_v3.DiscoveryResponse) {
+	s.sent[resp.GetTypeUrl()] = sentResponse{
+		version:   resp.GetVersionInfo(),
+		nonce:     resp.GetNonce(),
+		resources: resp.GetResources(),
+	}
+	s.sentTo(resp.GetTypeUrl(), resp.GetVersionInfo(), resp.GetNonce())
+}
+
+// deltaResponse records a delta response sent on the stream.
+func (s *streamState) deltaResponse(resp *envoy_service_discovery_v3.DeltaDiscoveryResponse) {
+	var names []string
+	for _, r := range resp.GetResources() {
+		names = append(names, r.GetName())
+	}
+
+	s.sent[resp.GetTypeUrl()] = sentResponse{
+		version:       resp.GetSystemVersionInfo(),
+		nonce:         resp.GetNonce(),
+		resourceNames: names,
+	}
+	s.sentTo(resp.GetTypeUrl(), resp.GetSystemVersionInfo(), resp.GetNonce())
+}
+
+// sentTo reports a response sent on the stream to the NodeRecorder.
+func (s *streamState) sentTo(typeURL, version, nonce string) {
+	if s.nodes != nil && s.connected {
+		s.nodes.Sent(s.id, typeURL, version, nonce)
+	}
+}
+
+// close reports that the node is no longer connected for the type
+// URLs sent on the stream.
+func (s *streamState) close() {
+	if s.nodes != nil && s.connected {
+		s.nodes.Disconnect(s.id)
+	}
+
+	if s.nacks == nil {
+		return
+	}
+
+	for typeURL := range s.sent {
+		s.nacks.Disconnect(s.nodeID, typeURL)
+	}
+}
+
+// resourceNames returns the names of the xDS resources.
+func resourceNames(resources []*any.Any) []string {
+	var names []string
+	for _, r := range resources {
+		var msg ptypes.DynamicAny
+		if err := ptypes.UnmarshalAny(r, &msg); err != nil {
+			continue
+		}
+
+		if name := resourceName(msg.Message); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resourceName returns the name of an xDS resource.
+func resourceName(msg proto.Message) string {
+	switch m := msg.(type) {
+	case interface{ GetName() string }:
+		return m.GetName()
+	case interface{ GetClusterName() string }:
+		return m.GetClusterName()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"fmt"
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+type recorder struct {
+	acks, disconnects []string
+	nacks             []xds.Nack
+}
+
+func (r *recorder) Ack(nodeID, typeURL string) { r.acks = append(r.acks, nodeID+" "+typeURL) }
+func (r *recorder) Nack(n xds.Nack)            { r.nacks = append(r.nacks, n) }
+func (r *recorder) Disconnect(nodeID, typeURL string) {
+	r.disconnects = append(r.disconnects, nodeID+" "+typeURL)
+}
+
+func TestStreamStateNack(t *testing.T) {
+	const typeURL = "type.googleapis.com/envoy.config.listener.v3.Listener"
+
+	rec := &recorder{}
+	s := newStreamState(1, rec, nil)
+
+	// The initial request is neither an ACK nor a NACK.
+	s.request(&envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl: typeURL,
+		Node:    &envoy_config_core_v3.Node{Id: "envoy-1"},
+	})
+	assert.Empty(t, rec.acks)
+	assert.Empty(t, rec.nacks)
+
+	s.response(&envoy_service_discovery_v3.DiscoveryResponse{
+		TypeUrl:     typeURL,
+		VersionInfo: "1",
+		Nonce:       "1",
+		Resources: []*any.Any{
+			protobuf.MustMarshalAny(&envoy_listener_v3.Listener{Name: "ingress_http"}),
+		},
+	})
+
+	s.request(&envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl:       typeURL,
+		VersionInfo:   "1",
+		ResponseNonce: "1",
+	})
+	assert.Equal(t, []string{"envoy-1 " + typeURL}, rec.acks)
+
+	s.response(&envoy_service_discovery_v3.DiscoveryResponse{
+		TypeUrl:     typeURL,
+		VersionInfo: "2",
+		Nonce:       "2",
+		Resources: []*any.Any{
+			protobuf.MustMarshalAny(&envoy_listener_v3.Listener{Name: "ingress_https"}),
+		},
+	})
+
+	// A request for a superseded response is ignored.
+	s.request(&envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl:       typeURL,
+		VersionInfo:   "1",
+		ResponseNonce: "1",
+		ErrorDetail:   &status.Status{Message: "stale"},
+	})
+	assert.Empty(t, rec.nacks)
+
+	s.request(&envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl:       typeURL,
+		VersionInfo:   "1",
+		ResponseNonce: "2",
+		ErrorDetail:   &status.Status{Code: 13, Message: "bad listener"},
+	})
+	require.Len(t, rec.nacks, 1)
+	nack := rec.nacks[0]
+	assert.Equal(t, "envoy-1", nack.NodeID)
+	assert.Equal(t, typeURL, nack.TypeURL)
+	assert.Equal(t, "2", nack.ResponseNonce)
+	assert.Equal(t, "1", nack.VersionInfo)
+	assert.Equal(t, []string{"ingress_https"}, nack.ResourceNames)
+	assert.Equal(t, int32(13), nack.Code)
+	assert.Equal(t, "bad listener", nack.Message)
+
+	s.close()
+	assert.Equal(t, []string{"envoy-1 " + typeURL}, rec.disconnects)
+}
+
+type nodeRecorder struct {
+	events []string
+}
+
+func (r *nodeRecorder) Connect(stream uint64, node *envoy_config_core_v3.Node) {
+	r.events = append(r.events, fmt.Sprintf("connect %d %s", stream, node.GetId()))
+}
+
+func (r *nodeRecorder) Sent(stream uint64, typeURL, version, nonce string) {
+	r.events = append(r.events, fmt.Sprintf("sent %d %s %s", stream, version, nonce))
+}
+
+func (r *nodeRecorder) Acked(stream uint64, typeURL, version, nonce string) {
+	r.events = append(r.events, fmt.Sprintf("acked %d %s %s", stream, version, nonce))
+}
+
+func (r *nodeRecorder) Disconnect(stream uint64) {
+	r.events = append(r.events, fmt.Sprintf("disconnect %d", stream))
+}
+
+func TestStreamStateNodes(t *testing.T) {
+	const typeURL = "type.googleapis.com/envoy.config.listener.v3.Listener"
+
+	rec := &nodeRecorder{}
+	s := newStreamState(7, nil, rec)
+
+	s.request(&envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl: typeURL,
+		Node:    &envoy_config_core_v3.Node{Id: "envoy-1"},
+	})
+	s.response(&envoy_service_discovery_v3.DiscoveryResponse{
+		TypeUrl:     typeURL,
+		VersionInfo: "1",
+		Nonce:       "1",
+	})
+
+	// Later requests repeat the node, but connect only once.
+	s.request(&envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl:       typeURL,
+		VersionInfo:   "1",
+		ResponseNonce: "1",
+		Node:          &envoy_config_core_v3.Node{Id: "envoy-1"},
+	})
+	s.response(&envoy_service_discovery_v3.DiscoveryResponse{
+		TypeUrl:     typeURL,
+		VersionInfo: "2",
+		Nonce:       "2",
+	})
+
+	// A NACK is not an ACK.
+	s.request(&envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl:       typeURL,
+		VersionInfo:   "1",
+		ResponseNonce: "2",
+		ErrorDetail:   &status.Status{Message: "bad listener"},
+	})
+	s.close()
+
+	assert.Equal(t, []string{
+		"connect 7 envoy-1",
+		"sent 7 1 1",
+		"acked 7 1 1",
+		"sent 7 2 2",
+		"disconnect 7",
+	}, rec.events)
+}
@@ -14,6 +14,8 @@
 package v3
 
 import (
+	"sync"
+
 	envoy_types "github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	envoy_cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	envoy_log "github.com/envoyproxy/go-control-plane/pkg/log"
@@ -21,7 +23,7 @@ import (
 	"github.com/projectcontour/contour/internal/xdscache"
 )
 
-var Hash = xds.ConstantHashV3{}
+var Hash = xds.GroupHashV3{}
 
 // Snapshotter is a v3 Snapshot cache that implements the xds.Snapshotter interface.
 type Snapshotter interface {
@@ -31,25 +33,71 @@ type Snapshotter interface {
 
 type snapshotter struct {
 	envoy_cache_v3.SnapshotCache
+
+	mu sync.Mutex
+
+	// groups holds the node IDs of the Envoy groups that have a
+	// snapshot of their own.
+	groups map[string]bool
+
+	// fallback is the snapshot for Envoys that are not in a group,
+	// which is also used for Envoys in groups without a snapshot.
+	fallback *envoy_cache_v3.Snapshot
+}
+
+func (s *snapshotter) Generate(group string, versions map[envoy_types.ResponseType]string, resources map[envoy_types.ResponseType][]envoy_types.Resource) error {
+	// Create a snapshot with all xDS resources. Each resource type
+	// has its own version, so that Envoy is only sent the resource
+	// types that changed.
+	snapshot := envoy_cache_v3.Snapshot{}
+	for typ, items := range resources {
+		snapshot.Resources[typ] = envoy_cache_v3.NewResources(versions[typ], items)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if group != "" {
+		s.groups[Hash.Group(group)] = true
+		return s.SetSnapshot(Hash.Group(group), snapshot)
+	}
+
+	s.fallback = &snapshot
+
+	// Envoys in groups without a snapshot of their own are connected
+	// under the node ID of their group, so update those too.
+	for _, id := range s.GetStatusKeys() {
+		if id == Hash.Group("") || s.groups[id] {
+			continue
+		}
+		if err := s.SetSnapshot(id, snapshot); err != nil {
+			return err
+		}
+	}
+
+	return s.SetSnapshot(Hash.Group(""), snapshot)
 }
 
-func (s *snapshotter) Generate(version string, resources map[envoy_types.ResponseType][]envoy_types.Resource) error {
-	// Create a snapshot with all xDS resources.
-	snapshot := envoy_cache_v3.NewSnapshot(
-		version,
-		resources[envoy_types.Endpoint],
-		resources[envoy_types.Cluster],
-		resources[envoy_types.Route],
-		resources[envoy_types.Listener],
-		nil,
-		resources[envoy_types.Secret],
-	)
-
-	return s.SetSnapshot(Hash.String(), snapshot)
+// CreateWatch returns a watch for an xDS request. Envoys in a group
+// that does not have a snapshot of its own are given the snapshot of
+// the Envoys that are not in a group.
+func (s *snapshotter) CreateWatch(req *envoy_cache_v3.Request) (chan envoy_cache_v3.Response, func()) {
+	s.mu.Lock()
+	if id := Hash.ID(req.GetNode()); id != Hash.Group("") && !s.groups[id] && s.fallback != nil {
+		if _, err := s.GetSnapshot(id); err != nil {
+			// Setting a snapshot does not fail, and the watch
+			// below waits for one if it did.
+			_ = s.SetSnapshot(id, *s.fallback)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.SnapshotCache.CreateWatch(req)
 }
 
 func NewSnapshotCache(ads bool, logger envoy_log.Logger) Snapshotter {
 	return &snapshotter{
 		SnapshotCache: envoy_cache_v3.NewSnapshotCache(ads, &Hash, logger),
+		groups:        map[string]bool{},
 	}
 }
@@ -16,17 +16,25 @@ package xdscache
 import (
 	"math"
 	"reflect"
+	"sort"
 	"strconv"
 	"sync"
 
 	envoy_types "github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/contour"
 	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/xds"
 	"github.com/sirupsen/logrus"
 )
 
 type Snapshotter interface {
-	Generate(version string, resources map[envoy_types.ResponseType][]envoy_types.Resource) error
+	// Generate sets the contents of each resource type for the Envoys
+	// in the given group, with the version of each resource type. The
+	// empty group holds the contents for Envoys that are not in a
+	// group, or in a group without contents of its own.
+	Generate(group string, versions map[envoy_types.ResponseType]string, resources map[envoy_types.ResponseType][]envoy_types.Resource) error
 }
 
 // SnapshotHandler implements the xDS snapshot cache
@@ -39,9 +47,18 @@ type SnapshotHandler struct {
 	// snapshotVersion holds the current version of the snapshot.
 	snapshotVersion int64
 
+	// contents and versions hold the contents and version of each
+	// resource type in the last snapshot of each Envoy group.
+	contents map[string]map[envoy_types.ResponseType][]envoy_types.Resource
+	versions map[string]map[envoy_types.ResponseType]string
+
 	snapshotters []Snapshotter
 	snapLock     sync.Mutex
 
+	// Propagation, if not nil, is told which resource types
+	// changed when the DAG is rebuilt.
+	Propagation *contour.PropagationTracker
+
 	logrus.FieldLogger
 }
 
@@ -49,6 +66,8 @@ type SnapshotHandler struct {
 func NewSnapshotHandler(resources []ResourceCache, logger logrus.FieldLogger) *SnapshotHandler {
 	return &SnapshotHandler{
 		resources:   parseResources(resources),
+		contents:    map[string]map[envoy_types.ResponseType][]envoy_types.Resource{},
+		versions:    map[string]map[envoy_types.ResponseType]string{},
 		FieldLogger: logger,
 	}
 }
@@ -63,36 +82,138 @@ func (s *SnapshotHandler) AddSnapshotter(snap Snapshotter) {
 // Refresh is called when the EndpointsTranslator updates values
 // in its cache.
 func (s *SnapshotHandler) Refresh() {
-	s.generateNewSnapshot()
+	s.generateNewSnapshot(false, envoy_types.Endpoint)
+}
+
+// RefreshSecrets is called when the secrets are updated without
+// a DAG rebuild.
+func (s *SnapshotHandler) RefreshSecrets() {
+	s.generateNewSnapshot(false, envoy_types.Secret)
+}
+
+// allTypes holds every resource type in a snapshot.
+var allTypes = []envoy_types.ResponseType{
+	envoy_types.Endpoint,
+	envoy_types.Cluster,
+	envoy_types.Route,
+	envoy_types.Listener,
+	envoy_types.Secret,
 }
 
 // OnChange is called when the DAG is rebuilt and a new snapshot is needed.
 func (s *SnapshotHandler) OnChange(root *dag.DAG) {
-	s.generateNewSnapshot()
+	s.generateNewSnapshot(true, allTypes...)
 }
 
-// generateNewSnapshot creates a new snapshot against
-// the Contour XDS caches.
-func (s *SnapshotHandler) generateNewSnapshot() {
+// generateNewSnapshot creates a new snapshot against the Contour
+// XDS caches for each Envoy group. Only the contents of the given
+// resource types are regenerated, and only the resource types whose
+// contents changed are given a new version. If rebuilt is true, the
+// snapshot is for a DAG rebuild, and the resource types that changed
+// are recorded before the snapshot is sent.
+func (s *SnapshotHandler) generateNewSnapshot(rebuilt bool, types ...envoy_types.ResponseType) {
+	s.snapLock.Lock()
+	defer s.snapLock.Unlock()
+
 	// Generate new snapshot version.
 	version := s.newSnapshotVersion()
 
-	resources := map[envoy_types.ResponseType][]envoy_types.Resource{
-		envoy_types.Endpoint: asResources(s.resources[envoy_types.Endpoint].Contents()),
-		envoy_types.Cluster:  asResources(s.resources[envoy_types.Cluster].Contents()),
-		envoy_types.Route:    asResources(s.resources[envoy_types.Route].Contents()),
-		envoy_types.Listener: asResources(s.resources[envoy_types.Listener].Contents()),
-		envoy_types.Secret:   asResources(s.resources[envoy_types.Secret].Contents()),
+	groups := s.groups()
+	changed := map[envoy_types.ResponseType]bool{}
+
+	for _, group := range groups {
+		regenerate := types
+		if _, ok := s.contents[group]; !ok {
+			// A new group needs the contents of every type.
+			s.contents[group] = map[envoy_types.ResponseType][]envoy_types.Resource{}
+			s.versions[group] = map[envoy_types.ResponseType]string{}
+			regenerate = allTypes
+		}
+
+		groupContents := s.contents[group]
+		groupVersions := s.versions[group]
+
+		for _, typ := range regenerate {
+			r, ok := s.resources[typ]
+			if !ok {
+				continue
+			}
+
+			contents := asResources(xds.ContentsFor(r, group))
+
+			if _, ok := groupVersions[typ]; ok && sameResources(groupContents[typ], contents) {
+				continue
+			}
+
+			groupContents[typ] = contents
+			groupVersions[typ] = version
+			changed[typ] = true
+		}
 	}
 
-	s.snapLock.Lock()
-	defer s.snapLock.Unlock()
+	if rebuilt && s.Propagation != nil {
+		var typeURLs []string
+		for typ := range changed {
+			typeURLs = append(typeURLs, s.resources[typ].TypeURL())
+		}
+		s.Propagation.Changed(typeURLs...)
+	}
+
+	for _, group := range groups {
+		groupContents := s.contents[group]
+		groupVersions := s.versions[group]
+
+		resources := make(map[envoy_types.ResponseType][]envoy_types.Resource, len(groupContents))
+		versions := make(map[envoy_types.ResponseType]string, len(groupVersions))
+		for typ, contents := range groupContents {
+			resources[typ] = contents
+			versions[typ] = groupVersions[typ]
+		}
+
+		for _, snap := range s.snapshotters {
+			if err := snap.Generate(group, versions, resources); err != nil {
+				s.Errorf("failed to generate snapshot version %q: %s", version, err)
+			}
+		}
+	}
+}
+
+// groups returns the Envoy groups that need a snapshot. Groups that
+// are no longer used keep a snapshot, since Envoys in the group may
+// still be connected.
+func (s *SnapshotHandler) groups() []string {
+	groups := map[string]bool{"": true}
+	for group := range s.contents {
+		groups[group] = true
+	}
+	for _, r := range s.resources {
+		if g, ok := r.(xds.GroupedResource); ok {
+			for _, group := range g.Groups() {
+				groups[group] = true
+			}
+		}
+	}
 
-	for _, snap := range s.snapshotters {
-		if err := snap.Generate(version, resources); err != nil {
-			s.Errorf("failed to generate snapshot version %q: %s", version, err)
+	names := make([]string, 0, len(groups))
+	for group := range groups {
+		names = append(names, group)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sameResources returns true if a and b hold equal resources in
+// the same order.
+func sameResources(a, b []envoy_types.Resource) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] && !proto.Equal(a[i], b[i]) {
+			return false
 		}
 	}
+	return true
 }
 
 // newSnapshotVersion increments the current snapshotVersion
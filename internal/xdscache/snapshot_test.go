@@ -17,6 +17,12 @@ import (
 	"math"
 	"testing"
 
+	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_types "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -55,3 +61,83 @@ func TestGetNewSnapshotVersion(t *testing.T) {
 		want:            "1",
 	})
 }
+
+// groupedCache is a fake grouped ResourceCache of listeners.
+type groupedCache struct {
+	contents map[string][]proto.Message
+}
+
+func (g *groupedCache) OnChange(*dag.DAG)                           {}
+func (g *groupedCache) Contents() []proto.Message                   { return g.contents[""] }
+func (g *groupedCache) Query([]string) []proto.Message              { return nil }
+func (g *groupedCache) Register(chan int, int, ...string)           {}
+func (g *groupedCache) TypeURL() string                             { return resource.ListenerType }
+func (g *groupedCache) GroupQuery(string, []string) []proto.Message { return nil }
+
+func (g *groupedCache) Groups() []string {
+	var groups []string
+	for group := range g.contents {
+		if group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func (g *groupedCache) GroupContents(group string) []proto.Message {
+	if contents, ok := g.contents[group]; ok {
+		return contents
+	}
+	return g.contents[""]
+}
+
+// snapshotRecorder is a fake Snapshotter that records the last
+// snapshot of each group.
+type snapshotRecorder struct {
+	versions  map[string]map[envoy_types.ResponseType]string
+	resources map[string]map[envoy_types.ResponseType][]envoy_types.Resource
+}
+
+func (s *snapshotRecorder) Generate(group string, versions map[envoy_types.ResponseType]string, resources map[envoy_types.ResponseType][]envoy_types.Resource) error {
+	s.versions[group] = versions
+	s.resources[group] = resources
+	return nil
+}
+
+func TestSnapshotHandlerGroups(t *testing.T) {
+	public := &envoy_listener_v3.Listener{Name: "ingress_http"}
+	internal := &envoy_listener_v3.Listener{Name: "ingress_https"}
+
+	listeners := &groupedCache{
+		contents: map[string][]proto.Message{
+			"": {public},
+		},
+	}
+
+	rec := &snapshotRecorder{
+		versions:  map[string]map[envoy_types.ResponseType]string{},
+		resources: map[string]map[envoy_types.ResponseType][]envoy_types.Resource{},
+	}
+
+	sh := NewSnapshotHandler([]ResourceCache{listeners}, fixture.NewTestLogger(t))
+	sh.AddSnapshotter(rec)
+
+	sh.OnChange(nil)
+	assert.Equal(t, []envoy_types.Resource{public}, rec.resources[""][envoy_types.Listener])
+	assert.Equal(t, "1", rec.versions[""][envoy_types.Listener])
+
+	// A new group gets a snapshot of its own.
+	listeners.contents["internal"] = []proto.Message{internal}
+	sh.OnChange(nil)
+	assert.Equal(t, []envoy_types.Resource{public}, rec.resources[""][envoy_types.Listener])
+	assert.Equal(t, "1", rec.versions[""][envoy_types.Listener])
+	assert.Equal(t, []envoy_types.Resource{internal}, rec.resources["internal"][envoy_types.Listener])
+	assert.Equal(t, "2", rec.versions["internal"][envoy_types.Listener])
+
+	// A group that is no longer used is given the contents of the
+	// Envoys that are not in a group.
+	delete(listeners.contents, "internal")
+	sh.OnChange(nil)
+	assert.Equal(t, []envoy_types.Resource{public}, rec.resources["internal"][envoy_types.Listener])
+	assert.Equal(t, "3", rec.versions["internal"][envoy_types.Listener])
+}
@@ -26,22 +26,48 @@ import (
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/sorter"
+	"github.com/projectcontour/contour/internal/xds"
 )
 
 // ClusterCache manages the contents of the gRPC CDS cache.
 type ClusterCache struct {
-	mu     sync.Mutex
-	values map[string]*envoy_cluster_v3.Cluster
+	mu       sync.Mutex
+	values   map[string]*envoy_cluster_v3.Cluster
+	versions map[string]string
 	contour.Cond
 }
 
 // Update replaces the contents of the cache with the supplied map.
+// Waiters are only notified if the contents changed.
 func (c *ClusterCache) Update(v map[string]*envoy_cluster_v3.Cluster) {
+	versions := make(map[string]string, len(v))
+	for name, cluster := range v {
+		versions[name] = xds.VersionOf(cluster)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	changed := c.values == nil || !xds.SameVersions(c.versions, versions)
+
 	c.values = v
-	c.Cond.Notify()
+	c.versions = versions
+
+	if changed {
+		c.Cond.Notify()
+	}
+}
+
+// Versions returns the version of each cluster, by name.
+func (c *ClusterCache) Versions() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	versions := make(map[string]string, len(c.versions))
+	for name, v := range c.versions {
+		versions[name] = v
+	}
+	return versions
 }
 
 // Contents returns a copy of the cache's contents.
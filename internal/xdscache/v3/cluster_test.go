@@ -24,6 +24,7 @@ import (
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -842,3 +843,43 @@ func clustermap(clusters ...*envoy_cluster_v3.Cluster) map[string]*envoy_cluster
 	}
 	return m
 }
+
+func TestClusterCacheVersions(t *testing.T) {
+	kuard := &envoy_cluster_v3.Cluster{
+		Name:                 "default/kuard/443/da39a3ee5e",
+		AltStatName:          "default_kuard_443",
+		ClusterDiscoveryType: envoy_v3.ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+		EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+			EdsConfig:   envoy_v3.ConfigSource("contour"),
+			ServiceName: "default/kuard",
+		},
+	}
+
+	var cc ClusterCache
+	ch := make(chan int, 1)
+	cc.Register(ch, 0)
+
+	cc.Update(clustermap(kuard))
+	before := cc.Versions()
+	assert.Len(t, before, 1)
+	assert.NotEmpty(t, before[kuard.Name])
+	<-ch
+
+	// Updating with the same clusters keeps the versions, and does
+	// not notify.
+	cc.Register(ch, 1)
+	cc.Update(clustermap(proto.Clone(kuard).(*envoy_cluster_v3.Cluster)))
+	assert.Equal(t, before, cc.Versions())
+	select {
+	case <-ch:
+		t.Fatal("unexpected notification")
+	default:
+	}
+
+	// Changing a cluster changes its version.
+	changed := proto.Clone(kuard).(*envoy_cluster_v3.Cluster)
+	changed.AltStatName = "default_kuard_8443"
+	cc.Update(clustermap(changed))
+	assert.NotEqual(t, before[kuard.Name], cc.Versions()[kuard.Name])
+	<-ch
+}
@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
@@ -27,6 +28,7 @@ import (
 	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/sorter"
+	"github.com/projectcontour/contour/internal/xds"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -225,6 +227,7 @@ func NewEndpointsTranslator(log logrus.FieldLogger) *EndpointsTranslator {
 		Cond:        contour.Cond{},
 		FieldLogger: log,
 		entries:     map[string]*envoy_endpoint_v3.ClusterLoadAssignment{},
+		versions:    map[string]string{},
 		cache: EndpointsCache{
 			stale:     nil,
 			services:  map[types.NamespacedName][]*dag.ServiceCluster{},
@@ -239,24 +242,47 @@ type EndpointsTranslator struct {
 	// Observer notifies when the endpoints cache has been updated.
 	Observer contour.Observer
 
+	// Propagation, if not nil, is told when changes to Endpoints
+	// change the cluster load assignments.
+	Propagation *contour.PropagationTracker
+
 	contour.Cond
 	logrus.FieldLogger
 
 	cache EndpointsCache
 
-	mu      sync.Mutex // Protects entries.
-	entries map[string]*envoy_endpoint_v3.ClusterLoadAssignment
+	mu       sync.Mutex // Protects entries and versions.
+	entries  map[string]*envoy_endpoint_v3.ClusterLoadAssignment
+	versions map[string]string
 }
 
 // Merge combines the given entries with the existing entries in the
 // EndpointsTranslator. If the same key exists in both maps, an existing entry
-// is replaced.
-func (e *EndpointsTranslator) Merge(entries map[string]*envoy_endpoint_v3.ClusterLoadAssignment) {
+// is replaced. Merge returns true if any entry changed.
+func (e *EndpointsTranslator) Merge(entries map[string]*envoy_endpoint_v3.ClusterLoadAssignment) bool {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	changed := false
 	for k, v := range entries {
+		if old, ok := e.entries[k]; ok && proto.Equal(old, v) {
+			continue
+		}
 		e.entries[k] = v
+		e.versions[k] = xds.VersionOf(v)
+		changed = true
+	}
+	return changed
+}
+
+// updated records the propagation of a change to Endpoints that
+// was received at the given time.
+func (e *EndpointsTranslator) updated(received time.Time) {
+	if e.Propagation != nil {
+		e.Propagation.Updated(resource.EndpointType, []contour.PropagationEvent{{
+			Kind:     "Endpoints",
+			Received: received,
+		}})
 	}
 }
 
@@ -303,6 +329,10 @@ func (e *EndpointsTranslator) OnChange(d *dag.DAG) {
 	e.mu.Lock()
 	if !equal(e.entries, entries) {
 		e.entries = entries
+		e.versions = make(map[string]string, len(entries))
+		for k, v := range entries {
+			e.versions[k] = xds.VersionOf(v)
+		}
 		changed = true
 	}
 	e.mu.Unlock()
@@ -338,8 +368,11 @@ func equal(a, b map[string]*envoy_endpoint_v3.ClusterLoadAssignment) bool {
 func (e *EndpointsTranslator) OnAdd(obj interface{}) {
 	switch obj := obj.(type) {
 	case *v1.Endpoints:
+		received := time.Now()
 		e.cache.UpdateEndpoint(obj)
-		e.Merge(e.cache.Recalculate())
+		if e.Merge(e.cache.Recalculate()) {
+			e.updated(received)
+		}
 		e.Notify()
 		if e.Observer != nil {
 			e.Observer.Refresh()
@@ -371,8 +404,11 @@ func (e *EndpointsTranslator) OnUpdate(oldObj, newObj interface{}) {
 			return
 		}
 
+		received := time.Now()
 		e.cache.UpdateEndpoint(newObj)
-		e.Merge(e.cache.Recalculate())
+		if e.Merge(e.cache.Recalculate()) {
+			e.updated(received)
+		}
 		e.Notify()
 		if e.Observer != nil {
 			e.Observer.Refresh()
@@ -385,8 +421,11 @@ func (e *EndpointsTranslator) OnUpdate(oldObj, newObj interface{}) {
 func (e *EndpointsTranslator) OnDelete(obj interface{}) {
 	switch obj := obj.(type) {
 	case *v1.Endpoints:
+		received := time.Now()
 		e.cache.DeleteEndpoint(obj)
-		e.Merge(e.cache.Recalculate())
+		if e.Merge(e.cache.Recalculate()) {
+			e.updated(received)
+		}
 		e.Notify()
 		if e.Observer != nil {
 			e.Observer.Refresh()
@@ -432,4 +471,16 @@ func (e *EndpointsTranslator) Query(names []string) []proto.Message {
 	return protobuf.AsMessages(values)
 }
 
+// Versions returns the version of each cluster load assignment, by name.
+func (e *EndpointsTranslator) Versions() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	versions := make(map[string]string, len(e.versions))
+	for name, v := range e.versions {
+		versions[name] = v
+	}
+	return versions
+}
+
 func (*EndpointsTranslator) TypeURL() string { return resource.EndpointType }
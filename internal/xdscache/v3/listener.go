@@ -38,6 +38,7 @@ const (
 	ENVOY_HTTP_LISTENER            = "ingress_http"
 	ENVOY_FALLBACK_ROUTECONFIG     = "ingress_fallbackcert"
 	ENVOY_HTTPS_LISTENER           = "ingress_https"
+	ENVOY_HTTP3_LISTENER           = "ingress_http3"
 	DEFAULT_HTTP_ACCESS_LOG        = "/dev/stdout"
 	DEFAULT_HTTP_LISTENER_ADDRESS  = "0.0.0.0"
 	DEFAULT_HTTP_LISTENER_PORT     = 8080
@@ -100,6 +101,10 @@ type ListenerConfig struct {
 	// RequestTimeout configures the request_timeout for all Connection Managers.
 	RequestTimeout timeout.Setting
 
+	// RequestHeadersTimeout configures the request_headers_timeout for all
+	// Connection Managers.
+	RequestHeadersTimeout timeout.Setting
+
 	// ConnectionIdleTimeout configures the common_http_protocol_options.idle_timeout for all
 	// Connection Managers.
 	ConnectionIdleTimeout timeout.Setting
@@ -117,6 +122,78 @@ type ListenerConfig struct {
 	// AllowChunkedLength enables setting allow_chunked_length on the HTTP1 options for all
 	// listeners.
 	AllowChunkedLength bool
+
+	// Listeners are the listeners, in addition to the default HTTP
+	// and HTTPS listeners, that virtual hosts may choose by name.
+	Listeners []ExtraListener
+}
+
+// ExtraListener holds the configuration of an Envoy listener in
+// addition to the default HTTP and HTTPS listeners.
+type ExtraListener struct {
+	// Name is the name of the listener, which virtual hosts
+	// choose it by.
+	Name string
+
+	// Address is the listener address.
+	// If not set, defaults to DEFAULT_HTTP_LISTENER_ADDRESS.
+	Address string
+
+	// Port is the listener port.
+	Port int
+
+	// Protocol is the protocol the listener serves.
+	Protocol config.ListenerProtocol
+
+	// UseProxyProto configures the listener to expect a PROXY
+	// V1 or V2 preamble.
+	UseProxyProto bool
+
+	// AccessLog is the access log path of the listener.
+	// If not set, defaults to DEFAULT_HTTP_ACCESS_LOG.
+	AccessLog string
+
+	// HTTPVersions are the HTTP versions the listener accepts.
+	// If not set, ListenerConfig.DefaultHTTPVersions are accepted.
+	HTTPVersions []envoy_v3.HTTPVersionType
+}
+
+// address returns the listener address or
+// DEFAULT_HTTP_LISTENER_ADDRESS if not configured.
+func (l *ExtraListener) address() string {
+	if l.Address != "" {
+		return l.Address
+	}
+	return DEFAULT_HTTP_LISTENER_ADDRESS
+}
+
+// accessLog returns the access log path of the listener or
+// DEFAULT_HTTP_ACCESS_LOG if not configured.
+func (l *ExtraListener) accessLog() string {
+	if l.AccessLog != "" {
+		return l.AccessLog
+	}
+	return DEFAULT_HTTP_ACCESS_LOG
+}
+
+// httpVersions returns the HTTP versions of the listener, or the
+// given default versions if not configured.
+func (l *ExtraListener) httpVersions(def []envoy_v3.HTTPVersionType) []envoy_v3.HTTPVersionType {
+	if len(l.HTTPVersions) > 0 {
+		return l.HTTPVersions
+	}
+	return def
+}
+
+// extraListener returns the extra listener with the given name, or
+// nil if there is none.
+func (lvc *ListenerConfig) extraListener(name string) *ExtraListener {
+	for i := range lvc.Listeners {
+		if lvc.Listeners[i].Name == name {
+			return &lvc.Listeners[i]
+		}
+	}
+	return nil
 }
 
 // httpAddress returns the port for the HTTP (non TLS)
@@ -192,21 +269,52 @@ func (lvc *ListenerConfig) accesslogFields() config.AccessLogFields {
 }
 
 func (lvc *ListenerConfig) newInsecureAccessLog() []*envoy_accesslog_v3.AccessLog {
+	return lvc.newAccessLog(lvc.httpAccessLog())
+}
+
+func (lvc *ListenerConfig) newSecureAccessLog() []*envoy_accesslog_v3.AccessLog {
+	return lvc.newAccessLog(lvc.httpsAccessLog())
+}
+
+// newAccessLog returns an access log of the configured type that
+// is written to path.
+func (lvc *ListenerConfig) newAccessLog(path string) []*envoy_accesslog_v3.AccessLog {
 	switch lvc.accesslogType() {
 	case string(config.JSONAccessLog):
-		return envoy_v3.FileAccessLogJSON(lvc.httpAccessLog(), lvc.accesslogFields())
+		return envoy_v3.FileAccessLogJSON(path, lvc.accesslogFields())
 	default:
-		return envoy_v3.FileAccessLogEnvoy(lvc.httpAccessLog())
+		return envoy_v3.FileAccessLogEnvoy(path)
 	}
 }
 
-func (lvc *ListenerConfig) newSecureAccessLog() []*envoy_accesslog_v3.AccessLog {
-	switch lvc.accesslogType() {
-	case "json":
-		return envoy_v3.FileAccessLogJSON(lvc.httpsAccessLog(), lvc.accesslogFields())
-	default:
-		return envoy_v3.FileAccessLogEnvoy(lvc.httpsAccessLog())
+// newHTTPConnectionManager returns the connection manager of a plain
+// HTTP listener, which uses the route configuration of the same name.
+func (v *listenerVisitor) newHTTPConnectionManager(name string, accessLog []*envoy_accesslog_v3.AccessLog, versions []envoy_v3.HTTPVersionType) *envoy_listener_v3.Filter {
+	return envoy_v3.HTTPConnectionManagerBuilder().
+		Codec(envoy_v3.CodecForVersions(versions...)).
+		DefaultFilters().
+		AddFilter(bufferFilter(v.buffered[name])).
+		RouteConfigName(name).
+		MetricsPrefix(name).
+		AccessLoggers(accessLog).
+		RequestTimeout(v.RequestTimeout).
+		RequestHeadersTimeout(v.RequestHeadersTimeout).
+		ConnectionIdleTimeout(v.ConnectionIdleTimeout).
+		StreamIdleTimeout(v.StreamIdleTimeout).
+		MaxConnectionDuration(v.MaxConnectionDuration).
+		ConnectionShutdownGracePeriod(v.ConnectionShutdownGracePeriod).
+		AllowChunkedLength(v.AllowChunkedLength).
+		ErrorPages(v.errorPages...).
+		Get()
+}
+
+// bufferFilter returns the buffer filter for the connection manager
+// of a route configuration that has buffered routes, and nil otherwise.
+func bufferFilter(buffered bool) *http.HttpFilter {
+	if !buffered {
+		return nil
 	}
+	return envoy_v3.FilterBuffer()
 }
 
 // minTLSVersion returns the requested minimum TLS protocol
@@ -223,6 +331,7 @@ func (lvc *ListenerConfig) minTLSVersion() envoy_tls_v3.TlsParameters_TlsProtoco
 type ListenerCache struct {
 	mu           sync.Mutex
 	values       map[string]*envoy_listener_v3.Listener
+	groups       map[string]map[string]*envoy_listener_v3.Listener
 	staticValues map[string]*envoy_listener_v3.Listener
 
 	Config ListenerConfig
@@ -242,19 +351,40 @@ func NewListenerCache(config ListenerConfig, address string, port int) *Listener
 
 // Update replaces the contents of the cache with the supplied map.
 func (c *ListenerCache) Update(v map[string]*envoy_listener_v3.Listener) {
+	c.update(v, nil)
+}
+
+// update replaces the contents of the cache with the supplied maps,
+// for Envoys that are not in a group, and for each Envoy group.
+func (c *ListenerCache) update(v map[string]*envoy_listener_v3.Listener, groups map[string]map[string]*envoy_listener_v3.Listener) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.values = v
+	c.groups = groups
 	c.Cond.Notify()
 }
 
+// valuesFor returns the listeners for Envoys in the given group.
+func (c *ListenerCache) valuesFor(group string) map[string]*envoy_listener_v3.Listener {
+	if v, ok := c.groups[group]; ok {
+		return v
+	}
+	return c.values
+}
+
 // Contents returns a copy of the cache's contents.
 func (c *ListenerCache) Contents() []proto.Message {
+	return c.GroupContents("")
+}
+
+// GroupContents returns a copy of the cache's contents for Envoys
+// in the given group.
+func (c *ListenerCache) GroupContents(group string) []proto.Message {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	var values []*envoy_listener_v3.Listener
-	for _, v := range c.values {
+	for _, v := range c.valuesFor(group) {
 		values = append(values, v)
 	}
 	for _, v := range c.staticValues {
@@ -267,11 +397,18 @@ func (c *ListenerCache) Contents() []proto.Message {
 // Query returns the proto.Messages in the ListenerCache that match
 // a slice of strings
 func (c *ListenerCache) Query(names []string) []proto.Message {
+	return c.GroupQuery("", names)
+}
+
+// GroupQuery returns the proto.Messages in the ListenerCache that
+// match a slice of strings, for Envoys in the given group.
+func (c *ListenerCache) GroupQuery(group string, names []string) []proto.Message {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	var values []*envoy_listener_v3.Listener
+	values := c.valuesFor(group)
+	var listeners []*envoy_listener_v3.Listener
 	for _, n := range names {
-		v, ok := c.values[n]
+		v, ok := values[n]
 		if !ok {
 			v, ok = c.staticValues[n]
 			if !ok {
@@ -283,29 +420,73 @@ func (c *ListenerCache) Query(names []string) []proto.Message {
 				continue
 			}
 		}
-		values = append(values, v)
+		listeners = append(listeners, v)
 	}
-	sort.Stable(sorter.For(values))
-	return protobuf.AsMessages(values)
+	sort.Stable(sorter.For(listeners))
+	return protobuf.AsMessages(listeners)
+}
+
+// Groups returns the names of the Envoy groups that have their own
+// listeners.
+func (c *ListenerCache) Groups() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var groups []string
+	for group := range c.groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
 }
 
 func (*ListenerCache) TypeURL() string { return resource.ListenerType }
 
 func (c *ListenerCache) OnChange(root *dag.DAG) {
 	listeners := visitListeners(root, &c.Config)
-	c.Update(listeners)
+
+	groups := map[string]map[string]*envoy_listener_v3.Listener{}
+	for _, group := range root.GetEnvoyGroups() {
+		groups[group] = visitGroupListeners(root, &c.Config, group)
+	}
+
+	c.update(listeners, groups)
 }
 
 type listenerVisitor struct {
 	*ListenerConfig
 
+	// group is the Envoy group the listeners are built for.
+	group string
+
 	listeners map[string]*envoy_listener_v3.Listener
 	http      bool // at least one dag.VirtualHost encountered
+
+	// extraHTTP records the extra HTTP listeners that serve at
+	// least one dag.VirtualHost.
+	extraHTTP map[string]bool
+
+	// buffered records the route configurations that have at
+	// least one route with a buffer policy.
+	buffered map[string]bool
+
+	// errorPages are the error pages of the dag.Listeners, which
+	// apply to every connection manager.
+	errorPages []*dag.ErrorPage
 }
 
 func visitListeners(root dag.Vertex, lvc *ListenerConfig) map[string]*envoy_listener_v3.Listener {
+	return visitGroupListeners(root, lvc, "")
+}
+
+// visitGroupListeners returns the listeners for Envoys in the given
+// group, which serve only the virtual hosts served by the group.
+func visitGroupListeners(root dag.Vertex, lvc *ListenerConfig, group string) map[string]*envoy_listener_v3.Listener {
 	lv := listenerVisitor{
 		ListenerConfig: lvc,
+		group:          group,
+		extraHTTP:      map[string]bool{},
+		buffered:       bufferedRouteConfigs(root, group),
 		listeners: map[string]*envoy_listener_v3.Listener{
 			ENVOY_HTTPS_LISTENER: envoy_v3.Listener(
 				ENVOY_HTTPS_LISTENER,
@@ -320,19 +501,7 @@ func visitListeners(root dag.Vertex, lvc *ListenerConfig) map[string]*envoy_list
 
 	if lv.http {
 		// Add a listener if there are vhosts bound to http.
-		cm := envoy_v3.HTTPConnectionManagerBuilder().
-			Codec(envoy_v3.CodecForVersions(lv.DefaultHTTPVersions...)).
-			DefaultFilters().
-			RouteConfigName(ENVOY_HTTP_LISTENER).
-			MetricsPrefix(ENVOY_HTTP_LISTENER).
-			AccessLoggers(lvc.newInsecureAccessLog()).
-			RequestTimeout(lvc.RequestTimeout).
-			ConnectionIdleTimeout(lvc.ConnectionIdleTimeout).
-			StreamIdleTimeout(lvc.StreamIdleTimeout).
-			MaxConnectionDuration(lvc.MaxConnectionDuration).
-			ConnectionShutdownGracePeriod(lvc.ConnectionShutdownGracePeriod).
-			AllowChunkedLength(lvc.AllowChunkedLength).
-			Get()
+		cm := lv.newHTTPConnectionManager(ENVOY_HTTP_LISTENER, lvc.newInsecureAccessLog(), tcpHTTPVersions(lv.DefaultHTTPVersions))
 
 		lv.listeners[ENVOY_HTTP_LISTENER] = envoy_v3.Listener(
 			ENVOY_HTTP_LISTENER,
@@ -352,9 +521,106 @@ func visitListeners(root dag.Vertex, lvc *ListenerConfig) map[string]*envoy_list
 		sort.Stable(sorter.For(lv.listeners[ENVOY_HTTPS_LISTENER].FilterChains))
 	}
 
+	if listener, ok := lv.listeners[ENVOY_HTTP3_LISTENER]; ok {
+		sort.Stable(sorter.For(listener.FilterChains))
+	}
+
+	// Add the extra listeners that serve virtual hosts. HTTPS, TCP
+	// and UDP listeners were added while visiting their virtual hosts.
+	for i := range lvc.Listeners {
+		l := &lvc.Listeners[i]
+
+		if l.Protocol == config.HTTPListenerProtocol {
+			if lv.extraHTTP[l.Name] {
+				lv.listeners[l.Name] = envoy_v3.Listener(
+					l.Name,
+					l.address(),
+					l.Port,
+					proxyProtocol(l.UseProxyProto),
+					lv.newHTTPConnectionManager(l.Name, lvc.newAccessLog(l.accessLog()), tcpHTTPVersions(l.httpVersions(lvc.DefaultHTTPVersions))),
+				)
+			}
+			continue
+		}
+
+		if listener, ok := lv.listeners[l.Name]; ok {
+			sort.Stable(sorter.For(listener.FilterChains))
+		}
+	}
+
 	return lv.listeners
 }
 
+// tcpHTTPVersions returns the HTTP versions without HTTP/3, which
+// is only served by the QUIC listener.
+func tcpHTTPVersions(versions []envoy_v3.HTTPVersionType) []envoy_v3.HTTPVersionType {
+	var tcp []envoy_v3.HTTPVersionType
+	for _, v := range versions {
+		if v != envoy_v3.HTTPVersion3 {
+			tcp = append(tcp, v)
+		}
+	}
+	return tcp
+}
+
+// addHTTP3FilterChain adds a filter chain serving the secure virtual
+// host over HTTP/3 to the QUIC listener, which listens on the UDP
+// port of the same number as the HTTPS listener. The filter chain
+// uses the route configuration and certificate of the HTTPS filter
+// chain, but QUIC requires TLS 1.3.
+func (v *listenerVisitor) addHTTP3FilterChain(vh *dag.SecureVirtualHost) {
+	listener, ok := v.listeners[ENVOY_HTTP3_LISTENER]
+	if !ok {
+		listener = envoy_v3.QUICListener(
+			ENVOY_HTTP3_LISTENER,
+			v.ListenerConfig.httpsAddress(),
+			v.ListenerConfig.httpsPort(),
+		)
+		v.listeners[ENVOY_HTTP3_LISTENER] = listener
+	}
+
+	var authFilter *http.HttpFilter
+	if vh.AuthorizationService != nil {
+		authFilter = envoy_v3.FilterExternalAuthz(
+			vh.AuthorizationService.Name,
+			vh.AuthorizationFailOpen,
+			vh.AuthorizationResponseTimeout,
+			vh.MaxRequestBytes(),
+		)
+	}
+
+	filters := envoy_v3.Filters(
+		envoy_v3.HTTPConnectionManagerBuilder().
+			Codec(envoy_v3.HTTPVersion3).
+			AddFilter(envoy_v3.FilterMisdirectedRequests(vh.VirtualHost.Name)).
+			DefaultFilters().
+			AddFilter(bufferFilter(v.buffered[path.Join("https", vh.VirtualHost.Name)])).
+			AddFilter(authFilter).
+			RouteConfigName(path.Join("https", vh.VirtualHost.Name)).
+			MetricsPrefix(ENVOY_HTTP3_LISTENER).
+			AccessLoggers(v.ListenerConfig.newSecureAccessLog()).
+			RequestTimeout(v.ListenerConfig.RequestTimeout).
+			RequestHeadersTimeout(v.ListenerConfig.RequestHeadersTimeout).
+			ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
+			StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
+			MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
+			ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
+			HTTPPolicy(vh.HTTPPolicy).
+			ErrorPages(vh.ErrorPages...).
+			ErrorPages(v.errorPages...).
+			Get(),
+	)
+
+	downstreamTLS := envoy_v3.DownstreamTLSContext(
+		vh.Secret,
+		envoy_tls_v3.TlsParameters_TLSv1_3,
+		nil,
+		"h3")
+
+	listener.FilterChains = append(listener.FilterChains,
+		envoy_v3.FilterChainQUIC(vh.VirtualHost.Name, downstreamTLS, filters))
+}
+
 func proxyProtocol(useProxy bool) []*envoy_listener_v3.ListenerFilter {
 	if useProxy {
 		return envoy_v3.ListenerFilters(
@@ -377,12 +643,50 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 	}
 
 	switch vh := vertex.(type) {
+	case *dag.Listener:
+		v.errorPages = vh.ErrorPages
+		vertex.Visit(v.visit)
 	case *dag.VirtualHost:
 		// we only create on http listener so record the fact
 		// that we need to then double back at the end and add
 		// the listener properly.
-		v.http = true
+		if !vh.ServedBy(v.group) {
+			return
+		}
+		if vh.Listener == "" {
+			v.http = true
+		} else {
+			v.extraHTTP[vh.Listener] = true
+		}
 	case *dag.SecureVirtualHost:
+		if !vh.ServedBy(v.group) {
+			return
+		}
+
+		name := ENVOY_HTTPS_LISTENER
+		accessLog := v.ListenerConfig.newSecureAccessLog()
+		versions := tcpHTTPVersions(v.DefaultHTTPVersions)
+
+		if vh.Listener != "" {
+			l := v.ListenerConfig.extraListener(vh.Listener)
+			if l == nil {
+				return
+			}
+
+			name = l.Name
+			accessLog = v.ListenerConfig.newAccessLog(l.accessLog())
+			versions = tcpHTTPVersions(l.httpVersions(v.DefaultHTTPVersions))
+
+			if _, ok := v.listeners[name]; !ok {
+				v.listeners[name] = envoy_v3.Listener(
+					name,
+					l.address(),
+					l.Port,
+					secureProxyProtocol(l.UseProxyProto),
+				)
+			}
+		}
+
 		var alpnProtos []string
 		var filters []*envoy_listener_v3.Filter
 
@@ -394,6 +698,7 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 					vh.AuthorizationService.Name,
 					vh.AuthorizationFailOpen,
 					vh.AuthorizationResponseTimeout,
+					vh.MaxRequestBytes(),
 				)
 			}
 
@@ -406,28 +711,33 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 			// coded into monitoring dashboards.
 			filters = envoy_v3.Filters(
 				envoy_v3.HTTPConnectionManagerBuilder().
-					Codec(envoy_v3.CodecForVersions(v.DefaultHTTPVersions...)).
+					Codec(envoy_v3.CodecForVersions(versions...)).
 					AddFilter(envoy_v3.FilterMisdirectedRequests(vh.VirtualHost.Name)).
 					DefaultFilters().
+					AddFilter(bufferFilter(v.buffered[path.Join("https", vh.VirtualHost.Name)])).
 					AddFilter(authFilter).
 					RouteConfigName(path.Join("https", vh.VirtualHost.Name)).
-					MetricsPrefix(ENVOY_HTTPS_LISTENER).
-					AccessLoggers(v.ListenerConfig.newSecureAccessLog()).
+					MetricsPrefix(name).
+					AccessLoggers(accessLog).
 					RequestTimeout(v.ListenerConfig.RequestTimeout).
+					RequestHeadersTimeout(v.ListenerConfig.RequestHeadersTimeout).
 					ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
 					StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
 					MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
 					ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
 					AllowChunkedLength(v.ListenerConfig.AllowChunkedLength).
+					HTTPPolicy(vh.HTTPPolicy).
+					ErrorPages(vh.ErrorPages...).
+					ErrorPages(v.errorPages...).
 					Get(),
 			)
 
-			alpnProtos = envoy_v3.ProtoNamesForVersions(v.DefaultHTTPVersions...)
+			alpnProtos = envoy_v3.ProtoNamesForVersions(versions...)
 		} else {
 			filters = envoy_v3.Filters(
-				envoy_v3.TCPProxy(ENVOY_HTTPS_LISTENER,
+				envoy_v3.TCPProxy(name,
 					vh.TCPProxy,
-					v.ListenerConfig.newSecureAccessLog()),
+					accessLog),
 			)
 
 			// Do not offer ALPN for TCP proxying, since
@@ -447,11 +757,16 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 				vers,
 				vh.DownstreamValidation,
 				alpnProtos...)
+			downstreamTLS.OcspStaplePolicy = envoy_v3.ParseOCSPStaplePolicy(vh.OCSPStaplePolicy)
 		}
 
-		v.listeners[ENVOY_HTTPS_LISTENER].FilterChains = append(v.listeners[ENVOY_HTTPS_LISTENER].FilterChains,
+		v.listeners[name].FilterChains = append(v.listeners[name].FilterChains,
 			envoy_v3.FilterChainTLS(vh.VirtualHost.Name, downstreamTLS, filters))
 
+		if vh.HTTP3Port != 0 && vh.Secret != nil && vh.TCPProxy == nil && name == ENVOY_HTTPS_LISTENER {
+			v.addHTTP3FilterChain(vh)
+		}
+
 		// If this VirtualHost has enabled the fallback certificate then set a default
 		// FilterChain which will allow routes with this vhost to accept non-SNI TLS requests.
 		// Note that we don't add the misdirected requests filter on this chain because at this
@@ -470,15 +785,18 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 			filters = envoy_v3.Filters(
 				envoy_v3.HTTPConnectionManagerBuilder().
 					DefaultFilters().
+					AddFilter(bufferFilter(v.buffered[ENVOY_FALLBACK_ROUTECONFIG])).
 					RouteConfigName(ENVOY_FALLBACK_ROUTECONFIG).
 					MetricsPrefix(ENVOY_HTTPS_LISTENER).
 					AccessLoggers(v.ListenerConfig.newSecureAccessLog()).
 					RequestTimeout(v.ListenerConfig.RequestTimeout).
+					RequestHeadersTimeout(v.ListenerConfig.RequestHeadersTimeout).
 					ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
 					StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
 					MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
 					ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
 					AllowChunkedLength(v.ListenerConfig.AllowChunkedLength).
+					ErrorPages(v.errorPages...).
 					Get(),
 			)
 
@@ -486,6 +804,37 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 				envoy_v3.FilterChainTLSFallback(downstreamTLS, filters))
 		}
 
+	case *dag.ListenerProxy:
+		if !vh.ServedBy(v.group) {
+			return
+		}
+
+		l := v.ListenerConfig.extraListener(vh.Listener)
+		if l == nil {
+			return
+		}
+
+		switch l.Protocol {
+		case config.TCPListenerProtocol:
+			// Without TLS there is no need to inspect the
+			// connection, which would stall protocols where
+			// the server speaks first.
+			v.listeners[l.Name] = envoy_v3.Listener(
+				l.Name,
+				l.address(),
+				l.Port,
+				proxyProtocol(l.UseProxyProto),
+				envoy_v3.TCPProxy(l.Name, vh.TCPProxy, v.ListenerConfig.newAccessLog(l.accessLog())),
+			)
+		case config.UDPListenerProtocol:
+			v.listeners[l.Name] = envoy_v3.UDPListener(
+				l.Name,
+				l.address(),
+				l.Port,
+				envoy_v3.UDPProxy(l.Name, vh.TCPProxy),
+			)
+		}
+
 	default:
 		// recurse
 		vertex.Visit(v.visit)
@@ -25,8 +25,10 @@ import (
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/dag"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/timeout"
+	"github.com/projectcontour/contour/pkg/config"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -1983,6 +1985,637 @@ func TestListenerVisit(t *testing.T) {
 	}
 }
 
+func TestListenerVisitExtraListeners(t *testing.T) {
+	lvc := ListenerConfig{
+		Listeners: []ExtraListener{{
+			Name:     "internal",
+			Address:  "10.0.0.1",
+			Port:     8081,
+			Protocol: config.HTTPListenerProtocol,
+		}, {
+			Name:          "proxied",
+			Port:          8444,
+			Protocol:      config.HTTPSListenerProtocol,
+			UseProxyProto: true,
+			AccessLog:     "/tmp/proxied_access.log",
+			HTTPVersions:  []envoy_v3.HTTPVersionType{envoy_v3.HTTPVersion1},
+		}},
+	}
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{
+				Listeners: map[string]config.ListenerProtocol{
+					"internal": config.HTTPListenerProtocol,
+					"proxied":  config.HTTPSListenerProtocol,
+				},
+			},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	route := []contour_api_v1.Route{{
+		Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+		Services:   []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}},
+	}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/public").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "www.example.com"},
+			Routes:      route,
+		}),
+		fixture.NewProxy("default/internal").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "internal.example.com", Listener: "internal"},
+			Routes:      route,
+		}),
+		fixture.NewProxy("default/proxied").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn:     "proxied.example.com",
+				Listener: "proxied",
+				TLS:      &contour_api_v1.TLS{SecretName: "secret"},
+			},
+			Routes: route,
+		}),
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Type: "kubernetes.io/tls",
+			Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:     "http",
+					Protocol: "TCP",
+					Port:     80,
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	want := listenermap(&envoy_listener_v3.Listener{
+		Name:          ENVOY_HTTP_LISTENER,
+		Address:       envoy_v3.SocketAddress("0.0.0.0", 8080),
+		FilterChains:  envoy_v3.FilterChains(envoy_v3.HTTPConnectionManager(ENVOY_HTTP_LISTENER, envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG), 0)),
+		SocketOptions: envoy_v3.TCPKeepaliveSocketOptions(),
+	}, &envoy_listener_v3.Listener{
+		Name:          "internal",
+		Address:       envoy_v3.SocketAddress("10.0.0.1", 8081),
+		FilterChains:  envoy_v3.FilterChains(envoy_v3.HTTPConnectionManager("internal", envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG), 0)),
+		SocketOptions: envoy_v3.TCPKeepaliveSocketOptions(),
+	}, &envoy_listener_v3.Listener{
+		Name:    "proxied",
+		Address: envoy_v3.SocketAddress("0.0.0.0", 8444),
+		ListenerFilters: envoy_v3.ListenerFilters(
+			envoy_v3.ProxyProtocol(),
+			envoy_v3.TLSInspector(),
+		),
+		FilterChains: []*envoy_listener_v3.FilterChain{{
+			FilterChainMatch: &envoy_listener_v3.FilterChainMatch{
+				ServerNames: []string{"proxied.example.com"},
+			},
+			TransportSocket: transportSocket("secret", envoy_tls_v3.TlsParameters_TLSv1_2, "http/1.1"),
+			Filters: envoy_v3.Filters(envoy_v3.HTTPConnectionManagerBuilder().
+				Codec(envoy_v3.HTTPVersion1).
+				AddFilter(envoy_v3.FilterMisdirectedRequests("proxied.example.com")).
+				DefaultFilters().
+				MetricsPrefix("proxied").
+				RouteConfigName(path.Join("https", "proxied.example.com")).
+				AccessLoggers(envoy_v3.FileAccessLogEnvoy("/tmp/proxied_access.log")).
+				Get()),
+		}},
+		SocketOptions: envoy_v3.TCPKeepaliveSocketOptions(),
+	})
+
+	protobuf.ExpectEqual(t, want, visitListeners(builder.Build(), &lvc))
+}
+
+func TestListenerVisitTCPAndUDPListeners(t *testing.T) {
+	lvc := ListenerConfig{
+		Listeners: []ExtraListener{{
+			Name:     "postgres",
+			Port:     5432,
+			Protocol: config.TCPListenerProtocol,
+		}, {
+			Name:     "dns",
+			Port:     5353,
+			Protocol: config.UDPListenerProtocol,
+		}},
+	}
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{
+				Listeners: map[string]config.ListenerProtocol{
+					"postgres": config.TCPListenerProtocol,
+					"dns":      config.UDPListenerProtocol,
+				},
+			},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	backend := []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/postgres").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "postgres.example.com", Listener: "postgres"},
+			TCPProxy:    &contour_api_v1.TCPProxy{Services: backend},
+		}),
+		fixture.NewProxy("default/dns").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "dns.example.com", Listener: "dns"},
+			TCPProxy:    &contour_api_v1.TCPProxy{Services: backend},
+		}),
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:     "http",
+					Protocol: "TCP",
+					Port:     80,
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	cluster := &dag.TCPProxy{
+		Clusters: []*dag.Cluster{{
+			Upstream: &dag.Service{
+				Weighted: dag.WeightedService{
+					ServiceName:      "backend",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Name: "http", Protocol: "TCP", Port: 80},
+					Weight:           1,
+				},
+			},
+		}},
+	}
+
+	want := listenermap(&envoy_listener_v3.Listener{
+		Name:    "postgres",
+		Address: envoy_v3.SocketAddress("0.0.0.0", 5432),
+		FilterChains: envoy_v3.FilterChains(
+			envoy_v3.TCPProxy("postgres", cluster, envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG)),
+		),
+		SocketOptions: envoy_v3.TCPKeepaliveSocketOptions(),
+	}, &envoy_listener_v3.Listener{
+		Name: "dns",
+		Address: &envoy_core_v3.Address{
+			Address: &envoy_core_v3.Address_SocketAddress{
+				SocketAddress: &envoy_core_v3.SocketAddress{
+					Protocol: envoy_core_v3.SocketAddress_UDP,
+					Address:  "0.0.0.0",
+					PortSpecifier: &envoy_core_v3.SocketAddress_PortValue{
+						PortValue: 5353,
+					},
+				},
+			},
+		},
+		ListenerFilters: envoy_v3.ListenerFilters(
+			envoy_v3.UDPProxy("dns", cluster),
+		),
+	})
+
+	protobuf.ExpectEqual(t, want, visitListeners(builder.Build(), &lvc))
+}
+
+func TestListenerVisitHTTP3(t *testing.T) {
+	lvc := ListenerConfig{
+		DefaultHTTPVersions: []envoy_v3.HTTPVersionType{envoy_v3.HTTPVersion2, envoy_v3.HTTPVersion3},
+	}
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{HTTP3Port: 443},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	route := []contour_api_v1.Route{{
+		Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+		Services:   []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}},
+	}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/www").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "www.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret"},
+			},
+			Routes: route,
+		}),
+		fixture.NewProxy("default/legacy").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "legacy.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret", DisableHTTP3: true},
+			},
+			Routes: route,
+		}),
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Type: "kubernetes.io/tls",
+			Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:     "http",
+					Protocol: "TCP",
+					Port:     80,
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	got := visitListeners(builder.Build(), &lvc)
+
+	http3 := envoy_v3.QUICListener(ENVOY_HTTP3_LISTENER, "0.0.0.0", 8443)
+	http3.FilterChains = []*envoy_listener_v3.FilterChain{
+		envoy_v3.FilterChainQUIC("www.example.com",
+			envoy_v3.DownstreamTLSContext(&dag.Secret{Object: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "secret",
+					Namespace: "default",
+				},
+				Type: "kubernetes.io/tls",
+				Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+			}}, envoy_tls_v3.TlsParameters_TLSv1_3, nil, "h3"),
+			envoy_v3.Filters(envoy_v3.HTTPConnectionManagerBuilder().
+				Codec(envoy_v3.HTTPVersion3).
+				AddFilter(envoy_v3.FilterMisdirectedRequests("www.example.com")).
+				DefaultFilters().
+				MetricsPrefix(ENVOY_HTTP3_LISTENER).
+				RouteConfigName(path.Join("https", "www.example.com")).
+				AccessLoggers(envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG)).
+				Get()),
+		),
+	}
+	protobuf.ExpectEqual(t, http3, got[ENVOY_HTTP3_LISTENER])
+
+	// The HTTPS listener serves both virtual hosts, and does not
+	// offer HTTP/3.
+	if n := len(got[ENVOY_HTTPS_LISTENER].FilterChains); n != 2 {
+		t.Fatalf("expected 2 HTTPS filter chains, got %d", n)
+	}
+	for _, fc := range got[ENVOY_HTTPS_LISTENER].FilterChains {
+		protobuf.ExpectEqual(t, transportSocket("secret", envoy_tls_v3.TlsParameters_TLSv1_2, "h2"), fc.TransportSocket)
+	}
+}
+
+func TestListenerVisitHTTPPolicy(t *testing.T) {
+	lvc := ListenerConfig{
+		RequestTimeout:        timeout.DurationSetting(30 * time.Second),
+		RequestHeadersTimeout: timeout.DurationSetting(10 * time.Second),
+	}
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	route := []contour_api_v1.Route{{
+		Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+		Services:   []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}},
+	}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/upload").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "upload.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret"},
+				HTTPPolicy: &contour_api_v1.HTTPPolicy{
+					RequestTimeout: "infinity",
+					HTTP1: &contour_api_v1.HTTP1Policy{
+						ProperCaseHeaders: true,
+					},
+				},
+			},
+			Routes: route,
+		}),
+		fixture.NewProxy("default/api").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "api.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret"},
+			},
+			Routes: route,
+		}),
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Type: "kubernetes.io/tls",
+			Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:     "http",
+					Protocol: "TCP",
+					Port:     80,
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	got := visitListeners(builder.Build(), &lvc)
+
+	hcm := func(vhost string, policy *dag.HTTPPolicy) []*envoy_listener_v3.Filter {
+		return envoy_v3.Filters(envoy_v3.HTTPConnectionManagerBuilder().
+			AddFilter(envoy_v3.FilterMisdirectedRequests(vhost)).
+			DefaultFilters().
+			RouteConfigName(path.Join("https", vhost)).
+			MetricsPrefix(ENVOY_HTTPS_LISTENER).
+			AccessLoggers(envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTPS_ACCESS_LOG)).
+			RequestTimeout(timeout.DurationSetting(30 * time.Second)).
+			RequestHeadersTimeout(timeout.DurationSetting(10 * time.Second)).
+			HTTPPolicy(policy).
+			Get())
+	}
+
+	want := map[string][]*envoy_listener_v3.Filter{
+		"api.example.com": hcm("api.example.com", nil),
+		"upload.example.com": hcm("upload.example.com", &dag.HTTPPolicy{
+			RequestTimeout:    timeout.DisabledSetting(),
+			ProperCaseHeaders: true,
+		}),
+	}
+
+	chains := got[ENVOY_HTTPS_LISTENER].FilterChains
+	if len(chains) != len(want) {
+		t.Fatalf("expected %d HTTPS filter chains, got %d", len(want), len(chains))
+	}
+	for _, fc := range chains {
+		vhost := fc.FilterChainMatch.ServerNames[0]
+		protobuf.ExpectEqual(t, want[vhost], fc.Filters)
+	}
+}
+
+func TestListenerVisitBufferPolicy(t *testing.T) {
+	lvc := ListenerConfig{}
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	services := []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}}
+
+	routes := []contour_api_v1.Route{{
+		Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+		Services:   services,
+	}, {
+		Conditions:   []contour_api_v1.MatchCondition{{Prefix: "/upload"}},
+		Services:     services,
+		BufferPolicy: &contour_api_v1.BufferPolicy{MaxRequestBytes: 8192},
+	}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/upload").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "upload.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret"},
+			},
+			Routes: routes,
+		}),
+		fixture.NewProxy("default/api").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "api.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret"},
+			},
+			Routes: routes[:1],
+		}),
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Type: "kubernetes.io/tls",
+			Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:     "http",
+					Protocol: "TCP",
+					Port:     80,
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	got := visitListeners(builder.Build(), &lvc)
+
+	hcm := func(vhost string, buffered bool) []*envoy_listener_v3.Filter {
+		b := envoy_v3.HTTPConnectionManagerBuilder().
+			AddFilter(envoy_v3.FilterMisdirectedRequests(vhost)).
+			DefaultFilters()
+		if buffered {
+			b = b.AddFilter(envoy_v3.FilterBuffer())
+		}
+		return envoy_v3.Filters(b.
+			RouteConfigName(path.Join("https", vhost)).
+			MetricsPrefix(ENVOY_HTTPS_LISTENER).
+			AccessLoggers(envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTPS_ACCESS_LOG)).
+			Get())
+	}
+
+	want := map[string][]*envoy_listener_v3.Filter{
+		"api.example.com":    hcm("api.example.com", false),
+		"upload.example.com": hcm("upload.example.com", true),
+	}
+
+	chains := got[ENVOY_HTTPS_LISTENER].FilterChains
+	if len(chains) != len(want) {
+		t.Fatalf("expected %d HTTPS filter chains, got %d", len(want), len(chains))
+	}
+	for _, fc := range chains {
+		vhost := fc.FilterChainMatch.ServerNames[0]
+		protobuf.ExpectEqual(t, want[vhost], fc.Filters)
+	}
+
+	// The insecure virtual hosts only upgrade to HTTPS, so
+	// the HTTP listener does not buffer.
+	protobuf.ExpectEqual(t,
+		envoy_v3.Filters(envoy_v3.HTTPConnectionManagerBuilder().
+			DefaultFilters().
+			RouteConfigName(ENVOY_HTTP_LISTENER).
+			MetricsPrefix(ENVOY_HTTP_LISTENER).
+			AccessLoggers(envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG)).
+			Get()),
+		got[ENVOY_HTTP_LISTENER].FilterChains[0].Filters,
+	)
+}
+
+func TestListenerVisitErrorPages(t *testing.T) {
+	lvc := ListenerConfig{}
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{
+				FieldLogger: fixture.NewTestLogger(t),
+				ErrorPages: []config.ErrorPageParameters{{
+					StatusCodes: []uint32{503},
+					ConfigMap:   config.NamespacedName{Name: "global", Namespace: "projectcontour"},
+					Key:         "unavailable.txt",
+				}},
+			},
+		},
+	}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/app").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "app.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret"},
+				ErrorPagePolicy: &contour_api_v1.ErrorPagePolicy{
+					Pages: []contour_api_v1.ErrorPage{{
+						StatusCodes:        []uint32{404},
+						ConfigMap:          "pages",
+						Key:                "404.html",
+						ContentType:        "text/html",
+						ResponseStatusCode: 200,
+					}},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}},
+			}},
+		}),
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pages",
+				Namespace: "default",
+			},
+			Data: map[string]string{"404.html": "<h1>not found</h1>"},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "global",
+				Namespace: "projectcontour",
+			},
+			Data: map[string]string{"unavailable.txt": "unavailable"},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Type: "kubernetes.io/tls",
+			Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:     "http",
+					Protocol: "TCP",
+					Port:     80,
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	got := visitListeners(builder.Build(), &lvc)
+
+	vhostPage := &dag.ErrorPage{
+		StatusCodes:        []uint32{404},
+		Body:               "<h1>not found</h1>",
+		ContentType:        "text/html",
+		ResponseStatusCode: 200,
+	}
+	globalPage := &dag.ErrorPage{
+		StatusCodes: []uint32{503},
+		Body:        "unavailable",
+	}
+
+	// The pages of the virtual host are matched before the global pages.
+	protobuf.ExpectEqual(t,
+		envoy_v3.Filters(envoy_v3.HTTPConnectionManagerBuilder().
+			AddFilter(envoy_v3.FilterMisdirectedRequests("app.example.com")).
+			DefaultFilters().
+			RouteConfigName(path.Join("https", "app.example.com")).
+			MetricsPrefix(ENVOY_HTTPS_LISTENER).
+			AccessLoggers(envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTPS_ACCESS_LOG)).
+			ErrorPages(vhostPage, globalPage).
+			Get()),
+		got[ENVOY_HTTPS_LISTENER].FilterChains[0].Filters,
+	)
+
+	protobuf.ExpectEqual(t,
+		envoy_v3.Filters(envoy_v3.HTTPConnectionManagerBuilder().
+			DefaultFilters().
+			RouteConfigName(ENVOY_HTTP_LISTENER).
+			MetricsPrefix(ENVOY_HTTP_LISTENER).
+			AccessLoggers(envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG)).
+			ErrorPages(globalPage).
+			Get()),
+		got[ENVOY_HTTP_LISTENER].FilterChains[0].Filters,
+	)
+}
+
 func transportSocket(secretname string, tlsMinProtoVersion envoy_tls_v3.TlsParameters_TlsProtocol, alpnprotos ...string) *envoy_core_v3.TransportSocket {
 	secret := &dag.Secret{
 		Object: &v1.Secret{
@@ -33,25 +33,48 @@ import (
 type RouteCache struct {
 	mu     sync.Mutex
 	values map[string]*envoy_route_v3.RouteConfiguration
+	groups map[string]map[string]*envoy_route_v3.RouteConfiguration
 	contour.Cond
 }
 
 // Update replaces the contents of the cache with the supplied map.
 func (c *RouteCache) Update(v map[string]*envoy_route_v3.RouteConfiguration) {
+	c.update(v, nil)
+}
+
+// update replaces the contents of the cache with the supplied maps,
+// for Envoys that are not in a group, and for each Envoy group.
+func (c *RouteCache) update(v map[string]*envoy_route_v3.RouteConfiguration, groups map[string]map[string]*envoy_route_v3.RouteConfiguration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.values = v
+	c.groups = groups
 	c.Cond.Notify()
 }
 
+// valuesFor returns the route configurations for Envoys in the
+// given group.
+func (c *RouteCache) valuesFor(group string) map[string]*envoy_route_v3.RouteConfiguration {
+	if v, ok := c.groups[group]; ok {
+		return v
+	}
+	return c.values
+}
+
 // Contents returns a copy of the cache's contents.
 func (c *RouteCache) Contents() []proto.Message {
+	return c.GroupContents("")
+}
+
+// GroupContents returns a copy of the cache's contents for Envoys in
+// the given group.
+func (c *RouteCache) GroupContents(group string) []proto.Message {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	var values []*envoy_route_v3.RouteConfiguration
-	for _, v := range c.values {
+	for _, v := range c.valuesFor(group) {
 		values = append(values, v)
 	}
 
@@ -61,12 +84,19 @@ func (c *RouteCache) Contents() []proto.Message {
 
 // Query searches the RouteCache for the named RouteConfiguration entries.
 func (c *RouteCache) Query(names []string) []proto.Message {
+	return c.GroupQuery("", names)
+}
+
+// GroupQuery searches the RouteCache for the named RouteConfiguration
+// entries, for Envoys in the given group.
+func (c *RouteCache) GroupQuery(group string, names []string) []proto.Message {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	routes := c.valuesFor(group)
 	var values []*envoy_route_v3.RouteConfiguration
 	for _, n := range names {
-		v, ok := c.values[n]
+		v, ok := routes[n]
 		if !ok {
 			// if there is no route registered with the cache
 			// we return a blank route configuration. This is
@@ -85,32 +115,83 @@ func (c *RouteCache) Query(names []string) []proto.Message {
 	return protobuf.AsMessages(values)
 }
 
+// Groups returns the names of the Envoy groups that have their own
+// route configurations.
+func (c *RouteCache) Groups() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var groups []string
+	for group := range c.groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
 // TypeURL returns the string type of RouteCache Resource.
 func (*RouteCache) TypeURL() string { return resource.RouteType }
 
 func (c *RouteCache) OnChange(root *dag.DAG) {
 	routes := visitRoutes(root)
-	c.Update(routes)
+
+	groups := map[string]map[string]*envoy_route_v3.RouteConfiguration{}
+	for _, group := range root.GetEnvoyGroups() {
+		groups[group] = visitGroupRoutes(root, group)
+	}
+
+	c.update(routes, groups)
 }
 
 type routeVisitor struct {
+	// group is the Envoy group the routes are built for.
+	group string
+
 	routes map[string]*envoy_route_v3.RouteConfiguration
+
+	// redirectHosts are the virtual hosts that internal
+	// redirects are limited to. Their routes are named after
+	// them so that redirect policies can match them.
+	redirectHosts map[string]bool
 }
 
 func visitRoutes(root dag.Vertex) map[string]*envoy_route_v3.RouteConfiguration {
+	return visitGroupRoutes(root, "")
+}
+
+// visitGroupRoutes returns the route configurations for Envoys in the
+// given group, which hold only the virtual hosts served by the group.
+func visitGroupRoutes(root dag.Vertex, group string) map[string]*envoy_route_v3.RouteConfiguration {
 	// Collect the route configurations for all the routes we can
 	// find. For HTTP hosts, the routes will all be collected on the
 	// well-known ENVOY_HTTP_LISTENER, but for HTTPS hosts, we will
 	// generate a per-vhost collection. This lets us keep different
 	// SNI names disjoint when we later configure the listener.
 	rv := routeVisitor{
+		group: group,
 		routes: map[string]*envoy_route_v3.RouteConfiguration{
 			ENVOY_HTTP_LISTENER: envoy_v3.RouteConfiguration(ENVOY_HTTP_LISTENER),
 		},
+		redirectHosts: redirectAllowedHosts(root),
 	}
 
 	rv.visit(root)
 
+	// The buffer filter is enabled on the connection managers of
+	// route configurations with buffered routes, so disable it
+	// on their virtual hosts. Routes with a buffer policy
+	// override this.
+	for name := range bufferedRouteConfigs(root, group) {
+		if rc, ok := rv.routes[name]; ok {
+			for _, evh := range rc.VirtualHosts {
+				if evh.TypedPerFilterConfig == nil {
+					evh.TypedPerFilterConfig = map[string]*any.Any{}
+				}
+				evh.TypedPerFilterConfig["envoy.filters.http.buffer"] = envoy_v3.RouteBufferDisabled()
+			}
+		}
+	}
+
 	for _, v := range rv.routes {
 		sort.Stable(sorter.For(v.VirtualHosts))
 	}
@@ -118,6 +199,78 @@ func visitRoutes(root dag.Vertex) map[string]*envoy_route_v3.RouteConfiguration
 	return rv.routes
 }
 
+// bufferedRouteConfigs returns the names of the route configurations
+// for Envoys in the given group that have a route with a buffer policy.
+func bufferedRouteConfigs(root dag.Vertex, group string) map[string]bool {
+	names := map[string]bool{}
+
+	var visit func(dag.Vertex)
+	visit = func(vertex dag.Vertex) {
+		switch vh := vertex.(type) {
+		case *dag.VirtualHost:
+			if !vh.ServedBy(group) {
+				return
+			}
+			vh.Visit(func(v dag.Vertex) {
+				// Routes that upgrade to HTTPS only redirect.
+				if route, ok := v.(*dag.Route); ok && route.BufferPolicy != nil && !route.HTTPSUpgrade {
+					name := ENVOY_HTTP_LISTENER
+					if vh.Listener != "" {
+						name = vh.Listener
+					}
+					names[name] = true
+				}
+			})
+		case *dag.SecureVirtualHost:
+			if vh.ServedBy(group) && vh.MaxRequestBytes() > 0 {
+				names[path.Join("https", vh.VirtualHost.Name)] = true
+				if vh.FallbackCertificate != nil {
+					names[ENVOY_FALLBACK_ROUTECONFIG] = true
+				}
+			}
+		default:
+			vertex.Visit(visit)
+		}
+	}
+	visit(root)
+
+	return names
+}
+
+// redirectAllowedHosts returns the names of the virtual hosts that
+// the internal redirect policies of routes are limited to.
+func redirectAllowedHosts(root dag.Vertex) map[string]bool {
+	hosts := map[string]bool{}
+
+	var visit func(dag.Vertex)
+	visit = func(vertex dag.Vertex) {
+		if route, ok := vertex.(*dag.Route); ok {
+			if route.InternalRedirectPolicy != nil {
+				for _, host := range route.InternalRedirectPolicy.AllowedHosts {
+					hosts[host] = true
+				}
+			}
+			return
+		}
+		vertex.Visit(visit)
+	}
+	visit(root)
+
+	return hosts
+}
+
+// nameRoutes names the routes of the virtual host after it, if
+// internal redirects may be limited to it.
+func (v *routeVisitor) nameRoutes(vhost string, routes []*envoy_route_v3.Route) {
+	if !v.redirectHosts[vhost] {
+		return
+	}
+
+	for _, rt := range routes {
+		rt.Name = vhost
+	}
+}
+
 func (v *routeVisitor) onVirtualHost(vh *dag.VirtualHost) {
 	var routes []*envoy_route_v3.Route
 
@@ -154,14 +307,22 @@ func (v *routeVisitor) onVirtualHost(vh *dag.VirtualHost) {
 				}
 				rt.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = envoy_v3.LocalRateLimitConfig(route.RateLimitPolicy.Local, "vhost."+vh.Name)
 			}
+			if route.BufferPolicy != nil {
+				if rt.TypedPerFilterConfig == nil {
+					rt.TypedPerFilterConfig = map[string]*any.Any{}
+				}
+				rt.TypedPerFilterConfig["envoy.filters.http.buffer"] = envoy_v3.RouteBuffer(route.BufferPolicy)
+			}
 			routes = append(routes, rt)
 		}
 	})
 
 	if len(routes) > 0 {
 		sortRoutes(routes)
+		v.nameRoutes(vh.Name, routes)
 
 		evh := envoy_v3.VirtualHost(vh.Name, routes...)
+		evh.PerRequestBufferLimitBytes = protobuf.UInt32OrNil(vh.PerRequestBufferLimitBytes)
 		if vh.CORSPolicy != nil {
 			evh.Cors = envoy_v3.CORSPolicy(vh.CORSPolicy)
 		}
@@ -172,7 +333,18 @@ func (v *routeVisitor) onVirtualHost(vh *dag.VirtualHost) {
 			evh.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = envoy_v3.LocalRateLimitConfig(vh.RateLimitPolicy.Local, "vhost."+vh.Name)
 		}
 
-		v.routes[ENVOY_HTTP_LISTENER].VirtualHosts = append(v.routes[ENVOY_HTTP_LISTENER].VirtualHosts, evh)
+		// Virtual hosts served by an extra HTTP listener are
+		// collected on the route configuration named after it.
+		name := ENVOY_HTTP_LISTENER
+		if vh.Listener != "" {
+			name = vh.Listener
+		}
+
+		if _, ok := v.routes[name]; !ok {
+			v.routes[name] = envoy_v3.RouteConfiguration(name)
+		}
+
+		v.routes[name].VirtualHosts = append(v.routes[name].VirtualHosts, evh)
 	}
 }
 
@@ -203,6 +375,12 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 			}
 			rt.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = envoy_v3.LocalRateLimitConfig(route.RateLimitPolicy.Local, "vhost."+svh.Name)
 		}
+		if route.BufferPolicy != nil {
+			if rt.TypedPerFilterConfig == nil {
+				rt.TypedPerFilterConfig = map[string]*any.Any{}
+			}
+			rt.TypedPerFilterConfig["envoy.filters.http.buffer"] = envoy_v3.RouteBuffer(route.BufferPolicy)
+		}
 
 		// If authorization is enabled on this host, we may need to set per-route filter overrides.
 		if svh.AuthorizationService != nil {
@@ -227,6 +405,7 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 
 	if len(routes) > 0 {
 		sortRoutes(routes)
+		v.nameRoutes(svh.VirtualHost.Name, routes)
 
 		name := path.Join("https", svh.VirtualHost.Name)
 
@@ -235,6 +414,7 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 		}
 
 		evh := envoy_v3.VirtualHost(svh.VirtualHost.Name, routes...)
+		evh.PerRequestBufferLimitBytes = protobuf.UInt32OrNil(svh.PerRequestBufferLimitBytes)
 		if svh.CORSPolicy != nil {
 			evh.Cors = envoy_v3.CORSPolicy(svh.CORSPolicy)
 		}
@@ -244,6 +424,11 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 			}
 			evh.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = envoy_v3.LocalRateLimitConfig(svh.RateLimitPolicy.Local, "vhost."+svh.Name)
 		}
+		if svh.HTTP3Port != 0 {
+			evh.ResponseHeadersToAdd = envoy_v3.HeaderValueList(map[string]string{
+				"alt-svc": envoy_v3.AltSvcHTTP3(svh.HTTP3Port),
+			}, false)
+		}
 
 		v.routes[name].VirtualHosts = append(v.routes[name].VirtualHosts, evh)
 
@@ -257,6 +442,7 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 			}
 
 			fvh := envoy_v3.VirtualHost(svh.Name, routes...)
+			fvh.PerRequestBufferLimitBytes = protobuf.UInt32OrNil(svh.PerRequestBufferLimitBytes)
 			if svh.CORSPolicy != nil {
 				fvh.Cors = envoy_v3.CORSPolicy(svh.CORSPolicy)
 			}
@@ -278,9 +464,13 @@ func (v *routeVisitor) visit(vertex dag.Vertex) {
 		l.Visit(func(vertex dag.Vertex) {
 			switch vh := vertex.(type) {
 			case *dag.VirtualHost:
-				v.onVirtualHost(vh)
+				if vh.ServedBy(v.group) {
+					v.onVirtualHost(vh)
+				}
 			case *dag.SecureVirtualHost:
-				v.onSecureVirtualHost(vh)
+				if vh.ServedBy(v.group) {
+					v.onSecureVirtualHost(vh)
+				}
 			default:
 				// recurse
 				vertex.Visit(v.visit)
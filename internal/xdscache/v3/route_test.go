@@ -19,13 +19,20 @@ import (
 
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_internal_redirect_allow_listed_routes_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/internal_redirect/allow_listed_routes/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/dag"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -2830,6 +2837,389 @@ func TestRouteVisit(t *testing.T) {
 	}
 }
 
+func TestRouteCacheGroups(t *testing.T) {
+	proxy := func(name, fqdn, group string) *contour_api_v1.HTTPProxy {
+		return &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn:       fqdn,
+					EnvoyGroup: group,
+				},
+				Routes: []contour_api_v1.Route{{
+					Services: []contour_api_v1.Service{{
+						Name:      "backend",
+						Namespace: "default",
+						Port:      80,
+					}},
+				}},
+			},
+		}
+	}
+
+	root := buildDAG(t,
+		proxy("public", "www.example.com", ""),
+		proxy("admin", "admin.example.com", "internal"),
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+	)
+
+	var rc RouteCache
+	rc.OnChange(root)
+
+	vhosts := func(contents []proto.Message) []string {
+		var names []string
+		for _, m := range contents {
+			for _, vh := range m.(*envoy_route_v3.RouteConfiguration).VirtualHosts {
+				names = append(names, vh.Name)
+			}
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"internal"}, rc.Groups())
+
+	// Envoys that are not in a group only serve the virtual hosts
+	// served by all Envoys.
+	assert.Equal(t, []string{"www.example.com"}, vhosts(rc.Contents()))
+
+	// Envoys in the group also serve the virtual hosts of the group.
+	assert.Equal(t, []string{"admin.example.com", "www.example.com"}, vhosts(rc.GroupContents("internal")))
+
+	// Envoys in other groups are served as if they were not in a group.
+	assert.Equal(t, []string{"www.example.com"}, vhosts(rc.GroupContents("external")))
+	assert.Equal(t, []string{"www.example.com"}, vhosts(rc.GroupQuery("external", []string{"ingress_http"})))
+}
+
+func TestRouteVisitExtraListeners(t *testing.T) {
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{
+				Listeners: map[string]config.ListenerProtocol{
+					"internal": config.HTTPListenerProtocol,
+					"proxied":  config.HTTPSListenerProtocol,
+				},
+			},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	route := []contour_api_v1.Route{{
+		Services: []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}},
+	}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/public").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "www.example.com"},
+			Routes:      route,
+		}),
+		fixture.NewProxy("default/internal").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "internal.example.com", Listener: "internal"},
+			Routes:      route,
+		}),
+		fixture.NewProxy("default/proxied").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn:     "proxied.example.com",
+				Listener: "proxied",
+				TLS:      &contour_api_v1.TLS{SecretName: "secret"},
+			},
+			Routes: route,
+		}),
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Type: "kubernetes.io/tls",
+			Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	got := map[string][]string{}
+	for name, rc := range visitRoutes(builder.Build()) {
+		for _, vh := range rc.VirtualHosts {
+			got[name] = append(got[name], vh.Name)
+		}
+	}
+
+	// The virtual host on the extra HTTPS listener is not served
+	// over plain HTTP.
+	assert.Equal(t, map[string][]string{
+		"ingress_http":              {"www.example.com"},
+		"internal":                  {"internal.example.com"},
+		"https/proxied.example.com": {"proxied.example.com"},
+	}, got)
+}
+
+func TestRouteVisitHTTP3AltSvc(t *testing.T) {
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{HTTP3Port: 443},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	route := []contour_api_v1.Route{{
+		Services: []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}},
+	}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/www").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "www.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret"},
+			},
+			Routes: route,
+		}),
+		fixture.NewProxy("default/legacy").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "legacy.example.com",
+				TLS:  &contour_api_v1.TLS{SecretName: "secret", DisableHTTP3: true},
+			},
+			Routes: route,
+		}),
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Type: "kubernetes.io/tls",
+			Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	routes := visitRoutes(builder.Build())
+
+	www := routes["https/www.example.com"]
+	require.NotNil(t, www)
+	require.Len(t, www.VirtualHosts, 1)
+	assert.Equal(t, envoy_v3.HeaderValueList(map[string]string{
+		"alt-svc": `h3=":443"; ma=86400`,
+	}, false), www.VirtualHosts[0].ResponseHeadersToAdd)
+
+	// The alt-svc header is only sent for secure virtual hosts that
+	// are served over HTTP/3.
+	legacy := routes["https/legacy.example.com"]
+	require.NotNil(t, legacy)
+	require.Len(t, legacy.VirtualHosts, 1)
+	assert.Nil(t, legacy.VirtualHosts[0].ResponseHeadersToAdd)
+
+	for _, vh := range routes[ENVOY_HTTP_LISTENER].VirtualHosts {
+		assert.Nil(t, vh.ResponseHeadersToAdd)
+	}
+}
+
+func TestRouteVisitBufferPolicy(t *testing.T) {
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	services := []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/upload").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "upload.example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: services,
+			}, {
+				Conditions:   []contour_api_v1.MatchCondition{{Prefix: "/upload"}},
+				Services:     services,
+				BufferPolicy: &contour_api_v1.BufferPolicy{MaxRequestBytes: 8192},
+			}},
+		}),
+		fixture.NewProxy("default/www").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "www.example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: services,
+			}},
+		}),
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	routes := visitRoutes(builder.Build())
+
+	rc := routes[ENVOY_HTTP_LISTENER]
+	require.NotNil(t, rc)
+	require.Len(t, rc.VirtualHosts, 2)
+
+	// Every virtual host on the route configuration disables
+	// buffering, and only the buffered route enables it.
+	disabled := map[string]*any.Any{
+		"envoy.filters.http.buffer": envoy_v3.RouteBufferDisabled(),
+	}
+	for _, vh := range rc.VirtualHosts {
+		protobuf.ExpectEqual(t, disabled, vh.TypedPerFilterConfig)
+	}
+
+	upload := rc.VirtualHosts[0]
+	require.Equal(t, "upload.example.com", upload.Name)
+	require.Len(t, upload.Routes, 2)
+	protobuf.ExpectEqual(t, map[string]*any.Any{
+		"envoy.filters.http.buffer": envoy_v3.RouteBuffer(&dag.BufferPolicy{MaxRequestBytes: 8192}),
+	}, upload.Routes[0].TypedPerFilterConfig)
+	assert.Nil(t, upload.Routes[1].TypedPerFilterConfig)
+}
+
+func TestRouteVisitInternalRedirectPolicy(t *testing.T) {
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	services := []contour_api_v1.Service{{Name: "backend", Namespace: "default", Port: 80}}
+
+	for _, o := range []interface{}{
+		fixture.NewProxy("default/assets").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "assets.example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: services,
+				InternalRedirectPolicy: &contour_api_v1.InternalRedirectPolicy{
+					MaxInternalRedirects:  2,
+					RedirectResponseCodes: []uint32{302, 307},
+					AllowedHosts:          []string{"storage.example.com"},
+				},
+			}},
+		}),
+		fixture.NewProxy("default/storage").WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "storage.example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: services,
+			}},
+		}),
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+	} {
+		builder.Source.Insert(o)
+	}
+
+	routes := visitRoutes(builder.Build())
+
+	rc := routes[ENVOY_HTTP_LISTENER]
+	require.NotNil(t, rc)
+	require.Len(t, rc.VirtualHosts, 2)
+
+	assets := rc.VirtualHosts[0]
+	require.Equal(t, "assets.example.com", assets.Name)
+	require.Len(t, assets.Routes, 1)
+	assert.Empty(t, assets.Routes[0].Name)
+	protobuf.ExpectEqual(t,
+		&envoy_route_v3.InternalRedirectPolicy{
+			MaxInternalRedirects:  protobuf.UInt32(2),
+			RedirectResponseCodes: []uint32{302, 307},
+			Predicates: []*envoy_core_v3.TypedExtensionConfig{{
+				Name: "envoy.internal_redirect_predicates.allow_listed_routes",
+				TypedConfig: protobuf.MustMarshalAny(
+					&envoy_internal_redirect_allow_listed_routes_v3.AllowListedRoutesConfig{
+						AllowedRouteNames: []string{"storage.example.com"},
+					},
+				),
+			}},
+		},
+		assets.Routes[0].GetRoute().InternalRedirectPolicy,
+	)
+
+	// The routes of the allowed virtual host are named after
+	// it, so the redirect policy can match them.
+	storage := rc.VirtualHosts[1]
+	require.Equal(t, "storage.example.com", storage.Name)
+	require.Len(t, storage.Routes, 1)
+	assert.Equal(t, "storage.example.com", storage.Routes[0].Name)
+}
+
 func TestSortLongestRouteFirst(t *testing.T) {
 	tests := map[string]struct {
 		routes []*envoy_route_v3.Route
@@ -3081,6 +3471,12 @@ func routeConfigurations(rcs ...*envoy_route_v3.RouteConfiguration) map[string]*
 func withMirrorPolicy(route *envoy_route_v3.Route_Route, mirror string) *envoy_route_v3.Route_Route {
 	route.Route.RequestMirrorPolicies = []*envoy_route_v3.RouteAction_RequestMirrorPolicy{{
 		Cluster: mirror,
+		RuntimeFraction: &envoy_core_v3.RuntimeFractionalPercent{
+			DefaultValue: &envoy_type_v3.FractionalPercent{
+				Numerator:   1000000,
+				Denominator: envoy_type_v3.FractionalPercent_MILLION,
+			},
+		},
 	}}
 	return route
 }
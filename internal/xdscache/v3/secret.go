@@ -28,11 +28,30 @@ import (
 	"github.com/projectcontour/contour/internal/sorter"
 )
 
+// OCSPStapler supplies OCSP responses for TLS certificates
+// that do not carry a response in their Secret.
+type OCSPStapler interface {
+	// Staple returns the current OCSP response for each of the
+	// supplied secrets, keyed by the same name. Secrets for which
+	// no valid response is known are omitted.
+	Staple(secrets map[string]*dag.Secret) map[string][]byte
+}
+
 // SecretCache manages the contents of the gRPC SDS cache.
 type SecretCache struct {
-	mu     sync.Mutex
-	values map[string]*envoy_tls_v3.Secret
+	mu      sync.Mutex
+	values  map[string]*envoy_tls_v3.Secret
+	secrets map[string]*dag.Secret
 	contour.Cond
+
+	// renderMu serializes rendering and updating the cache, so
+	// that a Refresh can't replace the secrets of a newer DAG
+	// with those of the DAG it started rendering.
+	renderMu sync.Mutex
+
+	// OCSPStapler is an optional source of OCSP responses
+	// to staple to the TLS certificates in this cache.
+	OCSPStapler OCSPStapler
 }
 
 // Update replaces the contents of the cache with the supplied map.
@@ -75,18 +94,66 @@ func (c *SecretCache) Query(names []string) []proto.Message {
 func (*SecretCache) TypeURL() string { return resource.SecretType }
 
 func (c *SecretCache) OnChange(root *dag.DAG) {
-	secrets := visitSecrets(root)
-	c.Update(secrets)
+	secrets := collectSecrets(root)
+
+	c.renderMu.Lock()
+	defer c.renderMu.Unlock()
+
+	c.mu.Lock()
+	c.secrets = secrets
+	c.mu.Unlock()
+
+	c.Update(c.render(secrets))
+}
+
+// Refresh re-renders the secrets of the most recent DAG so
+// that updated OCSP responses are sent to Envoy.
+func (c *SecretCache) Refresh() {
+	c.renderMu.Lock()
+	defer c.renderMu.Unlock()
+
+	c.mu.Lock()
+	secrets := c.secrets
+	c.mu.Unlock()
+
+	c.Update(c.render(secrets))
+}
+
+// render converts the given DAG secrets into Envoy secrets,
+// stapling any OCSP responses supplied by the OCSPStapler.
+func (c *SecretCache) render(secrets map[string]*dag.Secret) map[string]*envoy_tls_v3.Secret {
+	var staples map[string][]byte
+	if c.OCSPStapler != nil {
+		staples = c.OCSPStapler.Staple(secrets)
+	}
+
+	values := make(map[string]*envoy_tls_v3.Secret, len(secrets))
+	for name, s := range secrets {
+		envoySecret := envoy_v3.Secret(s)
+		if staple, ok := staples[name]; ok && len(s.OCSPStaple()) == 0 {
+			envoy_v3.StapleOCSPResponse(envoySecret, staple)
+		}
+		values[name] = envoySecret
+	}
+
+	return values
 }
 
 type secretVisitor struct {
-	secrets map[string]*envoy_tls_v3.Secret
+	secrets map[string]*dag.Secret
 }
 
 // visitSecrets produces a map of *envoy_tls_v3.Secret
 func visitSecrets(root dag.Vertex) map[string]*envoy_tls_v3.Secret {
+	var c SecretCache
+	return c.render(collectSecrets(root))
+}
+
+// collectSecrets produces a map of the *dag.Secrets referenced
+// by the DAG, keyed by their Envoy secret name.
+func collectSecrets(root dag.Vertex) map[string]*dag.Secret {
 	sv := secretVisitor{
-		secrets: make(map[string]*envoy_tls_v3.Secret),
+		secrets: make(map[string]*dag.Secret),
 	}
 	sv.visit(root)
 	return sv.secrets
@@ -95,8 +162,7 @@ func visitSecrets(root dag.Vertex) map[string]*envoy_tls_v3.Secret {
 func (v *secretVisitor) addSecret(s *dag.Secret) {
 	name := envoy.Secretname(s)
 	if _, ok := v.secrets[name]; !ok {
-		envoySecret := envoy_v3.Secret(s)
-		v.secrets[envoySecret.Name] = envoySecret
+		v.secrets[name] = s
 	}
 }
 
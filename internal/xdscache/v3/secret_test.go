@@ -14,15 +14,19 @@
 package v3
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/golang/protobuf/proto"
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/dag"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -476,6 +480,164 @@ func TestSecretVisit(t *testing.T) {
 	}
 }
 
+type staplerFunc func(map[string]*dag.Secret) map[string][]byte
+
+func (f staplerFunc) Staple(secrets map[string]*dag.Secret) map[string][]byte {
+	return f(secrets)
+}
+
+func TestSecretCacheOCSPStapler(t *testing.T) {
+	root := buildDAG(t,
+		&contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "simple",
+				Namespace: "default",
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: "www.example.com",
+					TLS: &contour_api_v1.TLS{
+						SecretName: "secret",
+					},
+				},
+				Routes: []contour_api_v1.Route{{
+					Services: []contour_api_v1.Service{{
+						Name:      "backend",
+						Namespace: "default",
+						Port:      80,
+					}},
+				}},
+			},
+		},
+		tlssecret("default", "secret", secretdata(CERTIFICATE, RSA_PRIVATE_KEY)),
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:       "http",
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+	)
+
+	var staple []byte
+	sc := SecretCache{
+		OCSPStapler: staplerFunc(func(secrets map[string]*dag.Secret) map[string][]byte {
+			staples := map[string][]byte{}
+			if staple != nil {
+				for name := range secrets {
+					staples[name] = staple
+				}
+			}
+			return staples
+		}),
+	}
+
+	// No staple is known yet.
+	sc.OnChange(root)
+	protobuf.ExpectEqual(t, []proto.Message{
+		secret("default/secret/68621186db", secretdata(CERTIFICATE, RSA_PRIVATE_KEY)),
+	}, sc.Contents())
+
+	// Refreshing picks up the new staple without rebuilding the DAG.
+	staple = []byte("staple")
+	sc.Refresh()
+
+	want := secret("default/secret/68621186db", secretdata(CERTIFICATE, RSA_PRIVATE_KEY))
+	envoy_v3.StapleOCSPResponse(want, staple)
+	protobuf.ExpectEqual(t, []proto.Message{want}, sc.Contents())
+}
+
+func TestSecretCacheRefreshDuringOnChange(t *testing.T) {
+	root := buildDAG(t,
+		&contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "simple",
+				Namespace: "default",
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: "www.example.com",
+					TLS: &contour_api_v1.TLS{
+						SecretName: "secret",
+					},
+				},
+				Routes: []contour_api_v1.Route{{
+					Services: []contour_api_v1.Service{{
+						Name:      "backend",
+						Namespace: "default",
+						Port:      80,
+					}},
+				}},
+			},
+		},
+		tlssecret("default", "secret", secretdata(CERTIFICATE, RSA_PRIVATE_KEY)),
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:       "http",
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+	)
+
+	var (
+		block    = true
+		started  = make(chan struct{})
+		released = make(chan struct{})
+	)
+
+	sc := SecretCache{
+		OCSPStapler: staplerFunc(func(secrets map[string]*dag.Secret) map[string][]byte {
+			if block {
+				block = false
+				close(started)
+				<-released
+			}
+			return nil
+		}),
+	}
+
+	sc.mu.Lock()
+	sc.secrets = collectSecrets(root)
+	sc.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// A Refresh of the old DAG's secrets is still rendering when
+	// a DAG without any secrets is built.
+	go func() {
+		defer wg.Done()
+		sc.Refresh()
+	}()
+	<-started
+
+	go func() {
+		defer wg.Done()
+		sc.OnChange(buildDAG(t))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(released)
+	wg.Wait()
+
+	// The secrets of the newer DAG are kept.
+	assert.Empty(t, sc.Contents())
+}
+
 // buildDAG produces a dag.DAG from the supplied objects.
 func buildDAG(t *testing.T, objs ...interface{}) *dag.DAG {
 	builder := dag.Builder{
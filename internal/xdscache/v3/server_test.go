@@ -190,6 +190,55 @@ func TestGRPC(t *testing.T) {
 			checkrecv(t, stream)                    // check we receive one notification
 			checktimeout(t, stream)                 // check that the second receive times out
 		},
+		"StreamAggregatedResources": func(t *testing.T, cc *grpc.ClientConn) {
+			eh.OnAdd(&v1beta1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "httpbin-org",
+					Namespace: "default",
+				},
+				Spec: v1beta1.IngressSpec{
+					Rules: []v1beta1.IngressRule{{
+						Host: "httpbin.org",
+						IngressRuleValue: v1beta1.IngressRuleValue{
+							HTTP: &v1beta1.HTTPIngressRuleValue{
+								Paths: []v1beta1.HTTPIngressPath{{
+									Backend: v1beta1.IngressBackend{
+										ServiceName: "httpbin-org",
+										ServicePort: intstr.FromInt(80),
+									},
+								}},
+							},
+						},
+					}},
+				},
+			})
+
+			ads := discovery.NewAggregatedDiscoveryServiceClient(cc)
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			stream, err := ads.StreamAggregatedResources(ctx)
+			require.NoError(t, err)
+			sendreq(t, stream, resource.ListenerType) // send initial notifications
+			sendreq(t, stream, resource.ClusterType)
+
+			// Clusters are sent before listeners, and the listeners
+			// are not sent until the clusters are acknowledged.
+			resp, err := stream.Recv()
+			require.NoError(t, err)
+			require.Equal(t, resource.ClusterType, resp.TypeUrl)
+
+			err = stream.Send(&discovery.DiscoveryRequest{
+				TypeUrl:       resource.ClusterType,
+				VersionInfo:   resp.VersionInfo,
+				ResponseNonce: resp.Nonce,
+			})
+			require.NoError(t, err)
+
+			resp, err = stream.Recv()
+			require.NoError(t, err)
+			require.Equal(t, resource.ListenerType, resp.TypeUrl)
+			checktimeout(t, stream) // check that the next receive times out
+		},
 	}
 
 	log := logrus.New()
@@ -212,7 +261,7 @@ func TestGRPC(t *testing.T) {
 			}
 
 			srv := xds.NewServer(nil)
-			contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, xdscache.ResourcesOf(resources)...), srv)
+			contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, nil, nil, xdscache.ResourcesOf(resources)...), srv)
 			l, err := net.Listen("tcp", "127.0.0.1:0")
 			require.NoError(t, err)
 			done := make(chan error, 1)
@@ -41,6 +41,12 @@ const (
 	// (in days).
 	DefaultCertificateLifetime = 365
 
+	// DefaultCALifetime holds the default lifetime (in days) of a CA
+	// generated when rotating certificates. It is longer than the
+	// certificate lifetime so that certificates can be renewed
+	// several times before the CA itself has to be rolled.
+	DefaultCALifetime = 5 * 365
+
 	// DefaultNamespace where Contour is deployed. This value is added
 	// to the certificates Subject Alt Names.
 	DefaultNamespace = "projectcontour"
@@ -75,12 +81,27 @@ type Configuration struct {
 
 	// EnvoyServiceName holds the name of the Envoy service name.
 	EnvoyServiceName string
+
+	// CALifetime is the number of days for which a CA generated by
+	// RotateCerts will be valid.
+	CALifetime uint
+
+	// RollCA makes RotateCerts generate a new CA even if the
+	// current CA could be kept.
+	RollCA bool
 }
 
 // Certificates contains a set of Certificates as []byte each holding
 // the CA Cert along with with Contour & Envoy Certs.
 type Certificates struct {
-	CACertificate      []byte
+	CACertificate []byte
+
+	// CAPrivateKey is the private key of the CA that issued the
+	// Contour and Envoy certificates. It is only populated by
+	// RotateCerts, which needs it to renew certificates without
+	// replacing the CA.
+	CAPrivateKey []byte
+
 	ContourCertificate []byte
 	ContourPrivateKey  []byte
 	EnvoyCertificate   []byte
@@ -134,6 +155,155 @@ func GenerateCerts(config *Configuration) (*Certificates, error) {
 	}, nil
 }
 
+// NeedsRenewal returns true if current is nil, or if the Contour or
+// Envoy certificate expires within renewBefore of now.
+func NeedsRenewal(current *Certificates, renewBefore time.Duration, now time.Time) bool {
+	if current == nil {
+		return true
+	}
+
+	for _, data := range [][]byte{current.ContourCertificate, current.EnvoyCertificate} {
+		certs, err := parseCertificates(data)
+		if err != nil || len(certs) == 0 {
+			return true
+		}
+
+		if !now.Add(renewBefore).Before(certs[0].NotAfter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RotateCerts generates new Contour & Envoy certificates to replace
+// current, returning them as a *Certificates struct or error if
+// encountered.
+//
+// If current holds the private key of its CA, and the CA remains
+// valid for the lifetime of the new certificates, the CA is kept.
+// Otherwise a new CA is generated, and the CA certificate bundle
+// holds both the new CA and the unexpired CAs of current. This lets
+// peers that still hold certificates issued by the previous CA
+// connect until they pick up the new certificates. If current is
+// nil, a new CA is generated.
+func RotateCerts(config *Configuration, current *Certificates) (*Certificates, error) {
+	if config == nil {
+		config = &Configuration{}
+	}
+
+	now := time.Now()
+	expiry := now.Add(24 * time.Duration(uint32OrDefault(config.Lifetime, DefaultCertificateLifetime)) * time.Hour)
+
+	var caCertPEM, caKeyPEM, bundle []byte
+
+	if current != nil {
+		// Drop the CAs that have expired, so that the bundle
+		// does not grow every time the CA is rolled.
+		previous, err := parseCertificates(current.CACertificate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA certificate bundle: %w", err)
+		}
+		for _, c := range previous {
+			if now.Before(c.NotAfter) {
+				bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+			}
+		}
+
+		if !config.RollCA && len(current.CAPrivateKey) > 0 {
+			caCertPEM = issuingCA(previous, current.CAPrivateKey, expiry)
+			caKeyPEM = current.CAPrivateKey
+		}
+	}
+
+	if caCertPEM == nil {
+		caExpiry := now.Add(24 * time.Duration(uint32OrDefault(config.CALifetime, DefaultCALifetime)) * time.Hour)
+		if caExpiry.Before(expiry) {
+			caExpiry = expiry
+		}
+
+		var err error
+		caCertPEM, caKeyPEM, err = newCA("Project Contour", caExpiry)
+		if err != nil {
+			return nil, err
+		}
+
+		// The new CA goes first, followed by the previous CAs.
+		bundle = append(append([]byte{}, caCertPEM...), bundle...)
+	}
+
+	contourCert, contourKey, err := newCert(caCertPEM,
+		caKeyPEM,
+		expiry,
+		stringOrDefault(config.ContourServiceName, DefaultContourServiceName),
+		stringOrDefault(config.Namespace, DefaultNamespace),
+		stringOrDefault(config.DNSName, DefaultDNSName),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	envoyCert, envoyKey, err := newCert(caCertPEM,
+		caKeyPEM,
+		expiry,
+		stringOrDefault(config.EnvoyServiceName, DefaultEnvoyServiceName),
+		stringOrDefault(config.Namespace, DefaultNamespace),
+		stringOrDefault(config.DNSName, DefaultDNSName),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Certificates{
+		CACertificate:      bundle,
+		CAPrivateKey:       caKeyPEM,
+		ContourCertificate: contourCert,
+		ContourPrivateKey:  contourKey,
+		EnvoyCertificate:   envoyCert,
+		EnvoyPrivateKey:    envoyKey,
+	}, nil
+}
+
+// issuingCA returns the PEM encoded certificate in cas that matches
+// caKeyPEM, provided that it is valid until expiry. Otherwise nil is
+// returned.
+func issuingCA(cas []*x509.Certificate, caKeyPEM []byte, expiry time.Time) []byte {
+	for _, c := range cas {
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+		if _, err := tls.X509KeyPair(certPEM, caKeyPEM); err != nil {
+			continue
+		}
+
+		if c.IsCA && !c.NotAfter.Before(expiry) {
+			return certPEM
+		}
+	}
+
+	return nil
+}
+
+// parseCertificates parses the PEM encoded certificates in data.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
+
 // newCert generates a new keypair given the CA keypair, the expiry time, the service name
 // ("contour" or "envoy"), and the Kubernetes namespace the service will run in (because
 // of the Kubernetes DNS schema.)
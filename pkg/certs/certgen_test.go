@@ -150,6 +150,70 @@ func TestGeneratedCertsValid(t *testing.T) {
 
 }
 
+func TestRotateCerts(t *testing.T) {
+	now := time.Now()
+	config := &Configuration{Lifetime: 30, CALifetime: 90}
+
+	pool := func(bundle []byte) *x509.CertPool {
+		roots := x509.NewCertPool()
+		require.True(t, roots.AppendCertsFromPEM(bundle))
+		return roots
+	}
+
+	// Without current certificates, a new CA is generated.
+	first, err := RotateCerts(config, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, first.CAPrivateKey)
+	assert.NoError(t, verifyCert(first.ContourCertificate, pool(first.CACertificate), "contour", now))
+	assert.NoError(t, verifyCert(first.EnvoyCertificate, pool(first.CACertificate), "envoy", now))
+
+	// Renewing keeps the CA.
+	renewed, err := RotateCerts(config, first)
+	require.NoError(t, err)
+	assert.Equal(t, first.CACertificate, renewed.CACertificate)
+	assert.Equal(t, first.CAPrivateKey, renewed.CAPrivateKey)
+	assert.NotEqual(t, first.ContourCertificate, renewed.ContourCertificate)
+	assert.NoError(t, verifyCert(renewed.ContourCertificate, pool(first.CACertificate), "contour", now))
+
+	// Rolling the CA keeps the previous CA in the bundle, so that
+	// both old and new certificates are trusted.
+	rolled, err := RotateCerts(&Configuration{Lifetime: 30, CALifetime: 90, RollCA: true}, renewed)
+	require.NoError(t, err)
+	assert.NotEqual(t, renewed.CAPrivateKey, rolled.CAPrivateKey)
+	cas, err := parseCertificates(rolled.CACertificate)
+	require.NoError(t, err)
+	assert.Len(t, cas, 2)
+	assert.NoError(t, verifyCert(rolled.EnvoyCertificate, pool(rolled.CACertificate), "envoy", now))
+	assert.NoError(t, verifyCert(renewed.EnvoyCertificate, pool(rolled.CACertificate), "envoy", now))
+	assert.Error(t, verifyCert(rolled.EnvoyCertificate, pool(renewed.CACertificate), "envoy", now))
+
+	// A CA that would expire before the new certificates is rolled.
+	rolled, err = RotateCerts(&Configuration{Lifetime: 365}, first)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.CAPrivateKey, rolled.CAPrivateKey)
+
+	// Certificates made by GenerateCerts have no CA key, so the CA is rolled.
+	generated, err := GenerateCerts(config)
+	require.NoError(t, err)
+	rolled, err = RotateCerts(config, generated)
+	require.NoError(t, err)
+	assert.NoError(t, verifyCert(generated.ContourCertificate, pool(rolled.CACertificate), "contour", now))
+	assert.NoError(t, verifyCert(rolled.ContourCertificate, pool(rolled.CACertificate), "contour", now))
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	now := time.Now()
+
+	assert.True(t, NeedsRenewal(nil, 0, now))
+
+	current, err := GenerateCerts(&Configuration{Lifetime: 30})
+	require.NoError(t, err)
+
+	assert.False(t, NeedsRenewal(current, 7*24*time.Hour, now))
+	assert.True(t, NeedsRenewal(current, 31*24*time.Hour, now))
+	assert.True(t, NeedsRenewal(current, 0, now.Add(31*24*time.Hour)))
+}
+
 func verifyCert(certPEM []byte, roots *x509.CertPool, dnsname string, currentTime time.Time) error {
 	block, _ := pem.Decode(certPEM)
 	if block == nil {
@@ -17,6 +17,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -178,7 +179,7 @@ type HTTPVersionType string
 
 func (h HTTPVersionType) Validate() error {
 	switch h {
-	case HTTPVersion1, HTTPVersion2:
+	case HTTPVersion1, HTTPVersion2, HTTPVersion3:
 		return nil
 	default:
 		return fmt.Errorf("invalid HTTP version %q", h)
@@ -188,6 +189,181 @@ func (h HTTPVersionType) Validate() error {
 const HTTPVersion1 HTTPVersionType = "http/1.1"
 const HTTPVersion2 HTTPVersionType = "http/2"
 
+// HTTPVersion3 is served over QUIC by a UDP listener alongside the
+// default HTTPS listener, so it requires another HTTP version for the
+// TCP listeners.
+const HTTPVersion3 HTTPVersionType = "http/3"
+
+// ListenerProtocol is the protocol served by an extra Envoy listener.
+type ListenerProtocol string
+
+func (l ListenerProtocol) Validate() error {
+	switch l {
+	case HTTPListenerProtocol, HTTPSListenerProtocol, TCPListenerProtocol, UDPListenerProtocol:
+		return nil
+	default:
+		return fmt.Errorf("invalid listener protocol %q", l)
+	}
+}
+
+// HTTPListenerProtocol listeners serve virtual hosts over plain HTTP.
+const HTTPListenerProtocol ListenerProtocol = "http"
+
+// HTTPSListenerProtocol listeners serve TLS virtual hosts, which are
+// selected by SNI.
+const HTTPSListenerProtocol ListenerProtocol = "https"
+
+// TCPListenerProtocol listeners serve virtual hosts that proxy TCP
+// connections. TLS virtual hosts are selected by SNI, while a virtual
+// host without TLS is served all the connections of the listener.
+const TCPListenerProtocol ListenerProtocol = "tcp"
+
+// UDPListenerProtocol listeners proxy the UDP datagrams they receive
+// for a single virtual host.
+const UDPListenerProtocol ListenerProtocol = "udp"
+
+// reservedListenerNames are the names of the listeners and route
+// configurations that Contour always generates.
+var reservedListenerNames = map[string]bool{
+	"ingress_http":         true,
+	"ingress_https":        true,
+	"ingress_http3":        true,
+	"ingress_fallbackcert": true,
+	"stats-health":         true,
+}
+
+// ListenerParameters holds the configuration of an Envoy listener
+// in addition to the default HTTP and HTTPS listeners. Virtual hosts
+// choose the listener by name.
+type ListenerParameters struct {
+	// Name is the name of the listener.
+	Name string `yaml:"name"`
+
+	// Address is the address the listener binds to.
+	// Defaults to "0.0.0.0".
+	Address string `yaml:"address,omitempty"`
+
+	// Port is the port the listener binds to.
+	Port int `yaml:"port"`
+
+	// Protocol is the protocol the listener serves: "http",
+	// "https", "tcp" or "udp".
+	Protocol ListenerProtocol `yaml:"protocol"`
+
+	// UseProxyProtocol configures the listener to expect a PROXY
+	// V1 or V2 preamble.
+	UseProxyProtocol bool `yaml:"use-proxy-protocol,omitempty"`
+
+	// AccessLog is the path of the access log of the listener.
+	// Defaults to "/dev/stdout".
+	AccessLog string `yaml:"accesslog,omitempty"`
+
+	// HTTPVersions are the HTTP versions the listener accepts.
+	// Defaults to DefaultHTTPVersions.
+	HTTPVersions []HTTPVersionType `yaml:"http-versions,omitempty"`
+}
+
+// Validate the listener parameters.
+func (l ListenerParameters) Validate() error {
+	if l.Name == "" {
+		return errors.New("listener name must be defined")
+	}
+
+	if reservedListenerNames[l.Name] || strings.Contains(l.Name, "/") {
+		return fmt.Errorf("invalid listener name %q", l.Name)
+	}
+
+	if l.Port < 1 || l.Port > 65535 {
+		return fmt.Errorf("invalid port %d for listener %q", l.Port, l.Name)
+	}
+
+	if err := l.Protocol.Validate(); err != nil {
+		return fmt.Errorf("listener %q: %w", l.Name, err)
+	}
+
+	if l.Protocol == UDPListenerProtocol && l.UseProxyProtocol {
+		return fmt.Errorf("listener %q: the PROXY protocol is not supported for UDP", l.Name)
+	}
+
+	for _, v := range l.HTTPVersions {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("listener %q: %w", l.Name, err)
+		}
+		if v == HTTPVersion3 {
+			return fmt.Errorf("listener %q: %s is only served alongside the default HTTPS listener", l.Name, v)
+		}
+	}
+
+	return nil
+}
+
+// ErrorPagePolicyParameters holds the error pages Envoy sends in
+// place of the error responses it generates itself.
+type ErrorPagePolicyParameters struct {
+	// Pages are the error pages, in the order they are matched.
+	Pages []ErrorPageParameters `yaml:"pages,omitempty"`
+}
+
+// Validate ensures that the error pages are valid.
+func (e ErrorPagePolicyParameters) Validate() error {
+	for _, page := range e.Pages {
+		if err := page.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ErrorPageParameters holds the response that replaces the error
+// responses with the given status codes.
+type ErrorPageParameters struct {
+	// StatusCodes are the status codes of the error responses
+	// to replace.
+	StatusCodes []uint32 `yaml:"status-codes"`
+
+	// ConfigMap is the ConfigMap that holds the response body.
+	ConfigMap NamespacedName `yaml:"configmap"`
+
+	// Key is the key of the response body in the ConfigMap.
+	Key string `yaml:"key"`
+
+	// ContentType is the content type of the response body.
+	// If not specified, "text/plain" is used.
+	ContentType string `yaml:"content-type,omitempty"`
+
+	// ResponseStatusCode replaces the status code of the
+	// response. If not specified, it is not changed.
+	ResponseStatusCode uint32 `yaml:"response-status-code,omitempty"`
+}
+
+// Validate ensures that the error page is valid.
+func (e ErrorPageParameters) Validate() error {
+	if len(e.StatusCodes) == 0 {
+		return errors.New("error page must have status codes")
+	}
+
+	for _, code := range e.StatusCodes {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("invalid error page status code %d", code)
+		}
+	}
+
+	if e.ConfigMap.Name == "" || e.ConfigMap.Namespace == "" {
+		return errors.New("error page ConfigMap must have a name and namespace")
+	}
+
+	if e.Key == "" {
+		return fmt.Errorf("error page ConfigMap %s/%s must have a key", e.ConfigMap.Namespace, e.ConfigMap.Name)
+	}
+
+	if code := e.ResponseStatusCode; code != 0 && (code < 200 || code > 599) {
+		return fmt.Errorf("invalid error page response status code %d", code)
+	}
+
+	return nil
+}
+
 // NamespacedName defines the namespace/name of the Kubernetes resource referred from the configuration file.
 // Used for Contour configuration YAML file parsing, otherwise we could use K8s types.NamespacedName.
 type NamespacedName struct {
@@ -225,6 +401,72 @@ type TLSParameters struct {
 	// to be used when establishing TLS connection to upstream
 	// cluster.
 	ClientCertificate NamespacedName `yaml:"envoy-client-certificate,omitempty"`
+
+	// OCSP configures how Contour fetches OCSP responses to
+	// staple to TLS certificates.
+	OCSP OCSPParameters `yaml:"ocsp,omitempty"`
+
+	// CertificateExpiryWarning is the window before a referenced
+	// TLS certificate expires in which a warning is added to the
+	// HTTPProxy status. Defaults to 720h (30 days).
+	CertificateExpiryWarning time.Duration `yaml:"certificate-expiry-warning,omitempty"`
+}
+
+// Validate the TLS parameters.
+func (t TLSParameters) Validate() error {
+	if t.CertificateExpiryWarning < 0 {
+		return fmt.Errorf("invalid certificate expiry warning %q", t.CertificateExpiryWarning)
+	}
+
+	return t.OCSP.Validate()
+}
+
+// OCSPParameters holds the configuration for fetching OCSP
+// responses to staple to TLS certificates.
+type OCSPParameters struct {
+	// FetchStaples enables Contour to fetch OCSP responses for
+	// TLS certificates whose secret does not already contain
+	// one. The certificate chain in the secret must include the
+	// issuer of the certificate.
+	FetchStaples bool `yaml:"fetch-staples,omitempty"`
+
+	// ResponderURL overrides the OCSP responder URL named in
+	// the certificates.
+	ResponderURL string `yaml:"responder-url,omitempty"`
+
+	// RefreshInterval is how often Contour checks whether
+	// OCSP responses need to be refreshed. Defaults to 5m.
+	RefreshInterval time.Duration `yaml:"refresh-interval,omitempty"`
+}
+
+// Validate the OCSP parameters.
+func (o OCSPParameters) Validate() error {
+	if o.ResponderURL != "" {
+		u, err := url.Parse(o.ResponderURL)
+		if err != nil {
+			return fmt.Errorf("invalid OCSP responder URL %q: %w", o.ResponderURL, err)
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid OCSP responder URL %q: scheme must be http or https", o.ResponderURL)
+		}
+	}
+
+	if o.RefreshInterval < 0 {
+		return fmt.Errorf("invalid OCSP refresh interval %q", o.RefreshInterval)
+	}
+
+	return nil
+}
+
+// HTTP3Parameters holds the configuration of the HTTP/3 listener.
+type HTTP3Parameters struct {
+	// AdvertisedPort is the UDP port that clients reach the HTTP/3
+	// listener on, which is advertised in the alt-svc header of
+	// HTTPS responses. It is usually the port of the Envoy Service,
+	// not the port Envoy listens on.
+	// Defaults to 443.
+	AdvertisedPort int `yaml:"advertised-port,omitempty"`
 }
 
 // ServerParameters holds the configuration for the Contour xDS server.
@@ -254,6 +496,14 @@ type TimeoutParameters struct {
 	// for more information.
 	RequestTimeout string `yaml:"request-timeout,omitempty"`
 
+	// RequestHeadersTimeout defines how long the proxy waits for a
+	// client to send all the request headers. Omit or set to
+	// "infinity" to disable the timeout entirely.
+	//
+	// See https://www.envoyproxy.io/docs/envoy/latest/api-v3/extensions/filters/network/http_connection_manager/v3/http_connection_manager.proto#envoy-v3-api-field-extensions-filters-network-http-connection-manager-v3-httpconnectionmanager-request-headers-timeout
+	// for more information.
+	RequestHeadersTimeout string `yaml:"request-headers-timeout,omitempty"`
+
 	// ConnectionIdleTimeout defines how long the proxy should wait while there are
 	// no active requests (for HTTP/1.1) or streams (for HTTP/2) before terminating
 	// an HTTP connection. Set to "infinity" to disable the timeout entirely.
@@ -292,23 +542,16 @@ type TimeoutParameters struct {
 
 // Validate the timeout parameters.
 func (t TimeoutParameters) Validate() error {
-	// We can't use `timeout.Parse` for validation here because
-	// that would make an exported package depend on an internal
-	// package.
-	v := func(str string) error {
-		switch str {
-		case "", "infinity", "infinite":
-			return nil
-		default:
-			_, err := time.ParseDuration(str)
-			return err
-		}
-	}
+	v := validateTimeout
 
 	if err := v(t.RequestTimeout); err != nil {
 		return fmt.Errorf("invalid request timeout %q: %w", t.RequestTimeout, err)
 	}
 
+	if err := v(t.RequestHeadersTimeout); err != nil {
+		return fmt.Errorf("invalid request headers timeout %q: %w", t.RequestHeadersTimeout, err)
+	}
+
 	if err := v(t.ConnectionIdleTimeout); err != nil {
 		return fmt.Errorf("connection idle timeout %q: %w", t.RequestTimeout, err)
 	}
@@ -328,6 +571,20 @@ func (t TimeoutParameters) Validate() error {
 	return nil
 }
 
+// validateTimeout checks that str is a timeout that the
+// internal timeout package can parse. We can't use
+// `timeout.Parse` for validation here because that would make
+// an exported package depend on an internal package.
+func validateTimeout(str string) error {
+	switch str {
+	case "", "infinity", "infinite":
+		return nil
+	default:
+		_, err := time.ParseDuration(str)
+		return err
+	}
+}
+
 // ClusterParameters holds various configurable cluster values.
 type ClusterParameters struct {
 	// DNSLookupFamily defines how external names are looked up
@@ -342,6 +599,51 @@ type ClusterParameters struct {
 	// See https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/cluster/v3/cluster.proto.html#envoy-v3-api-enum-config-cluster-v3-cluster-dnslookupfamily
 	// for more information.
 	DNSLookupFamily ClusterDNSFamilyType `yaml:"dns-lookup-family"`
+
+	// IdleTimeout defines how long an upstream connection may be idle,
+	// with no active requests, before Envoy closes it. This should be
+	// lower than the keepalive timeout of the upstream services.
+	// Omit to use the Envoy default of 1h, or set to "infinity" to
+	// disable the timeout entirely.
+	//
+	// See https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/core/v3/protocol.proto#envoy-v3-api-field-config-core-v3-httpprotocoloptions-idle-timeout
+	// for more information.
+	IdleTimeout string `yaml:"idle-timeout,omitempty"`
+
+	// MaxRequestsPerConnection defines the maximum number of requests
+	// Envoy sends on an upstream connection before closing it. Omit
+	// or set to 0 for no limit.
+	MaxRequestsPerConnection uint32 `yaml:"max-requests-per-connection,omitempty"`
+
+	// MaxStreamDuration defines the maximum time that a request to an
+	// upstream service may last before Envoy resets it. Omit or set to
+	// "infinity" for no max duration.
+	//
+	// See https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/core/v3/protocol.proto#envoy-v3-api-field-config-core-v3-httpprotocoloptions-max-stream-duration
+	// for more information.
+	MaxStreamDuration string `yaml:"max-stream-duration,omitempty"`
+
+	// PerConnectionBufferLimitBytes defines the soft limit on the size
+	// of the read and write buffers of each upstream connection. Omit
+	// or set to 0 to use the Envoy default of 1MiB.
+	PerConnectionBufferLimitBytes uint32 `yaml:"per-connection-buffer-limit-bytes,omitempty"`
+}
+
+// Validate the cluster parameters.
+func (c ClusterParameters) Validate() error {
+	if err := c.DNSLookupFamily.Validate(); err != nil {
+		return err
+	}
+
+	if err := validateTimeout(c.IdleTimeout); err != nil {
+		return fmt.Errorf("invalid cluster idle timeout %q: %w", c.IdleTimeout, err)
+	}
+
+	if err := validateTimeout(c.MaxStreamDuration); err != nil {
+		return fmt.Errorf("invalid cluster max stream duration %q: %w", c.MaxStreamDuration, err)
+	}
+
+	return nil
 }
 
 // Parameters contains the configuration file parameters for the
@@ -404,19 +706,32 @@ type Parameters struct {
 	// DefaultHTTPVersions defines the default set of HTTPS
 	// versions the proxy should accept. HTTP versions are
 	// strings of the form "HTTP/xx". Supported versions are
-	// "HTTP/1.1" and "HTTP/2".
+	// "HTTP/1.1", "HTTP/2" and "HTTP/3".
 	//
-	// If this field not specified, all supported versions are accepted.
+	// If this field not specified, HTTP/1.1 and HTTP/2 are accepted.
+	// HTTP/3 is only accepted if it is specified.
 	DefaultHTTPVersions []HTTPVersionType `yaml:"default-http-versions"`
 
+	// HTTP3 holds the configuration of the HTTP/3 listener, which is
+	// enabled by adding "HTTP/3" to DefaultHTTPVersions.
+	HTTP3 HTTP3Parameters `yaml:"http3,omitempty"`
+
 	// Cluster holds various configurable Envoy cluster values that can
 	// be set in the config file.
 	Cluster ClusterParameters `yaml:"cluster,omitempty"`
+
+	// Listeners are Envoy listeners in addition to the default
+	// HTTP and HTTPS listeners.
+	Listeners []ListenerParameters `yaml:"listeners,omitempty"`
+
+	// ErrorPagePolicy holds the error pages Envoy sends in place
+	// of the error responses it generates for all virtual hosts.
+	ErrorPagePolicy ErrorPagePolicyParameters `yaml:"error-page-policy,omitempty"`
 }
 
 // Validate verifies that the parameter values do not have any syntax errors.
 func (p *Parameters) Validate() error {
-	if err := p.Cluster.DNSLookupFamily.Validate(); err != nil {
+	if err := p.Cluster.Validate(); err != nil {
 		return err
 	}
 
@@ -441,6 +756,10 @@ func (p *Parameters) Validate() error {
 		return fmt.Errorf("invalid TLS client certificate: %w", err)
 	}
 
+	if err := p.TLS.Validate(); err != nil {
+		return err
+	}
+
 	if err := p.Timeouts.Validate(); err != nil {
 		return err
 	}
@@ -451,6 +770,29 @@ func (p *Parameters) Validate() error {
 		}
 	}
 
+	if len(p.DefaultHTTPVersions) == 1 && p.DefaultHTTPVersions[0] == HTTPVersion3 {
+		return fmt.Errorf("default HTTP versions must include %s or %s with %s", HTTPVersion1, HTTPVersion2, HTTPVersion3)
+	}
+
+	if port := p.HTTP3.AdvertisedPort; port < 0 || port > 65535 {
+		return fmt.Errorf("invalid HTTP/3 advertised port %d", port)
+	}
+
+	names := map[string]bool{}
+	for _, l := range p.Listeners {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+		if names[l.Name] {
+			return fmt.Errorf("duplicate listener name %q", l.Name)
+		}
+		names[l.Name] = true
+	}
+
+	if err := p.ErrorPagePolicy.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
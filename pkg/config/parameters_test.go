@@ -122,6 +122,39 @@ func TestValidateNamespacedName(t *testing.T) {
 	assert.Error(t, NamespacedName{Namespace: "ns"}.Validate())
 }
 
+func TestValidateOCSPParameters(t *testing.T) {
+	assert.NoError(t, OCSPParameters{}.Validate())
+	assert.NoError(t, OCSPParameters{FetchStaples: true, ResponderURL: "http://ocsp.example.com"}.Validate())
+	assert.NoError(t, OCSPParameters{RefreshInterval: time.Minute}.Validate())
+
+	assert.Error(t, OCSPParameters{ResponderURL: "ocsp.example.com"}.Validate())
+	assert.Error(t, OCSPParameters{ResponderURL: "ftp://ocsp.example.com"}.Validate())
+	assert.Error(t, OCSPParameters{RefreshInterval: -time.Minute}.Validate())
+}
+
+func TestValidateTLSParameters(t *testing.T) {
+	assert.NoError(t, TLSParameters{}.Validate())
+	assert.NoError(t, TLSParameters{CertificateExpiryWarning: 24 * time.Hour}.Validate())
+
+	assert.Error(t, TLSParameters{CertificateExpiryWarning: -time.Hour}.Validate())
+	assert.Error(t, TLSParameters{OCSP: OCSPParameters{RefreshInterval: -time.Minute}}.Validate())
+}
+
+func TestValidateListenerParameters(t *testing.T) {
+	assert.NoError(t, ListenerParameters{Name: "internal", Port: 8081, Protocol: HTTPListenerProtocol}.Validate())
+	assert.NoError(t, ListenerParameters{Name: "proxy", Port: 8444, Protocol: HTTPSListenerProtocol, UseProxyProtocol: true, HTTPVersions: []HTTPVersionType{HTTPVersion1}}.Validate())
+	assert.NoError(t, ListenerParameters{Name: "postgres", Port: 5432, Protocol: TCPListenerProtocol}.Validate())
+	assert.NoError(t, ListenerParameters{Name: "dns", Port: 53, Protocol: UDPListenerProtocol}.Validate())
+
+	assert.Error(t, ListenerParameters{Port: 8081, Protocol: HTTPListenerProtocol}.Validate())
+	assert.Error(t, ListenerParameters{Name: "ingress_http", Port: 8081, Protocol: HTTPListenerProtocol}.Validate())
+	assert.Error(t, ListenerParameters{Name: "https/internal", Port: 8081, Protocol: HTTPListenerProtocol}.Validate())
+	assert.Error(t, ListenerParameters{Name: "internal", Protocol: HTTPListenerProtocol}.Validate())
+	assert.Error(t, ListenerParameters{Name: "internal", Port: 8081, Protocol: "sctp"}.Validate())
+	assert.Error(t, ListenerParameters{Name: "dns", Port: 53, Protocol: UDPListenerProtocol, UseProxyProtocol: true}.Validate())
+	assert.Error(t, ListenerParameters{Name: "internal", Port: 8081, Protocol: HTTPListenerProtocol, HTTPVersions: []HTTPVersionType{"http/0.9"}}.Validate())
+}
+
 func TestValidateServerType(t *testing.T) {
 	assert.Error(t, ServerType("").Validate())
 	assert.Error(t, ServerType("foo").Validate())
@@ -188,6 +221,7 @@ func TestValidateHTTPVersionType(t *testing.T) {
 
 	assert.NoError(t, HTTPVersion1.Validate())
 	assert.NoError(t, HTTPVersion2.Validate())
+	assert.NoError(t, HTTPVersion3.Validate())
 }
 
 func TestValidateTimeoutParams(t *testing.T) {
@@ -208,6 +242,7 @@ func TestValidateTimeoutParams(t *testing.T) {
 	}.Validate())
 
 	assert.Error(t, TimeoutParameters{RequestTimeout: "foo"}.Validate())
+	assert.Error(t, TimeoutParameters{RequestHeadersTimeout: "qux"}.Validate())
 	assert.Error(t, TimeoutParameters{ConnectionIdleTimeout: "bar"}.Validate())
 	assert.Error(t, TimeoutParameters{StreamIdleTimeout: "baz"}.Validate())
 	assert.Error(t, TimeoutParameters{MaxConnectionDuration: "boop"}.Validate())
@@ -215,6 +250,49 @@ func TestValidateTimeoutParams(t *testing.T) {
 
 }
 
+func TestValidateClusterParams(t *testing.T) {
+	assert.NoError(t, ClusterParameters{DNSLookupFamily: AutoClusterDNSFamily}.Validate())
+	assert.NoError(t, ClusterParameters{
+		DNSLookupFamily:               IPv4ClusterDNSFamily,
+		IdleTimeout:                   "4s",
+		MaxRequestsPerConnection:      100,
+		MaxStreamDuration:             "infinity",
+		PerConnectionBufferLimitBytes: 32768,
+	}.Validate())
+
+	assert.Error(t, ClusterParameters{DNSLookupFamily: "stone"}.Validate())
+	assert.Error(t, ClusterParameters{DNSLookupFamily: AutoClusterDNSFamily, IdleTimeout: "foo"}.Validate())
+	assert.Error(t, ClusterParameters{DNSLookupFamily: AutoClusterDNSFamily, MaxStreamDuration: "bar"}.Validate())
+}
+
+func TestValidateErrorPageParams(t *testing.T) {
+	page := func(mutate func(*ErrorPageParameters)) ErrorPageParameters {
+		p := ErrorPageParameters{
+			StatusCodes: []uint32{502, 503},
+			ConfigMap:   NamespacedName{Name: "error-pages", Namespace: "projectcontour"},
+			Key:         "unavailable.html",
+		}
+		mutate(&p)
+		return p
+	}
+
+	assert.NoError(t, ErrorPagePolicyParameters{}.Validate())
+	assert.NoError(t, page(func(*ErrorPageParameters) {}).Validate())
+	assert.NoError(t, page(func(p *ErrorPageParameters) {
+		p.ContentType = "text/html"
+		p.ResponseStatusCode = 200
+	}).Validate())
+
+	assert.Error(t, page(func(p *ErrorPageParameters) { p.StatusCodes = nil }).Validate())
+	assert.Error(t, page(func(p *ErrorPageParameters) { p.StatusCodes = []uint32{302} }).Validate())
+	assert.Error(t, page(func(p *ErrorPageParameters) { p.ConfigMap.Namespace = "" }).Validate())
+	assert.Error(t, page(func(p *ErrorPageParameters) { p.Key = "" }).Validate())
+	assert.Error(t, page(func(p *ErrorPageParameters) { p.ResponseStatusCode = 100 }).Validate())
+	assert.Error(t, ErrorPagePolicyParameters{
+		Pages: []ErrorPageParameters{page(func(p *ErrorPageParameters) { p.Key = "" })},
+	}.Validate())
+}
+
 func TestConfigFileValidation(t *testing.T) {
 	check := func(yamlIn string) {
 		t.Helper()
@@ -229,6 +307,11 @@ cluster:
   dns-lookup-family: stone
 `)
 
+	check(`
+cluster:
+  idle-timeout: 5 seconds
+`)
+
 	check(`
 server:
   xds-server-type: magic
@@ -265,6 +348,45 @@ default-http-versions:
 - http/0.9
 `)
 
+	check(`
+default-http-versions:
+- HTTP/3
+`)
+
+	check(`
+http3:
+  advertised-port: 70000
+`)
+
+	check(`
+listeners:
+- name: internal
+  port: 8444
+  protocol: https
+  http-versions:
+  - http/3
+`)
+
+	check(`
+listeners:
+- name: internal
+  port: 8081
+  protocol: http
+- name: internal
+  port: 8082
+  protocol: http
+`)
+
+	check(`
+error-page-policy:
+  pages:
+  - status-codes: [200]
+    configmap:
+      name: error-pages
+      namespace: projectcontour
+    key: ok.html
+`)
+
 }
 
 func TestConfigFileDefaultOverrideImport(t *testing.T) {